@@ -0,0 +1,28 @@
+package lambda
+
+import (
+	"strconv"
+	"sync/atomic"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ColdStartHeader reports whether the invocation was the first one served by this container.
+const ColdStartHeader = "X-Cold-Start"
+
+// warm is set once the container has served its first invocation.
+var warm atomic.Bool
+
+// isColdStart reports whether this is the container's first invocation. It must be
+// called exactly once per invocation.
+func isColdStart() bool {
+	return !warm.Swap(true)
+}
+
+// addColdStartHeader sets X-Cold-Start on response.
+func addColdStartHeader(response *events.APIGatewayProxyResponse, coldStart bool) {
+	if response.Headers == nil {
+		response.Headers = make(map[string]string)
+	}
+	response.Headers[ColdStartHeader] = strconv.FormatBool(coldStart)
+}
@@ -0,0 +1,40 @@
+package lambda
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestDiagnosticHeadersColdStart(t *testing.T) {
+	warm.Store(false)
+	t.Cleanup(func() { warm.Store(false) })
+
+	handler := diagnosticHeadersMiddleware(
+		func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+		},
+	)
+
+	tests := []struct {
+		name string
+		want string
+	}{
+		{name: "first invocation", want: "true"},
+		{name: "second invocation", want: "false"},
+		{name: "later invocation", want: "false"},
+	}
+
+	// The invocations run in order against one container
+	for _, tt := range tests {
+		response, err := handler(context.Background(), events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet})
+		if err != nil {
+			t.Fatalf("%s: handler error = %v", tt.name, err)
+		}
+		if got := response.Headers[ColdStartHeader]; got != tt.want {
+			t.Errorf("%s: %s = %q, want %q", tt.name, ColdStartHeader, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package lambda
+
+import (
+	"context"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/utils"
+)
+
+// corsAllowedHeaders are the request headers cross-origin callers may send.
+const corsAllowedHeaders = "Content-Type,Authorization,X-Amz-Date,X-Api-Key,X-Amz-Security-Token,Idempotency-Key"
+
+// corsMiddleware answers OPTIONS pre-flight requests and adds the CORS and default headers
+// to every response, keeping any the handler set itself.
+func corsMiddleware(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		commonHeaders := map[string]string{
+			"Content-Type":                     "application/json",
+			"Access-Control-Allow-Methods":     "GET,POST,PUT,PATCH,DELETE,OPTIONS",
+			"Access-Control-Allow-Headers":     corsAllowedHeaders,
+			"Access-Control-Allow-Credentials": "true",
+		}
+		addAllowOrigin(request, commonHeaders)
+
+		if request.HTTPMethod == http.MethodOptions {
+			return events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Headers: commonHeaders}, nil
+		}
+
+		response, err := next(ctx, request)
+		if response.Headers == nil {
+			response.Headers = make(map[string]string)
+		}
+		for k, v := range commonHeaders {
+			if k == "Vary" {
+				utils.AddVary(&response, v)
+			} else if _, ok := response.Headers[k]; !ok {
+				response.Headers[k] = v
+			}
+		}
+
+		return response, err
+	}
+}
+
+// addAllowOrigin sets Access-Control-Allow-Origin in headers when the request's origin is
+// allowed. With an origin allow-list the response varies by Origin, so Vary is set too.
+func addAllowOrigin(request events.APIGatewayProxyRequest, headers map[string]string) {
+	if origin, ok := allowedOrigin(request); ok {
+		headers["Access-Control-Allow-Origin"] = origin
+	}
+	if os.Getenv("CORS_ALLOWED_ORIGINS") != "" {
+		headers["Vary"] = "Origin"
+	}
+}
+
+// allowedOrigin returns the Access-Control-Allow-Origin value for request. With
+// CORS_ALLOWED_ORIGINS unset or containing "*", every origin is allowed with "*". Otherwise
+// the request's Origin is echoed back when it is in the comma-separated list, and ok is
+// false when it is not, so the header is omitted.
+func allowedOrigin(request events.APIGatewayProxyRequest) (origin string, ok bool) {
+	allowed := strings.TrimSpace(os.Getenv("CORS_ALLOWED_ORIGINS"))
+	if allowed == "" {
+		return "*", true
+	}
+
+	requestOrigin := utils.GetHeader(request, "Origin")
+	for _, candidate := range strings.Split(allowed, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return "*", true
+		}
+		if requestOrigin != "" && strings.EqualFold(candidate, requestOrigin) {
+			return requestOrigin, true
+		}
+	}
+
+	return "", false
+}
@@ -0,0 +1,68 @@
+package lambda
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestCORSMiddlewareAllowedOrigins(t *testing.T) {
+	tests := []struct {
+		name        string
+		allowed     string
+		method      string
+		origin      string
+		wantOrigin  string
+		wantVary    string
+		wantHandled bool
+	}{
+		{name: "no allow-list", method: http.MethodGet, origin: "https://app.example.com", wantOrigin: "*",
+			wantHandled: true},
+		{name: "allowed origin", allowed: "https://app.example.com, https://admin.example.com", method: http.MethodGet,
+			origin: "https://admin.example.com", wantOrigin: "https://admin.example.com", wantVary: "Origin",
+			wantHandled: true},
+		{name: "disallowed origin", allowed: "https://app.example.com", method: http.MethodGet,
+			origin: "https://evil.example.com", wantVary: "Origin", wantHandled: true},
+		{name: "no Origin header", allowed: "https://app.example.com", method: http.MethodGet, wantVary: "Origin",
+			wantHandled: true},
+		{name: "wildcard", allowed: "*", method: http.MethodGet, origin: "https://any.example.com", wantOrigin: "*",
+			wantVary: "Origin", wantHandled: true},
+		{name: "pre-flight from an allowed origin", allowed: "https://app.example.com", method: http.MethodOptions,
+			origin: "https://app.example.com", wantOrigin: "https://app.example.com", wantVary: "Origin"},
+		{name: "pre-flight from a disallowed origin", allowed: "https://app.example.com", method: http.MethodOptions,
+			origin: "https://evil.example.com", wantVary: "Origin"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CORS_ALLOWED_ORIGINS", tt.allowed)
+
+			handled := false
+			next := func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				handled = true
+				return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+			}
+			request := events.APIGatewayProxyRequest{HTTPMethod: tt.method, Path: "/users"}
+			if tt.origin != "" {
+				request.Headers = map[string]string{"Origin": tt.origin}
+			}
+
+			response, err := corsMiddleware(next)(context.Background(), request)
+			if err != nil {
+				t.Fatalf("corsMiddleware() error = %v", err)
+			}
+			if handled != tt.wantHandled {
+				t.Errorf("handler called = %t, want %t", handled, tt.wantHandled)
+			}
+			origin, ok := response.Headers["Access-Control-Allow-Origin"]
+			if origin != tt.wantOrigin || ok != (tt.wantOrigin != "") {
+				t.Errorf("Access-Control-Allow-Origin = %q (set %t), want %q", origin, ok, tt.wantOrigin)
+			}
+			if got := response.Headers["Vary"]; got != tt.wantVary {
+				t.Errorf("Vary = %q, want %q", got, tt.wantVary)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package lambda
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// Deprecation describes a deprecated route and what clients should use instead.
+type Deprecation struct {
+	// Successor is the replacement path, e.g. "/v2/users".
+	Successor string
+	// Sunset is when the route will be removed; zero if no date is scheduled.
+	Sunset time.Time
+}
+
+// deprecatedRoutes marks routes as deprecated, keyed by "METHOD pattern" as matched by
+// Router, e.g. "GET /users": {Successor: "/v2/users"} or "GET /users/{id}".
+var deprecatedRoutes = map[string]Deprecation{}
+
+// addDeprecationHeaders sets Warning, Deprecation, Sunset and Link headers on responses
+// from deprecated routes, so clients are warned without the route breaking.
+func addDeprecationHeaders(request events.APIGatewayProxyRequest, response *events.APIGatewayProxyResponse) {
+	deprecation, ok := deprecatedRoutes[request.HTTPMethod+" "+routeResource(request)]
+	if !ok {
+		return
+	}
+
+	if response.Headers == nil {
+		response.Headers = make(map[string]string)
+	}
+
+	response.Headers["Deprecation"] = "true"
+	response.Headers["Warning"] = `299 - "Deprecated"`
+	if deprecation.Successor != "" {
+		response.Headers["Warning"] = fmt.Sprintf(`299 - "Deprecated, use %s"`, deprecation.Successor)
+		response.Headers["Link"] = fmt.Sprintf(`<%s>; rel="successor-version"`, deprecation.Successor)
+	}
+	if !deprecation.Sunset.IsZero() {
+		response.Headers["Sunset"] = deprecation.Sunset.UTC().Format(http.TimeFormat)
+	}
+}
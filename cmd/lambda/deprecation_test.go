@@ -0,0 +1,83 @@
+package lambda
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestDeprecationMiddleware(t *testing.T) {
+	sunset := time.Date(2027, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name        string
+		deprecated  map[string]Deprecation
+		request     events.APIGatewayProxyRequest
+		wantHeaders map[string]string
+	}{
+		{
+			name:       "deprecated route with successor and sunset",
+			deprecated: map[string]Deprecation{"GET /users": {Successor: "/v2/users", Sunset: sunset}},
+			request:    events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, Resource: "/users", Path: "/users"},
+			wantHeaders: map[string]string{
+				"Deprecation": "true",
+				"Warning":     `299 - "Deprecated, use /v2/users"`,
+				"Link":        `</v2/users>; rel="successor-version"`,
+				"Sunset":      "Fri, 01 Jan 2027 00:00:00 GMT",
+			},
+		},
+		{
+			name:       "deprecated route without successor",
+			deprecated: map[string]Deprecation{"GET /users/{id}": {}},
+			request:    events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, Resource: "/users/{id}", Path: "/users/1"},
+			wantHeaders: map[string]string{
+				"Deprecation": "true",
+				"Warning":     `299 - "Deprecated"`,
+				"Link":        "",
+				"Sunset":      "",
+			},
+		},
+		{
+			name:       "matched by path without a resource",
+			deprecated: map[string]Deprecation{"GET /users": {Successor: "/v2/users"}},
+			request:    events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, Path: "/users"},
+			wantHeaders: map[string]string{
+				"Deprecation": "true",
+				"Warning":     `299 - "Deprecated, use /v2/users"`,
+			},
+		},
+		{
+			name:        "other method on a deprecated path",
+			deprecated:  map[string]Deprecation{"GET /users": {Successor: "/v2/users"}},
+			request:     events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost, Resource: "/users", Path: "/users"},
+			wantHeaders: map[string]string{"Deprecation": "", "Warning": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			saved := deprecatedRoutes
+			deprecatedRoutes = tt.deprecated
+			t.Cleanup(func() { deprecatedRoutes = saved })
+
+			handler := deprecationMiddleware(
+				func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+					return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+				},
+			)
+			response, err := handler(context.Background(), tt.request)
+			if err != nil {
+				t.Fatalf("handler error = %v", err)
+			}
+
+			for name, want := range tt.wantHeaders {
+				if got := response.Headers[name]; got != want {
+					t.Errorf("%s = %q, want %q", name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package lambda
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/handlers"
+	"go-lambda-api/models"
+)
+
+func TestRouterIdempotentMutations(t *testing.T) {
+	userPath := func(method, body, key string) events.APIGatewayProxyRequest {
+		return events.APIGatewayProxyRequest{
+			HTTPMethod:     method,
+			Resource:       "/users/{id}",
+			Path:           "/users/user-1",
+			PathParameters: map[string]string{"id": "user-1"},
+			Headers:        map[string]string{"Content-Type": "application/json", handlers.IdempotencyKeyHeader: key},
+			Body:           body,
+		}
+	}
+
+	tests := []struct {
+		name        string
+		first       events.APIGatewayProxyRequest
+		retry       events.APIGatewayProxyRequest
+		wantStatus  int
+		wantReplay  bool
+		wantVersion int
+		wantDeleted bool
+	}{
+		{
+			name:        "PUT replayed",
+			first:       userPath(http.MethodPut, `{"name":"Ann Updated","email":"ann@example.com"}`, "k1"),
+			retry:       userPath(http.MethodPut, `{"name":"Ann Updated","email":"ann@example.com"}`, "k1"),
+			wantStatus:  http.StatusOK,
+			wantReplay:  true,
+			wantVersion: 2,
+		},
+		{
+			name:        "PATCH replayed",
+			first:       userPath(http.MethodPatch, `{"name":"Ann Updated"}`, "k1"),
+			retry:       userPath(http.MethodPatch, `{"name":"Ann Updated"}`, "k1"),
+			wantStatus:  http.StatusOK,
+			wantReplay:  true,
+			wantVersion: 2,
+		},
+		{
+			name:        "DELETE replayed",
+			first:       userPath(http.MethodDelete, "", "k1"),
+			retry:       userPath(http.MethodDelete, "", "k1"),
+			wantStatus:  http.StatusNoContent,
+			wantReplay:  true,
+			wantDeleted: true,
+		},
+		{
+			name:        "PATCH with another key applies again",
+			first:       userPath(http.MethodPatch, `{"name":"Ann Updated"}`, "k1"),
+			retry:       userPath(http.MethodPatch, `{"name":"Ann Updated"}`, "k2"),
+			wantStatus:  http.StatusOK,
+			wantVersion: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handlers.SetIdempotencyStore(handlers.NewMemoryIdempotencyStore())
+			models.ClearInMemoryUsers()
+			t.Cleanup(models.ClearInMemoryUsers)
+
+			ctx := context.Background()
+			userRepo := models.NewInMemoryUserRepository()
+			if _, err := userRepo.CreateUser(ctx, models.User{ID: "user-1", Name: "Ann", Email: "ann@example.com"}); err != nil {
+				t.Fatalf("CreateUser() error = %v", err)
+			}
+
+			var responses []events.APIGatewayProxyResponse
+			for _, request := range []events.APIGatewayProxyRequest{tt.first, tt.retry} {
+				response, err := Router(ctx, request, userRepo, handlers.NewHealthHandler())
+				if err != nil {
+					t.Fatalf("Router() error = %v", err)
+				}
+				if response.StatusCode != tt.wantStatus {
+					t.Fatalf("status = %d, want %d; body %s", response.StatusCode, tt.wantStatus, response.Body)
+				}
+				responses = append(responses, response)
+			}
+
+			replayed := responses[1].Headers[handlers.IdempotentReplayedHeader] == "true"
+			if replayed != tt.wantReplay {
+				t.Errorf("retry replayed = %t, want %t", replayed, tt.wantReplay)
+			}
+			if tt.wantReplay && responses[1].Body != responses[0].Body {
+				t.Errorf("replayed body = %s, want %s", responses[1].Body, responses[0].Body)
+			}
+
+			stored, err := userRepo.GetUserByID(ctx, "user-1")
+			if tt.wantDeleted {
+				if !errors.Is(err, models.ErrUserNotFound) {
+					t.Errorf("GetUserByID() error = %v, want %v", err, models.ErrUserNotFound)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("GetUserByID() error = %v", err)
+			}
+			if stored.Version != tt.wantVersion {
+				t.Errorf("version = %d, want %d", stored.Version, tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestRouterIdempotencyKeyReuse(t *testing.T) {
+	const body = `{"id":"user-1","name":"Ann","email":"ann@example.com"}`
+	createUser := func(body string, base64Encoded bool) events.APIGatewayProxyRequest {
+		return events.APIGatewayProxyRequest{
+			HTTPMethod:      http.MethodPost,
+			Resource:        "/users",
+			Path:            "/users",
+			Headers:         map[string]string{"Content-Type": "application/json", handlers.IdempotencyKeyHeader: "k1"},
+			Body:            body,
+			IsBase64Encoded: base64Encoded,
+		}
+	}
+
+	tests := []struct {
+		name       string
+		retry      events.APIGatewayProxyRequest
+		wantStatus int
+		wantReplay bool
+		wantError  string
+	}{
+		{name: "same payload replays", retry: createUser(body, false), wantStatus: http.StatusCreated, wantReplay: true},
+		{
+			name:       "same payload base64-encoded replays",
+			retry:      createUser(base64.StdEncoding.EncodeToString([]byte(body)), true),
+			wantStatus: http.StatusCreated,
+			wantReplay: true,
+		},
+		{
+			name:       "different payload is rejected",
+			retry:      createUser(`{"id":"user-2","name":"Bob","email":"bob@example.com"}`, false),
+			wantStatus: http.StatusUnprocessableEntity,
+			wantError:  "idempotency key reused with different payload",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handlers.SetIdempotencyStore(handlers.NewMemoryIdempotencyStore())
+			models.ClearInMemoryUsers()
+			t.Cleanup(models.ClearInMemoryUsers)
+
+			ctx := context.Background()
+			userRepo := models.NewInMemoryUserRepository()
+			first, err := Router(ctx, createUser(body, false), userRepo, handlers.NewHealthHandler())
+			if err != nil || first.StatusCode != http.StatusCreated {
+				t.Fatalf("first Router() = %d, %v; body %s", first.StatusCode, err, first.Body)
+			}
+
+			response, err := Router(ctx, tt.retry, userRepo, handlers.NewHealthHandler())
+			if err != nil {
+				t.Fatalf("Router() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", response.StatusCode, tt.wantStatus, response.Body)
+			}
+
+			replayed := response.Headers[handlers.IdempotentReplayedHeader] == "true"
+			if replayed != tt.wantReplay {
+				t.Errorf("replayed = %t, want %t", replayed, tt.wantReplay)
+			}
+			if tt.wantReplay && response.Body != first.Body {
+				t.Errorf("replayed body = %s, want %s", response.Body, first.Body)
+			}
+			if tt.wantError != "" {
+				var payload map[string]string
+				if err := json.Unmarshal([]byte(response.Body), &payload); err != nil || payload["error"] != tt.wantError {
+					t.Errorf("body = %s, want error %q", response.Body, tt.wantError)
+				}
+			}
+
+			users, _, err := userRepo.GetAllUsers(ctx, models.ListQuery{})
+			if err != nil || len(users) != 1 {
+				t.Errorf("GetAllUsers() = %d users, %v; want only the first", len(users), err)
+			}
+		})
+	}
+}
@@ -3,15 +3,15 @@ package lambda
 import (
 	"context"
 	"errors"
-	"log"
 	"net/http"
+	"sort"
+	"strings"
 
 	"go-lambda-api/handlers"
 	"go-lambda-api/models"
 	"go-lambda-api/utils"
 
 	"github.com/aws/aws-lambda-go/events"
-	"github.com/aws/aws-lambda-go/lambda"
 )
 
 const (
@@ -19,6 +19,38 @@ const (
 	RootPath    = "/"
 	HealthPath  = "/health"
 	UsersPath   = "/users"
+
+	HealthLivePath  = "/health/live"
+	HealthReadyPath = "/health/ready"
+
+	UsersAvatarPath = "/users/{id}/avatar"
+	UsersStatsPath  = "/users/stats"
+	UsersBatchPath  = "/users/batch"
+
+	AdminImportPath = "/admin/import"
+	AdminResetPath  = "/admin/reset"
+	AdminExportPath = "/admin/export"
+)
+
+// routerMiddleware wraps every route, outermost first.
+var routerMiddleware = Chain(
+	requestIDMiddleware,
+	loggingMiddleware,
+	responseSizeMiddleware,
+	requestScopeMiddleware,
+	compressionMiddleware,
+	formatMiddleware,
+	diagnosticHeadersMiddleware,
+	corsMiddleware,
+	deprecationMiddleware,
+	errorFormatMiddleware,
+	rateLimitMiddleware,
+	requestChecksMiddleware,
+	chaosMiddleware,
+	idempotencyMiddleware,
+	recoveryMiddleware,
+	responseSchemaMiddleware,
+	errorResponseMiddleware,
 )
 
 // Router handles routing of API Gateway requests to appropriate handlers.
@@ -28,93 +60,117 @@ func Router(
 	userRepo models.UserRepository,
 	healthHandler *handlers.HealthHandler,
 ) (events.APIGatewayProxyResponse, error) {
-	var response events.APIGatewayProxyResponse
-	var err error
-
-	// Initialize common headers, including CORS
-	commonHeaders := map[string]string{
-		"Content-Type":                     "application/json",
-		"Access-Control-Allow-Origin":      "*", // Allow all origins for simplicity
-		"Access-Control-Allow-Methods":     "GET,POST,PUT,DELETE,OPTIONS",
-		"Access-Control-Allow-Headers":     "Content-Type,Authorization,X-Amz-Date,X-Api-Key,X-Amz-Security-Token",
-		"Access-Control-Allow-Credentials": "true",
-	}
+	return routerMiddleware(routes(userRepo, healthHandler))(ctx, request)
+}
+
+// WithMiddleware wraps handler in the middleware every Lambda route runs behind, so other
+// entry points, such as the local server, serve requests the same way.
+func WithMiddleware(handler HandlerFunc) HandlerFunc {
+	return routerMiddleware(handler)
+}
 
-	// Handle OPTIONS pre-flight requests
-	if request.HTTPMethod == http.MethodOptions {
-		return events.APIGatewayProxyResponse{
-			StatusCode: http.StatusOK,
-			Headers:    commonHeaders,
-		}, nil
+// repoHandler is a route handler that needs the user repository, like handleGetUser.
+type repoHandler func(
+	context.Context, events.APIGatewayProxyRequest, models.UserRepository,
+) (events.APIGatewayProxyResponse, error)
+
+// routeTable maps each path to the handlers for its methods.
+func routeTable(
+	userRepo models.UserRepository, healthHandler *handlers.HealthHandler,
+) map[string]map[string]HandlerFunc {
+	withRepo := func(handle repoHandler) HandlerFunc {
+		return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+			return handle(ctx, request, userRepo)
+		}
 	}
 
-	// Set initial response headers (will be merged later if APIResponse is used)
-	response.Headers = commonHeaders
-
-	switch {
-	case request.Path == RootPath && request.HTTPMethod == http.MethodGet:
-		response, err = handleRootGet(request)
-	case request.Path == HealthPath && request.HTTPMethod == http.MethodGet:
-		response, err = healthHandler.GetHealthHandler(ctx, request)
-	case request.Path == UsersPath && request.HTTPMethod == http.MethodPost:
-		response, err = handleCreateUser(ctx, request, userRepo)
-	case request.Path == UsersIDPath && request.HTTPMethod == http.MethodGet:
-		response, err = handleGetUser(ctx, request, userRepo)
-	case request.Path == UsersPath && request.HTTPMethod == http.MethodGet:
-		response, err = handleGetAllUsers(ctx, request, userRepo)
-	case request.Path == UsersIDPath && request.HTTPMethod == http.MethodPut:
-		response, err = handleUpdateUser(ctx, request, userRepo)
-	case request.Path == UsersIDPath && request.HTTPMethod == http.MethodDelete:
-		response, err = handleDeleteUser(ctx, request, userRepo)
-	default:
-		response, err = utils.ErrorResponse(http.StatusNotFound, errors.New("not found"))
+	return map[string]map[string]HandlerFunc{
+		RootPath:        {http.MethodGet: handleRootGet},
+		HealthPath:      {http.MethodGet: healthHandler.GetHealthHandler},
+		HealthLivePath:  {http.MethodGet: healthHandler.GetLivenessHandler},
+		HealthReadyPath: {http.MethodGet: healthHandler.GetReadinessHandler},
+		UsersPath: {
+			http.MethodGet:    withRepo(handleGetAllUsers),
+			http.MethodPost:   withRepo(handleCreateUser),
+			http.MethodDelete: withRepo(handleDeleteAllUsers),
+		},
+		UsersStatsPath: {http.MethodGet: withRepo(handleUserStats)},
+		UsersBatchPath: {http.MethodPost: withRepo(handleBatchCreateUsers)},
+		UsersIDPath: {
+			http.MethodGet:    withRepo(handleGetUser),
+			http.MethodPut:    withRepo(handleUpdateUser),
+			http.MethodPatch:  withRepo(handlePatchUser),
+			http.MethodDelete: withRepo(handleDeleteUser),
+		},
+		UsersAvatarPath: {http.MethodPut: withRepo(handleUploadAvatar)},
+		AdminImportPath: {http.MethodPost: withRepo(handleImportUsers)},
+		AdminResetPath:  {http.MethodPost: withRepo(handleResetUsers)},
+		AdminExportPath: {http.MethodGet: withRepo(handleExportUsers)},
+		RoutesPath:      {http.MethodGet: ListRoutesHandler},
 	}
+}
 
-	if err != nil {
-		log.Printf("Error processing request: %v", err)
+// routes dispatches a request to the handler for its route and method. Unknown routes get a
+// 404, and known routes requested with another method a 405 with an Allow header. HEAD is
+// served by the route's GET handler with the body dropped.
+func routes(userRepo models.UserRepository, healthHandler *handlers.HealthHandler) HandlerFunc {
+	table := routeTable(userRepo, healthHandler)
 
-		// If an error occurs, preserve the original status code if it's an error from utils.ErrorResponse,
-		// otherwise, default to InternalServerError.
-		// We also ensure CORS headers are present in the error response.
-		statusCode := http.StatusInternalServerError
-		if response.StatusCode != 0 && response.StatusCode != http.StatusOK {
-			statusCode = response.StatusCode
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		methods, ok := table[routeResource(request)]
+		if !ok {
+			return utils.ErrorResponse(http.StatusNotFound, errors.New("not found"))
 		}
 
-		errorResponse, _ := utils.ErrorResponse(statusCode, err)
-		for k, v := range commonHeaders {
-			errorResponse.Headers[k] = v
+		method := request.HTTPMethod
+		if method == http.MethodHead {
+			method = http.MethodGet
 		}
-		return errorResponse, nil
-	}
+		handler, ok := methods[method]
+		if !ok {
+			response, err := utils.ErrorResponse(http.StatusMethodNotAllowed, errors.New("method not allowed"))
+			response.Headers["Allow"] = allowedMethods(methods)
 
-	// Merge common headers with the handler's response headers
-	if response.Headers == nil {
-		response.Headers = make(map[string]string)
-	}
-	for k, v := range commonHeaders {
-		// Only set if not already set by the handler to allow overrides
-		if _, ok := response.Headers[k]; !ok {
-			response.Headers[k] = v
+			return response, err
+		}
+
+		response, err := handler(ctx, request)
+		if request.HTTPMethod == http.MethodHead {
+			response.Body = ""
+			response.IsBase64Encoded = false
 		}
-	}
 
-	return response, nil
+		return response, err
+	}
 }
 
-func main() {
-	log.Println("Lambda cold start")
+// routeResource returns the route pattern API Gateway matched, e.g. "/users/{id}", which is
+// what the route table, schemas, timeouts and deprecations are keyed by. Requests built by
+// hand without a resource fall back to their path.
+func routeResource(request events.APIGatewayProxyRequest) string {
+	if request.Resource != "" {
+		return request.Resource
+	}
 
-	healthHandler := handlers.NewHealthHandler()
-	// For now, using a mock user repository. Replace with actual implementation.
-	userRepo := models.NewInMemoryUserRepository()
+	return request.Path
+}
 
-	lambda.Start(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
-		return Router(ctx, request, userRepo, healthHandler)
-	})
+// allowedMethods returns the Allow header value for a route's methods, including HEAD when
+// GET is allowed, like the local server.
+func allowedMethods(methods map[string]HandlerFunc) string {
+	allowed := make([]string, 0, len(methods)+1)
+	for method := range methods {
+		allowed = append(allowed, method)
+	}
+	if _, ok := methods[http.MethodGet]; ok {
+		allowed = append(allowed, http.MethodHead)
+	}
+	sort.Strings(allowed)
+
+	return strings.Join(allowed, ", ")
 }
 
-func handleRootGet(_ events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+func handleRootGet(_ context.Context, _ events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 	return utils.APIResponse(http.StatusOK, map[string]string{"message": "Welcome to the Go Lambda API"})
 }
 
@@ -126,6 +182,14 @@ func handleCreateUser(
 	return userHandler.CreateUserHandler(ctx, request)
 }
 
+func handleBatchCreateUsers(
+	ctx context.Context, request events.APIGatewayProxyRequest, userRepo models.UserRepository,
+) (events.APIGatewayProxyResponse, error) {
+	userHandler := handlers.NewUserHandler(userRepo)
+
+	return userHandler.BatchCreateUsersHandler(ctx, request)
+}
+
 func handleGetUser(
 	ctx context.Context, request events.APIGatewayProxyRequest, userRepo models.UserRepository,
 ) (events.APIGatewayProxyResponse, error) {
@@ -150,6 +214,14 @@ func handleUpdateUser(
 	return userHandler.UpdateUserHandler(ctx, request)
 }
 
+func handlePatchUser(
+	ctx context.Context, request events.APIGatewayProxyRequest, userRepo models.UserRepository,
+) (events.APIGatewayProxyResponse, error) {
+	userHandler := handlers.NewUserHandler(userRepo)
+
+	return userHandler.PatchUserHandler(ctx, request)
+}
+
 func handleDeleteUser(
 	ctx context.Context, request events.APIGatewayProxyRequest, userRepo models.UserRepository,
 ) (events.APIGatewayProxyResponse, error) {
@@ -157,3 +229,51 @@ func handleDeleteUser(
 
 	return userHandler.DeleteUserHandler(ctx, request)
 }
+
+func handleDeleteAllUsers(
+	ctx context.Context, request events.APIGatewayProxyRequest, userRepo models.UserRepository,
+) (events.APIGatewayProxyResponse, error) {
+	userHandler := handlers.NewUserHandler(userRepo)
+
+	return userHandler.DeleteAllUsersHandler(ctx, request)
+}
+
+func handleUploadAvatar(
+	ctx context.Context, request events.APIGatewayProxyRequest, userRepo models.UserRepository,
+) (events.APIGatewayProxyResponse, error) {
+	userHandler := handlers.NewUserHandler(userRepo)
+
+	return userHandler.UploadAvatarHandler(ctx, request)
+}
+
+func handleImportUsers(
+	ctx context.Context, request events.APIGatewayProxyRequest, userRepo models.UserRepository,
+) (events.APIGatewayProxyResponse, error) {
+	adminHandler := handlers.NewAdminHandler(userRepo)
+
+	return adminHandler.ImportUsersHandler(ctx, request)
+}
+
+func handleResetUsers(
+	ctx context.Context, request events.APIGatewayProxyRequest, userRepo models.UserRepository,
+) (events.APIGatewayProxyResponse, error) {
+	adminHandler := handlers.NewAdminHandler(userRepo)
+
+	return adminHandler.ResetUsersHandler(ctx, request)
+}
+
+func handleExportUsers(
+	ctx context.Context, request events.APIGatewayProxyRequest, userRepo models.UserRepository,
+) (events.APIGatewayProxyResponse, error) {
+	adminHandler := handlers.NewAdminHandler(userRepo)
+
+	return adminHandler.ExportUsersHandler(ctx, request)
+}
+
+func handleUserStats(
+	ctx context.Context, request events.APIGatewayProxyRequest, userRepo models.UserRepository,
+) (events.APIGatewayProxyResponse, error) {
+	adminHandler := handlers.NewAdminHandler(userRepo)
+
+	return adminHandler.UserStatsHandler(ctx, request)
+}
@@ -0,0 +1,249 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/handlers"
+	"go-lambda-api/utils"
+)
+
+// HandlerFunc handles an API Gateway request, like the handlers in the handlers package.
+type HandlerFunc func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
+
+// Middleware wraps a HandlerFunc with behavior that runs before and after it.
+type Middleware func(HandlerFunc) HandlerFunc
+
+// Chain composes middlewares into one, outermost first: Chain(a, b)(h) runs a, which calls
+// b, which calls h, and b's response passes back through a.
+func Chain(middlewares ...Middleware) Middleware {
+	return func(handler HandlerFunc) HandlerFunc {
+		for i := len(middlewares) - 1; i >= 0; i-- {
+			handler = middlewares[i](handler)
+		}
+
+		return handler
+	}
+}
+
+// requestIDMiddleware puts the request's correlation ID in the context, for log lines, and
+// returns it in X-Request-Id.
+func requestIDMiddleware(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		ctx = utils.ContextWithRequestID(ctx, utils.RequestID(request))
+		response, err := next(ctx, request)
+		utils.AddRequestIDHeader(ctx, &response)
+
+		return response, err
+	}
+}
+
+// requestScopeMiddleware attaches the per-request retry budget and feature flags, and the
+// route's deadline, to the context.
+func requestScopeMiddleware(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		ctx = handlers.WithRequestScope(ctx, request)
+		ctx, cancel := context.WithTimeout(ctx, utils.RequestTimeout(request.HTTPMethod, routeResource(request)))
+		defer cancel()
+
+		return next(ctx, request)
+	}
+}
+
+// compressionMiddleware gzips large responses for clients that accept it. It must wrap
+// every middleware that reads or rewrites the body.
+func compressionMiddleware(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		response, err := next(ctx, request)
+		utils.CompressResponse(request, &response)
+
+		return response, err
+	}
+}
+
+// formatMiddleware pretty-prints JSON responses when the feature flag is on.
+func formatMiddleware(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		response, err := next(ctx, request)
+		utils.FormatResponse(ctx, &response)
+
+		return response, err
+	}
+}
+
+// diagnosticHeadersMiddleware sets X-Cold-Start and X-API-Version.
+func diagnosticHeadersMiddleware(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		coldStart := isColdStart()
+		response, err := next(ctx, request)
+		addColdStartHeader(&response, coldStart)
+		utils.AddVersionHeader(&response)
+
+		return response, err
+	}
+}
+
+// deprecationMiddleware marks responses from deprecated routes.
+func deprecationMiddleware(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		response, err := next(ctx, request)
+		addDeprecationHeaders(request, &response)
+
+		return response, err
+	}
+}
+
+// errorFormatMiddleware renders error responses as problem details when requested.
+func errorFormatMiddleware(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		response, err := next(ctx, request)
+		utils.NegotiateErrorFormat(request, &response)
+
+		return response, err
+	}
+}
+
+// responseSizeMiddleware replaces responses over MAX_RESPONSE_BYTES with a 500 that says
+// why, where API Gateway would reject them with an opaque error. It sits outside the
+// compression middleware, so the body is measured as sent, and inside the logging one, so
+// the replacement is what gets logged.
+func responseSizeMiddleware(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		response, err := next(ctx, request)
+		if sizeErr := utils.CheckResponseSize(response); sizeErr != nil {
+			return utils.ResponseTooLargeResponse(response, sizeErr), nil
+		}
+
+		return response, err
+	}
+}
+
+// rateLimitMiddleware answers 429 with Retry-After once the request's tenant has used up its
+// rate limit. It runs before any other check, so rejected requests count too.
+func rateLimitMiddleware(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		if retryAfter, err := utils.CheckRateLimit(request); err != nil {
+			return utils.RateLimitedResponse(err, retryAfter), nil
+		}
+
+		return next(ctx, request)
+	}
+}
+
+// requestChecksMiddleware rejects requests with oversized headers, a missing User-Agent when
+// one is required, ambiguous query parameters, a Content-Length that does not match the body
+// or bodies that do not match the route's schema before they reach a handler.
+func requestChecksMiddleware(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		if err := utils.CheckHeaderLimits(request); err != nil {
+			return utils.ErrorResponse(http.StatusRequestHeaderFieldsTooLarge, err)
+		}
+		if err := utils.CheckUserAgent(request); err != nil {
+			return utils.ErrorResponse(http.StatusBadRequest, err)
+		}
+		if err := utils.ResolveQueryParams(&request); err != nil {
+			return utils.ErrorResponse(http.StatusBadRequest, err)
+		}
+		if err := utils.CheckContentLength(request); err != nil {
+			return utils.ErrorResponse(http.StatusBadRequest, err)
+		}
+		if err := handlers.ValidateRequestSchema(request, routeResource(request)); err != nil {
+			return utils.ErrorResponse(http.StatusBadRequest, err)
+		}
+
+		return next(ctx, request)
+	}
+}
+
+// chaosMiddleware injects latency and synthetic 500s and 503s when chaos mode is on. It runs
+// inside the request scope, so injected latency counts against the route's deadline, and
+// after the request checks, so only requests a handler would serve are affected.
+func chaosMiddleware(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		if response, injected := utils.InjectChaos(ctx, request); injected {
+			return response, nil
+		}
+
+		return next(ctx, request)
+	}
+}
+
+// idempotencyMiddleware replays stored responses for repeated Idempotency-Keys and stores
+// new ones. Requests rejected by outer middlewares are never recorded, so a corrected retry
+// can reuse its key.
+func idempotencyMiddleware(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		call, replay, err := handlers.StartIdempotent(ctx, request)
+		if err != nil {
+			return utils.ErrorResponse(handlers.IdempotencyErrorStatus(err), err)
+		}
+		if replay != nil {
+			return *replay, nil
+		}
+
+		response, err := next(ctx, request)
+		handlers.FinishIdempotent(ctx, call, response)
+
+		return response, err
+	}
+}
+
+// errInternal is returned to clients in place of a panic message, which may reveal internals.
+var errInternal = errors.New("internal server error")
+
+// recoveryMiddleware turns a panic in a handler into a 500, logging the panic and its stack
+// trace. It sits inside the idempotency middleware, so the key of a panicking request is
+// released for retries.
+func recoveryMiddleware(next HandlerFunc) HandlerFunc {
+	return func(
+		ctx context.Context, request events.APIGatewayProxyRequest,
+	) (response events.APIGatewayProxyResponse, err error) {
+		defer func() {
+			if rec := recover(); rec != nil {
+				log.Printf("Panic processing request %s: %v\n%s", utils.RequestIDFromContext(ctx), rec, debug.Stack())
+				response, err = utils.ErrorResponse(http.StatusInternalServerError, errInternal)
+			}
+		}()
+
+		return next(ctx, request)
+	}
+}
+
+// responseSchemaMiddleware replaces responses that do not match the route's schema with a
+// 500 when SCHEMA_VALIDATION is "all".
+func responseSchemaMiddleware(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		response, err := next(ctx, request)
+		if schemaErr := handlers.ValidateResponseSchema(request, routeResource(request), response); schemaErr != nil {
+			return handlers.SchemaViolationResponse(schemaErr)
+		}
+
+		return response, err
+	}
+}
+
+// errorResponseMiddleware turns a handler error into an error response, keeping the status
+// of responses built with utils.ErrorResponse and defaulting to 500 otherwise, so middlewares
+// further out only deal with responses.
+func errorResponseMiddleware(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		response, err := next(ctx, request)
+		if err == nil {
+			return response, nil
+		}
+
+		log.Printf("Error processing request %s: %v", utils.RequestIDFromContext(ctx), err)
+
+		statusCode := http.StatusInternalServerError
+		if response.StatusCode != 0 && response.StatusCode != http.StatusOK {
+			statusCode = response.StatusCode
+		}
+
+		return utils.ErrorResponse(statusCode, err)
+	}
+}
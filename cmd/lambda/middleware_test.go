@@ -0,0 +1,249 @@
+package lambda
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestRequestChecksMiddlewareDuplicateQueryParams(t *testing.T) {
+	tests := []struct {
+		name       string
+		strict     string
+		wantStatus int
+		wantLimit  string
+	}{
+		{name: "lenient uses the first value", wantStatus: http.StatusOK, wantLimit: "10"},
+		{name: "strict rejects", strict: "true", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("STRICT_QUERY_PARAMS", tt.strict)
+
+			var limit string
+			next := func(_ context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				limit = request.QueryStringParameters["limit"]
+				return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+			}
+
+			request := events.APIGatewayProxyRequest{
+				HTTPMethod:                      http.MethodGet,
+				Resource:                        "/users",
+				Path:                            "/users",
+				Headers:                         map[string]string{"User-Agent": "test"},
+				QueryStringParameters:           map[string]string{"limit": "20"},
+				MultiValueQueryStringParameters: map[string][]string{"limit": {"10", "20"}},
+			}
+			response, err := requestChecksMiddleware(next)(context.Background(), request)
+			if err != nil {
+				t.Fatalf("requestChecksMiddleware() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", response.StatusCode, tt.wantStatus, response.Body)
+			}
+			if limit != tt.wantLimit {
+				t.Errorf("handler saw limit %q, want %q", limit, tt.wantLimit)
+			}
+		})
+	}
+}
+
+func TestRequestChecksMiddlewareHeaderLimits(t *testing.T) {
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		wantStatus int
+	}{
+		{name: "at the limit", headers: map[string]string{"User-Agent": "test", "Accept": "*/*"}, wantStatus: http.StatusOK},
+		{
+			name:       "above the limit",
+			headers:    map[string]string{"User-Agent": "test", "Accept": "*/*", "X-Extra": "1"},
+			wantStatus: http.StatusRequestHeaderFieldsTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MAX_HEADER_COUNT", "2")
+
+			next := func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+			}
+			request := events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet, Resource: "/users", Path: "/users", Headers: tt.headers,
+			}
+			response, err := requestChecksMiddleware(next)(context.Background(), request)
+			if err != nil {
+				t.Fatalf("requestChecksMiddleware() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d; body %s", response.StatusCode, tt.wantStatus, response.Body)
+			}
+		})
+	}
+}
+
+func TestChainOrder(t *testing.T) {
+	// recording returns a middleware that logs its name before and after the handler it wraps
+	recording := func(name string, calls *[]string) Middleware {
+		return func(next HandlerFunc) HandlerFunc {
+			return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				*calls = append(*calls, name+" before")
+				response, err := next(ctx, request)
+				*calls = append(*calls, name+" after")
+				return response, err
+			}
+		}
+	}
+
+	tests := []struct {
+		name      string
+		names     []string
+		wantCalls []string
+	}{
+		{name: "no middlewares", wantCalls: []string{"handler"}},
+		{name: "one middleware", names: []string{"a"}, wantCalls: []string{"a before", "handler", "a after"}},
+		{
+			name:      "outermost first",
+			names:     []string{"a", "b", "c"},
+			wantCalls: []string{"a before", "b before", "c before", "handler", "c after", "b after", "a after"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var calls []string
+			middlewares := make([]Middleware, len(tt.names))
+			for i, name := range tt.names {
+				middlewares[i] = recording(name, &calls)
+			}
+			handler := func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				calls = append(calls, "handler")
+				return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+			}
+
+			if _, err := Chain(middlewares...)(handler)(context.Background(), events.APIGatewayProxyRequest{}); err != nil {
+				t.Fatalf("chained handler error = %v", err)
+			}
+			if !reflect.DeepEqual(calls, tt.wantCalls) {
+				t.Errorf("calls = %v, want %v", calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestRequestChecksMiddlewareContentLength(t *testing.T) {
+	tests := []struct {
+		name          string
+		contentLength string
+		wantStatus    int
+	}{
+		{name: "matching", contentLength: "2", wantStatus: http.StatusOK},
+		{name: "mismatched", contentLength: "3", wantStatus: http.StatusBadRequest},
+		{name: "absent", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			next := func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+			}
+
+			request := events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodDelete,
+				Resource:   "/users",
+				Path:       "/users",
+				Headers:    map[string]string{"User-Agent": "test"},
+				Body:       "{}",
+			}
+			if tt.contentLength != "" {
+				request.Headers["Content-Length"] = tt.contentLength
+			}
+			response, err := requestChecksMiddleware(next)(context.Background(), request)
+			if err != nil {
+				t.Fatalf("requestChecksMiddleware() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d; body %s", response.StatusCode, tt.wantStatus, response.Body)
+			}
+		})
+	}
+}
+
+func TestRequestChecksMiddlewareUserAgent(t *testing.T) {
+	tests := []struct {
+		name       string
+		resource   string
+		userAgent  string
+		wantStatus int
+	}{
+		{name: "present", resource: "/users", userAgent: "curl/8.5.0", wantStatus: http.StatusOK},
+		{name: "missing", resource: "/users", wantStatus: http.StatusBadRequest},
+		{name: "health exempt", resource: "/health", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("REQUIRE_USER_AGENT", "true")
+			next := func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+			}
+
+			request := events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet, Resource: tt.resource, Path: tt.resource, Headers: map[string]string{},
+			}
+			if tt.userAgent != "" {
+				request.Headers["User-Agent"] = tt.userAgent
+			}
+			response, err := requestChecksMiddleware(next)(context.Background(), request)
+			if err != nil {
+				t.Fatalf("requestChecksMiddleware() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d; body %s", response.StatusCode, tt.wantStatus, response.Body)
+			}
+		})
+	}
+}
+
+func TestChaosMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		stage      string
+		wantServed bool
+	}{
+		{name: "dev fails the request", stage: "dev"},
+		{name: "prod serves the request", stage: "prod", wantServed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CHAOS_MODE", "true")
+			t.Setenv("STAGE", tt.stage)
+			t.Setenv("CHAOS_ERROR_PERCENT", "100")
+
+			served := false
+			next := func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				served = true
+				return events.APIGatewayProxyResponse{StatusCode: http.StatusOK}, nil
+			}
+			request := events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, Resource: "/users", Path: "/users"}
+			response, err := chaosMiddleware(next)(context.Background(), request)
+			if err != nil {
+				t.Fatalf("chaosMiddleware() error = %v", err)
+			}
+
+			if served != tt.wantServed {
+				t.Errorf("handler called = %t, want %t", served, tt.wantServed)
+			}
+			if !tt.wantServed && response.StatusCode != http.StatusInternalServerError &&
+				response.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("status = %d, want 500 or 503", response.StatusCode)
+			}
+		})
+	}
+}
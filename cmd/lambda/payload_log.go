@@ -0,0 +1,82 @@
+package lambda
+
+import (
+	"context"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/utils"
+)
+
+// DefaultPayloadBudgetBytes is the body size above which a warning is logged
+// when PAYLOAD_BUDGET_BYTES is unset.
+const DefaultPayloadBudgetBytes = 1 << 20
+
+// loggingMiddleware logs the payload sizes of every request, and an access log line when
+// ACCESS_LOG_FORMAT=clf, once its response is final, so it must wrap every middleware that
+// changes the response's status or body. Only requestIDMiddleware, which puts the logged
+// request ID in the context and adds a header, sits outside it.
+func loggingMiddleware(next HandlerFunc) HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		start := time.Now()
+		response, err := next(ctx, request)
+		logPayloadSizes(ctx, request, response)
+		utils.LogAccess(accessLogEntry(request, response, start))
+
+		return response, err
+	}
+}
+
+// accessLogEntry describes a request served through API Gateway for the access log.
+func accessLogEntry(
+	request events.APIGatewayProxyRequest, response events.APIGatewayProxyResponse, start time.Time,
+) utils.AccessLogEntry {
+	uri := request.Path
+	if len(request.QueryStringParameters) > 0 {
+		query := make(url.Values, len(request.QueryStringParameters))
+		for name, value := range request.QueryStringParameters {
+			query.Set(name, value)
+		}
+		uri += "?" + query.Encode()
+	}
+
+	return utils.AccessLogEntry{
+		RemoteAddr: request.RequestContext.Identity.SourceIP,
+		Time:       start,
+		Method:     request.HTTPMethod,
+		URI:        uri,
+		Protocol:   request.RequestContext.Protocol,
+		Status:     response.StatusCode,
+		Bytes:      utils.BodyLength(response.Body, response.IsBase64Encoded),
+		Referer:    utils.GetHeader(request, "Referer"),
+		UserAgent:  utils.GetHeader(request, "User-Agent"),
+		Duration:   time.Since(start),
+	}
+}
+
+// logPayloadSizes logs the request and response body sizes as structured fields and
+// warns when either exceeds the PAYLOAD_BUDGET_BYTES budget.
+func logPayloadSizes(
+	ctx context.Context, request events.APIGatewayProxyRequest, response events.APIGatewayProxyResponse,
+) {
+	requestBytes := utils.BodyLength(request.Body, request.IsBase64Encoded)
+	responseBytes := utils.BodyLength(response.Body, response.IsBase64Encoded)
+
+	attrs := []any{
+		"request_id", utils.RequestIDFromContext(ctx),
+		"method", request.HTTPMethod,
+		"path", request.Path,
+		"status", response.StatusCode,
+		"request_bytes", requestBytes,
+		"response_bytes", responseBytes,
+	}
+	slog.Info("request payload sizes", attrs...)
+
+	budget := utils.GetEnvInt("PAYLOAD_BUDGET_BYTES", DefaultPayloadBudgetBytes)
+	if requestBytes > budget || responseBytes > budget {
+		slog.Warn("payload size budget exceeded", append(attrs, "budget_bytes", budget)...)
+	}
+}
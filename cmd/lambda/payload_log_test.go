@@ -0,0 +1,122 @@
+package lambda
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/utils"
+)
+
+func TestLogPayloadSizes(t *testing.T) {
+	tests := []struct {
+		name              string
+		request           events.APIGatewayProxyRequest
+		response          events.APIGatewayProxyResponse
+		wantRequestBytes  float64
+		wantResponseBytes float64
+		wantWarning       bool
+	}{
+		{
+			name:              "within budget",
+			request:           events.APIGatewayProxyRequest{HTTPMethod: http.MethodPost, Path: "/users", Body: "12345"},
+			response:          events.APIGatewayProxyResponse{StatusCode: http.StatusCreated, Body: "1234567890"},
+			wantRequestBytes:  5,
+			wantResponseBytes: 10,
+		},
+		{
+			name: "request over budget",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodPost, Path: "/users", Body: strings.Repeat("x", 17),
+			},
+			response:          events.APIGatewayProxyResponse{StatusCode: http.StatusCreated},
+			wantRequestBytes:  17,
+			wantResponseBytes: 0,
+			wantWarning:       true,
+		},
+		{
+			name:              "response over budget",
+			request:           events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, Path: "/users"},
+			response:          events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: strings.Repeat("x", 20)},
+			wantResponseBytes: 20,
+			wantWarning:       true,
+		},
+		{
+			name: "base64 bodies are measured decoded",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod:      http.MethodPut,
+				Path:            "/users/1/avatar",
+				Body:            base64.StdEncoding.EncodeToString(make([]byte, 12)),
+				IsBase64Encoded: true,
+			},
+			response:         events.APIGatewayProxyResponse{StatusCode: http.StatusOK},
+			wantRequestBytes: 12,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("PAYLOAD_BUDGET_BYTES", "16")
+
+			var logs bytes.Buffer
+			saved := slog.Default()
+			slog.SetDefault(slog.New(slog.NewJSONHandler(&logs, nil)))
+			t.Cleanup(func() { slog.SetDefault(saved) })
+
+			logPayloadSizes(context.Background(), tt.request, tt.response)
+
+			var entries []map[string]any
+			for _, line := range strings.Split(strings.TrimSpace(logs.String()), "\n") {
+				var entry map[string]any
+				if err := json.Unmarshal([]byte(line), &entry); err != nil {
+					t.Fatalf("decoding log line %q: %v", line, err)
+				}
+				entries = append(entries, entry)
+			}
+
+			sizes := entries[0]
+			if sizes["request_bytes"] != tt.wantRequestBytes || sizes["response_bytes"] != tt.wantResponseBytes {
+				t.Errorf("logged request_bytes %v, response_bytes %v; want %v, %v",
+					sizes["request_bytes"], sizes["response_bytes"], tt.wantRequestBytes, tt.wantResponseBytes)
+			}
+
+			warned := len(entries) > 1 && entries[1]["level"] == "WARN"
+			if warned != tt.wantWarning {
+				t.Errorf("warning logged = %v, want %v (logs %s)", warned, tt.wantWarning, logs.String())
+			}
+			if warned && entries[1]["budget_bytes"] != float64(16) {
+				t.Errorf("budget_bytes = %v, want 16", entries[1]["budget_bytes"])
+			}
+		})
+	}
+}
+
+func TestAccessLogEntry(t *testing.T) {
+	request := events.APIGatewayProxyRequest{
+		HTTPMethod:            http.MethodGet,
+		Path:                  "/users",
+		QueryStringParameters: map[string]string{"limit": "10", "name": "ann lee"},
+		Headers:               map[string]string{"user-agent": "curl/8.5.0", "Referer": "https://example.com/"},
+	}
+	request.RequestContext.Identity.SourceIP = "203.0.113.7"
+	request.RequestContext.Protocol = "HTTP/1.1"
+	response := events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: `{"users":[]}`}
+	start := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+
+	entry := accessLogEntry(request, response, start)
+	entry.Duration = 3 * time.Millisecond
+
+	want := `203.0.113.7 - - [15/Oct/2026:12:00:00 +0000] "GET /users?limit=10&name=ann+lee HTTP/1.1" 200 12 ` +
+		`"https://example.com/" "curl/8.5.0" 3`
+	if got := utils.FormatAccessLog(entry); got != want {
+		t.Errorf("access log line = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,313 @@
+package lambda
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+
+	"go-lambda-api/handlers"
+	"go-lambda-api/models"
+	"go-lambda-api/utils"
+)
+
+func TestRouterVersionHeader(t *testing.T) {
+	version := utils.Version
+	utils.Version = "1.2.3"
+	t.Cleanup(func() { utils.Version = version })
+	models.ClearInMemoryUsers()
+	t.Cleanup(models.ClearInMemoryUsers)
+
+	tests := []struct {
+		name       string
+		request    events.APIGatewayProxyRequest
+		wantStatus int
+	}{
+		{
+			name:       "health",
+			request:    events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, Resource: "/health", Path: "/health"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "list users",
+			request:    events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, Resource: "/users", Path: "/users"},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "missing user",
+			request: events.APIGatewayProxyRequest{
+				HTTPMethod:     http.MethodGet,
+				Resource:       "/users/{id}",
+				Path:           "/users/nobody",
+				PathParameters: map[string]string{"id": "nobody"},
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name:       "unknown route",
+			request:    events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, Resource: "/nowhere", Path: "/nowhere"},
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	userRepo := models.NewInMemoryUserRepository()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := Router(context.Background(), tt.request, userRepo, handlers.NewHealthHandler())
+			if err != nil {
+				t.Fatalf("Router() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", response.StatusCode, tt.wantStatus, response.Body)
+			}
+			if got := response.Headers[utils.APIVersionHeader]; got != "1.2.3" {
+				t.Errorf("%s = %q, want %q", utils.APIVersionHeader, got, "1.2.3")
+			}
+		})
+	}
+}
+
+func TestRouterRequestID(t *testing.T) {
+	models.ClearInMemoryUsers()
+	t.Cleanup(models.ClearInMemoryUsers)
+
+	health := events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet, Resource: "/health", Path: "/health"}
+	missing := events.APIGatewayProxyRequest{
+		HTTPMethod:     http.MethodGet,
+		Resource:       "/users/{id}",
+		Path:           "/users/nobody",
+		PathParameters: map[string]string{"id": "nobody"},
+	}
+	malformed := events.APIGatewayProxyRequest{
+		HTTPMethod: http.MethodPost, Resource: "/users", Path: "/users", Body: `{"name":`,
+		Headers: map[string]string{"Content-Type": "application/json"},
+	}
+
+	tests := []struct {
+		name       string
+		request    events.APIGatewayProxyRequest
+		requestID  string
+		wantStatus int
+	}{
+		{name: "success with an API Gateway ID", request: health, requestID: "gw-123", wantStatus: http.StatusOK},
+		{name: "success with a generated ID", request: health, wantStatus: http.StatusOK},
+		{name: "error with an API Gateway ID", request: missing, requestID: "gw-456", wantStatus: http.StatusNotFound},
+		{name: "error with a generated ID", request: missing, wantStatus: http.StatusNotFound},
+		{name: "bad request", request: malformed, requestID: "gw-789", wantStatus: http.StatusBadRequest},
+	}
+
+	userRepo := models.NewInMemoryUserRepository()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := tt.request
+			request.RequestContext.RequestID = tt.requestID
+
+			response, err := Router(context.Background(), request, userRepo, handlers.NewHealthHandler())
+			if err != nil {
+				t.Fatalf("Router() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", response.StatusCode, tt.wantStatus, response.Body)
+			}
+
+			id := response.Headers[utils.RequestIDHeader]
+			if tt.requestID != "" && id != tt.requestID {
+				t.Errorf("%s = %q, want %q", utils.RequestIDHeader, id, tt.requestID)
+			}
+			if tt.requestID == "" {
+				if _, err := uuid.Parse(id); err != nil {
+					t.Errorf("%s = %q, want a generated UUID", utils.RequestIDHeader, id)
+				}
+			}
+		})
+	}
+}
+
+// panickingRepository panics on every read, like a handler hitting a nil map.
+type panickingRepository struct {
+	models.UserRepository
+}
+
+func (panickingRepository) GetUserByID(context.Context, string) (models.User, error) {
+	var users map[string]*models.User
+	return *users["user-1"], nil
+}
+
+func TestRouterRecoversFromPanics(t *testing.T) {
+	tests := []struct {
+		name       string
+		origin     string
+		allowed    string
+		wantOrigin string
+	}{
+		{name: "default CORS", wantOrigin: "*"},
+		{name: "allowed origin", allowed: "https://app.example.com", origin: "https://app.example.com",
+			wantOrigin: "https://app.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CORS_ALLOWED_ORIGINS", tt.allowed)
+			logs := captureLogs(t)
+
+			request := events.APIGatewayProxyRequest{
+				HTTPMethod:     http.MethodGet,
+				Resource:       "/users/{id}",
+				Path:           "/users/user-1",
+				PathParameters: map[string]string{"id": "user-1"},
+				Headers:        map[string]string{"Origin": tt.origin},
+			}
+			response, err := Router(context.Background(), request, panickingRepository{}, handlers.NewHealthHandler())
+			if err != nil {
+				t.Fatalf("Router() error = %v", err)
+			}
+			if response.StatusCode != http.StatusInternalServerError {
+				t.Fatalf("status = %d, want %d; body %s", response.StatusCode, http.StatusInternalServerError, response.Body)
+			}
+
+			var body map[string]interface{}
+			if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+				t.Fatalf("decoding body %q: %v", response.Body, err)
+			}
+			if body["error"] != "internal server error" {
+				t.Errorf("error = %v, want %q", body["error"], "internal server error")
+			}
+			if strings.Contains(response.Body, "nil pointer") {
+				t.Errorf("body %s leaks the panic", response.Body)
+			}
+			if got := response.Headers["Access-Control-Allow-Origin"]; got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if !strings.Contains(logs.String(), "nil pointer") || !strings.Contains(logs.String(), "goroutine") {
+				t.Errorf("logs do not hold the panic and its stack trace: %s", logs)
+			}
+		})
+	}
+}
+
+// captureLogs sends the standard logger's output to the returned buffer until the test ends.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var logs bytes.Buffer
+	writer := log.Writer()
+	log.SetOutput(&logs)
+	t.Cleanup(func() { log.SetOutput(writer) })
+
+	return &logs
+}
+
+func TestRouterMethodNotAllowed(t *testing.T) {
+	models.ClearInMemoryUsers()
+	t.Cleanup(models.ClearInMemoryUsers)
+
+	tests := []struct {
+		name       string
+		method     string
+		resource   string
+		wantStatus int
+		wantAllow  string
+	}{
+		{
+			name:       "unsupported method on users",
+			method:     http.MethodPut,
+			resource:   "/users",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantAllow:  "DELETE, GET, HEAD, POST",
+		},
+		{
+			name:       "unsupported method on health",
+			method:     http.MethodDelete,
+			resource:   "/health",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantAllow:  "GET, HEAD",
+		},
+		{
+			name:       "unsupported method on a user",
+			method:     http.MethodPost,
+			resource:   "/users/{id}",
+			wantStatus: http.StatusMethodNotAllowed,
+			wantAllow:  "DELETE, GET, HEAD, PATCH, PUT",
+		},
+		{name: "HEAD served by GET", method: http.MethodHead, resource: "/health", wantStatus: http.StatusOK},
+		{name: "unknown route", method: http.MethodDelete, resource: "/nowhere", wantStatus: http.StatusNotFound},
+	}
+
+	userRepo := models.NewInMemoryUserRepository()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := events.APIGatewayProxyRequest{
+				HTTPMethod:     tt.method,
+				Resource:       tt.resource,
+				Path:           strings.ReplaceAll(tt.resource, "{id}", "user-1"),
+				PathParameters: map[string]string{"id": "user-1"},
+			}
+			response, err := Router(context.Background(), request, userRepo, handlers.NewHealthHandler())
+			if err != nil {
+				t.Fatalf("Router() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", response.StatusCode, tt.wantStatus, response.Body)
+			}
+			if got := response.Headers["Allow"]; got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+			if tt.method == http.MethodHead && response.Body != "" {
+				t.Errorf("HEAD body = %q, want none", response.Body)
+			}
+		})
+	}
+}
+
+func TestRouterResponseSizeLimit(t *testing.T) {
+	models.ClearInMemoryUsers()
+	t.Cleanup(models.ClearInMemoryUsers)
+	userRepo := models.NewInMemoryUserRepository()
+	for i := 0; i < 50; i++ {
+		user := models.User{ID: fmt.Sprintf("user-%02d", i), Name: strings.Repeat("n", 200),
+			Email: fmt.Sprintf("user%d@example.com", i)}
+		if _, err := userRepo.CreateUser(context.Background(), user); err != nil {
+			t.Fatalf("seeding %s: %v", user.ID, err)
+		}
+	}
+
+	tests := []struct {
+		name       string
+		maxBytes   string
+		limit      string
+		wantStatus int
+	}{
+		{name: "under the default limit", limit: "50", wantStatus: http.StatusOK},
+		{name: "large list over a configured limit", maxBytes: "4096", limit: "50",
+			wantStatus: http.StatusInternalServerError},
+		{name: "smaller page under a configured limit", maxBytes: "4096", limit: "5", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MAX_RESPONSE_BYTES", tt.maxBytes)
+
+			request := events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet, Resource: "/users", Path: "/users",
+				QueryStringParameters: map[string]string{"limit": tt.limit},
+			}
+			response, err := Router(context.Background(), request, userRepo, handlers.NewHealthHandler())
+			if err != nil {
+				t.Fatalf("Router() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", response.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK && !strings.Contains(response.Body, "response too large") {
+				t.Errorf("body = %s, want the response size error", response.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package lambda
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"go-lambda-api/handlers"
+	"go-lambda-api/utils"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// RoutesPath lists the route table for deployment tooling. It is admin-only.
+const RoutesPath = "/_routes"
+
+// Route authorization requirements reported by ListRoutes.
+const (
+	// RouteAuthNone routes are open to every caller.
+	RouteAuthNone = "none"
+	// RouteAuthAdmin routes require the X-Admin-Token header.
+	RouteAuthAdmin = "admin"
+)
+
+// adminRoutes are the routes whose handlers require the admin token, keyed by
+// "METHOD path" like deprecatedRoutes. It only documents the requirement for ListRoutes;
+// the handlers enforce it.
+var adminRoutes = map[string]bool{
+	http.MethodGet + " " + UsersStatsPath:   true,
+	http.MethodPost + " " + AdminImportPath: true,
+	http.MethodPost + " " + AdminResetPath:  true,
+	http.MethodGet + " " + AdminExportPath:  true,
+	http.MethodGet + " " + RoutesPath:       true,
+}
+
+// RouteInfo describes a route served by Router.
+type RouteInfo struct {
+	Method string `json:"method"`
+	// Path is the path pattern, e.g. "/users/{id}".
+	Path string `json:"path"`
+	// Auth is RouteAuthNone or RouteAuthAdmin.
+	Auth       string     `json:"auth"`
+	Deprecated bool       `json:"deprecated"`
+	Successor  string     `json:"successor,omitempty"`
+	Sunset     *time.Time `json:"sunset,omitempty"`
+}
+
+// ListRoutes returns every route in the route table, sorted by path and then method, with its
+// authorization requirement and deprecation status.
+func ListRoutes() []RouteInfo {
+	var routes []RouteInfo
+	for path, methods := range routeTable(nil, nil) {
+		for method := range methods {
+			key := method + " " + path
+			route := RouteInfo{Method: method, Path: path, Auth: RouteAuthNone}
+			if adminRoutes[key] {
+				route.Auth = RouteAuthAdmin
+			}
+			if deprecation, ok := deprecatedRoutes[key]; ok {
+				route.Deprecated = true
+				route.Successor = deprecation.Successor
+				if !deprecation.Sunset.IsZero() {
+					sunset := deprecation.Sunset.UTC()
+					route.Sunset = &sunset
+				}
+			}
+			routes = append(routes, route)
+		}
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+
+		return routes[i].Method < routes[j].Method
+	})
+
+	return routes
+}
+
+// ListRoutesHandler serves GET /_routes: the ListRoutes listing, for admins only.
+func ListRoutesHandler(
+	_ context.Context, request events.APIGatewayProxyRequest,
+) (events.APIGatewayProxyResponse, error) {
+	if !handlers.IsAdmin(request) {
+		return utils.ErrorResponse(http.StatusForbidden, errors.New("admin access required"))
+	}
+
+	return utils.APIResponse(http.StatusOK, map[string][]RouteInfo{"routes": ListRoutes()})
+}
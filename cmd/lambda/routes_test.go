@@ -0,0 +1,134 @@
+package lambda
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/handlers"
+)
+
+func TestListRoutes(t *testing.T) {
+	sunset := time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)
+	saved := deprecatedRoutes
+	deprecatedRoutes = map[string]Deprecation{
+		http.MethodGet + " " + UsersIDPath: {Successor: "/v2/users/{id}", Sunset: sunset},
+		http.MethodPost + " " + UsersPath:  {},
+	}
+	t.Cleanup(func() { deprecatedRoutes = saved })
+
+	routes := ListRoutes()
+
+	// The listing holds each registered method and path exactly once, in order
+	var registered, listed []string
+	for path, methods := range routeTable(nil, nil) {
+		for method := range methods {
+			registered = append(registered, path+" "+method)
+		}
+	}
+	sort.Strings(registered)
+	for _, route := range routes {
+		listed = append(listed, route.Path+" "+route.Method)
+	}
+	if !reflect.DeepEqual(listed, registered) {
+		t.Errorf("listed routes %v, want the registered %v", listed, registered)
+	}
+	for key := range adminRoutes {
+		if !containsRoute(routes, key) {
+			t.Errorf("admin route %q is not registered", key)
+		}
+	}
+
+	tests := []struct {
+		method string
+		path   string
+		want   RouteInfo
+	}{
+		{method: http.MethodGet, path: UsersPath, want: RouteInfo{Auth: RouteAuthNone}},
+		{method: http.MethodGet, path: UsersStatsPath, want: RouteInfo{Auth: RouteAuthAdmin}},
+		{method: http.MethodGet, path: RoutesPath, want: RouteInfo{Auth: RouteAuthAdmin}},
+		{
+			method: http.MethodGet,
+			path:   UsersIDPath,
+			want:   RouteInfo{Auth: RouteAuthNone, Deprecated: true, Successor: "/v2/users/{id}", Sunset: &sunset},
+		},
+		{method: http.MethodPost, path: UsersPath, want: RouteInfo{Auth: RouteAuthNone, Deprecated: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			want := tt.want
+			want.Method, want.Path = tt.method, tt.path
+			for _, route := range routes {
+				if route.Method == tt.method && route.Path == tt.path {
+					if !reflect.DeepEqual(route, want) {
+						t.Errorf("route = %+v, want %+v", route, want)
+					}
+					return
+				}
+			}
+			t.Errorf("%s %s not listed", tt.method, tt.path)
+		})
+	}
+}
+
+// containsRoute reports whether routes lists key, a "METHOD path" route.
+func containsRoute(routes []RouteInfo, key string) bool {
+	for _, route := range routes {
+		if route.Method+" "+route.Path == key {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestListRoutesHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		adminToken string
+		token      string
+		wantStatus int
+	}{
+		{name: "admin", adminToken: "secret", token: "secret", wantStatus: http.StatusOK},
+		{name: "wrong token", adminToken: "secret", token: "guess", wantStatus: http.StatusForbidden},
+		{name: "no token", adminToken: "secret", wantStatus: http.StatusForbidden},
+		{name: "admin disabled", token: "secret", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ADMIN_TOKEN", tt.adminToken)
+
+			request := events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodGet,
+				Path:       RoutesPath,
+				Headers:    map[string]string{handlers.AdminTokenHeader: tt.token},
+			}
+			response, err := ListRoutesHandler(context.Background(), request)
+			if err != nil {
+				t.Fatalf("ListRoutesHandler() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", response.StatusCode, tt.wantStatus, response.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var listing map[string][]RouteInfo
+			if err := json.Unmarshal([]byte(response.Body), &listing); err != nil {
+				t.Fatalf("decoding %s: %v", response.Body, err)
+			}
+			if !reflect.DeepEqual(listing["routes"], ListRoutes()) {
+				t.Errorf("routes = %+v, want %+v", listing["routes"], ListRoutes())
+			}
+		})
+	}
+}
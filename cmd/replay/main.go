@@ -0,0 +1,127 @@
+// Command replay runs a logged request through the Lambda Router against an in-memory
+// repository and prints the response, to reproduce reported bugs locally.
+//
+//	go run ./cmd/replay [-users users.json] request.json
+//
+// The request file (or stdin) holds the logged request:
+//
+//	{"method": "GET", "path": "/users/{id}", "path_parameters": {"id": "1"}, "headers": {...}, "body": "..."}
+//
+// The optional users file is a JSON array of users loaded into the repository first.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	localLambda "go-lambda-api/cmd/lambda"
+	"go-lambda-api/handlers"
+	"go-lambda-api/models"
+)
+
+// loggedRequest is the structured form of a logged request.
+type loggedRequest struct {
+	Method          string              `json:"method"`
+	Path            string              `json:"path"`
+	Resource        string              `json:"resource,omitempty"`
+	Headers         map[string]string   `json:"headers,omitempty"`
+	Query           map[string]string   `json:"query,omitempty"`
+	MultiValueQuery map[string][]string `json:"multi_value_query,omitempty"`
+	PathParameters  map[string]string   `json:"path_parameters,omitempty"`
+	Body            string              `json:"body,omitempty"`
+	IsBase64Encoded bool                `json:"is_base64_encoded,omitempty"`
+}
+
+// apiGatewayRequest reconstructs the API Gateway request that was logged.
+func (r loggedRequest) apiGatewayRequest() events.APIGatewayProxyRequest {
+	resource := r.Resource
+	if resource == "" {
+		resource = r.Path
+	}
+
+	return events.APIGatewayProxyRequest{
+		Resource:                        resource,
+		Path:                            r.Path,
+		HTTPMethod:                      r.Method,
+		Headers:                         nonNil(r.Headers),
+		QueryStringParameters:           nonNil(r.Query),
+		MultiValueQueryStringParameters: r.MultiValueQuery,
+		PathParameters:                  nonNil(r.PathParameters),
+		Body:                            r.Body,
+		IsBase64Encoded:                 r.IsBase64Encoded,
+	}
+}
+
+func nonNil(m map[string]string) map[string]string {
+	if m == nil {
+		return make(map[string]string)
+	}
+
+	return m
+}
+
+func main() {
+	usersFile := flag.String("users", "", "JSON array of users to load before replaying")
+	flag.Parse()
+
+	input := io.Reader(os.Stdin)
+	if flag.NArg() > 0 {
+		file, err := os.Open(flag.Arg(0))
+		if err != nil {
+			log.Fatalf("Could not open request: %v", err)
+		}
+		defer file.Close()
+		input = file
+	}
+
+	var logged loggedRequest
+	if err := json.NewDecoder(input).Decode(&logged); err != nil {
+		log.Fatalf("Could not decode request: %v", err)
+	}
+
+	userRepo := models.NewInMemoryUserRepository()
+	if *usersFile != "" {
+		if err := loadUsers(userRepo, *usersFile); err != nil {
+			log.Fatalf("Could not load users: %v", err)
+		}
+	}
+
+	healthHandler := handlers.NewHealthHandler()
+	response, err := localLambda.Router(context.Background(), logged.apiGatewayRequest(), userRepo, healthHandler)
+	if err != nil {
+		log.Fatalf("Router returned an error: %v", err)
+	}
+
+	output, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		log.Fatalf("Could not encode response: %v", err)
+	}
+	fmt.Println(string(output))
+}
+
+func loadUsers(userRepo models.UserRepository, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var users []models.User
+	if err := json.Unmarshal(data, &users); err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		if _, err := userRepo.CreateUser(context.Background(), user); err != nil {
+			return fmt.Errorf("user %s: %w", user.ID, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	localLambda "go-lambda-api/cmd/lambda"
+	"go-lambda-api/handlers"
+	"go-lambda-api/models"
+)
+
+func TestLoggedRequestRoundTrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		logged loggedRequest
+		want   events.APIGatewayProxyRequest
+	}{
+		{
+			name: "full request",
+			logged: loggedRequest{
+				Method:          http.MethodPut,
+				Path:            "/users/user-1",
+				Resource:        "/users/{id}",
+				Headers:         map[string]string{"Content-Type": "application/json", "If-Match": `"1"`},
+				Query:           map[string]string{"fields": "name"},
+				MultiValueQuery: map[string][]string{"fields": {"name"}},
+				PathParameters:  map[string]string{"id": "user-1"},
+				Body:            `{"name":"Ann","email":"ann@example.com"}`,
+			},
+			want: events.APIGatewayProxyRequest{
+				Resource:                        "/users/{id}",
+				Path:                            "/users/user-1",
+				HTTPMethod:                      http.MethodPut,
+				Headers:                         map[string]string{"Content-Type": "application/json", "If-Match": `"1"`},
+				QueryStringParameters:           map[string]string{"fields": "name"},
+				MultiValueQueryStringParameters: map[string][]string{"fields": {"name"}},
+				PathParameters:                  map[string]string{"id": "user-1"},
+				Body:                            `{"name":"Ann","email":"ann@example.com"}`,
+			},
+		},
+		{
+			name:   "resource defaults to the path",
+			logged: loggedRequest{Method: http.MethodGet, Path: "/users"},
+			want: events.APIGatewayProxyRequest{
+				Resource:              "/users",
+				Path:                  "/users",
+				HTTPMethod:            http.MethodGet,
+				Headers:               map[string]string{},
+				QueryStringParameters: map[string]string{},
+				PathParameters:        map[string]string{},
+			},
+		},
+		{
+			name:   "base64 body",
+			logged: loggedRequest{Method: http.MethodPost, Path: "/users", Body: "e30=", IsBase64Encoded: true},
+			want: events.APIGatewayProxyRequest{
+				Resource:              "/users",
+				Path:                  "/users",
+				HTTPMethod:            http.MethodPost,
+				Headers:               map[string]string{},
+				QueryStringParameters: map[string]string{},
+				PathParameters:        map[string]string{},
+				Body:                  "e30=",
+				IsBase64Encoded:       true,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.logged)
+			if err != nil {
+				t.Fatalf("encoding request: %v", err)
+			}
+			var decoded loggedRequest
+			if err := json.Unmarshal(data, &decoded); err != nil {
+				t.Fatalf("decoding request %s: %v", data, err)
+			}
+
+			if got := decoded.apiGatewayRequest(); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("apiGatewayRequest() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplayThroughRouter(t *testing.T) {
+	models.ClearInMemoryUsers()
+	t.Cleanup(models.ClearInMemoryUsers)
+
+	usersFile := filepath.Join(t.TempDir(), "users.json")
+	users := `[{"id":"user-1","name":"Ann","email":"ann@example.com"}]`
+	if err := os.WriteFile(usersFile, []byte(users), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	userRepo := models.NewInMemoryUserRepository()
+	if err := loadUsers(userRepo, usersFile); err != nil {
+		t.Fatalf("loadUsers() error = %v", err)
+	}
+
+	tests := []struct {
+		name       string
+		logged     string
+		wantStatus int
+		wantName   string
+	}{
+		{
+			name:       "GET a loaded user",
+			logged:     `{"method":"GET","path":"/users/user-1","resource":"/users/{id}","path_parameters":{"id":"user-1"}}`,
+			wantStatus: http.StatusOK,
+			wantName:   "Ann",
+		},
+		{
+			name:       "GET a missing user",
+			logged:     `{"method":"GET","path":"/users/nobody","resource":"/users/{id}","path_parameters":{"id":"nobody"}}`,
+			wantStatus: http.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var logged loggedRequest
+			if err := json.Unmarshal([]byte(tt.logged), &logged); err != nil {
+				t.Fatalf("decoding request: %v", err)
+			}
+
+			response, err := localLambda.Router(
+				context.Background(), logged.apiGatewayRequest(), userRepo, handlers.NewHealthHandler(),
+			)
+			if err != nil {
+				t.Fatalf("Router() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", response.StatusCode, tt.wantStatus, response.Body)
+			}
+			if tt.wantName == "" {
+				return
+			}
+
+			var user models.User
+			if err := json.Unmarshal([]byte(response.Body), &user); err != nil {
+				t.Fatalf("decoding response %q: %v", response.Body, err)
+			}
+			if user.Name != tt.wantName {
+				t.Errorf("name = %q, want %q", user.Name, tt.wantName)
+			}
+		})
+	}
+}
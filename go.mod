@@ -3,15 +3,15 @@ module go-lambda-api
 go 1.22
 
 require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
 	github.com/aws/aws-lambda-go v1.47.0
 	github.com/aws/aws-sdk-go v1.55.8
 	github.com/google/uuid v1.6.0
 	github.com/joho/godotenv v1.5.1
+	github.com/lib/pq v1.10.9
+	golang.org/x/text v0.21.0
 )
 
-require (
-	github.com/jmespath/go-jmespath v0.4.0 // indirect
-)
-
+require github.com/jmespath/go-jmespath v0.4.0 // indirect
 
 replace go-lambda-api/lambdarouter => ./cmd/lambda
@@ -0,0 +1,367 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/models"
+	"go-lambda-api/utils"
+)
+
+// AdminTokenHeader is the header carrying the admin token for admin-only endpoints.
+const AdminTokenHeader = "X-Admin-Token"
+
+var (
+	errAdminForbidden      = errors.New("admin access required")
+	errImportNotConfigured = errors.New("import source is not configured")
+	errExportSaltMissing   = errors.New("EXPORT_HASH_PII requires EXPORT_HASH_SALT")
+)
+
+// AdminHandler struct holds dependencies for admin-only operations.
+type AdminHandler struct {
+	Repo    models.UserRepository
+	Objects ObjectGetter
+
+	// objectsMu guards the lazy creation of Objects, as the local server shares one
+	// handler between concurrent requests.
+	objectsMu sync.Mutex
+}
+
+// NewAdminHandler creates a new AdminHandler. The object store is created lazily on first import.
+func NewAdminHandler(userRepo models.UserRepository) *AdminHandler {
+	return &AdminHandler{Repo: userRepo}
+}
+
+// ImportResult summarizes the outcome of a user import.
+type ImportResult struct {
+	Created int            `json:"created"`
+	Skipped int            `json:"skipped"`
+	Errors  []ImportRecord `json:"errors,omitempty"`
+}
+
+// ImportRecord describes a record that was skipped during import.
+type ImportRecord struct {
+	Record int    `json:"record"`
+	Email  string `json:"email,omitempty"`
+	Error  string `json:"error"`
+}
+
+// ImportUsersHandler reads users from the configured S3 object and creates them.
+// Records that fail validation or duplicate an existing email are skipped and reported.
+// With ?atomic=true the records are created in one transaction instead: any invalid or
+// conflicting record fails the import and nothing is created.
+func (h *AdminHandler) ImportUsersHandler(
+	ctx context.Context, request events.APIGatewayProxyRequest,
+) (events.APIGatewayProxyResponse, error) {
+	if !IsAdmin(request) {
+		return utils.ErrorResponse(http.StatusForbidden, errAdminForbidden)
+	}
+
+	bucket := os.Getenv("IMPORT_S3_BUCKET")
+	key := os.Getenv("IMPORT_S3_KEY")
+	if k := request.QueryStringParameters["key"]; k != "" {
+		key = k
+	}
+	if bucket == "" || key == "" {
+		return utils.ErrorResponse(http.StatusBadRequest, errImportNotConfigured)
+	}
+
+	objects, err := h.objectGetter()
+	if err != nil {
+		return utils.ErrorResponse(objectStoreErrorStatus(err), err)
+	}
+
+	output, err := objects.GetObject(ctx, bucket, key)
+	if err != nil {
+		return utils.ErrorResponse(http.StatusBadGateway, fmt.Errorf("failed to read import object: %w", err))
+	}
+	defer output.Body.Close()
+
+	data, err := io.ReadAll(output.Body)
+	if err != nil {
+		return utils.ErrorResponse(http.StatusBadGateway, fmt.Errorf("failed to read import object: %w", err))
+	}
+
+	var records []models.UserRequest
+	if isCSVObject(key, output.ContentType) {
+		records, err = parseCSVUsers(data)
+	} else {
+		err = json.Unmarshal(data, &records)
+	}
+	if err != nil {
+		return utils.ErrorResponse(http.StatusUnprocessableEntity, fmt.Errorf("invalid import file: %w", err))
+	}
+
+	if request.QueryStringParameters["atomic"] == "true" {
+		return h.importUsersAtomic(ctx, records)
+	}
+
+	result, err := h.importUsers(ctx, records)
+	if err != nil {
+		return utils.ErrorResponse(repositoryErrorStatus(err), err)
+	}
+
+	return utils.APIResponse(http.StatusOK, result)
+}
+
+// objectGetter returns Objects, creating it with newObjectStore on first use. A failed
+// creation is retried by the next import.
+// nolint: ireturn
+func (h *AdminHandler) objectGetter() (ObjectGetter, error) {
+	h.objectsMu.Lock()
+	defer h.objectsMu.Unlock()
+
+	if h.Objects == nil {
+		objects, err := newObjectStore()
+		if err != nil {
+			return nil, err
+		}
+		h.Objects = objects
+	}
+
+	return h.Objects, nil
+}
+
+// userClearer is implemented by repositories that can be wiped, i.e. the in-memory one.
+type userClearer interface {
+	ClearUsers()
+}
+
+// ResetUsersHandler clears all users from the in-memory repository and returns how many
+// were removed. It requires ALLOW_ADMIN_RESET=true and refuses to run against any
+// repository that cannot be cleared, so it never touches DynamoDB.
+func (h *AdminHandler) ResetUsersHandler(
+	ctx context.Context, request events.APIGatewayProxyRequest,
+) (events.APIGatewayProxyResponse, error) {
+	if !IsAdmin(request) {
+		return utils.ErrorResponse(http.StatusForbidden, errAdminForbidden)
+	}
+
+	if !utils.GetEnvBool("ALLOW_ADMIN_RESET", false) {
+		return utils.ErrorResponse(http.StatusForbidden, errors.New("reset is disabled"))
+	}
+
+	if _, ok := h.Repo.(userClearer); !ok {
+		return utils.ErrorResponse(http.StatusForbidden, errors.New("reset is only supported for the in-memory repository"))
+	}
+
+	// The count comes from the delete itself, so users created meanwhile are counted if deleted
+	cleared, err := h.Repo.DeleteAllUsers(ctx)
+	if err != nil {
+		return utils.ErrorResponse(repositoryErrorStatus(err), err)
+	}
+	sharedUserCache.clear()
+	utils.ResetTestMode()
+
+	return utils.APIResponse(http.StatusOK, map[string]int{"cleared": cleared})
+}
+
+// ExportUsersHandler returns every user for analytics. With EXPORT_HASH_PII=true each email
+// is replaced by its HMAC-SHA256 keyed with EXPORT_HASH_SALT, so exports stay joinable
+// on email without revealing it. Without a salt, anyone could hash candidate addresses and
+// match them, so the export is refused with a 500 instead.
+func (h *AdminHandler) ExportUsersHandler(
+	ctx context.Context, request events.APIGatewayProxyRequest,
+) (events.APIGatewayProxyResponse, error) {
+	if !IsAdmin(request) {
+		return utils.ErrorResponse(http.StatusForbidden, errAdminForbidden)
+	}
+
+	hashEmails, salt := utils.GetEnvBool("EXPORT_HASH_PII", false), os.Getenv("EXPORT_HASH_SALT")
+	if hashEmails && salt == "" {
+		return utils.ErrorResponse(http.StatusInternalServerError, errExportSaltMissing)
+	}
+
+	users, err := models.ListAllUsers(ctx, h.Repo)
+	if err != nil {
+		return utils.ErrorResponse(repositoryErrorStatus(err), err)
+	}
+
+	if hashEmails {
+		for i := range users {
+			users[i].Email = hashPII(salt, users[i].Email)
+		}
+	}
+
+	return utils.APIResponse(http.StatusOK, users)
+}
+
+// hashPII returns the hex HMAC-SHA256 of value keyed with salt.
+func hashPII(salt, value string) string {
+	mac := hmac.New(sha256.New, []byte(salt))
+	mac.Write([]byte(value))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (h *AdminHandler) importUsers(ctx context.Context, records []models.UserRequest) (ImportResult, error) {
+	result := ImportResult{}
+
+	existing, err := models.ListAllUsers(ctx, h.Repo)
+	if err != nil {
+		return result, err
+	}
+
+	seen := make(map[string]bool, len(existing)+len(records))
+	for _, user := range existing {
+		seen[models.NormalizeEmail(user.Email)] = true
+	}
+
+	skip := func(i int, email string, err error) {
+		result.Skipped++
+		result.Errors = append(result.Errors, ImportRecord{Record: i + 1, Email: email, Error: err.Error()})
+	}
+
+	for i, record := range records {
+		record.Normalize()
+		if err := record.Validate(false); err != nil {
+			skip(i, record.Email, err)
+			continue
+		}
+
+		email := record.Email
+		if seen[email] {
+			skip(i, record.Email, errors.New("duplicate email"))
+			continue
+		}
+
+		id := record.ID
+		if id == "" {
+			id = utils.NewID()
+		}
+
+		created, err := h.Repo.CreateUser(ctx, models.User{
+			ID:        id,
+			Name:      record.Name,
+			Email:     record.Email,
+			CreatedAt: utils.Now().UTC(),
+			Metadata:  record.Metadata,
+		})
+		if err != nil {
+			skip(i, record.Email, err)
+			continue
+		}
+		sharedUserEvents.publish(UserCreated, created)
+
+		seen[email] = true
+		result.Created++
+	}
+
+	return result, nil
+}
+
+// importUsersAtomic creates every record with a single BatchCreator.CreateUsers call. Invalid
+// records are reported with 422 before anything is written.
+func (h *AdminHandler) importUsersAtomic(
+	ctx context.Context, records []models.UserRequest,
+) (events.APIGatewayProxyResponse, error) {
+	creator, ok := h.Repo.(models.BatchCreator)
+	if !ok {
+		return utils.ErrorResponse(http.StatusNotImplemented, models.ErrBatchCreateUnsupported)
+	}
+	if len(records) > models.MaxBatchCreateSize {
+		return utils.ErrorResponse(http.StatusBadRequest, models.ErrBatchTooLarge)
+	}
+
+	result := ImportResult{}
+	users := make([]models.User, 0, len(records))
+	for i, record := range records {
+		record.Normalize()
+		if err := record.Validate(false); err != nil {
+			result.Skipped++
+			result.Errors = append(result.Errors, ImportRecord{Record: i + 1, Email: record.Email, Error: err.Error()})
+			continue
+		}
+
+		id := record.ID
+		if id == "" {
+			id = utils.NewID()
+		}
+		users = append(users, models.User{
+			ID:        id,
+			Name:      record.Name,
+			Email:     record.Email,
+			CreatedAt: utils.Now().UTC(),
+			Metadata:  record.Metadata,
+		})
+	}
+	if result.Skipped > 0 {
+		return utils.APIResponse(http.StatusUnprocessableEntity, result)
+	}
+
+	created, err := creator.CreateUsers(ctx, users)
+	if err != nil {
+		return utils.ErrorResponse(repositoryErrorStatus(err), err)
+	}
+
+	for _, user := range created {
+		sharedUserEvents.publish(UserCreated, user)
+	}
+	result.Created = len(created)
+
+	return utils.APIResponse(http.StatusOK, result)
+}
+
+// IsAdmin reports whether the request carries the configured admin token.
+// Admin endpoints are disabled entirely when ADMIN_TOKEN is unset.
+func IsAdmin(request events.APIGatewayProxyRequest) bool {
+	token := os.Getenv("ADMIN_TOKEN")
+	if token == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(utils.GetHeader(request, AdminTokenHeader)), []byte(token)) == 1
+}
+
+func isCSVObject(key, contentType string) bool {
+	return strings.HasSuffix(strings.ToLower(key), ".csv") || strings.HasPrefix(contentType, "text/csv")
+}
+
+// parseCSVUsers parses a CSV file with a header row containing "name" and "email" columns.
+func parseCSVUsers(data []byte) ([]models.UserRequest, error) {
+	rows, err := csv.NewReader(bytes.NewReader(data)).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, errors.New("missing header row")
+	}
+
+	nameCol, emailCol := -1, -1
+	for i, col := range rows[0] {
+		switch strings.ToLower(strings.TrimSpace(col)) {
+		case "name":
+			nameCol = i
+		case "email":
+			emailCol = i
+		}
+	}
+	if nameCol < 0 || emailCol < 0 {
+		return nil, errors.New(`header row must contain "name" and "email" columns`)
+	}
+
+	records := make([]models.UserRequest, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		records = append(records, models.UserRequest{
+			Name:  strings.TrimSpace(row[nameCol]),
+			Email: strings.TrimSpace(row[emailCol]),
+		})
+	}
+
+	return records, nil
+}
@@ -0,0 +1,394 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"go-lambda-api/models"
+)
+
+func TestImportUsersHandler(t *testing.T) {
+	existing := models.User{ID: "existing", Name: "Existing", Email: "taken@example.com"}
+
+	tests := []struct {
+		name        string
+		key         string
+		data        string
+		contentType string
+		storeErr    error
+		admin       bool
+		wantStatus  int
+		wantCreated int
+		wantSkipped int
+		// wantMetadata is the metadata stored for ann@example.com, when checked
+		wantMetadata map[string]string
+	}{
+		{
+			name: "valid JSON records",
+			key:  "users.json",
+			data: `[{"name":"Ann","email":"ann@example.com","metadata":{"plan":"pro"}},` +
+				`{"name":"Bob","email":"bob@example.com"}]`,
+			admin:        true,
+			wantStatus:   http.StatusOK,
+			wantCreated:  2,
+			wantMetadata: map[string]string{"plan": "pro"},
+		},
+		{
+			name: "duplicate records are skipped",
+			key:  "users.json",
+			data: `[{"name":"Ann","email":"ann@example.com"},{"name":"Ann again","email":"ann@example.com"},` +
+				`{"name":"Taken","email":"taken@example.com"}]`,
+			admin:       true,
+			wantStatus:  http.StatusOK,
+			wantCreated: 1,
+			wantSkipped: 2,
+		},
+		{
+			name:        "invalid records are skipped",
+			key:         "users.json",
+			data:        `[{"name":"","email":"nobody@example.com"},{"name":"Eve","email":"not-an-email"}]`,
+			admin:       true,
+			wantStatus:  http.StatusOK,
+			wantSkipped: 2,
+		},
+		{
+			name:        "CSV by extension",
+			key:         "users.csv",
+			data:        "name,email\nAnn,ann@example.com\nBob,bob@example.com\n",
+			admin:       true,
+			wantStatus:  http.StatusOK,
+			wantCreated: 2,
+		},
+		{
+			name:        "CSV by content type",
+			key:         "users",
+			data:        "email,name\nann@example.com,Ann\n",
+			contentType: "text/csv",
+			admin:       true,
+			wantStatus:  http.StatusOK,
+			wantCreated: 1,
+		},
+		{
+			name:       "malformed file",
+			key:        "users.json",
+			data:       `{"name":`,
+			admin:      true,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "store failure",
+			key:        "users.json",
+			storeErr:   errors.New("access denied"),
+			admin:      true,
+			wantStatus: http.StatusBadGateway,
+		},
+		{
+			name:       "not an admin",
+			key:        "users.json",
+			data:       `[]`,
+			wantStatus: http.StatusForbidden,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withAdminToken(t)
+			t.Setenv("IMPORT_S3_BUCKET", "imports")
+			t.Setenv("IMPORT_S3_KEY", "")
+
+			repo := seedUsers(t, existing)
+			handler := NewAdminHandler(repo)
+			handler.Objects = &fakeObjectStore{
+				objects:     map[string][]byte{"imports/" + tt.key: []byte(tt.data)},
+				contentType: tt.contentType,
+				err:         tt.storeErr,
+			}
+
+			request := adminRequest(map[string]string{"key": tt.key})
+			if !tt.admin {
+				request.Headers = nil
+			}
+			response, err := handler.ImportUsersHandler(context.Background(), request)
+			if err != nil {
+				t.Fatalf("ImportUsersHandler() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", response.StatusCode, tt.wantStatus, response.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			result := decodeResponse[ImportResult](t, response)
+			if result.Created != tt.wantCreated || result.Skipped != tt.wantSkipped {
+				t.Errorf("created %d, skipped %d; want %d, %d", result.Created, result.Skipped, tt.wantCreated, tt.wantSkipped)
+			}
+			if len(result.Errors) != tt.wantSkipped {
+				t.Errorf("%d errors reported, want %d", len(result.Errors), tt.wantSkipped)
+			}
+			if tt.wantMetadata != nil {
+				assertStoredMetadata(t, repo, "ann@example.com", tt.wantMetadata)
+			}
+		})
+	}
+}
+
+// assertStoredMetadata fails t unless the user stored with email has metadata want.
+func assertStoredMetadata(t *testing.T, repo models.UserRepository, email string, want map[string]string) {
+	t.Helper()
+
+	user, err := repo.GetUserByEmail(context.Background(), email)
+	if err != nil {
+		t.Fatalf("GetUserByEmail(%q) error = %v", email, err)
+	}
+	if !reflect.DeepEqual(user.Metadata, want) {
+		t.Errorf("metadata of %s = %v, want %v", email, user.Metadata, want)
+	}
+}
+
+func TestImportUsersHandlerNotConfigured(t *testing.T) {
+	withAdminToken(t)
+	t.Setenv("IMPORT_S3_BUCKET", "")
+
+	handler := NewAdminHandler(seedUsers(t))
+	handler.Objects = &fakeObjectStore{}
+
+	response, _ := handler.ImportUsersHandler(context.Background(), adminRequest(nil))
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("status = %d, want %d", response.StatusCode, http.StatusBadRequest)
+	}
+}
+
+// unclearableRepository stands in for a repository without ClearUsers, such as DynamoDB.
+type unclearableRepository struct {
+	models.UserRepository
+}
+
+func TestResetUsersHandler(t *testing.T) {
+	users := []models.User{
+		{ID: "user-1", Name: "Ann", Email: "ann@example.com"},
+		{ID: "user-2", Name: "Bob", Email: "bob@example.com"},
+	}
+
+	tests := []struct {
+		name        string
+		allowReset  string
+		admin       bool
+		unclearable bool
+		wantStatus  int
+		wantCleared int
+	}{
+		{name: "clears the in-memory repository", allowReset: "true", admin: true, wantStatus: http.StatusOK,
+			wantCleared: 2},
+		{name: "flag off", allowReset: "false", admin: true, wantStatus: http.StatusForbidden},
+		{name: "flag unset", admin: true, wantStatus: http.StatusForbidden},
+		{name: "repository without ClearUsers", allowReset: "true", admin: true, unclearable: true,
+			wantStatus: http.StatusForbidden},
+		{name: "not an admin", allowReset: "true", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withAdminToken(t)
+			t.Setenv("ALLOW_ADMIN_RESET", tt.allowReset)
+
+			repo := seedUsers(t, users...)
+			handler := NewAdminHandler(repo)
+			if tt.unclearable {
+				handler.Repo = unclearableRepository{repo}
+			}
+
+			request := adminRequest(nil)
+			if !tt.admin {
+				request.Headers = nil
+			}
+			response, err := handler.ResetUsersHandler(context.Background(), request)
+			if err != nil {
+				t.Fatalf("ResetUsersHandler() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", response.StatusCode, tt.wantStatus, response.Body)
+			}
+
+			remaining, err := models.ListAllUsers(context.Background(), repo)
+			if err != nil {
+				t.Fatalf("ListAllUsers() error = %v", err)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if len(remaining) != len(users) {
+					t.Errorf("%d users remain, want %d", len(remaining), len(users))
+				}
+				return
+			}
+
+			if cleared := decodeResponse[map[string]int](t, response)["cleared"]; cleared != tt.wantCleared {
+				t.Errorf("cleared = %d, want %d", cleared, tt.wantCleared)
+			}
+			if len(remaining) != 0 {
+				t.Errorf("%d users remain, want none", len(remaining))
+			}
+		})
+	}
+}
+
+func TestExportUsersHandler(t *testing.T) {
+	users := []models.User{
+		{ID: "user-1", Name: "Ann", Email: "ann@example.com"},
+		{ID: "user-2", Name: "Bob", Email: "bob@example.com"},
+	}
+	// HMAC-SHA256 of each email keyed with "pepper"
+	hashed := map[string]string{
+		"user-1": "cea0615277db22b071c78ce70a4863989eee4f315e2224a79e29b3cf4055b7bf",
+		"user-2": "8599ff5ae8fa86932b84a3398d54fdc117400ce8a341115230360335ffad7837",
+	}
+
+	tests := []struct {
+		name       string
+		hashPII    string
+		salt       string
+		wantStatus int
+		wantHashed bool
+	}{
+		{name: "plaintext by default", wantStatus: http.StatusOK},
+		{name: "hashed with a salt", hashPII: "true", salt: "pepper", wantStatus: http.StatusOK, wantHashed: true},
+		{name: "refused without a salt", hashPII: "true", wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withAdminToken(t)
+			t.Setenv("EXPORT_HASH_PII", tt.hashPII)
+			t.Setenv("EXPORT_HASH_SALT", tt.salt)
+			handler := NewAdminHandler(seedUsers(t, users...))
+
+			export := func() []models.User {
+				t.Helper()
+				response, err := handler.ExportUsersHandler(context.Background(), adminRequest(nil))
+				if err != nil {
+					t.Fatalf("ExportUsersHandler() error = %v", err)
+				}
+				if response.StatusCode != tt.wantStatus {
+					t.Fatalf("status = %d, want %d; body %s", response.StatusCode, tt.wantStatus, response.Body)
+				}
+				if tt.wantStatus != http.StatusOK {
+					return nil
+				}
+				return decodeResponse[[]models.User](t, response)
+			}
+
+			first, second := export(), export()
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			emails := make(map[string]string, len(first))
+			for i, user := range first {
+				emails[user.ID] = user.Email
+				if second[i].ID != user.ID || second[i].Email != user.Email {
+					t.Errorf("export of %s changed from %q to %q", user.ID, user.Email, second[i].Email)
+				}
+			}
+			for _, user := range users {
+				want := user.Email
+				if tt.wantHashed {
+					want = hashed[user.ID]
+				}
+				if emails[user.ID] != want {
+					t.Errorf("email of %s = %q, want %q", user.ID, emails[user.ID], want)
+				}
+			}
+		})
+	}
+}
+
+func TestImportUsersHandlerAtomic(t *testing.T) {
+	existing := models.User{ID: "existing", Name: "Existing", Email: "taken@example.com"}
+
+	tests := []struct {
+		name        string
+		data        string
+		unbatched   bool
+		wantStatus  int
+		wantCreated int
+		wantStored  int
+		// wantMetadata is the metadata stored for ann@example.com, when checked
+		wantMetadata map[string]string
+	}{
+		{
+			name: "all created",
+			data: `[{"name":"Ann","email":"ann@example.com","metadata":{"plan":"pro"}},` +
+				`{"name":"Bob","email":"bob@example.com"}]`,
+			wantStatus:   http.StatusOK,
+			wantCreated:  2,
+			wantStored:   3,
+			wantMetadata: map[string]string{"plan": "pro"},
+		},
+		{
+			name:       "taken email aborts every record",
+			data:       `[{"name":"Ann","email":"ann@example.com"},{"name":"Taken","email":"taken@example.com"}]`,
+			wantStatus: http.StatusConflict,
+			wantStored: 1,
+		},
+		{
+			name:       "taken ID aborts every record",
+			data:       `[{"name":"Ann","email":"ann@example.com"},{"id":"existing","name":"Bob","email":"bob@example.com"}]`,
+			wantStatus: http.StatusConflict,
+			wantStored: 1,
+		},
+		{
+			name:       "invalid record aborts every record",
+			data:       `[{"name":"Ann","email":"ann@example.com"},{"name":"Eve","email":"not-an-email"}]`,
+			wantStatus: http.StatusUnprocessableEntity,
+			wantStored: 1,
+		},
+		{
+			name:       "repository without transactions",
+			data:       `[{"name":"Ann","email":"ann@example.com"}]`,
+			unbatched:  true,
+			wantStatus: http.StatusNotImplemented,
+			wantStored: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withAdminToken(t)
+			t.Setenv("IMPORT_S3_BUCKET", "imports")
+
+			repo := seedUsers(t, existing)
+			handler := NewAdminHandler(repo)
+			if tt.unbatched {
+				handler.Repo = unclearableRepository{repo}
+			}
+			handler.Objects = &fakeObjectStore{objects: map[string][]byte{"imports/users.json": []byte(tt.data)}}
+
+			request := adminRequest(map[string]string{"key": "users.json", "atomic": "true"})
+			response, err := handler.ImportUsersHandler(context.Background(), request)
+			if err != nil {
+				t.Fatalf("ImportUsersHandler() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", response.StatusCode, tt.wantStatus, response.Body)
+			}
+			if tt.wantStatus == http.StatusOK {
+				if created := decodeResponse[ImportResult](t, response).Created; created != tt.wantCreated {
+					t.Errorf("created = %d, want %d", created, tt.wantCreated)
+				}
+			}
+
+			stored, err := models.ListAllUsers(context.Background(), repo)
+			if err != nil {
+				t.Fatalf("ListAllUsers() error = %v", err)
+			}
+			if len(stored) != tt.wantStored {
+				t.Errorf("%d users stored, want %d", len(stored), tt.wantStored)
+			}
+			if tt.wantMetadata != nil {
+				assertStoredMetadata(t, repo, "ann@example.com", tt.wantMetadata)
+			}
+		})
+	}
+}
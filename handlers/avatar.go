@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/utils"
+)
+
+// DefaultAvatarMaxBytes is the maximum decoded avatar size when AVATAR_MAX_BYTES is unset.
+const DefaultAvatarMaxBytes = 1 << 20
+
+// avatarExtensions maps accepted avatar content types to object key extensions.
+var avatarExtensions = map[string]string{
+	"image/png":  ".png",
+	"image/jpeg": ".jpg",
+	"image/gif":  ".gif",
+	"image/webp": ".webp",
+}
+
+// UploadAvatarHandler stores a binary avatar image in S3 and records its URL on the user.
+// API Gateway delivers binary media types base64-encoded with IsBase64Encoded set.
+func (h *UserHandler) UploadAvatarHandler(
+	ctx context.Context, request events.APIGatewayProxyRequest,
+) (events.APIGatewayProxyResponse, error) {
+	userID := request.PathParameters["id"]
+	if userID == "" {
+		return utils.ErrorResponse(http.StatusBadRequest, errors.New("user ID is required"))
+	}
+
+	bucket := os.Getenv("AVATAR_S3_BUCKET")
+	if bucket == "" {
+		return utils.ErrorResponse(http.StatusNotImplemented, errors.New("avatar storage is not configured"))
+	}
+
+	contentType, _, err := mime.ParseMediaType(utils.GetHeader(request, "Content-Type"))
+	if err != nil {
+		return utils.ErrorResponse(http.StatusUnsupportedMediaType, errors.New("invalid content type"))
+	}
+	extension, ok := avatarExtensions[contentType]
+	if !ok {
+		return utils.ErrorResponse(
+			http.StatusUnsupportedMediaType, fmt.Errorf("unsupported avatar content type %q", contentType),
+		)
+	}
+
+	image := []byte(request.Body)
+	if request.IsBase64Encoded {
+		image, err = base64.StdEncoding.DecodeString(request.Body)
+		if err != nil {
+			return utils.ErrorResponse(http.StatusBadRequest, errors.New("invalid base64 body"))
+		}
+	}
+	if len(image) == 0 {
+		return utils.ErrorResponse(http.StatusBadRequest, errors.New("avatar image is required"))
+	}
+	if maxBytes := utils.GetEnvInt("AVATAR_MAX_BYTES", DefaultAvatarMaxBytes); len(image) > maxBytes {
+		return utils.ErrorResponse(http.StatusRequestEntityTooLarge, fmt.Errorf("avatar exceeds %d bytes", maxBytes))
+	}
+
+	user, err := h.Repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return utils.ErrorResponse(repositoryErrorStatus(err), err)
+	}
+
+	avatars, err := h.avatarPutter()
+	if err != nil {
+		return utils.ErrorResponse(objectStoreErrorStatus(err), err)
+	}
+
+	key := "avatars/" + userID + extension
+	if err := avatars.PutObject(ctx, bucket, key, contentType, image); err != nil {
+		return utils.ErrorResponse(http.StatusBadGateway, fmt.Errorf("failed to store avatar: %w", err))
+	}
+
+	user.AvatarURL = avatarURL(bucket, key)
+	user.UpdatedAt = utils.Now().UTC()
+
+	updatedUser, err := h.Repo.UpdateUser(ctx, user)
+	sharedUserCache.invalidate(userID)
+	if err != nil {
+		return utils.ErrorResponse(repositoryErrorStatus(err), err)
+	}
+	sharedUserEvents.publish(UserUpdated, updatedUser)
+
+	return utils.APIResponseNegotiated(request, http.StatusOK, renderUser(request, updatedUser))
+}
+
+// avatarPutter returns Avatars, creating it with newObjectStore on first use. A failed
+// creation is retried by the next upload.
+// nolint: ireturn
+func (h *UserHandler) avatarPutter() (ObjectPutter, error) {
+	h.avatarsMu.Lock()
+	defer h.avatarsMu.Unlock()
+
+	if h.Avatars == nil {
+		avatars, err := newObjectStore()
+		if err != nil {
+			return nil, err
+		}
+		h.Avatars = avatars
+	}
+
+	return h.Avatars, nil
+}
+
+// objectStoreErrorStatus maps an error from newObjectStore to its HTTP status.
+func objectStoreErrorStatus(err error) int {
+	if errors.Is(err, errObjectStoreUnavailable) {
+		return http.StatusNotImplemented
+	}
+
+	return http.StatusInternalServerError
+}
+
+// avatarURL returns the public URL of an avatar object, using AVATAR_BASE_URL when set
+// (e.g. a CloudFront distribution) and the S3 virtual-hosted URL otherwise.
+func avatarURL(bucket, key string) string {
+	if baseURL := os.Getenv("AVATAR_BASE_URL"); baseURL != "" {
+		return strings.TrimSuffix(baseURL, "/") + "/" + key
+	}
+
+	return fmt.Sprintf("https://%s.s3.%s.amazonaws.com/%s", bucket, awsRegion(), key)
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/models"
+)
+
+func TestUploadAvatarHandler(t *testing.T) {
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	existing := models.User{ID: "user-1", Name: "Ann", Email: "ann@example.com"}
+
+	tests := []struct {
+		name        string
+		id          string
+		contentType string
+		body        string
+		base64      bool
+		storeErr    error
+		wantStatus  int
+		wantKey     string
+		wantImage   []byte
+	}{
+		{
+			name:        "base64-encoded PNG",
+			id:          existing.ID,
+			contentType: "image/png",
+			body:        base64.StdEncoding.EncodeToString(png),
+			base64:      true,
+			wantStatus:  http.StatusOK,
+			wantKey:     "avatars/user-1.png",
+			wantImage:   png,
+		},
+		{
+			name:        "raw body",
+			id:          existing.ID,
+			contentType: "image/jpeg",
+			body:        "jpeg bytes",
+			wantStatus:  http.StatusOK,
+			wantKey:     "avatars/user-1.jpg",
+			wantImage:   []byte("jpeg bytes"),
+		},
+		{
+			name:        "invalid base64",
+			id:          existing.ID,
+			contentType: "image/png",
+			body:        "not base64!",
+			base64:      true,
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "unsupported content type",
+			id:          existing.ID,
+			contentType: "text/plain",
+			body:        "hello",
+			wantStatus:  http.StatusUnsupportedMediaType,
+		},
+		{
+			name:        "too large",
+			id:          existing.ID,
+			contentType: "image/png",
+			body:        base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 65)),
+			base64:      true,
+			wantStatus:  http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:        "empty image",
+			id:          existing.ID,
+			contentType: "image/png",
+			wantStatus:  http.StatusBadRequest,
+		},
+		{
+			name:        "unknown user",
+			id:          "missing",
+			contentType: "image/png",
+			body:        "png bytes",
+			wantStatus:  http.StatusNotFound,
+		},
+		{
+			name:        "store failure",
+			id:          existing.ID,
+			contentType: "image/png",
+			body:        "png bytes",
+			storeErr:    errors.New("access denied"),
+			wantStatus:  http.StatusBadGateway,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("AVATAR_S3_BUCKET", "avatars-bucket")
+			t.Setenv("AVATAR_BASE_URL", "https://cdn.example.com/")
+			t.Setenv("AVATAR_MAX_BYTES", "64")
+
+			repo := seedUsers(t, existing)
+			store := &fakeObjectStore{err: tt.storeErr}
+			handler := &UserHandler{Repo: repo, Avatars: store}
+
+			response, err := handler.UploadAvatarHandler(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod:      http.MethodPut,
+				PathParameters:  map[string]string{"id": tt.id},
+				Headers:         map[string]string{"Content-Type": tt.contentType},
+				Body:            tt.body,
+				IsBase64Encoded: tt.base64,
+			})
+			if err != nil {
+				t.Fatalf("UploadAvatarHandler() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", response.StatusCode, tt.wantStatus, response.Body)
+			}
+			if tt.wantKey == "" {
+				if len(store.objects) != 0 {
+					t.Errorf("stored %d objects, want none", len(store.objects))
+				}
+				return
+			}
+
+			if got := store.objects["avatars-bucket/"+tt.wantKey]; !bytes.Equal(got, tt.wantImage) {
+				t.Errorf("stored image = %q, want %q", got, tt.wantImage)
+			}
+			if store.contentType != tt.contentType {
+				t.Errorf("stored content type = %q, want %q", store.contentType, tt.contentType)
+			}
+			wantURL := "https://cdn.example.com/" + tt.wantKey
+			if got := decodeResponse[models.User](t, response).AvatarURL; got != wantURL {
+				t.Errorf("response avatar_url = %q, want %q", got, wantURL)
+			}
+			if stored, _ := repo.GetUserByID(context.Background(), tt.id); stored.AvatarURL != wantURL {
+				t.Errorf("stored avatar_url = %q, want %q", stored.AvatarURL, wantURL)
+			}
+		})
+	}
+}
+
+func TestUploadAvatarHandlerNotConfigured(t *testing.T) {
+	t.Setenv("AVATAR_S3_BUCKET", "")
+
+	handler := NewUserHandler(seedUsers(t))
+	response, err := handler.UploadAvatarHandler(context.Background(), events.APIGatewayProxyRequest{
+		PathParameters: map[string]string{"id": "user-1"},
+		Headers:        map[string]string{"Content-Type": "image/png"},
+		Body:           "png bytes",
+	})
+	if err != nil {
+		t.Fatalf("UploadAvatarHandler() error = %v", err)
+	}
+	if response.StatusCode != http.StatusNotImplemented {
+		t.Errorf("status = %d, want %d", response.StatusCode, http.StatusNotImplemented)
+	}
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/models"
+	"go-lambda-api/utils"
+)
+
+// DefaultBatchCreateMaxUsers is the maximum number of users accepted by POST /users/batch
+// when BATCH_CREATE_MAX_USERS is unset.
+const DefaultBatchCreateMaxUsers = 100
+
+// BatchCreateItem is the outcome of one entry of a POST /users/batch request: the status a
+// POST /users of that entry would have returned, and the created user or the error.
+type BatchCreateItem struct {
+	Index  int         `json:"index"`
+	Status int         `json:"status"`
+	User   interface{} `json:"user,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// BatchCreateResult is the response of POST /users/batch, with one item per entry in
+// request order.
+type BatchCreateResult struct {
+	Created int               `json:"created"`
+	Failed  int               `json:"failed"`
+	Results []BatchCreateItem `json:"results"`
+}
+
+// BatchCreateUsersHandler creates the users of a JSON array of user requests. Entries succeed
+// or fail independently, so a partial batch can be fixed up and resent: the response is 201
+// when every user was created and 207 Multi-Status otherwise.
+func (h *UserHandler) BatchCreateUsersHandler(
+	ctx context.Context, request events.APIGatewayProxyRequest,
+) (events.APIGatewayProxyResponse, error) {
+	body, err := jsonBody(request)
+	if err != nil {
+		return utils.ErrorResponse(bodyErrorStatus(err), err)
+	}
+
+	var userReqs []models.UserRequest
+	if err := decodeBody(ctx, body, &userReqs); err != nil {
+		return utils.ErrorResponse(http.StatusBadRequest, err)
+	}
+	if len(userReqs) == 0 {
+		return utils.ErrorResponse(http.StatusBadRequest, errors.New("at least one user is required"))
+	}
+	if maxUsers := utils.GetEnvInt("BATCH_CREATE_MAX_USERS", DefaultBatchCreateMaxUsers); len(userReqs) > maxUsers {
+		return utils.ErrorResponse(http.StatusBadRequest, fmt.Errorf("at most %d users may be created at once", maxUsers))
+	}
+
+	result := BatchCreateResult{Results: make([]BatchCreateItem, len(userReqs))}
+	fail := func(i, status int, err error) {
+		result.Failed++
+		result.Results[i] = BatchCreateItem{Index: i, Status: status, Error: err.Error()}
+	}
+
+	users := make([]models.User, 0, len(userReqs))
+	indexes := make([]int, 0, len(userReqs))
+	for i, userReq := range userReqs {
+		userReq.Normalize()
+		if err := userReq.Validate(false); err != nil {
+			fail(i, validationStatus(err), err)
+			continue
+		}
+
+		id := userReq.ID
+		if id == "" {
+			id = utils.NewID()
+		}
+		users = append(users, models.User{
+			ID:        id,
+			Name:      userReq.Name,
+			Email:     userReq.Email,
+			CreatedAt: utils.Now().UTC(),
+			Metadata:  userReq.Metadata,
+		})
+		indexes = append(indexes, i)
+	}
+
+	for j, created := range models.BulkCreateUsers(ctx, h.Repo, users) {
+		i := indexes[j]
+		if created.Err != nil {
+			fail(i, repositoryErrorStatus(created.Err), created.Err)
+			continue
+		}
+		sharedUserEvents.publish(UserCreated, created.User)
+
+		result.Created++
+		result.Results[i] = BatchCreateItem{Index: i, Status: http.StatusCreated, User: renderUser(request, created.User)}
+	}
+
+	status := http.StatusCreated
+	if result.Failed > 0 {
+		status = http.StatusMultiStatus
+	}
+
+	return utils.APIResponseNegotiated(request, status, result)
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"go-lambda-api/models"
+)
+
+func TestBatchCreateUsersHandler(t *testing.T) {
+	existing := models.User{ID: "existing", Name: "Existing", Email: "taken@example.com"}
+
+	tests := []struct {
+		name         string
+		body         string
+		maxUsers     string
+		wantStatus   int
+		wantStatuses []int
+		wantStored   int
+	}{
+		{
+			name:         "all created",
+			body:         `[{"name":"Ann","email":"ann@example.com"},{"name":"Bob","email":"bob@example.com"}]`,
+			wantStatus:   http.StatusCreated,
+			wantStatuses: []int{http.StatusCreated, http.StatusCreated},
+			wantStored:   3,
+		},
+		{
+			name: "mixed batch with one invalid entry",
+			body: `[{"name":"Ann","email":"ann@example.com"},{"name":"Eve","email":"not-an-email"},` +
+				`{"name":"Bob","email":"bob@example.com"}]`,
+			wantStatus:   http.StatusMultiStatus,
+			wantStatuses: []int{http.StatusCreated, http.StatusUnprocessableEntity, http.StatusCreated},
+			wantStored:   3,
+		},
+		{
+			name: "taken and repeated emails",
+			body: `[{"name":"Ann","email":"ann@example.com"},{"name":"Taken","email":"taken@example.com"},` +
+				`{"name":"Ann again","email":"ann@example.com"}]`,
+			wantStatus:   http.StatusMultiStatus,
+			wantStatuses: []int{http.StatusCreated, http.StatusConflict, http.StatusConflict},
+			wantStored:   2,
+		},
+		{name: "empty batch", body: `[]`, wantStatus: http.StatusBadRequest, wantStored: 1},
+		{name: "not an array", body: `{"name":"Ann"}`, wantStatus: http.StatusBadRequest, wantStored: 1},
+		{
+			name:       "too many users",
+			body:       `[{"name":"Ann","email":"ann@example.com"},{"name":"Bob","email":"bob@example.com"}]`,
+			maxUsers:   "1",
+			wantStatus: http.StatusBadRequest,
+			wantStored: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("BATCH_CREATE_MAX_USERS", tt.maxUsers)
+			repo := seedUsers(t, existing)
+			handler := NewUserHandler(repo)
+
+			response, err := handler.BatchCreateUsersHandler(context.Background(), jsonRequest(http.MethodPost, tt.body))
+			if err != nil {
+				t.Fatalf("BatchCreateUsersHandler() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", response.StatusCode, tt.wantStatus, response.Body)
+			}
+
+			if tt.wantStatuses != nil {
+				result := decodeResponse[BatchCreateResult](t, response)
+				if len(result.Results) != len(tt.wantStatuses) {
+					t.Fatalf("%d results, want %d", len(result.Results), len(tt.wantStatuses))
+				}
+				created := 0
+				for i, item := range result.Results {
+					if item.Index != i || item.Status != tt.wantStatuses[i] {
+						t.Errorf("result %d = index %d status %d, want index %d status %d",
+							i, item.Index, item.Status, i, tt.wantStatuses[i])
+					}
+					if (item.Status == http.StatusCreated) != (item.User != nil) || (item.User == nil) == (item.Error == "") {
+						t.Errorf("result %d has user %v and error %q", i, item.User, item.Error)
+					}
+					if item.Status == http.StatusCreated {
+						created++
+					}
+				}
+				if result.Created != created || result.Failed != len(tt.wantStatuses)-created {
+					t.Errorf("created %d, failed %d; want %d, %d", result.Created, result.Failed, created,
+						len(tt.wantStatuses)-created)
+				}
+			}
+
+			stored, err := models.ListAllUsers(context.Background(), repo)
+			if err != nil {
+				t.Fatalf("ListAllUsers() error = %v", err)
+			}
+			if len(stored) != tt.wantStored {
+				t.Errorf("%d users stored, want %d", len(stored), tt.wantStored)
+			}
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/models"
+	"go-lambda-api/utils"
+)
+
+const (
+	// DefaultBatchGetConcurrency is the number of concurrent lookups for ?ids= when
+	// BATCH_GET_CONCURRENCY is unset.
+	DefaultBatchGetConcurrency = 8
+
+	// DefaultBatchGetMaxIDs is the maximum number of IDs accepted by ?ids= when
+	// BATCH_GET_MAX_IDS is unset.
+	DefaultBatchGetMaxIDs = 100
+)
+
+// batchGetResult is the outcome of looking up one requested ID.
+type batchGetResult struct {
+	user models.User
+	err  error
+}
+
+// batchGetMiss stands in for a requested ID that does not exist, so the response stays
+// aligned with the requested IDs.
+type batchGetMiss struct {
+	ID    string `json:"id"`
+	Found bool   `json:"found"`
+}
+
+// parseIDs splits a comma-separated ?ids= value, dropping empty entries.
+func parseIDs(value string) []string {
+	var ids []string
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+
+	return ids
+}
+
+// batchGetUsersHandler returns one entry per ID of ?ids=a,b,c, in the requested order, so
+// clients can map results positionally: the user, or a batchGetMiss for an ID that does
+// not exist. Repeated IDs get an entry each time.
+func (h *UserHandler) batchGetUsersHandler(
+	ctx context.Context, request events.APIGatewayProxyRequest,
+) (events.APIGatewayProxyResponse, error) {
+	ids := parseIDs(request.QueryStringParameters["ids"])
+	if maxIDs := utils.GetEnvInt("BATCH_GET_MAX_IDS", DefaultBatchGetMaxIDs); len(ids) > maxIDs {
+		return utils.ErrorResponse(http.StatusBadRequest, fmt.Errorf("at most %d ids may be requested", maxIDs))
+	}
+
+	results := fetchUsers(ctx, h.Repo, ids, utils.GetEnvInt("BATCH_GET_CONCURRENCY", DefaultBatchGetConcurrency))
+
+	rendered := make([]interface{}, len(results))
+	for i, result := range results {
+		if errors.Is(result.err, models.ErrUserNotFound) {
+			rendered[i] = batchGetMiss{ID: ids[i]}
+			continue
+		}
+		if result.err != nil {
+			return utils.ErrorResponse(repositoryErrorStatus(result.err), result.err)
+		}
+		rendered[i] = renderUser(request, result.user)
+	}
+
+	return utils.APIResponseNegotiated(request, http.StatusOK, rendered)
+}
+
+// fetchUsers looks up ids with at most concurrency lookups in flight and returns one result
+// per ID, in the order requested. IDs not started before ctx is done fail with ctx's error.
+func fetchUsers(ctx context.Context, repo models.UserRepository, ids []string, concurrency int) []batchGetResult {
+	results := make([]batchGetResult, len(ids))
+	concurrency = max(min(concurrency, len(ids)), 1)
+
+	// Each worker writes only the indexes it receives, so results needs no lock
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = fetchUser(ctx, repo, ids[i])
+			}
+		}()
+	}
+
+	for i := range ids {
+		select {
+		case indexes <- i:
+		case <-ctx.Done():
+			results[i] = batchGetResult{err: ctx.Err()}
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	return results
+}
+
+// fetchUser reads a single user through the shared cache, like GetUserHandler.
+func fetchUser(ctx context.Context, repo models.UserRepository, id string) batchGetResult {
+	if user, ok := sharedUserCache.get(id); ok {
+		return batchGetResult{user: user}
+	}
+
+	user, err := repo.GetUserByID(ctx, id)
+	if err != nil {
+		return batchGetResult{err: err}
+	}
+	sharedUserCache.set(user)
+
+	return batchGetResult{user: user}
+}
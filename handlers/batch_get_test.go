@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/models"
+)
+
+// inFlightRepository records the most GetUserByID calls in flight at once.
+type inFlightRepository struct {
+	models.UserRepository
+
+	inFlight    atomic.Int32
+	maxInFlight atomic.Int32
+}
+
+func (r *inFlightRepository) GetUserByID(ctx context.Context, id string) (models.User, error) {
+	n := r.inFlight.Add(1)
+	defer r.inFlight.Add(-1)
+	for {
+		highest := r.maxInFlight.Load()
+		if n <= highest || r.maxInFlight.CompareAndSwap(highest, n) {
+			break
+		}
+	}
+	time.Sleep(time.Millisecond)
+
+	return r.UserRepository.GetUserByID(ctx, id)
+}
+
+func TestFetchUsers(t *testing.T) {
+	users := make([]models.User, 100)
+	for i := range users {
+		users[i] = models.User{ID: fmt.Sprintf("user-%03d", i), Name: "User", Email: fmt.Sprintf("user%d@example.com", i)}
+	}
+
+	// Every user in reverse, with misses and repeats mixed in
+	ids := make([]string, 0, 2*len(users))
+	for i := len(users) - 1; i >= 0; i-- {
+		ids = append(ids, users[i].ID)
+		if i%10 == 0 {
+			ids = append(ids, fmt.Sprintf("missing-%d", i), users[i].ID)
+		}
+	}
+
+	tests := []struct {
+		name        string
+		concurrency int
+		wantMax     int32
+	}{
+		{name: "serial", concurrency: 1, wantMax: 1},
+		{name: "default concurrency", concurrency: DefaultBatchGetConcurrency, wantMax: DefaultBatchGetConcurrency},
+		{name: "more workers than IDs", concurrency: 1000, wantMax: int32(len(ids))},
+		{name: "invalid concurrency runs serially", concurrency: 0, wantMax: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &inFlightRepository{UserRepository: seedUsers(t, users...)}
+
+			results := fetchUsers(context.Background(), repo, ids, tt.concurrency)
+
+			if len(results) != len(ids) {
+				t.Fatalf("got %d results, want %d", len(results), len(ids))
+			}
+			for i, id := range ids {
+				result := results[i]
+				if strings.HasPrefix(id, "missing-") {
+					if !errors.Is(result.err, models.ErrUserNotFound) {
+						t.Errorf("result %d for %s: error = %v, want not found", i, id, result.err)
+					}
+					continue
+				}
+				if result.err != nil || result.user.ID != id {
+					t.Errorf("result %d = %q, %v; want %q", i, result.user.ID, result.err, id)
+				}
+			}
+			if highest := repo.maxInFlight.Load(); highest > tt.wantMax {
+				t.Errorf("%d lookups in flight at once, want at most %d", highest, tt.wantMax)
+			}
+		})
+	}
+}
+
+func TestGetAllUsersHandlerIDsAlignment(t *testing.T) {
+	users := []models.User{
+		{ID: "user-1", Name: "Ann", Email: "ann@example.com"},
+		{ID: "user-2", Name: "Bob", Email: "bob@example.com"},
+	}
+
+	tests := []struct {
+		name       string
+		ids        string
+		maxIDs     string
+		wantStatus int
+		want       []map[string]any
+	}{
+		{
+			name:       "found and missing in requested order",
+			ids:        "user-2,missing-1,user-1,missing-2",
+			wantStatus: http.StatusOK,
+			want: []map[string]any{
+				{"id": "user-2", "name": "Bob"},
+				{"id": "missing-1", "found": false},
+				{"id": "user-1", "name": "Ann"},
+				{"id": "missing-2", "found": false},
+			},
+		},
+		{
+			name:       "repeated IDs",
+			ids:        "user-1, missing-1,user-1,missing-1",
+			wantStatus: http.StatusOK,
+			want: []map[string]any{
+				{"id": "user-1", "name": "Ann"},
+				{"id": "missing-1", "found": false},
+				{"id": "user-1", "name": "Ann"},
+				{"id": "missing-1", "found": false},
+			},
+		},
+		{
+			name:       "all missing",
+			ids:        "missing-1",
+			wantStatus: http.StatusOK,
+			want:       []map[string]any{{"id": "missing-1", "found": false}},
+		},
+		{name: "too many IDs", ids: "user-1,user-2", maxIDs: "1", wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("BATCH_GET_MAX_IDS", tt.maxIDs)
+			sharedUserCache.clear()
+			t.Cleanup(sharedUserCache.clear)
+			handler := NewUserHandler(seedUsers(t, users...))
+
+			request := events.APIGatewayProxyRequest{
+				HTTPMethod:            http.MethodGet,
+				QueryStringParameters: map[string]string{"ids": tt.ids},
+			}
+			response, err := handler.GetAllUsersHandler(context.Background(), request)
+			if err != nil {
+				t.Fatalf("GetAllUsersHandler() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", response.StatusCode, tt.wantStatus, response.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			entries := decodeResponse[[]map[string]any](t, response)
+			if len(entries) != len(tt.want) {
+				t.Fatalf("got %d entries, want %d: %s", len(entries), len(tt.want), response.Body)
+			}
+			for i, want := range tt.want {
+				got := make(map[string]any, len(want))
+				for key := range want {
+					got[key] = entries[i][key]
+				}
+				if !reflect.DeepEqual(got, want) {
+					t.Errorf("entry %d = %v, want %v", i, entries[i], want)
+				}
+			}
+		})
+	}
+}
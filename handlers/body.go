@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/utils"
+)
+
+// DefaultMaxBodyBytes is the largest JSON request body accepted when MAX_BODY_BYTES is unset.
+const DefaultMaxBodyBytes = 64 << 10
+
+var (
+	errBodyTooLarge      = errors.New("request body too large")
+	errInvalidBase64Body = errors.New("invalid base64 body")
+)
+
+// jsonBody returns the JSON body of request, decoding it when API Gateway delivered it
+// base64-encoded. Bodies over MAX_BODY_BYTES, measured after decoding, are rejected with
+// errBodyTooLarge before they are decoded or parsed.
+func jsonBody(request events.APIGatewayProxyRequest) ([]byte, error) {
+	size := utils.BodyLength(request.Body, request.IsBase64Encoded)
+	if maxBytes := utils.GetEnvInt("MAX_BODY_BYTES", DefaultMaxBodyBytes); size > maxBytes {
+		return nil, fmt.Errorf("%w: exceeds %d bytes", errBodyTooLarge, maxBytes)
+	}
+
+	if !request.IsBase64Encoded {
+		return []byte(request.Body), nil
+	}
+
+	body, err := base64.StdEncoding.DecodeString(request.Body)
+	if err != nil {
+		return nil, errInvalidBase64Body
+	}
+
+	return body, nil
+}
+
+// bodyErrorStatus maps an error from jsonBody to its HTTP status.
+func bodyErrorStatus(err error) int {
+	if errors.Is(err, errBodyTooLarge) {
+		return http.StatusRequestEntityTooLarge
+	}
+
+	return http.StatusBadRequest
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/models"
+)
+
+func TestJSONBody(t *testing.T) {
+	atLimit := strings.Repeat("a", DefaultMaxBodyBytes)
+
+	tests := []struct {
+		name     string
+		body     string
+		base64   bool
+		maxBytes string
+		wantErr  error
+	}{
+		{name: "just under the default limit", body: atLimit[1:]},
+		{name: "at the default limit", body: atLimit},
+		{name: "just over the default limit", body: atLimit + "a", wantErr: errBodyTooLarge},
+		{name: "under a configured limit", body: "0123456789", maxBytes: "10"},
+		{name: "over a configured limit", body: "0123456789a", maxBytes: "10", wantErr: errBodyTooLarge},
+		{
+			name:   "base64 measured decoded",
+			body:   base64.StdEncoding.EncodeToString([]byte(atLimit)),
+			base64: true,
+		},
+		{
+			name:    "base64 over the limit once decoded",
+			body:    base64.StdEncoding.EncodeToString([]byte(atLimit + "a")),
+			base64:  true,
+			wantErr: errBodyTooLarge,
+		},
+		{name: "invalid base64", body: "not base64!", base64: true, wantErr: errInvalidBase64Body},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MAX_BODY_BYTES", tt.maxBytes)
+
+			body, err := jsonBody(events.APIGatewayProxyRequest{Body: tt.body, IsBase64Encoded: tt.base64})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("jsonBody() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			want := tt.body
+			if tt.base64 {
+				decoded, _ := base64.StdEncoding.DecodeString(tt.body)
+				want = string(decoded)
+			}
+			if string(body) != want {
+				t.Errorf("jsonBody() returned %d bytes, want %d", len(body), len(want))
+			}
+		})
+	}
+}
+
+func TestUserHandlerBodyLimit(t *testing.T) {
+	existing := models.User{ID: "user-1", Name: "Ann", Email: "ann@example.com"}
+	body := `{"name":"Bob","email":"bob@example.com"}`
+
+	tests := []struct {
+		name       string
+		method     string
+		maxBytes   int
+		wantStatus int
+	}{
+		{name: "create just under", method: http.MethodPost, maxBytes: len(body), wantStatus: http.StatusCreated},
+		{
+			name:       "create just over",
+			method:     http.MethodPost,
+			maxBytes:   len(body) - 1,
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+		{name: "update just under", method: http.MethodPut, maxBytes: len(body), wantStatus: http.StatusOK},
+		{
+			name:       "update just over",
+			method:     http.MethodPut,
+			maxBytes:   len(body) - 1,
+			wantStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MAX_BODY_BYTES", strconv.Itoa(tt.maxBytes))
+			handler := NewUserHandler(seedUsers(t, existing))
+
+			request := jsonRequest(tt.method, body)
+			handle := handler.CreateUserHandler
+			if tt.method == http.MethodPut {
+				request.PathParameters = map[string]string{"id": existing.ID}
+				handle = handler.UpdateUserHandler
+			}
+			response, err := handle(context.Background(), request)
+			if err != nil {
+				t.Fatalf("handler error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", response.StatusCode, tt.wantStatus, response.Body)
+			}
+		})
+	}
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+
+	"go-lambda-api/models"
+	"go-lambda-api/utils"
+)
+
+// DefaultUserCacheMaxEntries is the number of users cached when USER_CACHE_MAX_ENTRIES is
+// unset.
+const DefaultUserCacheMaxEntries = 10000
+
+// userCache is a concurrency-safe read-through cache for GetUserByID. Expired entries are
+// dropped when read and whenever the cache is full.
+type userCache struct {
+	mu      sync.RWMutex
+	entries map[string]userCacheEntry
+}
+
+type userCacheEntry struct {
+	user    models.User
+	expires time.Time
+}
+
+// sharedUserCache is shared by every handler in the process, so a mutation through one
+// UserHandler invalidates reads through any other.
+var sharedUserCache = &userCache{entries: make(map[string]userCacheEntry)}
+
+// userCacheTTL returns USER_CACHE_TTL_MS; zero (the default) disables caching.
+func userCacheTTL() time.Duration {
+	return time.Duration(utils.GetEnvInt("USER_CACHE_TTL_MS", 0)) * time.Millisecond
+}
+
+// userCacheMaxEntries returns USER_CACHE_MAX_ENTRIES, defaulting to
+// DefaultUserCacheMaxEntries.
+func userCacheMaxEntries() int {
+	return max(utils.GetEnvInt("USER_CACHE_MAX_ENTRIES", DefaultUserCacheMaxEntries), 1)
+}
+
+func (c *userCache) get(id string) (models.User, bool) {
+	c.mu.RLock()
+	entry, ok := c.entries[id]
+	c.mu.RUnlock()
+	if !ok {
+		return models.User{}, false
+	}
+
+	if time.Now().After(entry.expires) {
+		c.mu.Lock()
+		// A concurrent set may have refreshed the entry since it was read
+		if current, ok := c.entries[id]; ok && time.Now().After(current.expires) {
+			delete(c.entries, id)
+		}
+		c.mu.Unlock()
+
+		return models.User{}, false
+	}
+
+	return entry.user, true
+}
+
+// set caches user for USER_CACHE_TTL_MS. A read that raced an update may try to cache the
+// user it read before the update, so a live entry at a higher version is kept instead.
+func (c *userCache) set(user models.User) {
+	ttl := userCacheTTL()
+	if ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if current, ok := c.entries[user.ID]; ok && !now.After(current.expires) && current.user.Version > user.Version {
+		return
+	}
+	if _, ok := c.entries[user.ID]; !ok && len(c.entries) >= userCacheMaxEntries() {
+		c.evict(now)
+	}
+
+	c.entries[user.ID] = userCacheEntry{user: user, expires: now.Add(ttl)}
+}
+
+// evict makes room for one entry: it drops every expired entry and, when none had expired,
+// the entry closest to expiry, which with one TTL for all entries is the oldest.
+func (c *userCache) evict(now time.Time) {
+	oldest := ""
+	for id, entry := range c.entries {
+		if now.After(entry.expires) {
+			delete(c.entries, id)
+		} else if oldest == "" || entry.expires.Before(c.entries[oldest].expires) {
+			oldest = id
+		}
+	}
+
+	if len(c.entries) >= userCacheMaxEntries() && oldest != "" {
+		delete(c.entries, oldest)
+	}
+}
+
+func (c *userCache) invalidate(id string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, id)
+}
+
+func (c *userCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]userCacheEntry)
+}
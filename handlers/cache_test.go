@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/models"
+)
+
+func TestSharedUserCacheInvalidation(t *testing.T) {
+	existing := models.User{ID: "user-1", Name: "Ann", Email: "ann@example.com"}
+
+	tests := []struct {
+		name       string
+		mutate     func(t *testing.T, writer *UserHandler, repo models.UserRepository)
+		wantStatus int
+		wantName   string
+	}{
+		{
+			name: "PUT through another handler",
+			mutate: func(t *testing.T, writer *UserHandler, _ models.UserRepository) {
+				t.Helper()
+				request := jsonRequest(http.MethodPut, `{"name":"Ann Updated"}`)
+				request.PathParameters = map[string]string{"id": existing.ID}
+				if response, _ := writer.UpdateUserHandler(context.Background(), request); response.StatusCode != http.StatusOK {
+					t.Fatalf("PUT status = %d, body %s", response.StatusCode, response.Body)
+				}
+			},
+			wantStatus: http.StatusOK,
+			wantName:   "Ann Updated",
+		},
+		{
+			name: "PATCH through another handler",
+			mutate: func(t *testing.T, writer *UserHandler, _ models.UserRepository) {
+				t.Helper()
+				request := jsonRequest(http.MethodPatch, `{"name":"Ann Patched"}`)
+				request.PathParameters = map[string]string{"id": existing.ID}
+				if response, _ := writer.PatchUserHandler(context.Background(), request); response.StatusCode != http.StatusOK {
+					t.Fatalf("PATCH status = %d, body %s", response.StatusCode, response.Body)
+				}
+			},
+			wantStatus: http.StatusOK,
+			wantName:   "Ann Patched",
+		},
+		{
+			name: "DELETE through another handler",
+			mutate: func(t *testing.T, writer *UserHandler, _ models.UserRepository) {
+				t.Helper()
+				request := events.APIGatewayProxyRequest{
+					HTTPMethod:     http.MethodDelete,
+					PathParameters: map[string]string{"id": existing.ID},
+				}
+				if response, _ := writer.DeleteUserHandler(context.Background(), request); response.StatusCode >= 300 {
+					t.Fatalf("DELETE status = %d, body %s", response.StatusCode, response.Body)
+				}
+			},
+			wantStatus: http.StatusNotFound,
+		},
+		{
+			name: "write bypassing the handlers is served from the cache",
+			mutate: func(t *testing.T, _ *UserHandler, repo models.UserRepository) {
+				t.Helper()
+				user, err := repo.GetUserByID(context.Background(), existing.ID)
+				if err != nil {
+					t.Fatalf("GetUserByID() error = %v", err)
+				}
+				user.Name = "Ann Bypassed"
+				if _, err := repo.UpdateUser(context.Background(), user); err != nil {
+					t.Fatalf("UpdateUser() error = %v", err)
+				}
+			},
+			wantStatus: http.StatusOK,
+			wantName:   "Ann",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("USER_CACHE_TTL_MS", "60000")
+			sharedUserCache.clear()
+			t.Cleanup(sharedUserCache.clear)
+
+			repo := seedUsers(t, existing)
+			reader, writer := NewUserHandler(repo), NewUserHandler(repo)
+			get := events.APIGatewayProxyRequest{
+				HTTPMethod:     http.MethodGet,
+				PathParameters: map[string]string{"id": existing.ID},
+			}
+
+			// Fill the cache through the reader
+			if response, _ := reader.GetUserHandler(context.Background(), get); response.StatusCode != http.StatusOK {
+				t.Fatalf("first GET status = %d, body %s", response.StatusCode, response.Body)
+			}
+
+			tt.mutate(t, writer, repo)
+
+			response, err := reader.GetUserHandler(context.Background(), get)
+			if err != nil {
+				t.Fatalf("GetUserHandler() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", response.StatusCode, tt.wantStatus, response.Body)
+			}
+			if tt.wantStatus == http.StatusOK {
+				if got := decodeResponse[models.User](t, response).Name; got != tt.wantName {
+					t.Errorf("name = %q, want %q", got, tt.wantName)
+				}
+			}
+		})
+	}
+}
+
+func TestUserCacheSet(t *testing.T) {
+	tests := []struct {
+		name       string
+		maxEntries string
+		cached     []models.User
+		expired    bool
+		set        models.User
+		wantIDs    []string
+		wantName   string
+	}{
+		{
+			name:     "newer version replaces",
+			cached:   []models.User{{ID: "user-1", Name: "Ann", Version: 1}},
+			set:      models.User{ID: "user-1", Name: "Ann Updated", Version: 2},
+			wantIDs:  []string{"user-1"},
+			wantName: "Ann Updated",
+		},
+		{
+			name:     "older version is ignored",
+			cached:   []models.User{{ID: "user-1", Name: "Ann Updated", Version: 2}},
+			set:      models.User{ID: "user-1", Name: "Ann", Version: 1},
+			wantIDs:  []string{"user-1"},
+			wantName: "Ann Updated",
+		},
+		{
+			name:     "older version replaces an expired entry",
+			cached:   []models.User{{ID: "user-1", Name: "Ann Updated", Version: 2}},
+			expired:  true,
+			set:      models.User{ID: "user-1", Name: "Ann", Version: 1},
+			wantIDs:  []string{"user-1"},
+			wantName: "Ann",
+		},
+		{
+			name:       "full cache drops the oldest entry",
+			maxEntries: "2",
+			cached:     []models.User{{ID: "user-1"}, {ID: "user-2"}},
+			set:        models.User{ID: "user-3", Name: "Cy"},
+			wantIDs:    []string{"user-2", "user-3"},
+			wantName:   "Cy",
+		},
+		{
+			name:       "full cache drops expired entries",
+			maxEntries: "2",
+			cached:     []models.User{{ID: "user-1"}, {ID: "user-2"}},
+			expired:    true,
+			set:        models.User{ID: "user-3", Name: "Cy"},
+			wantIDs:    []string{"user-3"},
+			wantName:   "Cy",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("USER_CACHE_TTL_MS", "60000")
+			t.Setenv("USER_CACHE_MAX_ENTRIES", tt.maxEntries)
+			cache := &userCache{entries: make(map[string]userCacheEntry)}
+			for _, user := range tt.cached {
+				cache.set(user)
+				// Keep insertion order visible to eviction
+				time.Sleep(time.Millisecond)
+			}
+			if tt.expired {
+				for id, entry := range cache.entries {
+					entry.expires = time.Now().Add(-time.Second)
+					cache.entries[id] = entry
+				}
+			}
+
+			cache.set(tt.set)
+
+			ids := make([]string, 0, len(cache.entries))
+			for id := range cache.entries {
+				ids = append(ids, id)
+			}
+			sort.Strings(ids)
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("cached IDs = %v, want %v", ids, tt.wantIDs)
+			}
+			if user, ok := cache.get(tt.set.ID); !ok || user.Name != tt.wantName {
+				t.Errorf("get(%s) = %q, %t; want %q", tt.set.ID, user.Name, ok, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestUserCacheGetEvictsExpired(t *testing.T) {
+	t.Setenv("USER_CACHE_TTL_MS", "60000")
+	cache := &userCache{entries: make(map[string]userCacheEntry)}
+	cache.set(models.User{ID: "user-1"})
+	entry := cache.entries["user-1"]
+	entry.expires = time.Now().Add(-time.Second)
+	cache.entries["user-1"] = entry
+
+	if _, ok := cache.get("user-1"); ok {
+		t.Fatal("get() found an expired entry")
+	}
+	if _, ok := cache.entries["user-1"]; ok {
+		t.Error("expired entry still cached after get()")
+	}
+}
@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ReturnRepresentationDefaults is the Prefer "return" value asking a create response to
+// list the fields the server set or changed under "defaults_applied".
+const ReturnRepresentationDefaults = "representation+defaults"
+
+// wantsDefaultsApplied reports whether the request sent "Prefer: return=representation+defaults".
+func wantsDefaultsApplied(request events.APIGatewayProxyRequest) bool {
+	return preference(request, "return") == ReturnRepresentationDefaults
+}
+
+// withDefaultsApplied adds a "defaults_applied" list of field names to a rendered user.
+func withDefaultsApplied(representation interface{}, fields []string) interface{} {
+	data, err := json.Marshal(representation)
+	if err != nil {
+		return representation
+	}
+
+	var document map[string]interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return representation
+	}
+	document["defaults_applied"] = fields
+
+	return document
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/utils"
+)
+
+// representationETag returns a strong ETag for a rendered representation of a user at
+// version, "<version>-<hash>", so If-Match can name the version it was read at. The
+// projection is part of the hash, so a projected response never shares an ETag with the
+// full one, and so is the media type when it is not JSON.
+func representationETag(representation interface{}, version int, fields []string, mediaType string) string {
+	data, err := json.Marshal(representation)
+	if err != nil {
+		return ""
+	}
+
+	hash := sha256.New()
+	hash.Write(data)
+	hash.Write([]byte("\x00fields=" + strings.Join(fields, ",")))
+	if mediaType != "application/json" {
+		hash.Write([]byte("\x00type=" + mediaType))
+	}
+
+	return fmt.Sprintf(`"%d-%s"`, version, hex.EncodeToString(hash.Sum(nil)[:16]))
+}
+
+// etagMatches reports whether an If-None-Match header value names etag: it is "*" or lists
+// etag. Comparison is weak, as RFC 9110 requires for If-None-Match, so W/ prefixes are ignored.
+func etagMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ifMatchVersion returns the user version an If-Match header requires, given as an ETag from
+// a GET ("3-9f86d0..."), a version entity tag ("3") or bare (3). It returns 0, meaning no
+// check, when the header is absent or "*", or names a user read before versioning.
+func ifMatchVersion(request events.APIGatewayProxyRequest) (int, error) {
+	value := strings.TrimSpace(utils.GetHeader(request, "If-Match"))
+	if value == "" || value == "*" {
+		return 0, nil
+	}
+
+	prefix, _, _ := strings.Cut(strings.Trim(value, `"`), "-")
+	version, err := strconv.Atoi(prefix)
+	if err != nil || version < 0 {
+		return 0, fmt.Errorf("the If-Match header must be an ETag or user version such as \"3\", got %q", value)
+	}
+
+	return version, nil
+}
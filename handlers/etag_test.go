@@ -0,0 +1,149 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/models"
+)
+
+func TestGetUserHandlerETagProjection(t *testing.T) {
+	handler := NewUserHandler(seedUsers(t, models.User{ID: "user-1", Name: "Ann", Email: "ann@example.com"}))
+	t.Cleanup(sharedUserCache.clear)
+
+	get := func(query map[string]string, ifNoneMatch string) events.APIGatewayProxyResponse {
+		t.Helper()
+		request := events.APIGatewayProxyRequest{
+			PathParameters:        map[string]string{"id": "user-1"},
+			QueryStringParameters: query,
+			Headers:               map[string]string{"If-None-Match": ifNoneMatch},
+		}
+		response, err := handler.GetUserHandler(context.Background(), request)
+		if err != nil {
+			t.Fatalf("GetUserHandler() error = %v", err)
+		}
+		return response
+	}
+
+	tests := []struct {
+		name  string
+		query map[string]string
+	}{
+		{name: "full representation"},
+		{name: "name only", query: map[string]string{"fields": "name"}},
+		{name: "email only", query: map[string]string{"fields": "email"}},
+		{name: "name and email", query: map[string]string{"fields": "name,email"}},
+		{name: "all fields", query: map[string]string{"fields": "id,name,email,version,created_at,updated_at"}},
+	}
+
+	seen := make(map[string]string, len(tests))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response := get(tt.query, "")
+			if response.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want %d; body %s", response.StatusCode, http.StatusOK, response.Body)
+			}
+
+			etag := response.Headers["ETag"]
+			if etag == "" {
+				t.Fatal("no ETag")
+			}
+			if other, ok := seen[etag]; ok {
+				t.Errorf("ETag %s shared with %q", etag, other)
+			}
+			seen[etag] = tt.name
+
+			if again := get(tt.query, "").Headers["ETag"]; again != etag {
+				t.Errorf("second ETag = %s, want %s", again, etag)
+			}
+			if status := get(tt.query, etag).StatusCode; status != http.StatusNotModified {
+				t.Errorf("status with matching If-None-Match = %d, want %d", status, http.StatusNotModified)
+			}
+		})
+	}
+
+	nameETag := get(map[string]string{"fields": "name"}, "").Headers["ETag"]
+	if status := get(map[string]string{"fields": "email"}, nameETag).StatusCode; status != http.StatusOK {
+		t.Errorf("status with another projection's ETag = %d, want %d", status, http.StatusOK)
+	}
+}
+
+func TestGetUserHandlerConditionalFetch(t *testing.T) {
+	tests := []struct {
+		name        string
+		ifNoneMatch func(etag string) string
+		update      bool
+		wantStatus  int
+	}{
+		{name: "matching ETag", ifNoneMatch: func(etag string) string { return etag }, wantStatus: http.StatusNotModified},
+		{
+			name:        "weak matching ETag",
+			ifNoneMatch: func(etag string) string { return "W/" + etag },
+			wantStatus:  http.StatusNotModified,
+		},
+		{
+			name:        "ETag in a list",
+			ifNoneMatch: func(etag string) string { return `"0-stale", ` + etag },
+			wantStatus:  http.StatusNotModified,
+		},
+		{name: "any ETag", ifNoneMatch: func(string) string { return "*" }, wantStatus: http.StatusNotModified},
+		{name: "other ETag", ifNoneMatch: func(string) string { return `"0-stale"` }, wantStatus: http.StatusOK},
+		{
+			name:        "ETag of an older version",
+			ifNoneMatch: func(etag string) string { return etag },
+			update:      true,
+			wantStatus:  http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sharedUserCache.clear()
+			t.Cleanup(sharedUserCache.clear)
+			repo := seedUsers(t, models.User{ID: "user-1", Name: "Ann", Email: "ann@example.com"})
+			handler := NewUserHandler(repo)
+			ctx := context.Background()
+
+			request := events.APIGatewayProxyRequest{PathParameters: map[string]string{"id": "user-1"}}
+			first, err := handler.GetUserHandler(ctx, request)
+			if err != nil {
+				t.Fatalf("GetUserHandler() error = %v", err)
+			}
+			etag := first.Headers["ETag"]
+			if first.StatusCode != http.StatusOK || etag == "" || first.Body == "" {
+				t.Fatalf("first fetch = %d with ETag %q and body %q, want 200 with both", first.StatusCode, etag,
+					first.Body)
+			}
+
+			if tt.update {
+				update := jsonRequest(http.MethodPut, `{"name":"Annie"}`)
+				update.PathParameters = request.PathParameters
+				if response, err := handler.UpdateUserHandler(ctx, update); err != nil || response.StatusCode != http.StatusOK {
+					t.Fatalf("UpdateUserHandler() = %d, %v", response.StatusCode, err)
+				}
+			}
+
+			request.Headers = map[string]string{"If-None-Match": tt.ifNoneMatch(etag)}
+			response, err := handler.GetUserHandler(ctx, request)
+			if err != nil {
+				t.Fatalf("GetUserHandler() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", response.StatusCode, tt.wantStatus)
+			}
+			if tt.wantStatus == http.StatusNotModified {
+				if response.Body != "" {
+					t.Errorf("304 body = %q, want none", response.Body)
+				}
+				if response.Headers["ETag"] != etag {
+					t.Errorf("304 ETag = %q, want %q", response.Headers["ETag"], etag)
+				}
+			} else if tt.update && response.Headers["ETag"] == etag {
+				t.Errorf("ETag unchanged by the update: %s", etag)
+			}
+		})
+	}
+}
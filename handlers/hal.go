@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"mime"
+	"net/url"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/utils"
+)
+
+// HALContentType is the HAL media type; clients accepting it get "_links" on user objects.
+const HALContentType = "application/hal+json"
+
+// halLink is a HAL link object. Method is an extension naming the HTTP method to use.
+type halLink struct {
+	Href   string `json:"href"`
+	Method string `json:"method,omitempty"`
+}
+
+// wantsHAL reports whether user objects should carry "_links": when HAL_LINKS is set, or
+// when the request accepts application/hal+json.
+func wantsHAL(request events.APIGatewayProxyRequest) bool {
+	if utils.GetEnvBool("HAL_LINKS", false) {
+		return true
+	}
+
+	for _, accepted := range strings.Split(utils.GetHeader(request, "Accept"), ",") {
+		if mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accepted)); err == nil && mediaType == HALContentType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// userLinks returns the HAL links of the user with id.
+func userLinks(id string) map[string]halLink {
+	href := "/users/" + url.PathEscape(id)
+
+	return map[string]halLink{
+		"self":   {Href: href},
+		"update": {Href: href, Method: "PUT"},
+		"delete": {Href: href, Method: "DELETE"},
+	}
+}
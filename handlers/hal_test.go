@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/models"
+)
+
+func TestGetUserHandlerHALLinks(t *testing.T) {
+	links := map[string]halLink{
+		"self":   {Href: "/users/user%201"},
+		"update": {Href: "/users/user%201", Method: http.MethodPut},
+		"delete": {Href: "/users/user%201", Method: http.MethodDelete},
+	}
+
+	tests := []struct {
+		name      string
+		halLinks  string
+		accept    string
+		query     map[string]string
+		wantLinks map[string]halLink
+	}{
+		{name: "absent by default"},
+		{name: "absent for plain JSON", accept: "application/json"},
+		{name: "HAL accepted", accept: "application/hal+json", wantLinks: links},
+		{name: "HAL among other types", accept: "text/html, application/hal+json;q=0.9", wantLinks: links},
+		{name: "HAL_LINKS flag", halLinks: "true", wantLinks: links},
+		{name: "HAL with a projection", accept: "application/hal+json", query: map[string]string{"fields": "name"},
+			wantLinks: links},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HAL_LINKS", tt.halLinks)
+			t.Cleanup(sharedUserCache.clear)
+			handler := NewUserHandler(seedUsers(t, models.User{ID: "user 1", Name: "Ann", Email: "ann@example.com"}))
+
+			request := events.APIGatewayProxyRequest{
+				PathParameters:        map[string]string{"id": "user 1"},
+				QueryStringParameters: tt.query,
+				Headers:               map[string]string{"Accept": tt.accept},
+			}
+			response, err := handler.GetUserHandler(context.Background(), request)
+			if err != nil {
+				t.Fatalf("GetUserHandler() error = %v", err)
+			}
+			if response.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want %d; body %s", response.StatusCode, http.StatusOK, response.Body)
+			}
+
+			body := decodeResponse[struct {
+				Links map[string]halLink `json:"_links"`
+			}](t, response)
+			if !reflect.DeepEqual(body.Links, tt.wantLinks) {
+				t.Errorf("_links = %v, want %v", body.Links, tt.wantLinks)
+			}
+		})
+	}
+}
@@ -3,24 +3,138 @@ package handlers
 import (
 	"context"
 	"net/http"
+	"sync"
+	"time"
 
 	"go-lambda-api/utils"
 
 	"github.com/aws/aws-lambda-go/events"
 )
 
-// HealthHandler struct for health check operations.
-type HealthHandler struct{}
+// HealthCheck is a named dependency check run by the readiness endpoint.
+type HealthCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+// BackendInfo describes the user repository backend, so the readiness endpoint shows how an
+// environment is configured.
+type BackendInfo struct {
+	Backend string `json:"backend"`
+	Table   string `json:"table,omitempty"`
+	Region  string `json:"region,omitempty"`
+}
+
+// HealthHandler struct for health check operations. It remembers how long each failing
+// check has been failing, across requests served by the same process.
+type HealthHandler struct {
+	Checks []HealthCheck
+	// Backend, when set, is reported by the readiness endpoint.
+	Backend *BackendInfo
+
+	mu       sync.Mutex
+	failures map[string]*healthFailure
+}
+
+// healthFailure describes a dependency whose check is currently failing.
+type healthFailure struct {
+	Dependency          string    `json:"dependency"`
+	Error               string    `json:"error"`
+	FailingSince        time.Time `json:"failing_since"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+}
 
-// NewHealthHandler creates and returns a new HealthHandler.
-func NewHealthHandler() *HealthHandler {
-	return &HealthHandler{}
+// NewHealthHandler creates and returns a new HealthHandler with the given readiness checks.
+func NewHealthHandler(checks ...HealthCheck) *HealthHandler {
+	return &HealthHandler{Checks: checks}
 }
 
-// GetHealthHandler returns a 200 OK response for health checks.
+// GetHealthHandler returns a 200 OK response for health checks. It is an alias for liveness.
 func (h *HealthHandler) GetHealthHandler(
 	ctx context.Context,
 	request events.APIGatewayProxyRequest,
+) (events.APIGatewayProxyResponse, error) {
+	return h.GetLivenessHandler(ctx, request)
+}
+
+// GetLivenessHandler returns 200 OK whenever the process is able to serve a request.
+func (h *HealthHandler) GetLivenessHandler(
+	ctx context.Context,
+	request events.APIGatewayProxyRequest,
 ) (events.APIGatewayProxyResponse, error) {
 	return utils.APIResponse(http.StatusOK, map[string]string{"message": "Health Check OK"})
 }
+
+// GetReadinessHandler runs the dependency checks and returns 200 when all pass,
+// or 503 Service Unavailable with the failing checks otherwise. A failing response also
+// lists under "degraded" each failing dependency with its error, when it started failing
+// and how many checks in a row have failed. Either response describes the repository
+// backend under "backend", with its table and region hidden when HEALTH_REDACT_BACKEND=true.
+func (h *HealthHandler) GetReadinessHandler(
+	ctx context.Context,
+	request events.APIGatewayProxyRequest,
+) (events.APIGatewayProxyResponse, error) {
+	status := http.StatusOK
+	results := make(map[string]string, len(h.Checks))
+	degraded := make([]healthFailure, 0)
+
+	for _, check := range h.Checks {
+		err := check.Check(ctx)
+		failure := h.recordCheck(check.Name, err)
+		if err != nil {
+			status = http.StatusServiceUnavailable
+			results[check.Name] = err.Error()
+			degraded = append(degraded, failure)
+			continue
+		}
+		results[check.Name] = "ok"
+	}
+
+	body := map[string]interface{}{"status": "ready", "checks": results}
+	if status != http.StatusOK {
+		body["status"] = "not ready"
+		body["degraded"] = degraded
+	}
+	if h.Backend != nil {
+		backend := *h.Backend
+		if utils.GetEnvBool("HEALTH_REDACT_BACKEND", false) {
+			backend.Table, backend.Region = redactValue(backend.Table), redactValue(backend.Region)
+		}
+		body["backend"] = backend
+	}
+
+	return utils.APIResponse(status, body)
+}
+
+// recordCheck updates the failure history of a check and returns its current failure.
+func (h *HealthHandler) recordCheck(name string, err error) healthFailure {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err == nil {
+		delete(h.failures, name)
+		return healthFailure{}
+	}
+
+	if h.failures == nil {
+		h.failures = make(map[string]*healthFailure)
+	}
+	failure, ok := h.failures[name]
+	if !ok {
+		failure = &healthFailure{Dependency: name, FailingSince: utils.Now().UTC()}
+		h.failures[name] = failure
+	}
+	failure.Error = err.Error()
+	failure.ConsecutiveFailures++
+
+	return *failure
+}
+
+// redactValue hides a non-empty value, keeping empty ones empty so they stay omitted.
+func redactValue(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	return "redacted"
+}
@@ -0,0 +1,216 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestHealthHandler(t *testing.T) {
+	healthy := HealthCheck{Name: "repository", Check: func(context.Context) error { return nil }}
+	unhealthy := HealthCheck{Name: "cache", Check: func(context.Context) error { return errors.New("connection refused") }}
+
+	tests := []struct {
+		name          string
+		checks        []HealthCheck
+		wantLive      int
+		wantReady     int
+		wantReadiness string
+		wantChecks    map[string]string
+	}{
+		{
+			name:          "no dependencies",
+			wantLive:      http.StatusOK,
+			wantReady:     http.StatusOK,
+			wantReadiness: "ready",
+			wantChecks:    map[string]string{},
+		},
+		{
+			name:          "healthy dependencies",
+			checks:        []HealthCheck{healthy},
+			wantLive:      http.StatusOK,
+			wantReady:     http.StatusOK,
+			wantReadiness: "ready",
+			wantChecks:    map[string]string{"repository": "ok"},
+		},
+		{
+			name:          "unhealthy dependency",
+			checks:        []HealthCheck{healthy, unhealthy},
+			wantLive:      http.StatusOK,
+			wantReady:     http.StatusServiceUnavailable,
+			wantReadiness: "not ready",
+			wantChecks:    map[string]string{"repository": "ok", "cache": "connection refused"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewHealthHandler(tt.checks...)
+			ctx := context.Background()
+			request := events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet}
+
+			for name, live := range map[string]func(
+				context.Context, events.APIGatewayProxyRequest,
+			) (events.APIGatewayProxyResponse, error){
+				"/health": handler.GetHealthHandler, "/health/live": handler.GetLivenessHandler,
+			} {
+				response, err := live(ctx, request)
+				if err != nil || response.StatusCode != tt.wantLive {
+					t.Errorf("%s = %d, %v; want %d", name, response.StatusCode, err, tt.wantLive)
+				}
+			}
+
+			response, err := handler.GetReadinessHandler(ctx, request)
+			if err != nil {
+				t.Fatalf("GetReadinessHandler() error = %v", err)
+			}
+			if response.StatusCode != tt.wantReady {
+				t.Errorf("/health/ready status = %d, want %d", response.StatusCode, tt.wantReady)
+			}
+
+			body := decodeResponse[struct {
+				Status string            `json:"status"`
+				Checks map[string]string `json:"checks"`
+			}](t, response)
+			if body.Status != tt.wantReadiness {
+				t.Errorf("status = %q, want %q", body.Status, tt.wantReadiness)
+			}
+			if len(body.Checks) != len(tt.wantChecks) {
+				t.Errorf("checks = %v, want %v", body.Checks, tt.wantChecks)
+			}
+			for name, want := range tt.wantChecks {
+				if body.Checks[name] != want {
+					t.Errorf("checks[%s] = %q, want %q", name, body.Checks[name], want)
+				}
+			}
+		})
+	}
+}
+
+func TestReadinessDegraded(t *testing.T) {
+	var cacheErr error
+	handler := NewHealthHandler(
+		HealthCheck{Name: "repository", Check: func(context.Context) error { return nil }},
+		HealthCheck{Name: "cache", Check: func(context.Context) error { return cacheErr }},
+	)
+
+	// The steps run in order against one handler, which remembers failures between them
+	tests := []struct {
+		name          string
+		err           error
+		wantStatus    int
+		wantFailures  int
+		wantSameSince bool
+	}{
+		{name: "first failure", err: errors.New("connection refused"), wantStatus: http.StatusServiceUnavailable,
+			wantFailures: 1},
+		{name: "second failure", err: errors.New("timeout"), wantStatus: http.StatusServiceUnavailable,
+			wantFailures: 2, wantSameSince: true},
+		{name: "recovered", wantStatus: http.StatusOK},
+		{name: "failing again", err: errors.New("connection refused"), wantStatus: http.StatusServiceUnavailable,
+			wantFailures: 1},
+	}
+
+	var firstSince time.Time
+	for _, tt := range tests {
+		cacheErr = tt.err
+		response, err := handler.GetReadinessHandler(context.Background(), events.APIGatewayProxyRequest{})
+		if err != nil {
+			t.Fatalf("%s: GetReadinessHandler() error = %v", tt.name, err)
+		}
+		if response.StatusCode != tt.wantStatus {
+			t.Fatalf("%s: status = %d, want %d", tt.name, response.StatusCode, tt.wantStatus)
+		}
+
+		body := decodeResponse[struct {
+			Degraded []healthFailure `json:"degraded"`
+		}](t, response)
+		if tt.err == nil {
+			if body.Degraded != nil {
+				t.Errorf("%s: degraded = %+v, want none", tt.name, body.Degraded)
+			}
+			continue
+		}
+
+		if len(body.Degraded) != 1 {
+			t.Fatalf("%s: degraded = %+v, want only cache", tt.name, body.Degraded)
+		}
+		failure := body.Degraded[0]
+		if failure.Dependency != "cache" || failure.Error != tt.err.Error() {
+			t.Errorf("%s: degraded %s: %q, want cache: %q", tt.name, failure.Dependency, failure.Error, tt.err)
+		}
+		if failure.ConsecutiveFailures != tt.wantFailures {
+			t.Errorf("%s: consecutive_failures = %d, want %d", tt.name, failure.ConsecutiveFailures, tt.wantFailures)
+		}
+		if failure.FailingSince.IsZero() {
+			t.Errorf("%s: failing_since is not set", tt.name)
+		}
+		if tt.wantSameSince && !failure.FailingSince.Equal(firstSince) {
+			t.Errorf("%s: failing_since = %s, want %s", tt.name, failure.FailingSince, firstSince)
+		}
+		if tt.wantFailures == 1 {
+			firstSince = failure.FailingSince
+		}
+	}
+}
+
+func TestReadinessBackend(t *testing.T) {
+	dynamo := &BackendInfo{Backend: "dynamodb", Table: "users-prod", Region: "us-east-1"}
+
+	tests := []struct {
+		name    string
+		backend *BackendInfo
+		redact  string
+		want    map[string]string
+	}{
+		{name: "not configured"},
+		{name: "memory", backend: &BackendInfo{Backend: "memory"}, want: map[string]string{"backend": "memory"}},
+		{
+			name:    "dynamodb",
+			backend: dynamo,
+			want:    map[string]string{"backend": "dynamodb", "table": "users-prod", "region": "us-east-1"},
+		},
+		{
+			name:    "dynamodb redacted",
+			backend: dynamo,
+			redact:  "true",
+			want:    map[string]string{"backend": "dynamodb", "table": "redacted", "region": "redacted"},
+		},
+		{
+			name:    "postgres",
+			backend: &BackendInfo{Backend: "postgres", Table: "users"},
+			want:    map[string]string{"backend": "postgres", "table": "users"},
+		},
+		{
+			name:    "postgres redacted",
+			backend: &BackendInfo{Backend: "postgres", Table: "users"},
+			redact:  "true",
+			want:    map[string]string{"backend": "postgres", "table": "redacted"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("HEALTH_REDACT_BACKEND", tt.redact)
+			handler := NewHealthHandler()
+			handler.Backend = tt.backend
+
+			response, err := handler.GetReadinessHandler(context.Background(), events.APIGatewayProxyRequest{})
+			if err != nil {
+				t.Fatalf("GetReadinessHandler() error = %v", err)
+			}
+
+			body := decodeResponse[struct {
+				Backend map[string]string `json:"backend"`
+			}](t, response)
+			if !reflect.DeepEqual(body.Backend, tt.want) {
+				t.Errorf("backend = %v, want %v", body.Backend, tt.want)
+			}
+		})
+	}
+}
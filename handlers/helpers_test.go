@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/models"
+)
+
+// testAdminToken is the ADMIN_TOKEN set by withAdminToken.
+const testAdminToken = "test-admin-token"
+
+// withAdminToken enables the admin endpoints for the test.
+func withAdminToken(t *testing.T) {
+	t.Helper()
+	t.Setenv("ADMIN_TOKEN", testAdminToken)
+}
+
+// adminRequest returns a request carrying the admin token.
+func adminRequest(query map[string]string) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{
+		Headers:               map[string]string{AdminTokenHeader: testAdminToken},
+		QueryStringParameters: query,
+	}
+}
+
+// jsonRequest returns a request with body and a JSON Content-Type.
+func jsonRequest(method, body string) events.APIGatewayProxyRequest {
+	return events.APIGatewayProxyRequest{
+		HTTPMethod: method,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		Body:       body,
+	}
+}
+
+// decodeResponse decodes a JSON response body into a T, failing the test when it cannot.
+func decodeResponse[T any](t *testing.T, response events.APIGatewayProxyResponse) T {
+	t.Helper()
+
+	var value T
+	if err := json.Unmarshal([]byte(response.Body), &value); err != nil {
+		t.Fatalf("decoding response body %q: %v", response.Body, err)
+	}
+
+	return value
+}
+
+// listedIDs returns the IDs of the users listed under DefaultListRootKey in a GET /users response.
+func listedIDs(t *testing.T, response events.APIGatewayProxyResponse) []string {
+	t.Helper()
+
+	page := decodeResponse[map[string]json.RawMessage](t, response)
+	var listed []models.User
+	if err := json.Unmarshal(page[DefaultListRootKey], &listed); err != nil {
+		t.Fatalf("decoding %s: %v", DefaultListRootKey, err)
+	}
+
+	ids := make([]string, len(listed))
+	for i, user := range listed {
+		ids[i] = user.ID
+	}
+
+	return ids
+}
+
+// seedUsers empties the shared in-memory repository, creates users in it and returns it. It
+// is emptied again when the test ends, so tests using it must not run in parallel.
+// nolint: ireturn
+func seedUsers(t *testing.T, users ...models.User) models.UserRepository {
+	t.Helper()
+
+	models.ClearInMemoryUsers()
+	t.Cleanup(models.ClearInMemoryUsers)
+
+	repo := models.NewInMemoryUserRepository()
+	for _, user := range users {
+		if _, err := repo.CreateUser(context.Background(), user); err != nil {
+			t.Fatalf("seeding user %q: %v", user.ID, err)
+		}
+	}
+
+	return repo
+}
+
+// fakeObjectStore is an in-memory ObjectStore.
+type fakeObjectStore struct {
+	objects     map[string][]byte
+	contentType string
+	err         error
+}
+
+func (s *fakeObjectStore) GetObject(_ context.Context, bucket, key string) (Object, error) {
+	if s.err != nil {
+		return Object{}, s.err
+	}
+	data, ok := s.objects[bucket+"/"+key]
+	if !ok {
+		return Object{}, errors.New("no such key")
+	}
+
+	return Object{Body: io.NopCloser(bytes.NewReader(data)), ContentType: s.contentType}, nil
+}
+
+func (s *fakeObjectStore) PutObject(_ context.Context, bucket, key, contentType string, body []byte) error {
+	if s.err != nil {
+		return s.err
+	}
+	if s.objects == nil {
+		s.objects = make(map[string][]byte)
+	}
+	s.objects[bucket+"/"+key] = body
+	s.contentType = contentType
+
+	return nil
+}
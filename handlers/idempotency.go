@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/utils"
+)
+
+const (
+	// IdempotencyKeyHeader carries the client's key for safely retrying a mutating request.
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader marks a response replayed from the idempotency store.
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	// DefaultIdempotencyTTL is how long responses are kept when IDEMPOTENCY_TTL_MS is unset.
+	DefaultIdempotencyTTL = 24 * time.Hour
+	// DefaultIdempotencyLease is how long a key stays claimed by a request that has not
+	// finished when IDEMPOTENCY_LEASE_MS is unset. It outlasts utils.DefaultRequestTimeout.
+	DefaultIdempotencyLease = time.Minute
+)
+
+var (
+	// ErrIdempotencyInProgress is returned while an earlier request with the same key is running.
+	ErrIdempotencyInProgress = errors.New("a request with this Idempotency-Key is in progress")
+	// ErrIdempotencyKeyReused is returned when a key is sent again with a different body.
+	ErrIdempotencyKeyReused = errors.New("idempotency key reused with different payload")
+	// ErrIdempotencyUnavailable is returned when the idempotency store cannot be read or written.
+	ErrIdempotencyUnavailable = errors.New("idempotency store unavailable")
+)
+
+// IdempotencyRecord is what an IdempotencyStore keeps for one key.
+type IdempotencyRecord struct {
+	// PayloadHash is the hex SHA-256 of the body of the first request with the key.
+	PayloadHash string
+	// Response is nil while the first request is in flight.
+	Response *events.APIGatewayProxyResponse
+	// Expires is when the record is forgotten: the end of the lease while the request is in
+	// flight, so a request that never finished does not hold its key for the whole TTL.
+	Expires time.Time
+}
+
+// IdempotencyStore keeps the responses of mutating requests by Idempotency-Key, so a
+// retried create, update or delete returns the original response instead of mutating again.
+type IdempotencyStore interface {
+	// Claim stores record, an in-flight request, under key unless an unexpired record is
+	// already stored there. It returns that existing record and false in that case, and
+	// record and true otherwise.
+	Claim(ctx context.Context, key string, record IdempotencyRecord) (IdempotencyRecord, bool, error)
+	// Save replaces the record under key with record, the finished request.
+	Save(ctx context.Context, key string, record IdempotencyRecord) error
+	// Release forgets key, so the request can be retried with it.
+	Release(ctx context.Context, key string) error
+}
+
+// IdempotentCall is a mutating request whose response will be stored under its key.
+type IdempotentCall struct {
+	key         string
+	payloadHash string
+}
+
+// sharedIdempotency is shared by every handler in the process. It is in memory unless
+// replaced with SetIdempotencyStore.
+var sharedIdempotency IdempotencyStore = NewMemoryIdempotencyStore()
+
+// SetIdempotencyStore replaces the in-memory idempotency store, e.g. with one that is
+// shared by every Lambda container. It must be called before requests are served.
+func SetIdempotencyStore(store IdempotencyStore) {
+	sharedIdempotency = store
+}
+
+// StartIdempotent looks up the request's Idempotency-Key. It returns the stored response when
+// the request was already served, ErrIdempotencyKeyReused when the first request with the key
+// had a different body, ErrIdempotencyInProgress while it is still running,
+// ErrIdempotencyUnavailable when the store fails, and otherwise a call to pass to
+// FinishIdempotent. Requests without a key and non-mutating requests return neither.
+func StartIdempotent(
+	ctx context.Context, request events.APIGatewayProxyRequest,
+) (*IdempotentCall, *events.APIGatewayProxyResponse, error) {
+	clientKey := utils.GetHeader(request, IdempotencyKeyHeader)
+	if clientKey == "" || !isMutatingMethod(request.HTTPMethod) {
+		return nil, nil, nil
+	}
+
+	// The key is scoped to the caller and the operation, so one caller never replays another's
+	// response and reusing a key on another route does not replay
+	key := callerPrincipal(request) + " " + request.HTTPMethod + " " + request.Path + " " +
+		request.PathParameters["id"] + " " + clientKey
+	call := &IdempotentCall{key: key, payloadHash: payloadHash(request)}
+
+	// The claim expires after a lease rather than the TTL, so a request that crashed before
+	// FinishIdempotent blocks retries with its key only briefly
+	record, claimed, err := sharedIdempotency.Claim(ctx, key, IdempotencyRecord{
+		PayloadHash: call.payloadHash,
+		Expires:     time.Now().Add(idempotencyLease()),
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", ErrIdempotencyUnavailable, err)
+	}
+	if claimed {
+		return call, nil, nil
+	}
+
+	if record.PayloadHash != call.payloadHash {
+		return nil, nil, ErrIdempotencyKeyReused
+	}
+	if record.Response == nil {
+		return nil, nil, ErrIdempotencyInProgress
+	}
+
+	replay := copyResponse(*record.Response)
+	replay.Headers[IdempotentReplayedHeader] = "true"
+
+	return nil, &replay, nil
+}
+
+// IdempotencyErrorStatus returns the status for an error from StartIdempotent: 422 for a
+// reused key, 409 for one still in progress and 503 when the store is unavailable.
+func IdempotencyErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, ErrIdempotencyKeyReused):
+		return http.StatusUnprocessableEntity
+	case errors.Is(err, ErrIdempotencyInProgress):
+		return http.StatusConflict
+	default:
+		return http.StatusServiceUnavailable
+	}
+}
+
+// payloadHash returns the hex SHA-256 of the request body, decoded when API Gateway delivered
+// it base64-encoded so both encodings of one payload match.
+func payloadHash(request events.APIGatewayProxyRequest) string {
+	body := []byte(request.Body)
+	if request.IsBase64Encoded {
+		if decoded, err := base64.StdEncoding.DecodeString(request.Body); err == nil {
+			body = decoded
+		}
+	}
+	sum := sha256.Sum256(body)
+
+	return hex.EncodeToString(sum[:])
+}
+
+// FinishIdempotent stores the response of call for IDEMPOTENCY_TTL_MS. Server errors are not
+// stored, so the client can retry them with the same key. Store failures are logged; the key
+// then stays in flight until its lease expires.
+func FinishIdempotent(ctx context.Context, call *IdempotentCall, response events.APIGatewayProxyResponse) {
+	if call == nil {
+		return
+	}
+	// Record the outcome even when the request ran out its deadline
+	ctx = context.WithoutCancel(ctx)
+
+	var err error
+	if response.StatusCode >= http.StatusInternalServerError {
+		err = sharedIdempotency.Release(ctx, call.key)
+	} else {
+		stored := copyResponse(response)
+		err = sharedIdempotency.Save(ctx, call.key, IdempotencyRecord{
+			PayloadHash: call.payloadHash,
+			Response:    &stored,
+			Expires:     time.Now().Add(idempotencyTTL()),
+		})
+	}
+	if err != nil {
+		log.Printf("Error storing idempotent response for %q: %v", call.key, err)
+	}
+}
+
+// memoryIdempotencySweepClaims is how many claims a memoryIdempotencyStore serves between
+// sweeps for expired records.
+const memoryIdempotencySweepClaims = 1000
+
+// memoryIdempotencyStore is an IdempotencyStore in process memory, so each Lambda container
+// or local server process has its own keys. A claimed key's expired record is replaced
+// directly, and every memoryIdempotencySweepClaims claims the other expired records are
+// dropped, so a claim does not scan every record.
+type memoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[string]IdempotencyRecord
+	claims  int
+}
+
+// NewMemoryIdempotencyStore creates an empty in-memory IdempotencyStore, the default.
+// nolint: ireturn
+func NewMemoryIdempotencyStore() IdempotencyStore {
+	return &memoryIdempotencyStore{records: make(map[string]IdempotencyRecord)}
+}
+
+func (s *memoryIdempotencyStore) Claim(
+	_ context.Context, key string, record IdempotencyRecord,
+) (IdempotencyRecord, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.claims++
+	if s.claims%memoryIdempotencySweepClaims == 0 {
+		s.sweep(now)
+	}
+
+	if existing, ok := s.records[key]; ok && !now.After(existing.Expires) {
+		return existing, false, nil
+	}
+	s.records[key] = record
+
+	return record, true, nil
+}
+
+// sweep drops every record expired at now. The caller must hold s.mu.
+func (s *memoryIdempotencyStore) sweep(now time.Time) {
+	for key, record := range s.records {
+		if now.After(record.Expires) {
+			delete(s.records, key)
+		}
+	}
+}
+
+func (s *memoryIdempotencyStore) Save(_ context.Context, key string, record IdempotencyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.records[key] = record
+
+	return nil
+}
+
+func (s *memoryIdempotencyStore) Release(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+
+	return nil
+}
+
+// idempotencyTTL returns IDEMPOTENCY_TTL_MS, defaulting to DefaultIdempotencyTTL.
+func idempotencyTTL() time.Duration {
+	ttlMillis := utils.GetEnvInt("IDEMPOTENCY_TTL_MS", int(DefaultIdempotencyTTL/time.Millisecond))
+
+	return time.Duration(ttlMillis) * time.Millisecond
+}
+
+// idempotencyLease returns IDEMPOTENCY_LEASE_MS, defaulting to DefaultIdempotencyLease, and
+// at most the TTL.
+func idempotencyLease() time.Duration {
+	leaseMillis := utils.GetEnvInt("IDEMPOTENCY_LEASE_MS", int(DefaultIdempotencyLease/time.Millisecond))
+
+	return min(time.Duration(leaseMillis)*time.Millisecond, idempotencyTTL())
+}
+
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
+// copyResponse copies response so the stored headers are not shared with the caller.
+func copyResponse(response events.APIGatewayProxyResponse) events.APIGatewayProxyResponse {
+	headers := make(map[string]string, len(response.Headers)+1)
+	for k, v := range response.Headers {
+		headers[k] = v
+	}
+	response.Headers = headers
+
+	return response
+}
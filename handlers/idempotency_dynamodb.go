@@ -0,0 +1,128 @@
+//go:build dynamodb
+
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// dynamoDBIdempotencyItem is an IdempotencyRecord as stored in DynamoDB. The table's partition
+// key is the string attribute "Key", and "ExpiresAt", in Unix seconds, can be made the table's
+// TTL attribute so DynamoDB deletes expired records.
+type dynamoDBIdempotencyItem struct {
+	Key         string `dynamodbav:"Key"`
+	PayloadHash string `dynamodbav:"PayloadHash"`
+	Response    string `dynamodbav:"Response,omitempty"` // JSON, absent while in flight
+	ExpiresAt   int64  `dynamodbav:"ExpiresAt"`
+}
+
+// dynamoDBIdempotencyStore is an IdempotencyStore in a DynamoDB table, shared by every Lambda
+// container, so a retry that lands on another container still replays.
+type dynamoDBIdempotencyStore struct {
+	db        dynamodbiface.DynamoDBAPI
+	tableName string
+}
+
+// NewDynamoDBIdempotencyStore creates an IdempotencyStore in the DynamoDB table tableName.
+// nolint: ireturn
+func NewDynamoDBIdempotencyStore(db dynamodbiface.DynamoDBAPI, tableName string) IdempotencyStore {
+	return &dynamoDBIdempotencyStore{db: db, tableName: tableName}
+}
+
+// Claim writes record unless an unexpired record exists, which DynamoDB returns when the
+// condition fails. Expired records may linger until DynamoDB's TTL deletes them, so the
+// condition checks ExpiresAt rather than relying on the record being gone.
+func (s *dynamoDBIdempotencyStore) Claim(
+	ctx context.Context, key string, record IdempotencyRecord,
+) (IdempotencyRecord, bool, error) {
+	item, err := marshalIdempotencyItem(key, record)
+	if err != nil {
+		return IdempotencyRecord{}, false, err
+	}
+
+	_, err = s.db.PutItemWithContext(ctx, &dynamodb.PutItemInput{
+		TableName:                aws.String(s.tableName),
+		Item:                     item,
+		ConditionExpression:      aws.String("attribute_not_exists(#Key) OR #ExpiresAt < :now"),
+		ExpressionAttributeNames: map[string]*string{"#Key": aws.String("Key"), "#ExpiresAt": aws.String("ExpiresAt")},
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": {N: aws.String(strconv.FormatInt(time.Now().Unix(), 10))},
+		},
+		ReturnValuesOnConditionCheckFailure: aws.String(dynamodb.ReturnValuesOnConditionCheckFailureAllOld),
+	})
+	if err == nil {
+		return record, true, nil
+	}
+
+	var conditionErr *dynamodb.ConditionalCheckFailedException
+	if !errors.As(err, &conditionErr) {
+		return IdempotencyRecord{}, false, err
+	}
+	existing, err := unmarshalIdempotencyItem(conditionErr.Item)
+	if err != nil {
+		return IdempotencyRecord{}, false, err
+	}
+
+	return existing, false, nil
+}
+
+func (s *dynamoDBIdempotencyStore) Save(ctx context.Context, key string, record IdempotencyRecord) error {
+	item, err := marshalIdempotencyItem(key, record)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.PutItemWithContext(ctx, &dynamodb.PutItemInput{TableName: aws.String(s.tableName), Item: item})
+
+	return err
+}
+
+func (s *dynamoDBIdempotencyStore) Release(ctx context.Context, key string) error {
+	_, err := s.db.DeleteItemWithContext(ctx, &dynamodb.DeleteItemInput{
+		TableName: aws.String(s.tableName),
+		Key:       map[string]*dynamodb.AttributeValue{"Key": {S: aws.String(key)}},
+	})
+
+	return err
+}
+
+func marshalIdempotencyItem(key string, record IdempotencyRecord) (map[string]*dynamodb.AttributeValue, error) {
+	item := dynamoDBIdempotencyItem{Key: key, PayloadHash: record.PayloadHash, ExpiresAt: record.Expires.Unix()}
+	if record.Response != nil {
+		response, err := json.Marshal(record.Response)
+		if err != nil {
+			return nil, err
+		}
+		item.Response = string(response)
+	}
+
+	return dynamodbattribute.MarshalMap(item)
+}
+
+func unmarshalIdempotencyItem(av map[string]*dynamodb.AttributeValue) (IdempotencyRecord, error) {
+	var item dynamoDBIdempotencyItem
+	if err := dynamodbattribute.UnmarshalMap(av, &item); err != nil {
+		return IdempotencyRecord{}, err
+	}
+
+	record := IdempotencyRecord{PayloadHash: item.PayloadHash, Expires: time.Unix(item.ExpiresAt, 0)}
+	if item.Response != "" {
+		var response events.APIGatewayProxyResponse
+		if err := json.Unmarshal([]byte(item.Response), &response); err != nil {
+			return IdempotencyRecord{}, err
+		}
+		record.Response = &response
+	}
+
+	return record, nil
+}
@@ -0,0 +1,52 @@
+//go:build dynamodb
+
+package handlers
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// fakeIdempotencyTable is a DynamoDB table of idempotency items by key, enforcing Claim's
+// condition that no unexpired item exists.
+type fakeIdempotencyTable struct {
+	dynamodbiface.DynamoDBAPI
+
+	items map[string]map[string]*dynamodb.AttributeValue
+}
+
+func (f *fakeIdempotencyTable) PutItemWithContext(
+	_ aws.Context, input *dynamodb.PutItemInput, _ ...request.Option,
+) (*dynamodb.PutItemOutput, error) {
+	key := aws.StringValue(input.Item["Key"].S)
+	if existing, ok := f.items[key]; ok && input.ConditionExpression != nil {
+		expiresAt, _ := strconv.ParseInt(aws.StringValue(existing["ExpiresAt"].N), 10, 64)
+		if expiresAt >= time.Now().Unix() {
+			return nil, &dynamodb.ConditionalCheckFailedException{Item: existing}
+		}
+	}
+	f.items[key] = input.Item
+
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *fakeIdempotencyTable) DeleteItemWithContext(
+	_ aws.Context, input *dynamodb.DeleteItemInput, _ ...request.Option,
+) (*dynamodb.DeleteItemOutput, error) {
+	delete(f.items, aws.StringValue(input.Key["Key"].S))
+
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func TestDynamoDBIdempotencyStore(t *testing.T) {
+	testIdempotencyStore(t, func(*testing.T) IdempotencyStore {
+		table := &fakeIdempotencyTable{items: make(map[string]map[string]*dynamodb.AttributeValue)}
+		return NewDynamoDBIdempotencyStore(table, "idempotency")
+	})
+}
@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/models"
+)
+
+// testIdempotencyStore checks the IdempotencyStore contract against a store from newStore.
+func testIdempotencyStore(t *testing.T, newStore func(t *testing.T) IdempotencyStore) {
+	t.Helper()
+
+	ctx := context.Background()
+	inFlight := IdempotencyRecord{PayloadHash: "hash-1", Expires: time.Now().Add(time.Hour)}
+	response := events.APIGatewayProxyResponse{
+		StatusCode: http.StatusCreated,
+		Headers:    map[string]string{"Content-Type": "application/json"},
+		Body:       `{"id":"user-1"}`,
+	}
+	finished := inFlight
+	finished.Response = &response
+
+	tests := []struct {
+		name        string
+		prepare     func(t *testing.T, store IdempotencyStore)
+		wantClaimed bool
+		wantRecord  *IdempotencyRecord
+	}{
+		{name: "new key", wantClaimed: true},
+		{
+			name: "key in flight",
+			prepare: func(t *testing.T, store IdempotencyStore) {
+				if _, _, err := store.Claim(ctx, "key-1", inFlight); err != nil {
+					t.Fatalf("Claim() error = %v", err)
+				}
+			},
+			wantRecord: &inFlight,
+		},
+		{
+			name: "key with a saved response",
+			prepare: func(t *testing.T, store IdempotencyStore) {
+				if err := store.Save(ctx, "key-1", finished); err != nil {
+					t.Fatalf("Save() error = %v", err)
+				}
+			},
+			wantRecord: &finished,
+		},
+		{
+			name: "released key",
+			prepare: func(t *testing.T, store IdempotencyStore) {
+				if _, _, err := store.Claim(ctx, "key-1", inFlight); err != nil {
+					t.Fatalf("Claim() error = %v", err)
+				}
+				if err := store.Release(ctx, "key-1"); err != nil {
+					t.Fatalf("Release() error = %v", err)
+				}
+			},
+			wantClaimed: true,
+		},
+		{
+			name: "expired key",
+			prepare: func(t *testing.T, store IdempotencyStore) {
+				expired := finished
+				expired.Expires = time.Now().Add(-2 * time.Second)
+				if err := store.Save(ctx, "key-1", expired); err != nil {
+					t.Fatalf("Save() error = %v", err)
+				}
+			},
+			wantClaimed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := newStore(t)
+			if tt.prepare != nil {
+				tt.prepare(t, store)
+			}
+
+			claim := IdempotencyRecord{PayloadHash: "hash-2", Expires: time.Now().Add(time.Hour)}
+			record, claimed, err := store.Claim(ctx, "key-1", claim)
+			if err != nil {
+				t.Fatalf("Claim() error = %v", err)
+			}
+			if claimed != tt.wantClaimed {
+				t.Fatalf("Claim() claimed = %t, want %t", claimed, tt.wantClaimed)
+			}
+			if tt.wantRecord == nil {
+				return
+			}
+
+			if record.PayloadHash != tt.wantRecord.PayloadHash {
+				t.Errorf("PayloadHash = %q, want %q", record.PayloadHash, tt.wantRecord.PayloadHash)
+			}
+			if !reflect.DeepEqual(record.Response, tt.wantRecord.Response) {
+				t.Errorf("Response = %+v, want %+v", record.Response, tt.wantRecord.Response)
+			}
+		})
+	}
+}
+
+func TestMemoryIdempotencyStore(t *testing.T) {
+	testIdempotencyStore(t, func(*testing.T) IdempotencyStore {
+		return NewMemoryIdempotencyStore()
+	})
+}
+
+func TestMemoryIdempotencyStoreSweep(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryIdempotencyStore().(*memoryIdempotencyStore)
+
+	expired := IdempotencyRecord{PayloadHash: "hash-1", Expires: time.Now().Add(-time.Second)}
+	live := IdempotencyRecord{PayloadHash: "hash-1", Expires: time.Now().Add(time.Hour)}
+	for _, key := range []string{"expired-1", "expired-2"} {
+		if err := store.Save(ctx, key, expired); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+	}
+	if err := store.Save(ctx, "live", live); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	claim := func(i int) {
+		t.Helper()
+		if _, _, err := store.Claim(ctx, "live", live); err != nil {
+			t.Fatalf("Claim() %d error = %v", i, err)
+		}
+	}
+
+	for i := 1; i < memoryIdempotencySweepClaims; i++ {
+		claim(i)
+	}
+	if len(store.records) != 3 {
+		t.Errorf("%d records before the sweep, want 3: claims must not scan the store", len(store.records))
+	}
+
+	claim(memoryIdempotencySweepClaims)
+	if _, ok := store.records["live"]; !ok || len(store.records) != 1 {
+		t.Errorf("records after the sweep = %v, want only the live one", store.records)
+	}
+}
+
+func TestCreateUserHandlerIdempotencyKey(t *testing.T) {
+	const body = `{"name":"Ann","email":"ann@example.com"}`
+
+	tests := []struct {
+		name       string
+		keys       [2]string
+		ttl        string
+		wantReplay bool
+	}{
+		{name: "same key replays", keys: [2]string{"key-1", "key-1"}, wantReplay: true},
+		{name: "different keys", keys: [2]string{"key-1", "key-2"}},
+		{name: "no key", keys: [2]string{"", ""}},
+		{name: "expired key", keys: [2]string{"key-1", "key-1"}, ttl: "1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("IDEMPOTENCY_TTL_MS", tt.ttl)
+			saved := sharedIdempotency
+			SetIdempotencyStore(NewMemoryIdempotencyStore())
+			t.Cleanup(func() { SetIdempotencyStore(saved) })
+			repo := seedUsers(t)
+			handler := NewUserHandler(repo)
+			ctx := context.Background()
+
+			// serve runs the request the way the router's idempotency middleware does
+			serve := func(key string) events.APIGatewayProxyResponse {
+				t.Helper()
+				request := jsonRequest(http.MethodPost, body)
+				request.Path = "/users"
+				if key != "" {
+					request.Headers[IdempotencyKeyHeader] = key
+				}
+
+				call, replay, err := StartIdempotent(ctx, request)
+				if err != nil {
+					t.Fatalf("StartIdempotent() error = %v", err)
+				}
+				if replay != nil {
+					return *replay
+				}
+				response, err := handler.CreateUserHandler(ctx, request)
+				if err != nil {
+					t.Fatalf("CreateUserHandler() error = %v", err)
+				}
+				FinishIdempotent(ctx, call, response)
+
+				return response
+			}
+
+			first := serve(tt.keys[0])
+			if first.StatusCode != http.StatusCreated {
+				t.Fatalf("first status = %d, want %d (body %s)", first.StatusCode, http.StatusCreated, first.Body)
+			}
+			time.Sleep(5 * time.Millisecond)
+			second := serve(tt.keys[1])
+
+			replayed := second.Headers[IdempotentReplayedHeader] == "true"
+			if replayed != tt.wantReplay {
+				t.Errorf("replayed = %t, want %t", replayed, tt.wantReplay)
+			}
+			if tt.wantReplay && (second.StatusCode != first.StatusCode || second.Body != first.Body) {
+				t.Errorf("replay = %d %s, want %d %s", second.StatusCode, second.Body, first.StatusCode, first.Body)
+			}
+			if !tt.wantReplay && second.StatusCode != http.StatusConflict {
+				t.Errorf("second status = %d, want %d", second.StatusCode, http.StatusConflict)
+			}
+
+			users, _, err := repo.GetAllUsers(ctx, models.ListQuery{})
+			if err != nil || len(users) != 1 {
+				t.Errorf("GetAllUsers() = %d users, %v; want 1", len(users), err)
+			}
+		})
+	}
+}
+
+// expiryRecordingStore records the expiry of every record claimed or saved in it.
+type expiryRecordingStore struct {
+	IdempotencyStore
+	claimed, saved time.Time
+}
+
+func (s *expiryRecordingStore) Claim(
+	ctx context.Context, key string, record IdempotencyRecord,
+) (IdempotencyRecord, bool, error) {
+	s.claimed = record.Expires
+
+	return s.IdempotencyStore.Claim(ctx, key, record)
+}
+
+func (s *expiryRecordingStore) Save(ctx context.Context, key string, record IdempotencyRecord) error {
+	s.saved = record.Expires
+
+	return s.IdempotencyStore.Save(ctx, key, record)
+}
+
+func TestIdempotencyLease(t *testing.T) {
+	tests := []struct {
+		name      string
+		lease     string
+		ttl       string
+		wantLease time.Duration
+		wantTTL   time.Duration
+	}{
+		{name: "defaults", wantLease: DefaultIdempotencyLease, wantTTL: DefaultIdempotencyTTL},
+		{name: "configured", lease: "5000", ttl: "600000", wantLease: 5 * time.Second, wantTTL: 10 * time.Minute},
+		{name: "lease capped at the TTL", lease: "600000", ttl: "1000", wantLease: time.Second, wantTTL: time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("IDEMPOTENCY_LEASE_MS", tt.lease)
+			t.Setenv("IDEMPOTENCY_TTL_MS", tt.ttl)
+			store := &expiryRecordingStore{IdempotencyStore: NewMemoryIdempotencyStore()}
+			SetIdempotencyStore(store)
+			t.Cleanup(func() { SetIdempotencyStore(NewMemoryIdempotencyStore()) })
+
+			request := events.APIGatewayProxyRequest{
+				HTTPMethod: http.MethodPost,
+				Path:       "/users",
+				Headers:    map[string]string{IdempotencyKeyHeader: "k1"},
+				Body:       `{"name":"Ann"}`,
+			}
+			start := time.Now()
+			call, replay, err := StartIdempotent(context.Background(), request)
+			if err != nil || call == nil || replay != nil {
+				t.Fatalf("StartIdempotent() = %v, %v, %v; want a call", call, replay, err)
+			}
+			if lease := store.claimed.Sub(start); lease < tt.wantLease || lease > tt.wantLease+time.Second {
+				t.Errorf("claim expires after %v, want %v", lease, tt.wantLease)
+			}
+
+			FinishIdempotent(context.Background(), call, events.APIGatewayProxyResponse{StatusCode: http.StatusCreated})
+			if ttl := store.saved.Sub(start); ttl < tt.wantTTL || ttl > tt.wantTTL+time.Second {
+				t.Errorf("saved response expires after %v, want %v", ttl, tt.wantTTL)
+			}
+		})
+	}
+}
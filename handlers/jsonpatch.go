@@ -0,0 +1,217 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go-lambda-api/models"
+)
+
+// JSONPatchContentType selects RFC 6902 JSON Patch on PATCH requests.
+const JSONPatchContentType = "application/json-patch+json"
+
+// errPatchTestFailed is returned when a JSON Patch "test" operation does not match.
+var errPatchTestFailed = errors.New("patch test operation failed")
+
+// patchOperation is a single RFC 6902 operation.
+type patchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from"`
+	Value json.RawMessage `json:"value"`
+}
+
+// jsonPatchUser applies an RFC 6902 JSON Patch document to user and returns the patched user.
+// Operations are applied in order and atomically: any failure leaves the user unchanged.
+func jsonPatchUser(user models.User, body []byte) (models.User, error) {
+	var operations []patchOperation
+	if err := json.Unmarshal(body, &operations); err != nil {
+		return models.User{}, fmt.Errorf("invalid JSON Patch document: %w", err)
+	}
+	if len(operations) == 0 {
+		return models.User{}, errors.New("no fields to update")
+	}
+
+	original, err := json.Marshal(user)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	document, err := decodeJSONMap(original)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	for i, operation := range operations {
+		if err := applyPatchOperation(document, operation); err != nil {
+			return models.User{}, fmt.Errorf("operation %d: %w", i, err)
+		}
+	}
+
+	for field, optional := range userFields {
+		if _, present := document[field]; !present && !optional {
+			return models.User{}, fmt.Errorf("field %q cannot be removed", field)
+		}
+	}
+
+	patched, err := json.Marshal(document)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	var patchedUser models.User
+	if err := json.Unmarshal(patched, &patchedUser); err != nil {
+		return models.User{}, err
+	}
+
+	return patchedUser, nil
+}
+
+func applyPatchOperation(document map[string]interface{}, operation patchOperation) error {
+	path, err := parsePatchPath(operation.Path, operation.Op != "test")
+	if err != nil {
+		return err
+	}
+
+	switch operation.Op {
+	case "add", "replace", "test":
+		value, err := decodePatchValue(operation.Value)
+		if err != nil {
+			return err
+		}
+
+		parent, key, err := resolveParent(document, path)
+		if err != nil {
+			return err
+		}
+
+		current, exists := parent[key]
+		switch operation.Op {
+		case "add":
+			parent[key] = value
+		case "replace":
+			if !exists {
+				return fmt.Errorf("path %q does not exist", operation.Path)
+			}
+			parent[key] = value
+		default:
+			if !exists || !reflect.DeepEqual(current, value) {
+				return errPatchTestFailed
+			}
+		}
+	case "remove":
+		_, err := removeAt(document, path, operation.Path)
+		return err
+	case "move", "copy":
+		from, err := parsePatchPath(operation.From, operation.Op == "move")
+		if err != nil {
+			return err
+		}
+
+		var value interface{}
+		if operation.Op == "move" {
+			value, err = removeAt(document, from, operation.From)
+		} else {
+			value, err = valueAt(document, from, operation.From)
+		}
+		if err != nil {
+			return err
+		}
+
+		parent, key, err := resolveParent(document, path)
+		if err != nil {
+			return err
+		}
+		parent[key] = value
+	default:
+		return fmt.Errorf("unsupported op %q", operation.Op)
+	}
+
+	return nil
+}
+
+// parsePatchPath splits a JSON Pointer into unescaped tokens. The first token must be a
+// user field, and if the operation modifies the target it must not be an immutable field.
+func parsePatchPath(pointer string, modifies bool) ([]string, error) {
+	if !strings.HasPrefix(pointer, "/") {
+		return nil, fmt.Errorf("invalid path %q", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+
+	if _, known := userFields[tokens[0]]; !known {
+		return nil, fmt.Errorf("invalid path %q", pointer)
+	}
+	if modifies && immutableUserFields[tokens[0]] {
+		return nil, fmt.Errorf("field %q cannot be modified", tokens[0])
+	}
+
+	return tokens, nil
+}
+
+// resolveParent returns the object containing the final path token, which must exist.
+func resolveParent(document map[string]interface{}, path []string) (map[string]interface{}, string, error) {
+	parent := document
+	for _, token := range path[:len(path)-1] {
+		child, ok := parent[token].(map[string]interface{})
+		if !ok {
+			return nil, "", fmt.Errorf("invalid path: %q is not an object", token)
+		}
+		parent = child
+	}
+
+	return parent, path[len(path)-1], nil
+}
+
+func valueAt(document map[string]interface{}, path []string, pointer string) (interface{}, error) {
+	parent, key, err := resolveParent(document, path)
+	if err != nil {
+		return nil, err
+	}
+
+	value, exists := parent[key]
+	if !exists {
+		return nil, fmt.Errorf("path %q does not exist", pointer)
+	}
+
+	return value, nil
+}
+
+func removeAt(document map[string]interface{}, path []string, pointer string) (interface{}, error) {
+	parent, key, err := resolveParent(document, path)
+	if err != nil {
+		return nil, err
+	}
+
+	value, exists := parent[key]
+	if !exists {
+		return nil, fmt.Errorf("path %q does not exist", pointer)
+	}
+	delete(parent, key)
+
+	return value, nil
+}
+
+// decodePatchValue decodes an operation value, keeping numbers as json.Number.
+func decodePatchValue(raw json.RawMessage) (interface{}, error) {
+	if len(raw) == 0 {
+		return nil, errors.New("value is required")
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+
+	return value, nil
+}
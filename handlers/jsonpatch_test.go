@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"go-lambda-api/models"
+)
+
+func TestPatchUserHandlerJSONPatch(t *testing.T) {
+	existing := models.User{
+		ID: "user-1", Name: "Ann", Email: "ann@example.com", Metadata: map[string]string{"team": "payments"},
+	}
+
+	tests := []struct {
+		name         string
+		body         string
+		wantStatus   int
+		wantName     string
+		wantEmail    string
+		wantMetadata map[string]string
+	}{
+		{
+			name:         "replace",
+			body:         `[{"op":"replace","path":"/email","value":"ann.lee@example.com"}]`,
+			wantStatus:   http.StatusOK,
+			wantName:     "Ann",
+			wantEmail:    "ann.lee@example.com",
+			wantMetadata: map[string]string{"team": "payments"},
+		},
+		{
+			name: "operations apply in order",
+			body: `[{"op":"test","path":"/name","value":"Ann"},{"op":"add","path":"/metadata/role","value":"admin"},` +
+				`{"op":"remove","path":"/metadata/team"},{"op":"copy","from":"/email","path":"/metadata/contact"}]`,
+			wantStatus:   http.StatusOK,
+			wantName:     "Ann",
+			wantEmail:    "ann@example.com",
+			wantMetadata: map[string]string{"role": "admin", "contact": "ann@example.com"},
+		},
+		{
+			name:       "replace of immutable id",
+			body:       `[{"op":"replace","path":"/id","value":"other"}]`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "remove of immutable created_at",
+			body:       `[{"op":"remove","path":"/created_at"}]`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unknown field",
+			body:       `[{"op":"replace","path":"/nickname","value":"A"}]`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "path without leading slash",
+			body:       `[{"op":"replace","path":"name","value":"A"}]`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "replace of a missing key",
+			body:       `[{"op":"replace","path":"/metadata/missing","value":"x"}]`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "remove of a required field",
+			body:       `[{"op":"remove","path":"/name"}]`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "unsupported op",
+			body:       `[{"op":"merge","path":"/name","value":"A"}]`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "failed test leaves the user unchanged",
+			body:       `[{"op":"replace","path":"/name","value":"Bob"},{"op":"test","path":"/email","value":"x@example.com"}]`,
+			wantStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := seedUsers(t, existing)
+			handler := NewUserHandler(repo)
+
+			request := jsonRequest(http.MethodPatch, tt.body)
+			request.Headers["Content-Type"] = JSONPatchContentType
+			request.PathParameters = map[string]string{"id": existing.ID}
+
+			response, err := handler.PatchUserHandler(context.Background(), request)
+			if err != nil {
+				t.Fatalf("PatchUserHandler() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", response.StatusCode, tt.wantStatus, response.Body)
+			}
+
+			stored, err := repo.GetUserByID(context.Background(), existing.ID)
+			if err != nil {
+				t.Fatalf("GetUserByID() error = %v", err)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if stored.Name != existing.Name || stored.Email != existing.Email ||
+					!reflect.DeepEqual(stored.Metadata, existing.Metadata) {
+					t.Errorf("stored user = %+v, want it unchanged", stored)
+				}
+				return
+			}
+
+			if stored.Name != tt.wantName || stored.Email != tt.wantEmail ||
+				!reflect.DeepEqual(stored.Metadata, tt.wantMetadata) {
+				t.Errorf("stored user = %+v, want name %q, email %q, metadata %v",
+					stored, tt.wantName, tt.wantEmail, tt.wantMetadata)
+			}
+		})
+	}
+}
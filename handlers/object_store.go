@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+)
+
+// errObjectStoreUnavailable is returned by newObjectStore in builds without S3 support.
+var errObjectStoreUnavailable = errors.New("object storage requires building with -tags dynamodb")
+
+// Object is an object read from object storage.
+type Object struct {
+	Body        io.ReadCloser
+	ContentType string
+}
+
+// ObjectGetter reads import files from object storage.
+type ObjectGetter interface {
+	GetObject(ctx context.Context, bucket, key string) (Object, error)
+}
+
+// ObjectPutter stores avatars in object storage.
+type ObjectPutter interface {
+	PutObject(ctx context.Context, bucket, key, contentType string, body []byte) error
+}
+
+// ObjectStore reads and writes objects. The dynamodb build backs it with S3; other builds
+// have none, and the routes that need it answer 501.
+type ObjectStore interface {
+	ObjectGetter
+	ObjectPutter
+}
+
+func awsRegion() string {
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+
+	return "us-east-1"
+}
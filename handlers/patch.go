@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"go-lambda-api/models"
+)
+
+// immutableUserFields are user document fields that no update path may change.
+// avatar_url is only set by the avatar upload endpoint, and updated_at and version by the server.
+var immutableUserFields = map[string]bool{
+	"id":         true,
+	"created_at": true,
+	"updated_at": true,
+	"avatar_url": true,
+	"version":    true,
+}
+
+// userFields maps each JSON field of models.User to whether it is optional (omitempty).
+// Optional fields may be removed by patching them to null.
+var userFields = jsonFields(reflect.TypeOf(models.User{}))
+
+func jsonFields(t reflect.Type) map[string]bool {
+	fields := make(map[string]bool, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		name, options, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fields[name] = strings.Contains(options, "omitempty")
+	}
+
+	return fields
+}
+
+// decodeJSONMap decodes a JSON object into a map, keeping numbers as json.Number
+// so large integers survive a decode/encode round trip without float64 rounding.
+func decodeJSONMap(data []byte) (map[string]interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var document map[string]interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	if document == nil {
+		return nil, errors.New("request body must be a JSON object")
+	}
+
+	return document, nil
+}
+
+// mergePatchUser applies an RFC 7396 JSON merge patch to user and returns the merged user.
+// Only user fields may be patched, immutable fields are rejected, and null removes
+// optional fields (or keys within an object such as metadata).
+func mergePatchUser(user models.User, patch map[string]interface{}) (models.User, error) {
+	if len(patch) == 0 {
+		return models.User{}, errors.New("no fields to update")
+	}
+
+	for field, value := range patch {
+		optional, known := userFields[field]
+		if !known {
+			return models.User{}, fmt.Errorf("unknown field %q", field)
+		}
+		if immutableUserFields[field] {
+			return models.User{}, fmt.Errorf("field %q cannot be modified", field)
+		}
+		if value == nil && !optional {
+			return models.User{}, fmt.Errorf("field %q cannot be null", field)
+		}
+	}
+
+	original, err := json.Marshal(user)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	document, err := decodeJSONMap(original)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	merged, err := json.Marshal(mergePatch(document, patch))
+	if err != nil {
+		return models.User{}, err
+	}
+
+	var patched models.User
+	if err := json.Unmarshal(merged, &patched); err != nil {
+		return models.User{}, err
+	}
+
+	return patched, nil
+}
+
+// mergePatch applies patch to document per RFC 7396: objects merge recursively,
+// null deletes a key and any other value replaces it.
+func mergePatch(document, patch map[string]interface{}) map[string]interface{} {
+	for key, value := range patch {
+		if value == nil {
+			delete(document, key)
+			continue
+		}
+
+		patchObject, isObject := value.(map[string]interface{})
+		if !isObject {
+			document[key] = value
+			continue
+		}
+
+		target, _ := document[key].(map[string]interface{})
+		if target == nil {
+			target = make(map[string]interface{})
+		}
+		document[key] = mergePatch(target, patchObject)
+	}
+
+	return document
+}
@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMergePatchPreservesLargeIntegers(t *testing.T) {
+	tests := []struct {
+		name   string
+		number string
+	}{
+		{name: "beyond float64 precision", number: "9007199254740993"},
+		{name: "max int64", number: "9223372036854775807"},
+		{name: "min int64", number: "-9223372036854775808"},
+		{name: "decimal", number: "0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			document, err := decodeJSONMap([]byte(`{"name":"Ann","counter":1}`))
+			if err != nil {
+				t.Fatalf("decodeJSONMap(document) error = %v", err)
+			}
+			patch, err := decodeJSONMap([]byte(`{"counter":` + tt.number + `}`))
+			if err != nil {
+				t.Fatalf("decodeJSONMap(patch) error = %v", err)
+			}
+
+			merged, err := json.Marshal(mergePatch(document, patch))
+			if err != nil {
+				t.Fatalf("marshaling merged document: %v", err)
+			}
+
+			want := `{"counter":` + tt.number + `,"name":"Ann"}`
+			if string(merged) != want {
+				t.Errorf("merged = %s, want %s", merged, want)
+			}
+		})
+	}
+}
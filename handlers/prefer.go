@@ -0,0 +1,25 @@
+package handlers
+
+import (
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/utils"
+)
+
+// PreferHeader is the RFC 7240 request header used to express response preferences.
+const PreferHeader = "Prefer"
+
+// preference returns the value of the named preference in the request's Prefer header,
+// e.g. "representation" for "return" given "Prefer: return=representation".
+func preference(request events.APIGatewayProxyRequest, name string) string {
+	for _, pref := range strings.Split(utils.GetHeader(request, PreferHeader), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(pref), "=")
+		if strings.EqualFold(strings.TrimSpace(key), name) {
+			return strings.Trim(strings.TrimSpace(value), `"`)
+		}
+	}
+
+	return ""
+}
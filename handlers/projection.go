@@ -0,0 +1,33 @@
+package handlers
+
+import (
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/models"
+)
+
+// parseFields parses a ?fields=name,email projection into a sorted list of user fields that
+// always includes id. It returns nil when no projection was requested and an error naming
+// the first unknown field.
+func parseFields(request events.APIGatewayProxyRequest) (models.Projection, error) {
+	value, ok := request.QueryStringParameters["fields"]
+	if !ok {
+		return nil, nil
+	}
+
+	return models.ParseProjection(value)
+}
+
+// project removes every key of document not in fields.
+func project(document map[string]interface{}, fields []string) {
+	keep := make(map[string]bool, len(fields))
+	for _, field := range fields {
+		keep[field] = true
+	}
+
+	for key := range document {
+		if !keep[key] {
+			delete(document, key)
+		}
+	}
+}
@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/models"
+	"go-lambda-api/utils"
+)
+
+// TimestampFormatHeader selects how timestamps are rendered: "epoch" for Unix epoch
+// milliseconds, anything else (the default) for RFC 3339.
+const TimestampFormatHeader = "X-Timestamp-Format"
+
+// EMPTY_FIELD_POLICY values controlling how unset optional fields are rendered.
+const (
+	EmptyFieldOmit  = "omit"  // leave the field out (default)
+	EmptyFieldNull  = "null"  // render the field as null
+	EmptyFieldEmpty = "empty" // render the field as "" or {}
+)
+
+// emptyFieldPolicy returns the configured EMPTY_FIELD_POLICY, defaulting to omit.
+func emptyFieldPolicy() string {
+	switch policy := strings.ToLower(os.Getenv("EMPTY_FIELD_POLICY")); policy {
+	case EmptyFieldNull, EmptyFieldEmpty:
+		return policy
+	default:
+		return EmptyFieldOmit
+	}
+}
+
+// renderUser returns the response representation of user for request.
+func renderUser(request events.APIGatewayProxyRequest, user models.User) interface{} {
+	epoch := strings.EqualFold(utils.GetHeader(request, TimestampFormatHeader), "epoch")
+	policy := emptyFieldPolicy()
+	fields, _ := parseFields(request) // handlers reject invalid projections before rendering
+	hidden := hiddenUserFields(request)
+	hal := wantsHAL(request)
+	if !epoch && policy == EmptyFieldOmit && fields == nil && hidden == nil && !hal {
+		return user
+	}
+
+	data, err := json.Marshal(user)
+	if err != nil {
+		return user
+	}
+
+	document, err := decodeJSONMap(data)
+	if err != nil {
+		return user
+	}
+
+	value := reflect.ValueOf(user)
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		name, options, _ := strings.Cut(field.Tag.Get("json"), ",")
+
+		if timestamp, ok := value.Field(i).Interface().(time.Time); ok && epoch {
+			if timestamp.IsZero() {
+				delete(document, name)
+			} else {
+				document[name] = timestamp.UnixMilli()
+			}
+		}
+
+		// Fields dropped by omitempty are unset optional fields
+		if _, present := document[name]; present || !strings.Contains(options, "omitempty") {
+			continue
+		}
+		switch policy {
+		case EmptyFieldNull:
+			document[name] = nil
+		case EmptyFieldEmpty:
+			document[name] = emptyValue(field.Type)
+		}
+	}
+
+	if fields != nil {
+		project(document, fields)
+	}
+	for _, field := range hidden {
+		delete(document, field)
+	}
+	if hal {
+		document["_links"] = userLinks(user.ID)
+	}
+
+	return document
+}
+
+// emptyValue returns the "empty" rendering of an unset field of type t: "" for strings,
+// {} for maps and null for anything without a natural empty form, such as timestamps.
+func emptyValue(t reflect.Type) interface{} {
+	switch t.Kind() {
+	case reflect.String:
+		return ""
+	case reflect.Map:
+		return map[string]interface{}{}
+	default:
+		return nil
+	}
+}
+
+// renderUsers returns the response representation of a list of users.
+func renderUsers(request events.APIGatewayProxyRequest, users []models.User) []interface{} {
+	rendered := make([]interface{}, 0, len(users))
+	for _, user := range users {
+		rendered = append(rendered, renderUser(request, user))
+	}
+
+	return rendered
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/models"
+)
+
+func TestRenderUserTimestampFormat(t *testing.T) {
+	createdAt := time.Date(2024, time.March, 1, 12, 30, 0, 123000000, time.UTC)
+	updatedAt := createdAt.Add(time.Hour)
+	user := models.User{ID: "user-1", Name: "Ann", Email: "ann@example.com", CreatedAt: createdAt, UpdatedAt: updatedAt}
+
+	tests := []struct {
+		name          string
+		format        string
+		wantCreatedAt string
+		wantUpdatedAt string
+	}{
+		{
+			name:          "RFC 3339 by default",
+			wantCreatedAt: `"2024-03-01T12:30:00.123Z"`,
+			wantUpdatedAt: `"2024-03-01T13:30:00.123Z"`,
+		},
+		{name: "epoch", format: "epoch", wantCreatedAt: "1709296200123", wantUpdatedAt: "1709299800123"},
+		{name: "epoch ignores case", format: "EPOCH", wantCreatedAt: "1709296200123", wantUpdatedAt: "1709299800123"},
+		{
+			name:          "unknown format",
+			format:        "unix",
+			wantCreatedAt: `"2024-03-01T12:30:00.123Z"`,
+			wantUpdatedAt: `"2024-03-01T13:30:00.123Z"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := events.APIGatewayProxyRequest{Headers: map[string]string{}}
+			if tt.format != "" {
+				request.Headers[TimestampFormatHeader] = tt.format
+			}
+
+			data, err := json.Marshal(renderUser(request, user))
+			if err != nil {
+				t.Fatalf("marshaling the representation: %v", err)
+			}
+			var document map[string]json.RawMessage
+			if err := json.Unmarshal(data, &document); err != nil {
+				t.Fatalf("decoding %s: %v", data, err)
+			}
+
+			if got := string(document["created_at"]); got != tt.wantCreatedAt {
+				t.Errorf("created_at = %s, want %s", got, tt.wantCreatedAt)
+			}
+			if got := string(document["updated_at"]); got != tt.wantUpdatedAt {
+				t.Errorf("updated_at = %s, want %s", got, tt.wantUpdatedAt)
+			}
+		})
+	}
+}
+
+func TestRenderUserEmptyFieldPolicy(t *testing.T) {
+	createdAt := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	unset := models.User{ID: "user-1", Name: "Ann", Email: "ann@example.com", CreatedAt: createdAt, UpdatedAt: createdAt}
+	set := unset
+	set.AvatarURL = "https://cdn.example.com/avatars/user-1.png"
+	set.Metadata = map[string]string{"team": "payments"}
+
+	tests := []struct {
+		name          string
+		policy        string
+		user          models.User
+		wantAvatarURL string
+		wantMetadata  string
+	}{
+		{name: "omit by default", user: unset},
+		{name: "omit", policy: EmptyFieldOmit, user: unset},
+		{name: "null", policy: EmptyFieldNull, user: unset, wantAvatarURL: "null", wantMetadata: "null"},
+		{name: "empty", policy: EmptyFieldEmpty, user: unset, wantAvatarURL: `""`, wantMetadata: "{}"},
+		{name: "unknown policy omits", policy: "blank", user: unset},
+		{
+			name:          "set fields are unaffected",
+			policy:        EmptyFieldNull,
+			user:          set,
+			wantAvatarURL: `"https://cdn.example.com/avatars/user-1.png"`,
+			wantMetadata:  `{"team":"payments"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("EMPTY_FIELD_POLICY", tt.policy)
+
+			data, err := json.Marshal(renderUser(events.APIGatewayProxyRequest{}, tt.user))
+			if err != nil {
+				t.Fatalf("marshaling the representation: %v", err)
+			}
+			var document map[string]json.RawMessage
+			if err := json.Unmarshal(data, &document); err != nil {
+				t.Fatalf("decoding %s: %v", data, err)
+			}
+
+			for field, want := range map[string]string{"avatar_url": tt.wantAvatarURL, "metadata": tt.wantMetadata} {
+				got, present := document[field]
+				if want == "" {
+					if present {
+						t.Errorf("%s = %s, want it omitted", field, got)
+					}
+					continue
+				}
+				if string(got) != want {
+					t.Errorf("%s = %s, want %s", field, got, want)
+				}
+			}
+			if string(document["created_at"]) != `"2024-03-01T12:00:00Z"` {
+				t.Errorf("created_at = %s, want it rendered as set", document["created_at"])
+			}
+		})
+	}
+}
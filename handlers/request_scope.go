@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/models"
+	"go-lambda-api/utils"
+)
+
+// WithRequestScope returns ctx prepared for serving request: it carries the request's retry
+// budget and feature flags, including percentage rollouts. Feature overrides from the
+// X-Feature-Overrides header are only honored for admin callers and ignored for everyone else.
+func WithRequestScope(ctx context.Context, request events.APIGatewayProxyRequest) context.Context {
+	ctx = models.ContextWithRetryBudget(
+		ctx, models.NewRetryBudget(utils.GetEnvInt("REQUEST_RETRY_BUDGET", models.DefaultRetryBudget)),
+	)
+
+	features := utils.RequestFeatures(request)
+	if header := utils.GetHeader(request, utils.FeatureOverridesHeader); header != "" && IsAdmin(request) {
+		overridden, err := utils.ApplyFeatureOverrides(features, header)
+		if err != nil {
+			log.Printf("Ignoring feature overrides: %v", err)
+		} else {
+			features = overridden
+		}
+	}
+
+	return utils.ContextWithFeatures(ctx, features)
+}
+
+// decodeBody unmarshals a JSON request body into v, rejecting unknown fields with
+// utils.DecodeStrict unless the strict_json feature is disabled for the request.
+func decodeBody(ctx context.Context, body []byte, v interface{}) error {
+	if !utils.FeatureEnabled(ctx, utils.FeatureStrictJSON) {
+		return json.Unmarshal(body, v)
+	}
+
+	return utils.DecodeStrict(body, v)
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/utils"
+)
+
+func TestWithRequestScopeFeatureOverrides(t *testing.T) {
+	tests := []struct {
+		name           string
+		adminToken     string
+		token          string
+		overrides      string
+		wantStrictJSON bool
+		wantPrettyJSON bool
+	}{
+		{name: "defaults", adminToken: testAdminToken, token: testAdminToken, wantStrictJSON: true},
+		{
+			name:           "admin overrides apply",
+			adminToken:     testAdminToken,
+			token:          testAdminToken,
+			overrides:      "strict_json=false, pretty_json=true",
+			wantPrettyJSON: true,
+		},
+		{
+			name:           "ignored without a token",
+			adminToken:     testAdminToken,
+			overrides:      "strict_json=false,pretty_json=true",
+			wantStrictJSON: true,
+		},
+		{
+			name:           "ignored with a wrong token",
+			adminToken:     testAdminToken,
+			token:          "wrong",
+			overrides:      "strict_json=false,pretty_json=true",
+			wantStrictJSON: true,
+		},
+		{
+			name:           "ignored when admin access is disabled",
+			token:          testAdminToken,
+			overrides:      "strict_json=false,pretty_json=true",
+			wantStrictJSON: true,
+		},
+		{
+			name:           "unknown flag rejects every override",
+			adminToken:     testAdminToken,
+			token:          testAdminToken,
+			overrides:      "pretty_json=true,chaos=true",
+			wantStrictJSON: true,
+		},
+		{
+			name:           "invalid value rejects every override",
+			adminToken:     testAdminToken,
+			token:          testAdminToken,
+			overrides:      "pretty_json=true,strict_json=maybe",
+			wantStrictJSON: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ADMIN_TOKEN", tt.adminToken)
+			t.Setenv("FEATURE_STRICT_JSON", "")
+			t.Setenv("FEATURE_PRETTY_JSON", "")
+
+			headers := map[string]string{utils.FeatureOverridesHeader: tt.overrides}
+			if tt.token != "" {
+				headers[AdminTokenHeader] = tt.token
+			}
+			ctx := WithRequestScope(context.Background(), events.APIGatewayProxyRequest{Headers: headers})
+
+			if got := utils.FeatureEnabled(ctx, utils.FeatureStrictJSON); got != tt.wantStrictJSON {
+				t.Errorf("strict_json = %v, want %v", got, tt.wantStrictJSON)
+			}
+			if got := utils.FeatureEnabled(ctx, utils.FeaturePrettyJSON); got != tt.wantPrettyJSON {
+				t.Errorf("pretty_json = %v, want %v", got, tt.wantPrettyJSON)
+			}
+		})
+	}
+}
@@ -0,0 +1,294 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/utils"
+)
+
+// Schema validation modes, selected with SCHEMA_VALIDATION.
+const (
+	// SchemaValidationOff skips schema checks.
+	SchemaValidationOff = "off"
+	// SchemaValidationRequest rejects request bodies that do not match their route's schema.
+	SchemaValidationRequest = "request"
+	// SchemaValidationAll also checks successful responses, turning a mismatch into a 500.
+	// It is meant for development, to catch handlers drifting from the contract.
+	SchemaValidationAll = "all"
+)
+
+// JSON types a schema field may have.
+const (
+	schemaString  = "string"
+	schemaNumber  = "number"
+	schemaBoolean = "boolean"
+	schemaObject  = "object"
+	schemaArray   = "array"
+)
+
+// fieldSchema describes one member of a JSON object.
+type fieldSchema struct {
+	Types    []string // any of these JSON types
+	Required bool
+	Nullable bool
+	Values   string // JSON type of every value, for objects such as metadata
+}
+
+// objectSchema is the allowlist of members of a JSON object body. Members not listed are rejected.
+type objectSchema map[string]fieldSchema
+
+// routeSchema holds the body schemas of one route; nil means the body is not checked.
+type routeSchema struct {
+	Request  objectSchema
+	Response objectSchema
+}
+
+var (
+	userCreateSchema = objectSchema{
+		"id":       {Types: []string{schemaString}},
+		"name":     {Types: []string{schemaString}, Required: true},
+		"email":    {Types: []string{schemaString}, Required: true},
+		"metadata": {Types: []string{schemaObject}, Values: schemaString},
+	}
+	userUpdateSchema = objectSchema{
+		"name":     {Types: []string{schemaString}},
+		"email":    {Types: []string{schemaString}},
+		"metadata": {Types: []string{schemaObject}, Values: schemaString},
+		"version":  {Types: []string{schemaNumber}},
+	}
+	userMergePatchSchema = objectSchema{
+		"name":     {Types: []string{schemaString}},
+		"email":    {Types: []string{schemaString}},
+		"metadata": {Types: []string{schemaObject}, Values: schemaString, Nullable: true},
+	}
+
+	// userSchema matches every rendering of a user: timestamps may be epoch numbers, unset
+	// fields may be null under EMPTY_FIELD_POLICY, and projections may leave anything but id out.
+	userSchema = objectSchema{
+		"id":         {Types: []string{schemaString}, Required: true},
+		"name":       {Types: []string{schemaString}, Nullable: true},
+		"email":      {Types: []string{schemaString}, Nullable: true},
+		"created_at": {Types: []string{schemaString, schemaNumber}, Nullable: true},
+		"updated_at": {Types: []string{schemaString, schemaNumber}, Nullable: true},
+		"avatar_url": {Types: []string{schemaString}, Nullable: true},
+		"version":    {Types: []string{schemaNumber}, Nullable: true},
+		"metadata":   {Types: []string{schemaObject}, Values: schemaString, Nullable: true},
+		"_links":     {Types: []string{schemaObject}},
+	}
+	userCreatedSchema = withFields(userSchema, objectSchema{
+		"defaults_applied": {Types: []string{schemaArray}},
+	})
+)
+
+// routeSchemas maps "METHOD /route/{template}" to the schemas enforced for it.
+var routeSchemas = map[string]routeSchema{
+	"POST /users":       {Request: userCreateSchema, Response: userCreatedSchema},
+	"GET /users/{id}":   {Response: userSchema},
+	"PUT /users/{id}":   {Request: userUpdateSchema, Response: userSchema},
+	"PATCH /users/{id}": {Request: userMergePatchSchema, Response: userSchema},
+}
+
+func withFields(base, extra objectSchema) objectSchema {
+	merged := make(objectSchema, len(base)+len(extra))
+	for name, field := range base {
+		merged[name] = field
+	}
+	for name, field := range extra {
+		merged[name] = field
+	}
+
+	return merged
+}
+
+// SchemaError describes a body that does not match its route's schema.
+type SchemaError struct {
+	Field   string
+	Message string
+}
+
+func (e *SchemaError) Error() string {
+	if e.Field == "" {
+		return e.Message
+	}
+
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// FieldName returns the offending member, so error responses carry a "field" key.
+func (e *SchemaError) FieldName() string {
+	return e.Field
+}
+
+func schemaValidationMode() string {
+	switch mode := strings.ToLower(os.Getenv("SCHEMA_VALIDATION")); mode {
+	case SchemaValidationRequest, SchemaValidationAll:
+		return mode
+	default:
+		return SchemaValidationOff
+	}
+}
+
+// ValidateRequestSchema checks a JSON request body against the schema registered for the
+// request's method and route, e.g. "/users/{id}", decoding it first when it arrived
+// base64-encoded. It returns a *SchemaError when the body does not match, and nil when
+// validation is off, the route has no request schema, or the body is a JSON Patch document.
+// Bodies that are too large or not valid base64 are left to the handler, which rejects them
+// with the right status.
+func ValidateRequestSchema(request events.APIGatewayProxyRequest, route string) error {
+	if schemaValidationMode() == SchemaValidationOff {
+		return nil
+	}
+
+	schema := routeSchemas[request.HTTPMethod+" "+route].Request
+	if schema == nil {
+		return nil
+	}
+	mediaType, _, _ := mime.ParseMediaType(utils.GetHeader(request, "Content-Type"))
+	if mediaType == JSONPatchContentType {
+		return nil
+	}
+
+	body, err := jsonBody(request)
+	if err != nil {
+		return nil
+	}
+
+	return schema.validate(body)
+}
+
+// ValidateResponseSchema checks a successful JSON response against the schema registered
+// for the request's method and route when SCHEMA_VALIDATION is "all".
+func ValidateResponseSchema(
+	request events.APIGatewayProxyRequest, route string, response events.APIGatewayProxyResponse,
+) error {
+	if schemaValidationMode() != SchemaValidationAll {
+		return nil
+	}
+
+	schema := routeSchemas[request.HTTPMethod+" "+route].Response
+	if schema == nil || response.StatusCode >= http.StatusBadRequest || response.Body == "" ||
+		response.Headers["Content-Type"] == utils.XMLContentType {
+		return nil
+	}
+
+	if err := schema.validate([]byte(response.Body)); err != nil {
+		return fmt.Errorf("response does not match schema for %s %s: %w", request.HTTPMethod, route, err)
+	}
+
+	return nil
+}
+
+func (s objectSchema) validate(body []byte) error {
+	var document interface{}
+	if err := json.Unmarshal(body, &document); err != nil {
+		return &SchemaError{Message: "body must be valid JSON"}
+	}
+
+	object, ok := document.(map[string]interface{})
+	if !ok {
+		return &SchemaError{Message: "body must be a JSON object"}
+	}
+
+	// Report the first problem in a stable order
+	names := make([]string, 0, len(object))
+	for name := range object {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		field, ok := s[name]
+		if !ok {
+			return &SchemaError{Field: name, Message: "unknown field"}
+		}
+		if err := field.validate(name, object[name]); err != nil {
+			return err
+		}
+	}
+
+	required := make([]string, 0, len(s))
+	for name, field := range s {
+		if field.Required {
+			required = append(required, name)
+		}
+	}
+	sort.Strings(required)
+
+	for _, name := range required {
+		if _, ok := object[name]; !ok {
+			return &SchemaError{Field: name, Message: "is required"}
+		}
+	}
+
+	return nil
+}
+
+func (f fieldSchema) validate(name string, value interface{}) error {
+	if value == nil {
+		if f.Nullable {
+			return nil
+		}
+
+		return &SchemaError{Field: name, Message: "must not be null"}
+	}
+
+	valueType := jsonType(value)
+	matched := false
+	for _, t := range f.Types {
+		if t == valueType {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return &SchemaError{Field: name, Message: "must be of type " + strings.Join(f.Types, " or ")}
+	}
+
+	if object, ok := value.(map[string]interface{}); ok && f.Values != "" {
+		for key, member := range object {
+			if jsonType(member) != f.Values {
+				return &SchemaError{Field: name + "." + key, Message: "must be of type " + f.Values}
+			}
+		}
+	}
+
+	return nil
+}
+
+// jsonType names the JSON type of a value decoded by encoding/json.
+func jsonType(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return schemaString
+	case float64:
+		return schemaNumber
+	case bool:
+		return schemaBoolean
+	case map[string]interface{}:
+		return schemaObject
+	case []interface{}:
+		return schemaArray
+	default:
+		return "null"
+	}
+}
+
+// errSchemaViolation is returned to clients in place of a response that failed validation.
+var errSchemaViolation = errors.New("response failed schema validation")
+
+// SchemaViolationResponse logs a response schema mismatch and builds the 500 returned instead.
+func SchemaViolationResponse(err error) (events.APIGatewayProxyResponse, error) {
+	log.Printf("Schema violation: %v", err)
+
+	return utils.ErrorResponse(http.StatusInternalServerError, errSchemaViolation)
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestValidateRequestSchema(t *testing.T) {
+	tests := []struct {
+		name        string
+		mode        string
+		method      string
+		route       string
+		contentType string
+		body        string
+		base64      bool
+		wantField   string
+		wantErr     bool
+	}{
+		{
+			name: "conformant create", mode: SchemaValidationRequest, method: http.MethodPost, route: "/users",
+			body: `{"name":"Ann","email":"ann@example.com","metadata":{"team":"a"}}`,
+		},
+		{
+			name: "unknown field", mode: SchemaValidationRequest, method: http.MethodPost, route: "/users",
+			body: `{"name":"Ann","email":"ann@example.com","role":"admin"}`, wantErr: true, wantField: "role",
+		},
+		{
+			name: "missing required field", mode: SchemaValidationRequest, method: http.MethodPost, route: "/users",
+			body: `{"name":"Ann"}`, wantErr: true, wantField: "email",
+		},
+		{
+			name: "wrong type", mode: SchemaValidationRequest, method: http.MethodPost, route: "/users",
+			body: `{"name":42,"email":"ann@example.com"}`, wantErr: true, wantField: "name",
+		},
+		{
+			name: "wrong metadata value type", mode: SchemaValidationRequest, method: http.MethodPut, route: "/users/{id}",
+			body: `{"metadata":{"tier":1}}`, wantErr: true, wantField: "metadata.tier",
+		},
+		{
+			name: "null metadata in a merge patch", mode: SchemaValidationRequest, method: http.MethodPatch,
+			route: "/users/{id}", body: `{"metadata":null}`,
+		},
+		{
+			name: "not an object", mode: SchemaValidationRequest, method: http.MethodPost, route: "/users",
+			body: `["Ann"]`, wantErr: true,
+		},
+		{
+			name: "base64 body", mode: SchemaValidationRequest, method: http.MethodPost, route: "/users",
+			body: `{"name":"Ann","email":"ann@example.com","role":"admin"}`, base64: true, wantErr: true,
+			wantField: "role",
+		},
+		{
+			name: "JSON Patch is not checked", mode: SchemaValidationRequest, method: http.MethodPatch,
+			route: "/users/{id}", contentType: JSONPatchContentType, body: `[{"op":"remove","path":"/name"}]`,
+		},
+		{
+			name: "route without a request schema", mode: SchemaValidationRequest, method: http.MethodGet,
+			route: "/users/{id}", body: `{"anything":true}`,
+		},
+		{
+			name: "validation off", mode: SchemaValidationOff, method: http.MethodPost, route: "/users",
+			body: `{"role":"admin"}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SCHEMA_VALIDATION", tt.mode)
+
+			request := jsonRequest(tt.method, tt.body)
+			if tt.contentType != "" {
+				request.Headers["Content-Type"] = tt.contentType
+			}
+			if tt.base64 {
+				request.Body = base64.StdEncoding.EncodeToString([]byte(tt.body))
+				request.IsBase64Encoded = true
+			}
+
+			err := ValidateRequestSchema(request, tt.route)
+			if !tt.wantErr {
+				if err != nil {
+					t.Fatalf("ValidateRequestSchema() error = %v, want nil", err)
+				}
+				return
+			}
+
+			var schemaErr *SchemaError
+			if !errors.As(err, &schemaErr) {
+				t.Fatalf("ValidateRequestSchema() error = %v, want a *SchemaError", err)
+			}
+			if schemaErr.Field != tt.wantField {
+				t.Errorf("field = %q, want %q (%v)", schemaErr.Field, tt.wantField, err)
+			}
+		})
+	}
+}
+
+func TestValidateResponseSchema(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    string
+		status  int
+		body    string
+		wantErr bool
+	}{
+		{name: "conformant user", mode: SchemaValidationAll, status: http.StatusOK, body: `{"id":"user-1","name":"Ann"}`},
+		{name: "undocumented field", mode: SchemaValidationAll, status: http.StatusOK,
+			body: `{"id":"user-1","password":"x"}`, wantErr: true},
+		{name: "missing id", mode: SchemaValidationAll, status: http.StatusOK, body: `{"name":"Ann"}`, wantErr: true},
+		{name: "error responses are not checked", mode: SchemaValidationAll, status: http.StatusNotFound,
+			body: `{"error":"user not found"}`},
+		{name: "requests only", mode: SchemaValidationRequest, status: http.StatusOK, body: `{"password":"x"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("SCHEMA_VALIDATION", tt.mode)
+
+			request := events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet}
+			response := events.APIGatewayProxyResponse{
+				StatusCode: tt.status,
+				Headers:    map[string]string{"Content-Type": "application/json"},
+				Body:       tt.body,
+			}
+			err := ValidateResponseSchema(request, "/users/{id}", response)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateResponseSchema() error = %v, want error: %t", err, tt.wantErr)
+			}
+		})
+	}
+}
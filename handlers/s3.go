@@ -0,0 +1,58 @@
+//go:build dynamodb
+
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// s3ObjectStore is an ObjectStore in S3.
+type s3ObjectStore struct {
+	client s3iface.S3API
+}
+
+// NewS3ObjectStore creates an ObjectStore backed by client.
+// nolint: ireturn
+func NewS3ObjectStore(client s3iface.S3API) ObjectStore {
+	return &s3ObjectStore{client: client}
+}
+
+func (s *s3ObjectStore) GetObject(ctx context.Context, bucket, key string) (Object, error) {
+	output, err := s.client.GetObjectWithContext(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return Object{}, err
+	}
+
+	return Object{Body: output.Body, ContentType: aws.StringValue(output.ContentType)}, nil
+}
+
+func (s *s3ObjectStore) PutObject(ctx context.Context, bucket, key, contentType string, body []byte) error {
+	_, err := s.client.PutObjectWithContext(ctx, &s3.PutObjectInput{
+		Bucket:      aws.String(bucket),
+		Key:         aws.String(key),
+		Body:        bytes.NewReader(body),
+		ContentType: aws.String(contentType),
+	})
+
+	return err
+}
+
+// newObjectStore builds the ObjectStore used by handlers that need one; overridable for testing.
+var newObjectStore = func() (ObjectStore, error) {
+	sess, err := session.NewSession(&aws.Config{Region: aws.String(awsRegion())})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create AWS session: %w", err)
+	}
+
+	return NewS3ObjectStore(s3.New(sess)), nil
+}
@@ -0,0 +1,9 @@
+//go:build !dynamodb
+
+package handlers
+
+// newObjectStore fails, since S3 support is only compiled in with -tags dynamodb, which keeps
+// the AWS SDK out of the in-memory build; overridable for testing.
+var newObjectStore = func() (ObjectStore, error) {
+	return nil, errObjectStoreUnavailable
+}
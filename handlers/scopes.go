@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/utils"
+)
+
+// Caller scopes understood by field-level authorization.
+const (
+	ScopeAdmin     = "admin"
+	ScopeReadEmail = "users:email"
+)
+
+// restrictedUserFields maps user fields to the scope a caller needs to see them when
+// FIELD_AUTHORIZATION=true. Admins see every field.
+var restrictedUserFields = map[string]string{
+	"email": ScopeReadEmail,
+}
+
+// callerScopes returns the scopes of the caller: admin for a valid admin token, plus the
+// space-separated "scope" from the API Gateway authorizer context or its JWT claims.
+func callerScopes(request events.APIGatewayProxyRequest) map[string]bool {
+	scopes := make(map[string]bool)
+	if IsAdmin(request) {
+		scopes[ScopeAdmin] = true
+	}
+
+	authorizer := request.RequestContext.Authorizer
+	values := []interface{}{authorizer["scope"]}
+	if claims, ok := authorizer["claims"].(map[string]interface{}); ok {
+		values = append(values, claims["scope"])
+	}
+	for _, value := range values {
+		if scope, ok := value.(string); ok {
+			for _, s := range strings.Fields(scope) {
+				scopes[s] = true
+			}
+		}
+	}
+
+	return scopes
+}
+
+// callerPrincipal identifies the authenticated caller: the authorizer's principalId, the
+// "sub" of its JWT claims, the Cognito identity, the IAM user ARN or the API key ID, in that
+// order. It is empty for unauthenticated callers.
+func callerPrincipal(request events.APIGatewayProxyRequest) string {
+	authorizer := request.RequestContext.Authorizer
+	if principal, ok := authorizer["principalId"].(string); ok && principal != "" {
+		return principal
+	}
+	if claims, ok := authorizer["claims"].(map[string]interface{}); ok {
+		if sub, ok := claims["sub"].(string); ok && sub != "" {
+			return sub
+		}
+	}
+
+	identity := request.RequestContext.Identity
+	for _, principal := range []string{identity.CognitoIdentityID, identity.UserArn, identity.APIKeyID} {
+		if principal != "" {
+			return principal
+		}
+	}
+
+	return ""
+}
+
+// hiddenUserFields returns the user fields the caller may not see, sorted.
+func hiddenUserFields(request events.APIGatewayProxyRequest) []string {
+	if !utils.GetEnvBool("FIELD_AUTHORIZATION", false) {
+		return nil
+	}
+
+	scopes := callerScopes(request)
+	if scopes[ScopeAdmin] {
+		return nil
+	}
+
+	var hidden []string
+	for field, scope := range restrictedUserFields {
+		if !scopes[scope] {
+			hidden = append(hidden, field)
+		}
+	}
+	sort.Strings(hidden)
+
+	return hidden
+}
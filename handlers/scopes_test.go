@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/models"
+)
+
+func TestGetUserHandlerFieldAuthorization(t *testing.T) {
+	tests := []struct {
+		name               string
+		fieldAuthorization string
+		admin              bool
+		authorizer         map[string]interface{}
+		wantEmail          bool
+	}{
+		{name: "authorization off", wantEmail: true},
+		{name: "admin", fieldAuthorization: "true", admin: true, wantEmail: true},
+		{name: "basic caller", fieldAuthorization: "true"},
+		{
+			name:               "basic caller with other scopes",
+			fieldAuthorization: "true",
+			authorizer:         map[string]interface{}{"scope": "users:read"},
+		},
+		{
+			name:               "email scope",
+			fieldAuthorization: "true",
+			authorizer:         map[string]interface{}{"scope": "users:read users:email"},
+			wantEmail:          true,
+		},
+		{
+			name:               "email scope in JWT claims",
+			fieldAuthorization: "true",
+			authorizer:         map[string]interface{}{"claims": map[string]interface{}{"scope": "users:email"}},
+			wantEmail:          true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withAdminToken(t)
+			t.Setenv("FIELD_AUTHORIZATION", tt.fieldAuthorization)
+			handler := NewUserHandler(seedUsers(t, models.User{ID: "user-1", Name: "Ann", Email: "ann@example.com"}))
+			t.Cleanup(sharedUserCache.clear)
+
+			request := events.APIGatewayProxyRequest{PathParameters: map[string]string{"id": "user-1"}}
+			if tt.admin {
+				request = adminRequest(nil)
+				request.PathParameters = map[string]string{"id": "user-1"}
+			}
+			request.RequestContext.Authorizer = tt.authorizer
+
+			response, err := handler.GetUserHandler(context.Background(), request)
+			if err != nil {
+				t.Fatalf("GetUserHandler() error = %v", err)
+			}
+			if response.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want %d; body %s", response.StatusCode, http.StatusOK, response.Body)
+			}
+
+			body := decodeResponse[map[string]interface{}](t, response)
+			if _, ok := body["email"]; ok != tt.wantEmail {
+				t.Errorf("email present = %t, want %t; body %s", ok, tt.wantEmail, response.Body)
+			}
+			if body["name"] != "Ann" {
+				t.Errorf("name = %v, want Ann", body["name"])
+			}
+		})
+	}
+}
@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"go-lambda-api/models"
+)
+
+// listSort is a parsed "field:direction" sort specification.
+type listSort struct {
+	field string
+	desc  bool
+}
+
+// parseListSort parses "field" or "field:asc|desc".
+func parseListSort(spec string) (listSort, error) {
+	field, direction, _ := strings.Cut(spec, ":")
+	if !models.IsSortField(field) {
+		return listSort{}, fmt.Errorf("invalid sort field %q", field)
+	}
+
+	desc, err := parseSortDirection(direction)
+	if err != nil {
+		return listSort{}, err
+	}
+
+	return listSort{field: field, desc: desc}, nil
+}
+
+// parseSortDirection parses "asc" or "desc", reporting whether it is descending. Empty is
+// ascending.
+func parseSortDirection(direction string) (bool, error) {
+	switch strings.ToLower(direction) {
+	case "", "asc":
+		return false, nil
+	case "desc":
+		return true, nil
+	default:
+		return false, fmt.Errorf("invalid sort direction %q", direction)
+	}
+}
+
+// listSortFor returns the sort requested with ?sort=, falling back to LIST_DEFAULT_SORT.
+// ?order=asc|desc sets the direction of either, and must agree with a direction given in
+// ?sort= itself. A zero listSort means the repository order is kept.
+func listSortFor(query map[string]string) (listSort, error) {
+	by := defaultListSort()
+	if spec := query["sort"]; spec != "" {
+		var err error
+		if by, err = parseListSort(spec); err != nil {
+			return listSort{}, err
+		}
+	}
+
+	order, ok := query["order"]
+	if !ok {
+		return by, nil
+	}
+	desc, err := parseSortDirection(order)
+	if err != nil {
+		return listSort{}, err
+	}
+	if by.field == "" {
+		return listSort{}, errors.New("order requires sort")
+	}
+	if strings.Contains(query["sort"], ":") && desc != by.desc {
+		return listSort{}, fmt.Errorf("order %q conflicts with sort %q", order, query["sort"])
+	}
+	by.desc = desc
+
+	return by, nil
+}
+
+// defaultListSort returns LIST_DEFAULT_SORT, or a zero listSort when it is unset or invalid.
+func defaultListSort() listSort {
+	spec := os.Getenv("LIST_DEFAULT_SORT")
+	if spec == "" {
+		return listSort{}
+	}
+
+	by, err := parseListSort(spec)
+	if err != nil {
+		log.Printf("Ignoring LIST_DEFAULT_SORT=%q: %v", spec, err)
+		return listSort{}
+	}
+
+	return by
+}
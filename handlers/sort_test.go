@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/models"
+)
+
+func TestListSortFor(t *testing.T) {
+	tests := []struct {
+		name        string
+		defaultSort string
+		query       map[string]string
+		want        listSort
+		wantErr     bool
+	}{
+		{name: "repository order", want: listSort{}},
+		{name: "default sort", defaultSort: "created_at:desc", want: listSort{field: "created_at", desc: true}},
+		{name: "invalid default sort is ignored", defaultSort: "age:desc", want: listSort{}},
+		{
+			name:        "sort overrides the default",
+			defaultSort: "created_at:desc",
+			query:       map[string]string{"sort": "name"},
+			want:        listSort{field: "name"},
+		},
+		{
+			name:        "order sets the direction of the default",
+			defaultSort: "created_at:desc",
+			query:       map[string]string{"order": "asc"},
+			want:        listSort{field: "created_at"},
+		},
+		{name: "sort with order", query: map[string]string{"sort": "email", "order": "desc"},
+			want: listSort{field: "email", desc: true}},
+		{name: "conflicting order", query: map[string]string{"sort": "email:asc", "order": "desc"}, wantErr: true},
+		{name: "order without sort", query: map[string]string{"order": "desc"}, wantErr: true},
+		{name: "invalid sort field", query: map[string]string{"sort": "age"}, wantErr: true},
+		{name: "invalid direction", query: map[string]string{"sort": "name:up"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LIST_DEFAULT_SORT", tt.defaultSort)
+
+			got, err := listSortFor(tt.query)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("listSortFor(%v) error = %v, want error %v", tt.query, err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("listSortFor(%v) = %+v, want %+v", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetAllUsersHandlerDefaultSort(t *testing.T) {
+	base := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	// By name the users are in neither creation order nor ID order
+	users := []models.User{
+		{ID: "a", Name: "Bob", Email: "bob@example.com", CreatedAt: base},
+		{ID: "b", Name: "Ann", Email: "ann@example.com", CreatedAt: base.Add(2 * time.Hour)},
+		{ID: "c", Name: "Cy", Email: "cy@example.com", CreatedAt: base.Add(time.Hour)},
+	}
+
+	tests := []struct {
+		name        string
+		defaultSort string
+		query       map[string]string
+		wantIDs     []string
+	}{
+		{name: "repository order", wantIDs: []string{"a", "b", "c"}},
+		{name: "newest first by default", defaultSort: "created_at:desc", wantIDs: []string{"b", "c", "a"}},
+		{name: "client sort overrides", defaultSort: "created_at:desc", query: map[string]string{"sort": "name"},
+			wantIDs: []string{"b", "a", "c"}},
+		{name: "client order overrides", defaultSort: "created_at:desc", query: map[string]string{"order": "asc"},
+			wantIDs: []string{"a", "c", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LIST_DEFAULT_SORT", tt.defaultSort)
+			handler := NewUserHandler(seedUsers(t, users...))
+
+			response, err := handler.GetAllUsersHandler(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod:            http.MethodGet,
+				QueryStringParameters: tt.query,
+			})
+			if err != nil || response.StatusCode != http.StatusOK {
+				t.Fatalf("GET /users = %d %s, %v; want 200", response.StatusCode, response.Body, err)
+			}
+
+			if ids := listedIDs(t, response); !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("ids = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestGetAllUsersHandlerSort(t *testing.T) {
+	base := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	users := []models.User{
+		{ID: "d", Name: "Bob", Email: "bob2@example.com", CreatedAt: base.Add(time.Hour)},
+		{ID: "a", Name: "Cy", Email: "cy@example.com", CreatedAt: base},
+		{ID: "b", Name: "Ann", Email: "ann@example.com", CreatedAt: base.Add(2 * time.Hour)},
+		{ID: "c", Name: "Bob", Email: "bob@example.com", CreatedAt: base.Add(time.Hour)},
+	}
+
+	tests := []struct {
+		name       string
+		query      map[string]string
+		wantStatus int
+		wantIDs    []string
+	}{
+		{name: "name ascending", query: map[string]string{"sort": "name"}, wantStatus: http.StatusOK,
+			wantIDs: []string{"b", "c", "d", "a"}},
+		{name: "name descending", query: map[string]string{"sort": "name", "order": "desc"},
+			wantStatus: http.StatusOK, wantIDs: []string{"a", "d", "c", "b"}},
+		{name: "created_at ascending", query: map[string]string{"sort": "created_at", "order": "asc"},
+			wantStatus: http.StatusOK, wantIDs: []string{"a", "c", "d", "b"}},
+		{name: "created_at descending", query: map[string]string{"sort": "created_at:desc"},
+			wantStatus: http.StatusOK, wantIDs: []string{"b", "d", "c", "a"}},
+		{name: "invalid field", query: map[string]string{"sort": "age"}, wantStatus: http.StatusBadRequest},
+		{name: "invalid order", query: map[string]string{"sort": "name", "order": "sideways"},
+			wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LIST_DEFAULT_SORT", "")
+			handler := NewUserHandler(seedUsers(t, users...))
+
+			// Ties are broken by ID, so repeated requests list users in the same order
+			for range 3 {
+				response, err := handler.GetAllUsersHandler(context.Background(), events.APIGatewayProxyRequest{
+					HTTPMethod:            http.MethodGet,
+					QueryStringParameters: tt.query,
+				})
+				if err != nil {
+					t.Fatalf("GetAllUsersHandler() error = %v", err)
+				}
+				if response.StatusCode != tt.wantStatus {
+					t.Fatalf("status = %d, want %d (body %s)", response.StatusCode, tt.wantStatus, response.Body)
+				}
+				if tt.wantStatus != http.StatusOK {
+					return
+				}
+				if ids := listedIDs(t, response); !slices.Equal(ids, tt.wantIDs) {
+					t.Fatalf("ids = %v, want %v", ids, tt.wantIDs)
+				}
+			}
+		})
+	}
+}
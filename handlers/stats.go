@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/models"
+	"go-lambda-api/utils"
+)
+
+const (
+	// DefaultStatsDays is the number of days in new_per_day when ?days= is not given.
+	DefaultStatsDays = 30
+	// MaxStatsDays is the largest ?days= accepted by GET /users/stats.
+	MaxStatsDays = 366
+)
+
+// statsDateLayout is the layout of new_per_day keys.
+const statsDateLayout = "2006-01-02"
+
+// UserStats holds aggregate counts over all users.
+type UserStats struct {
+	Total     int            `json:"total"`
+	ByDomain  map[string]int `json:"by_domain"`
+	Days      int            `json:"days"`
+	NewPerDay map[string]int `json:"new_per_day"`
+}
+
+// UserStatsHandler returns the total number of users, the number per email domain and the
+// number created on each of the last ?days= days (UTC, including today). It reads every
+// user, which for DynamoDB is a full table scan, so it is admin-only.
+func (h *AdminHandler) UserStatsHandler(
+	ctx context.Context, request events.APIGatewayProxyRequest,
+) (events.APIGatewayProxyResponse, error) {
+	if !IsAdmin(request) {
+		return utils.ErrorResponse(http.StatusForbidden, errAdminForbidden)
+	}
+
+	days := DefaultStatsDays
+	if value := request.QueryStringParameters["days"]; value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > MaxStatsDays {
+			return utils.ErrorResponse(http.StatusBadRequest,
+				errors.New("days must be an integer between 1 and "+strconv.Itoa(MaxStatsDays)))
+		}
+		days = n
+	}
+
+	users, err := models.ListAllUsers(ctx, h.Repo)
+	if err != nil {
+		return utils.ErrorResponse(repositoryErrorStatus(err), err)
+	}
+
+	return utils.APIResponseNegotiated(request, http.StatusOK, userStats(users, days, utils.Now()))
+}
+
+// userStats aggregates users, counting creations on the days days up to and including now's
+// UTC date. Every one of those days has an entry, zero or not.
+func userStats(users []models.User, days int, now time.Time) UserStats {
+	stats := UserStats{
+		Total:     len(users),
+		ByDomain:  make(map[string]int),
+		Days:      days,
+		NewPerDay: make(map[string]int, days),
+	}
+
+	today := now.UTC().Truncate(24 * time.Hour)
+	for i := 0; i < days; i++ {
+		stats.NewPerDay[today.AddDate(0, 0, -i).Format(statsDateLayout)] = 0
+	}
+
+	for _, user := range users {
+		if at := strings.LastIndex(user.Email, "@"); at >= 0 {
+			stats.ByDomain[strings.ToLower(user.Email[at+1:])]++
+		}
+
+		day := user.CreatedAt.UTC().Format(statsDateLayout)
+		if _, ok := stats.NewPerDay[day]; ok {
+			stats.NewPerDay[day]++
+		}
+	}
+
+	return stats
+}
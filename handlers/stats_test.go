@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+	"time"
+
+	"go-lambda-api/models"
+)
+
+func TestUserStats(t *testing.T) {
+	now := time.Date(2024, 3, 10, 15, 30, 0, 0, time.UTC)
+	users := []models.User{
+		{ID: "user-1", Email: "ann@example.com", CreatedAt: now.Add(-time.Hour)},
+		{ID: "user-2", Email: "bob@Example.com", CreatedAt: now.AddDate(0, 0, -1)},
+		{ID: "user-3", Email: "cat@other.org", CreatedAt: now.AddDate(0, 0, -1)},
+		{ID: "user-4", Email: "dan@other.org", CreatedAt: now.AddDate(0, 0, -5)},
+		{ID: "user-5", Email: "eve@example.com", CreatedAt: now.AddDate(-1, 0, 0)},
+	}
+
+	tests := []struct {
+		name          string
+		days          int
+		wantNewPerDay map[string]int
+	}{
+		{
+			name:          "today only",
+			days:          1,
+			wantNewPerDay: map[string]int{"2024-03-10": 1},
+		},
+		{
+			name:          "zero days are listed",
+			days:          3,
+			wantNewPerDay: map[string]int{"2024-03-10": 1, "2024-03-09": 2, "2024-03-08": 0},
+		},
+		{
+			name: "week",
+			days: 7,
+			wantNewPerDay: map[string]int{
+				"2024-03-10": 1, "2024-03-09": 2, "2024-03-08": 0, "2024-03-07": 0,
+				"2024-03-06": 0, "2024-03-05": 1, "2024-03-04": 0,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stats := userStats(users, tt.days, now)
+
+			if stats.Total != len(users) {
+				t.Errorf("total = %d, want %d", stats.Total, len(users))
+			}
+			wantByDomain := map[string]int{"example.com": 3, "other.org": 2}
+			if !reflect.DeepEqual(stats.ByDomain, wantByDomain) {
+				t.Errorf("by_domain = %v, want %v", stats.ByDomain, wantByDomain)
+			}
+			if stats.Days != tt.days {
+				t.Errorf("days = %d, want %d", stats.Days, tt.days)
+			}
+			if !reflect.DeepEqual(stats.NewPerDay, tt.wantNewPerDay) {
+				t.Errorf("new_per_day = %v, want %v", stats.NewPerDay, tt.wantNewPerDay)
+			}
+		})
+	}
+}
+
+func TestUserStatsHandler(t *testing.T) {
+	now := time.Now()
+	users := []models.User{
+		{ID: "user-1", Name: "Ann", Email: "ann@example.com", CreatedAt: now},
+		{ID: "user-2", Name: "Bob", Email: "bob@example.com", CreatedAt: now},
+		{ID: "user-3", Name: "Cat", Email: "cat@other.org", CreatedAt: now.AddDate(-2, 0, 0)},
+	}
+
+	tests := []struct {
+		name       string
+		days       string
+		admin      bool
+		wantStatus int
+		wantDays   int
+	}{
+		{name: "default days", admin: true, wantStatus: http.StatusOK, wantDays: DefaultStatsDays},
+		{name: "explicit days", days: "7", admin: true, wantStatus: http.StatusOK, wantDays: 7},
+		{name: "zero days", days: "0", admin: true, wantStatus: http.StatusBadRequest},
+		{name: "too many days", days: "367", admin: true, wantStatus: http.StatusBadRequest},
+		{name: "non-numeric days", days: "week", admin: true, wantStatus: http.StatusBadRequest},
+		{name: "not an admin", wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withAdminToken(t)
+			handler := NewAdminHandler(seedUsers(t, users...))
+
+			request := adminRequest(map[string]string{"days": tt.days})
+			if !tt.admin {
+				request.Headers = nil
+			}
+			response, err := handler.UserStatsHandler(context.Background(), request)
+			if err != nil {
+				t.Fatalf("UserStatsHandler() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d; body %s", response.StatusCode, tt.wantStatus, response.Body)
+			}
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			stats := decodeResponse[UserStats](t, response)
+			if stats.Total != len(users) {
+				t.Errorf("total = %d, want %d", stats.Total, len(users))
+			}
+			wantByDomain := map[string]int{"example.com": 2, "other.org": 1}
+			if !reflect.DeepEqual(stats.ByDomain, wantByDomain) {
+				t.Errorf("by_domain = %v, want %v", stats.ByDomain, wantByDomain)
+			}
+			if stats.Days != tt.wantDays || len(stats.NewPerDay) != tt.wantDays {
+				t.Errorf("days = %d with %d entries, want %d", stats.Days, len(stats.NewPerDay), tt.wantDays)
+			}
+			created := 0
+			for _, n := range stats.NewPerDay {
+				created += n
+			}
+			if created != 2 {
+				t.Errorf("new_per_day sums to %d, want 2", created)
+			}
+		})
+	}
+}
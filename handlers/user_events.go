@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go-lambda-api/models"
+	"go-lambda-api/utils"
+)
+
+// User event types published on successful mutations.
+const (
+	UserCreated = "user.created"
+	UserUpdated = "user.updated"
+	UserDeleted = "user.deleted"
+)
+
+// userEventBuffer is how many events a slow subscriber may fall behind before events are
+// dropped for it.
+const userEventBuffer = 64
+
+// UserEvent describes a change to a user. For deletions only User.ID is set.
+type UserEvent struct {
+	Type string      `json:"type"`
+	User models.User `json:"user"`
+	Time time.Time   `json:"time"`
+}
+
+// UserEventHandler consumes user events. A returned error is logged and does not affect
+// the request that published the event or any other subscriber.
+type UserEventHandler func(UserEvent) error
+
+// userEventBus delivers user events to subscribers. Each subscriber has its own queue and
+// goroutine, so a slow or failing subscriber never blocks a request or another subscriber;
+// when its queue is full it misses the event.
+type userEventBus struct {
+	mu          sync.RWMutex
+	subscribers map[*userEventSubscriber]struct{}
+}
+
+type userEventSubscriber struct {
+	name   string
+	handle UserEventHandler
+	queue  chan UserEvent
+	done   chan struct{}
+}
+
+// sharedUserEvents receives the events of every handler in the process.
+var sharedUserEvents = &userEventBus{subscribers: make(map[*userEventSubscriber]struct{})}
+
+// SubscribeUserEvents calls handle asynchronously for every user event published from now
+// on, and returns a function that unsubscribes. name identifies the subscriber in logs.
+func SubscribeUserEvents(name string, handle UserEventHandler) func() {
+	return sharedUserEvents.subscribe(name, handle)
+}
+
+func (b *userEventBus) subscribe(name string, handle UserEventHandler) func() {
+	sub := &userEventSubscriber{
+		name:   name,
+		handle: handle,
+		queue:  make(chan UserEvent, userEventBuffer),
+		done:   make(chan struct{}),
+	}
+
+	b.mu.Lock()
+	b.subscribers[sub] = struct{}{}
+	b.mu.Unlock()
+
+	go sub.run()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.subscribers, sub)
+			b.mu.Unlock()
+			close(sub.done)
+		})
+	}
+}
+
+func (b *userEventBus) publish(eventType string, user models.User) {
+	event := UserEvent{Type: eventType, User: user, Time: utils.Now()}
+
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subscribers {
+		select {
+		case sub.queue <- event:
+		default:
+			slog.Warn("user event dropped", "subscriber", sub.name, "type", event.Type, "user_id", user.ID)
+		}
+	}
+}
+
+func (s *userEventSubscriber) run() {
+	for {
+		select {
+		case <-s.done:
+			return
+		case event := <-s.queue:
+			if err := s.deliver(event); err != nil {
+				slog.Error("user event subscriber failed", "subscriber", s.name, "type", event.Type, "error", err)
+			}
+		}
+	}
+}
+
+// deliver calls the handler, turning a panic into an error so the subscriber keeps running.
+func (s *userEventSubscriber) deliver(event UserEvent) (err error) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			err = fmt.Errorf("panic: %v", rec)
+		}
+	}()
+
+	return s.handle(event)
+}
+
+// AuditUserEvent logs a user event as a structured audit record.
+func AuditUserEvent(event UserEvent) error {
+	slog.Info("user audit", "type", event.Type, "user_id", event.User.ID, "time", event.Time)
+
+	return nil
+}
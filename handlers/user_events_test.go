@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go-lambda-api/models"
+)
+
+func TestUserEventBus(t *testing.T) {
+	tests := []struct {
+		name  string
+		other UserEventHandler
+	}{
+		{name: "another subscriber", other: func(UserEvent) error { return nil }},
+		{name: "failing subscriber", other: func(UserEvent) error { return errors.New("webhook unreachable") }},
+		{name: "panicking subscriber", other: func(UserEvent) error { panic("audit broke") }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			bus := &userEventBus{subscribers: make(map[*userEventSubscriber]struct{})}
+
+			otherReceived := make(chan UserEvent, 3)
+			t.Cleanup(bus.subscribe("other", func(event UserEvent) error {
+				otherReceived <- event
+				return tt.other(event)
+			}))
+			received := make(chan UserEvent, 3)
+			t.Cleanup(bus.subscribe("recorder", func(event UserEvent) error {
+				received <- event
+				return nil
+			}))
+
+			published := []UserEvent{
+				{Type: UserCreated, User: models.User{ID: "user-1"}},
+				{Type: UserUpdated, User: models.User{ID: "user-1"}},
+				{Type: UserDeleted, User: models.User{ID: "user-1"}},
+			}
+			for _, event := range published {
+				bus.publish(event.Type, event.User)
+			}
+
+			for _, ch := range []chan UserEvent{received, otherReceived} {
+				for _, want := range published {
+					select {
+					case event := <-ch:
+						if event.Type != want.Type || event.User.ID != want.User.ID {
+							t.Errorf("received %s for %q, want %s for %q", event.Type, event.User.ID, want.Type, want.User.ID)
+						}
+					case <-time.After(time.Second):
+						t.Fatalf("%s was not delivered", want.Type)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestUserEventBusSlowSubscriber(t *testing.T) {
+	bus := &userEventBus{subscribers: make(map[*userEventSubscriber]struct{})}
+
+	release := make(chan struct{})
+	t.Cleanup(bus.subscribe("slow", func(UserEvent) error {
+		<-release
+		return nil
+	}))
+	t.Cleanup(func() { close(release) })
+	received := make(chan UserEvent, 1)
+	t.Cleanup(bus.subscribe("recorder", func(event UserEvent) error {
+		received <- event
+		return nil
+	}))
+
+	// Past the slow subscriber's queue, its events are dropped rather than blocking others
+	for i := range userEventBuffer + 2 {
+		bus.publish(UserCreated, models.User{ID: "user-1"})
+		select {
+		case <-received:
+		case <-time.After(time.Second):
+			t.Fatalf("event %d was not delivered past a slow subscriber", i)
+		}
+	}
+}
@@ -2,21 +2,49 @@ package handlers
 
 import (
 	"context"
-	"encoding/json"
 	"errors"
+	"fmt"
+	"maps"
+	"mime"
 	"net/http"
-	"time"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
 
 	"github.com/aws/aws-lambda-go/events"
-	"github.com/google/uuid"
 
 	"go-lambda-api/models"
 	"go-lambda-api/utils"
 )
 
-// UserHandler struct holds the UserRepository interface.
+const (
+	// DefaultListLimit is the page size of GET /users without ?limit= when DEFAULT_LIST_LIMIT is unset.
+	DefaultListLimit = 25
+	// MaxListLimit is the largest page size GET /users returns.
+	MaxListLimit = 100
+	// DefaultListRootKey is the key GET /users returns the page under when LIST_ROOT_KEY is unset.
+	DefaultListRootKey = "users"
+)
+
+// Create conflict modes, selected with CREATE_CONFLICT_MODE.
+const (
+	// CreateConflictStrict answers every create whose email is taken with 409.
+	CreateConflictStrict = "strict"
+	// CreateConflictIdempotent answers a create whose email is taken with 200 and the existing
+	// user when the request matches it, and with 409 otherwise.
+	CreateConflictIdempotent = "idempotent"
+)
+
+// UserHandler struct holds the UserRepository interface and the avatar store.
+// Avatars is created lazily from S3 on the first avatar upload.
 type UserHandler struct {
-	Repo models.UserRepository
+	Repo    models.UserRepository
+	Avatars ObjectPutter
+
+	// avatarsMu guards the lazy creation of Avatars, as the local server shares one
+	// handler between concurrent requests.
+	avatarsMu sync.Mutex
 }
 
 // NewUserHandler creates a new UserHandler.
@@ -24,34 +52,108 @@ func NewUserHandler(userRepo models.UserRepository) *UserHandler {
 	return &UserHandler{Repo: userRepo}
 }
 
+// CreateUserHandler creates a user. With ?findOrCreate=true, a user that already has the
+// email is returned with 200 instead of a 409. With CREATE_CONFLICT_MODE=idempotent, so is
+// one whose name, email, metadata and, if sent, ID match the request.
 func (h *UserHandler) CreateUserHandler(
 	ctx context.Context, request events.APIGatewayProxyRequest,
 ) (events.APIGatewayProxyResponse, error) {
-	var userReq models.UserRequest
+	body, err := jsonBody(request)
+	if err != nil {
+		return utils.ErrorResponse(bodyErrorStatus(err), err)
+	}
 
-	if err := json.Unmarshal([]byte(request.Body), &userReq); err != nil {
+	var userReq models.UserRequest
+	if err := decodeBody(ctx, body, &userReq); err != nil {
 		return utils.ErrorResponse(http.StatusBadRequest, err)
 	}
 
+	sentName, sentEmail := userReq.Name, userReq.Email
+	userReq.Normalize()
 	if err := userReq.Validate(false); err != nil {
-		return utils.ErrorResponse(http.StatusBadRequest, err)
+		return utils.ErrorResponse(validationStatus(err), err)
+	}
+
+	defaultsApplied := []string{"created_at", "updated_at"}
+	id := userReq.ID
+	if id == "" {
+		id = utils.NewID()
+		defaultsApplied = append([]string{"id"}, defaultsApplied...)
+	}
+	if userReq.Name != sentName {
+		defaultsApplied = append(defaultsApplied, "name")
+	}
+	if userReq.Email != sentEmail {
+		defaultsApplied = append(defaultsApplied, "email")
 	}
 
 	newUser := models.User{
-		ID:        uuid.New().String(),
+		ID:        id,
 		Name:      userReq.Name,
 		Email:     userReq.Email,
-		CreatedAt: time.Now(),
+		CreatedAt: utils.Now().UTC(),
+		Metadata:  userReq.Metadata,
+	}
+
+	createdUser, err := h.Repo.CreateUser(ctx, newUser)
+	if errors.Is(err, models.ErrDuplicateEmail) && request.QueryStringParameters["findOrCreate"] == "true" {
+		// The repository's uniqueness check decides between the branches. It is atomic in every
+		// repository, so of concurrent calls for one email exactly one creates the user
+		if existing, found := findUserByEmail(ctx, h.Repo, newUser.Email); found {
+			return utils.APIResponseNegotiated(request, http.StatusOK, renderUser(request, existing))
+		}
 	}
+	if errors.Is(err, models.ErrDuplicateEmail) {
+		if createConflictMode() == CreateConflictIdempotent {
+			if existing, found := findUserByEmail(ctx, h.Repo, newUser.Email); found && matchesUserRequest(existing, userReq) {
+				return utils.APIResponseNegotiated(request, http.StatusOK, renderUser(request, existing))
+			}
+		}
+
+		return h.conflictResponse(ctx, newUser.Email, err)
+	}
+	if errors.Is(err, models.ErrDuplicateID) {
+		if createConflictMode() == CreateConflictIdempotent {
+			// An identical create that sent its ID is taken on the ID before the email
+			if existing, err := h.Repo.GetUserByID(ctx, newUser.ID); err == nil && matchesUserRequest(existing, userReq) {
+				return utils.APIResponseNegotiated(request, http.StatusOK, renderUser(request, existing))
+			}
+		}
+
+		response, respErr := utils.ErrorResponse(http.StatusConflict, err)
+		response.Headers["Location"] = "/users/" + newUser.ID
 
-	createdUser, err := h.Repo.CreateUser(newUser)
+		return response, respErr
+	}
 	if err != nil {
-		return utils.ErrorResponse(http.StatusInternalServerError, err)
+		return utils.ErrorResponse(repositoryErrorStatus(err), err)
+	}
+	sharedUserEvents.publish(UserCreated, createdUser)
+
+	representation := renderUser(request, createdUser)
+	if wantsDefaultsApplied(request) {
+		representation = withDefaultsApplied(representation, defaultsApplied)
 	}
 
-	return utils.APIResponse(http.StatusCreated, createdUser)
+	response, err := utils.APIResponseNegotiated(request, http.StatusCreated, representation)
+	if err == nil {
+		addEmailSuggestion(&response, createdUser.Email)
+	}
+
+	return response, err
 }
 
+// addEmailSuggestion warns in a Warning header when email is valid but looks like a typo,
+// e.g. "a@gmial.com", naming the address the client probably meant.
+func addEmailSuggestion(response *events.APIGatewayProxyResponse, email string) {
+	if suggestion := models.SuggestEmail(email); suggestion != "" {
+		response.Headers["Warning"] = fmt.Sprintf(`299 - "email may be mistyped, did you mean %s?"`, suggestion)
+	}
+}
+
+// GetUserHandler returns a user, trimmed to the ?fields= projection when given, with an
+// ETag that covers both the representation and the projection. A request whose
+// If-None-Match names that ETag gets an empty 304 Not Modified instead.
 func (h *UserHandler) GetUserHandler(
 	ctx context.Context, request events.APIGatewayProxyRequest,
 ) (events.APIGatewayProxyResponse, error) {
@@ -60,20 +162,152 @@ func (h *UserHandler) GetUserHandler(
 		return utils.ErrorResponse(http.StatusBadRequest, errors.New("user ID is required"))
 	}
 
-	user, err := h.Repo.GetUserByID(userID)
+	fields, err := parseFields(request)
 	if err != nil {
-		return utils.ErrorResponse(http.StatusNotFound, err)
+		return utils.ErrorResponse(http.StatusBadRequest, err)
+	}
+
+	user, ok := sharedUserCache.get(userID)
+	if !ok {
+		// The version is read even when not requested, for the ETag
+		user, err = h.getUser(ctx, userID, fields.With("version"))
+		if err != nil {
+			return utils.ErrorResponse(repositoryErrorStatus(err), err)
+		}
+	}
+
+	representation := renderUser(request, user)
+	mediaType := "application/json"
+	if utils.PrefersXML(request) {
+		mediaType = utils.XMLContentType
 	}
+	etag := representationETag(representation, user.Version, fields, mediaType)
+	if etag != "" && etagMatches(utils.GetHeader(request, "If-None-Match"), etag) {
+		response, err := utils.APIResponse(http.StatusNotModified, nil)
+		delete(response.Headers, "Content-Type")
+		response.Headers["ETag"] = etag
 
-	return utils.APIResponse(http.StatusOK, user)
+		return response, err
+	}
+
+	response, err := utils.APIResponseNegotiated(request, http.StatusOK, representation)
+	if etag != "" {
+		response.Headers["ETag"] = etag
+	}
+
+	return response, err
 }
 
+// getUser reads a user from the repository, reading only the projected fields when the
+// repository supports it. Only complete users are cached.
+func (h *UserHandler) getUser(ctx context.Context, id string, projection models.Projection) (models.User, error) {
+	if reader, ok := h.Repo.(models.ProjectedReader); ok && projection != nil {
+		return reader.GetUserByIDProjected(ctx, id, projection)
+	}
+
+	user, err := h.Repo.GetUserByID(ctx, id)
+	if err == nil {
+		sharedUserCache.set(user)
+	}
+
+	return user, err
+}
+
+// GetUserByEmailHandler returns the user whose email matches ?email=, or 404 when none does.
+func (h *UserHandler) GetUserByEmailHandler(
+	ctx context.Context, request events.APIGatewayProxyRequest,
+) (events.APIGatewayProxyResponse, error) {
+	email := request.QueryStringParameters["email"]
+	if email == "" {
+		return utils.ErrorResponse(http.StatusBadRequest, errors.New("email is required"))
+	}
+
+	user, err := h.Repo.GetUserByEmail(ctx, email)
+	if err != nil {
+		return utils.ErrorResponse(repositoryErrorStatus(err), err)
+	}
+
+	return utils.APIResponseNegotiated(request, http.StatusOK, renderUser(request, user))
+}
+
+// GetAllUsersHandler lists one page of users: ?limit= users (default DEFAULT_LIST_LIMIT, at
+// most MaxListLimit) starting at ?cursor=, whose name contains ?name= ignoring case, ordered
+// by ?sort= or LIST_DEFAULT_SORT. The repository filters and sorts before paging, so pages
+// are full and the order holds across them. With ?ids=a,b,c it instead returns just those
+// users, in the order requested, and with ?email= the single user holding that address. The
+// page is returned under LIST_ROOT_KEY, with "next_cursor" alongside and "X-Truncated: true"
+// set unless it is the last page.
 func (h *UserHandler) GetAllUsersHandler(
 	ctx context.Context, request events.APIGatewayProxyRequest,
 ) (events.APIGatewayProxyResponse, error) {
-	userList := h.Repo.GetAllUsers()
+	if _, err := parseFields(request); err != nil {
+		return utils.ErrorResponse(http.StatusBadRequest, err)
+	}
+
+	if _, ok := request.QueryStringParameters["ids"]; ok {
+		return h.batchGetUsersHandler(ctx, request)
+	}
+	if _, ok := request.QueryStringParameters["email"]; ok {
+		return h.GetUserByEmailHandler(ctx, request)
+	}
 
-	return utils.APIResponse(http.StatusOK, userList)
+	by, err := listSortFor(request.QueryStringParameters)
+	if err != nil {
+		return utils.ErrorResponse(http.StatusBadRequest, err)
+	}
+
+	limit, err := listLimit(request.QueryStringParameters["limit"])
+	if err != nil {
+		return utils.ErrorResponse(http.StatusBadRequest, err)
+	}
+
+	userList, nextCursor, err := h.Repo.GetAllUsers(ctx, models.ListQuery{
+		Limit:        limit,
+		Cursor:       request.QueryStringParameters["cursor"],
+		SortField:    by.field,
+		Descending:   by.desc,
+		NameContains: request.QueryStringParameters["name"],
+	})
+	if err != nil {
+		return utils.ErrorResponse(repositoryErrorStatus(err), err)
+	}
+
+	page := map[string]interface{}{listRootKey(): renderUsers(request, userList)}
+	if nextCursor == "" {
+		return utils.APIResponseNegotiated(request, http.StatusOK, page)
+	}
+
+	page["next_cursor"] = nextCursor
+	response, err := utils.APIResponseNegotiated(request, http.StatusOK, page)
+	if err == nil {
+		response.Headers["X-Truncated"] = "true"
+	}
+
+	return response, err
+}
+
+// listRootKey returns LIST_ROOT_KEY, defaulting to DefaultListRootKey. A key that would
+// collide with "next_cursor" is ignored.
+func listRootKey() string {
+	if key := strings.TrimSpace(os.Getenv("LIST_ROOT_KEY")); key != "" && key != "next_cursor" {
+		return key
+	}
+
+	return DefaultListRootKey
+}
+
+// listLimit parses ?limit=, defaulting to DEFAULT_LIST_LIMIT and capping at MaxListLimit.
+func listLimit(value string) (int, error) {
+	if value == "" {
+		return min(utils.GetEnvInt("DEFAULT_LIST_LIMIT", DefaultListLimit), MaxListLimit), nil
+	}
+
+	limit, err := strconv.Atoi(value)
+	if err != nil || limit < 1 {
+		return 0, errors.New("limit must be a positive integer")
+	}
+
+	return min(limit, MaxListLimit), nil
 }
 
 func (h *UserHandler) UpdateUserHandler(
@@ -84,35 +318,162 @@ func (h *UserHandler) UpdateUserHandler(
 		return utils.ErrorResponse(http.StatusBadRequest, errors.New("user ID is required"))
 	}
 
+	body, err := jsonBody(request)
+	if err != nil {
+		return utils.ErrorResponse(bodyErrorStatus(err), err)
+	}
+
 	var userReq models.UserRequest
-	if err := json.Unmarshal([]byte(request.Body), &userReq); err != nil {
+	if err := decodeBody(ctx, body, &userReq); err != nil {
 		return utils.ErrorResponse(http.StatusBadRequest, err)
 	}
 
+	userReq.Normalize()
 	if err := userReq.Validate(true); err != nil {
+		return utils.ErrorResponse(validationStatus(err), err)
+	}
+
+	expectedVersion, err := ifMatchVersion(request)
+	if err != nil {
 		return utils.ErrorResponse(http.StatusBadRequest, err)
 	}
+	if userReq.Version != 0 {
+		if expectedVersion != 0 && expectedVersion != userReq.Version {
+			return utils.ErrorResponse(
+				http.StatusBadRequest, errors.New("the If-Match header and version field name different versions"),
+			)
+		}
+		expectedVersion = userReq.Version
+	}
+
+	merge := func(existingUser models.User) models.User {
+		// The repository rejects the update unless this is still the stored version
+		if expectedVersion != 0 {
+			existingUser.Version = expectedVersion
+		}
+		if userReq.Name != "" {
+			existingUser.Name = userReq.Name
+		}
+		if userReq.Email != "" {
+			existingUser.Email = userReq.Email
+		}
+		if userReq.Metadata != nil {
+			existingUser.Metadata = userReq.Metadata
+		}
+		existingUser.UpdatedAt = utils.Now().UTC()
+
+		return existingUser
+	}
 
-	existingUser, err := h.Repo.GetUserByID(userID)
+	// Merge under the repository's lock when it supports it, so concurrent updates aren't lost
+	var updatedUser models.User
+	err = models.ErrAtomicUpdateUnsupported
+	if updater, ok := h.Repo.(models.AtomicUpdater); ok {
+		updatedUser, err = updater.UpdateUserFunc(ctx, userID, merge)
+	}
+	if errors.Is(err, models.ErrAtomicUpdateUnsupported) {
+		var existingUser models.User
+		existingUser, err = h.Repo.GetUserByID(ctx, userID)
+		if err == nil {
+			updatedUser, err = h.Repo.UpdateUser(ctx, merge(existingUser))
+		}
+	}
+	sharedUserCache.invalidate(userID)
+	if err != nil {
+		return utils.ErrorResponse(repositoryErrorStatus(err), err)
+	}
+	sharedUserEvents.publish(UserUpdated, updatedUser)
+
+	response, err := utils.APIResponseNegotiated(request, http.StatusOK, renderUser(request, updatedUser))
+	if err == nil && userReq.Email != "" {
+		addEmailSuggestion(&response, updatedUser.Email)
+	}
+
+	return response, err
+}
+
+// PatchUserHandler applies a JSON merge patch, or an RFC 6902 JSON Patch when the content type
+// is application/json-patch+json, to an existing user and returns the merged user.
+// "Prefer: return=minimal" suppresses the body; "return=representation" is the default.
+func (h *UserHandler) PatchUserHandler(
+	ctx context.Context, request events.APIGatewayProxyRequest,
+) (events.APIGatewayProxyResponse, error) {
+	userID := request.PathParameters["id"]
+	if userID == "" {
+		return utils.ErrorResponse(http.StatusBadRequest, errors.New("user ID is required"))
+	}
+
+	body, err := jsonBody(request)
+	if err != nil {
+		return utils.ErrorResponse(bodyErrorStatus(err), err)
+	}
+
+	expectedVersion, err := ifMatchVersion(request)
+	if err != nil {
+		return utils.ErrorResponse(http.StatusBadRequest, err)
+	}
+
+	existingUser, err := h.Repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return utils.ErrorResponse(repositoryErrorStatus(err), err)
+	}
+
+	var patchedUser models.User
+	mediaType, _, _ := mime.ParseMediaType(utils.GetHeader(request, "Content-Type"))
+	if mediaType == JSONPatchContentType {
+		patchedUser, err = jsonPatchUser(existingUser, body)
+	} else {
+		var patch map[string]interface{}
+		patch, err = decodeJSONMap(body)
+		if err == nil {
+			patchedUser, err = mergePatchUser(existingUser, patch)
+		}
+	}
+	if errors.Is(err, errPatchTestFailed) {
+		return utils.ErrorResponse(http.StatusConflict, err)
+	}
 	if err != nil {
-		return utils.ErrorResponse(http.StatusNotFound, err)
+		return utils.ErrorResponse(http.StatusBadRequest, err)
 	}
 
-	if userReq.Name != "" {
-		existingUser.Name = userReq.Name
+	userReq := models.UserRequest{Name: patchedUser.Name, Email: patchedUser.Email, Metadata: patchedUser.Metadata}
+	userReq.Normalize()
+	if err := userReq.Validate(true); err != nil {
+		return utils.ErrorResponse(validationStatus(err), err)
 	}
-	if userReq.Email != "" {
-		existingUser.Email = userReq.Email
+	patchedUser.Name = userReq.Name
+	patchedUser.Email = userReq.Email
+	patchedUser.UpdatedAt = utils.Now().UTC()
+	if expectedVersion != 0 {
+		patchedUser.Version = expectedVersion
 	}
 
-	updatedUser, err := h.Repo.UpdateUser(existingUser)
+	// UpdateUser returns the stored record, so the representation matches a subsequent GET.
+	updatedUser, err := h.Repo.UpdateUser(ctx, patchedUser)
+	sharedUserCache.invalidate(userID)
 	if err != nil {
-		return utils.ErrorResponse(http.StatusInternalServerError, err)
+		return utils.ErrorResponse(repositoryErrorStatus(err), err)
 	}
+	sharedUserEvents.publish(UserUpdated, updatedUser)
+
+	switch preference(request, "return") {
+	case "minimal":
+		response, err := utils.APIResponse(http.StatusNoContent, nil)
+		response.Headers["Preference-Applied"] = "return=minimal"
 
-	return utils.APIResponse(http.StatusOK, updatedUser)
+		return response, err
+	case "representation":
+		response, err := utils.APIResponseNegotiated(request, http.StatusOK, renderUser(request, updatedUser))
+		response.Headers["Preference-Applied"] = "return=representation"
+
+		return response, err
+	default:
+		return utils.APIResponseNegotiated(request, http.StatusOK, renderUser(request, updatedUser))
+	}
 }
 
+// DeleteUserHandler deletes a user and responds 204, or 200 with {"deleted":true,"id":...}
+// when DELETE_RETURN_BODY=true or the client sends "Prefer: return=representation".
 func (h *UserHandler) DeleteUserHandler(
 	ctx context.Context, request events.APIGatewayProxyRequest,
 ) (events.APIGatewayProxyResponse, error) {
@@ -121,14 +482,130 @@ func (h *UserHandler) DeleteUserHandler(
 		return utils.ErrorResponse(http.StatusBadRequest, errors.New("user ID is required"))
 	}
 
-	err := h.Repo.DeleteUser(userID)
+	err := h.Repo.DeleteUser(ctx, userID)
+	sharedUserCache.invalidate(userID)
 	if err != nil {
-		if err.Error() == "user not found" {
-			return utils.ErrorResponse(http.StatusNotFound, err)
-		}
+		return utils.ErrorResponse(repositoryErrorStatus(err), err)
+	}
+	sharedUserEvents.publish(UserDeleted, models.User{ID: userID})
+
+	confirmation := map[string]interface{}{"deleted": true, "id": userID}
+	switch preference(request, "return") {
+	case "minimal":
+		response, err := utils.APIResponse(http.StatusNoContent, nil)
+		response.Headers["Preference-Applied"] = "return=minimal"
+
+		return response, err
+	case "representation":
+		response, err := utils.APIResponseNegotiated(request, http.StatusOK, confirmation)
+		response.Headers["Preference-Applied"] = "return=representation"
+
+		return response, err
+	}
 
-		return utils.ErrorResponse(http.StatusInternalServerError, err)
+	if utils.GetEnvBool("DELETE_RETURN_BODY", false) {
+		return utils.APIResponseNegotiated(request, http.StatusOK, confirmation)
 	}
 
 	return utils.APIResponse(http.StatusNoContent, nil)
 }
+
+// DeleteAllUsersHandler deletes every user for DELETE /users?confirm=true and returns how many
+// were deleted. It is meant for wiping test environments, so it answers 403 unless
+// ALLOW_BULK_DELETE=true, and 400 without confirm=true so a stray DELETE /users never wipes.
+func (h *UserHandler) DeleteAllUsersHandler(
+	ctx context.Context, request events.APIGatewayProxyRequest,
+) (events.APIGatewayProxyResponse, error) {
+	if !utils.GetEnvBool("ALLOW_BULK_DELETE", false) {
+		return utils.ErrorResponse(http.StatusForbidden, errors.New("bulk delete is disabled"))
+	}
+	if request.QueryStringParameters["confirm"] != "true" {
+		return utils.ErrorResponse(http.StatusBadRequest, errors.New("deleting every user requires ?confirm=true"))
+	}
+
+	deleted, err := h.Repo.DeleteAllUsers(ctx)
+	sharedUserCache.clear()
+	if err != nil {
+		return utils.ErrorResponse(repositoryErrorStatus(err), err)
+	}
+
+	return utils.APIResponseNegotiated(request, http.StatusOK, map[string]int{"deleted": deleted})
+}
+
+// validationStatus maps a Validate error to its HTTP status: 422 for constraint
+// violations and 400 for missing or malformed input.
+func validationStatus(err error) int {
+	var validationErr *models.ValidationError
+	if errors.As(err, &validationErr) {
+		return http.StatusUnprocessableEntity
+	}
+
+	return http.StatusBadRequest
+}
+
+// conflictResponse builds a 409 that points the client at the user already holding email,
+// via an "existing_id" body field and a Location header.
+func (h *UserHandler) conflictResponse(
+	ctx context.Context, email string, err error,
+) (events.APIGatewayProxyResponse, error) {
+	existing, found := findUserByEmail(ctx, h.Repo, email)
+	if !found {
+		return utils.ErrorResponse(http.StatusConflict, err)
+	}
+
+	response, respErr := utils.APIResponse(http.StatusConflict, map[string]string{
+		"error":       err.Error(),
+		"existing_id": existing.ID,
+	})
+	response.Headers["Location"] = "/users/" + existing.ID
+
+	return response, respErr
+}
+
+// createConflictMode returns the CREATE_CONFLICT_MODE (default "strict").
+func createConflictMode() string {
+	if mode := strings.ToLower(os.Getenv("CREATE_CONFLICT_MODE")); mode == CreateConflictIdempotent {
+		return mode
+	}
+
+	return CreateConflictStrict
+}
+
+// matchesUserRequest reports whether creating userReq, already normalized, would have
+// produced user apart from the generated ID and timestamps.
+func matchesUserRequest(user models.User, userReq models.UserRequest) bool {
+	return (userReq.ID == "" || userReq.ID == user.ID) &&
+		userReq.Name == user.Name &&
+		models.SameEmail(userReq.Email, user.Email) &&
+		maps.Equal(userReq.Metadata, user.Metadata)
+}
+
+func findUserByEmail(ctx context.Context, repo models.UserRepository, email string) (models.User, bool) {
+	user, err := repo.GetUserByEmail(ctx, email)
+
+	return user, err == nil
+}
+
+// repositoryErrorStatus maps a repository error to its HTTP status, using the models
+// package's sentinel errors. Errors that are not recognized are 500s.
+func repositoryErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, models.ErrUserNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, models.ErrDuplicateEmail), errors.Is(err, models.ErrDuplicateID),
+		errors.Is(err, models.ErrVersionConflict):
+		return http.StatusConflict
+	case errors.Is(err, models.ErrInvalidCursor), errors.Is(err, models.ErrBatchTooLarge),
+		errors.Is(err, models.ErrEmptyBatch):
+		return http.StatusBadRequest
+	case errors.Is(err, models.ErrBatchCreateUnsupported):
+		return http.StatusNotImplemented
+	case errors.Is(err, models.ErrRetryBudgetExhausted), errors.Is(err, models.ErrCircuitOpen),
+		errors.Is(err, models.ErrWriteUnprocessed):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusInternalServerError
+	}
+}
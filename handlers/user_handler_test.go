@@ -0,0 +1,1383 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/models"
+	"go-lambda-api/utils"
+)
+
+func TestCreateUserHandlerConflict(t *testing.T) {
+	existing := models.User{ID: "existing", Name: "Existing", Email: "taken@example.com"}
+
+	tests := []struct {
+		name           string
+		body           string
+		wantStatus     int
+		wantExistingID string
+		wantLocation   string
+	}{
+		{
+			name:           "duplicate email references the existing user",
+			body:           `{"name":"Other","email":"taken@example.com"}`,
+			wantStatus:     http.StatusConflict,
+			wantExistingID: "existing",
+			wantLocation:   "/users/existing",
+		},
+		{
+			name:           "duplicate email with the domain in another case",
+			body:           `{"name":"Other","email":"taken@EXAMPLE.com"}`,
+			wantStatus:     http.StatusConflict,
+			wantExistingID: "existing",
+			wantLocation:   "/users/existing",
+		},
+		{
+			name:         "duplicate ID",
+			body:         `{"id":"existing","name":"Other","email":"other@example.com"}`,
+			wantStatus:   http.StatusConflict,
+			wantLocation: "/users/existing",
+		},
+		{
+			name:       "no conflict",
+			body:       `{"name":"Other","email":"other@example.com"}`,
+			wantStatus: http.StatusCreated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewUserHandler(seedUsers(t, existing))
+
+			response, err := handler.CreateUserHandler(context.Background(), jsonRequest(http.MethodPost, tt.body))
+			if err != nil {
+				t.Fatalf("CreateUserHandler() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", response.StatusCode, tt.wantStatus, response.Body)
+			}
+			if tt.wantLocation != "" && response.Headers["Location"] != tt.wantLocation {
+				t.Errorf("Location = %q, want %q", response.Headers["Location"], tt.wantLocation)
+			}
+			if tt.wantExistingID != "" {
+				body := decodeResponse[map[string]string](t, response)
+				if body["existing_id"] != tt.wantExistingID {
+					t.Errorf("existing_id = %q, want %q", body["existing_id"], tt.wantExistingID)
+				}
+			}
+		})
+	}
+}
+
+func TestCreateUserHandlerConflictMode(t *testing.T) {
+	existing := models.User{
+		ID: "existing", Name: "Existing", Email: "taken@example.com", Metadata: map[string]string{"team": "payments"},
+	}
+	identical := `{"name":"Existing","email":"taken@EXAMPLE.com","metadata":{"team":"payments"}}`
+
+	tests := []struct {
+		name       string
+		mode       string
+		body       string
+		wantStatus int
+	}{
+		{name: "identical under strict", mode: CreateConflictStrict, body: identical, wantStatus: http.StatusConflict},
+		{name: "identical by default", body: identical, wantStatus: http.StatusConflict},
+		{
+			name:       "differing under strict",
+			mode:       CreateConflictStrict,
+			body:       `{"name":"Other","email":"taken@example.com","metadata":{"team":"payments"}}`,
+			wantStatus: http.StatusConflict,
+		},
+		{name: "identical under idempotent", mode: CreateConflictIdempotent, body: identical, wantStatus: http.StatusOK},
+		{
+			name:       "identical with the ID under idempotent",
+			mode:       CreateConflictIdempotent,
+			body:       `{"id":"existing","name":"Existing","email":"taken@example.com","metadata":{"team":"payments"}}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "differing with the ID under idempotent",
+			mode:       CreateConflictIdempotent,
+			body:       `{"id":"existing","name":"Other","email":"other@example.com"}`,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "differing name under idempotent",
+			mode:       CreateConflictIdempotent,
+			body:       `{"name":"Other","email":"taken@example.com","metadata":{"team":"payments"}}`,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "differing metadata under idempotent",
+			mode:       CreateConflictIdempotent,
+			body:       `{"name":"Existing","email":"taken@example.com"}`,
+			wantStatus: http.StatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CREATE_CONFLICT_MODE", tt.mode)
+			repo := seedUsers(t, existing)
+			handler := NewUserHandler(repo)
+
+			response, err := handler.CreateUserHandler(context.Background(), jsonRequest(http.MethodPost, tt.body))
+			if err != nil {
+				t.Fatalf("CreateUserHandler() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", response.StatusCode, tt.wantStatus, response.Body)
+			}
+			if tt.wantStatus == http.StatusOK {
+				if got := decodeResponse[models.User](t, response); got.ID != existing.ID {
+					t.Errorf("returned user %s, want %s", got.ID, existing.ID)
+				}
+			}
+
+			users, err := models.ListAllUsers(context.Background(), repo)
+			if err != nil {
+				t.Fatalf("ListAllUsers() error = %v", err)
+			}
+			if len(users) != 1 {
+				t.Errorf("%d users stored, want 1", len(users))
+			}
+		})
+	}
+}
+
+func TestUpdatePreservesCreatedAt(t *testing.T) {
+	createdAt := time.Date(2020, time.January, 2, 3, 4, 5, 678901234, time.UTC)
+	existing := models.User{ID: "user-1", Name: "Ann", Email: "ann@example.com", CreatedAt: createdAt}
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		body        string
+		wantStatus  int
+	}{
+		{name: "PUT", method: http.MethodPut, body: `{"name":"Ann Updated"}`, wantStatus: http.StatusOK},
+		{name: "PUT with created_at", method: http.MethodPut,
+			body: `{"name":"Ann Updated","created_at":"2000-01-01T00:00:00Z"}`, wantStatus: http.StatusBadRequest},
+		{name: "merge PATCH", method: http.MethodPatch, body: `{"name":"Ann Updated"}`, wantStatus: http.StatusOK},
+		{name: "merge PATCH with created_at", method: http.MethodPatch,
+			body: `{"created_at":"2000-01-01T00:00:00Z"}`, wantStatus: http.StatusBadRequest},
+		{name: "JSON Patch", method: http.MethodPatch, contentType: JSONPatchContentType,
+			body: `[{"op":"replace","path":"/name","value":"Ann Updated"}]`, wantStatus: http.StatusOK},
+		{name: "JSON Patch of created_at", method: http.MethodPatch, contentType: JSONPatchContentType,
+			body: `[{"op":"replace","path":"/created_at","value":"2000-01-01T00:00:00Z"}]`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := seedUsers(t, existing)
+			handler := NewUserHandler(repo)
+
+			request := jsonRequest(tt.method, tt.body)
+			request.PathParameters = map[string]string{"id": existing.ID}
+			if tt.contentType != "" {
+				request.Headers["Content-Type"] = tt.contentType
+			}
+
+			update := handler.UpdateUserHandler
+			if tt.method == http.MethodPatch {
+				update = handler.PatchUserHandler
+			}
+			response, err := update(context.Background(), request)
+			if err != nil {
+				t.Fatalf("%s error = %v", tt.method, err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", response.StatusCode, tt.wantStatus, response.Body)
+			}
+
+			stored, err := repo.GetUserByID(context.Background(), existing.ID)
+			if err != nil {
+				t.Fatalf("GetUserByID() error = %v", err)
+			}
+			if got, want := stored.CreatedAt.Format(time.RFC3339Nano), createdAt.Format(time.RFC3339Nano); got != want {
+				t.Errorf("stored CreatedAt = %s, want %s", got, want)
+			}
+			if response.StatusCode == http.StatusOK {
+				if got := decodeResponse[models.User](t, response).CreatedAt; !got.Equal(createdAt) {
+					t.Errorf("response CreatedAt = %s, want %s", got, createdAt)
+				}
+			}
+		})
+	}
+}
+
+func TestPatchUserHandlerReturnsStoredUser(t *testing.T) {
+	existing := models.User{ID: "user-1", Name: "Ann", Email: "ann@example.com", Metadata: map[string]string{"team": "a"}}
+
+	tests := []struct {
+		name           string
+		contentType    string
+		prefer         string
+		body           string
+		wantPreference string
+	}{
+		{name: "merge patch", body: `{"name":"Ann Updated","metadata":{"team":null,"role":"admin"}}`},
+		{name: "return=representation", prefer: "return=representation", body: `{"email":"ann.b@example.com"}`,
+			wantPreference: "return=representation"},
+		{name: "JSON Patch", contentType: JSONPatchContentType,
+			body: `[{"op":"add","path":"/metadata/role","value":"admin"}]`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewUserHandler(seedUsers(t, existing))
+
+			request := jsonRequest(http.MethodPatch, tt.body)
+			request.PathParameters = map[string]string{"id": existing.ID}
+			if tt.contentType != "" {
+				request.Headers["Content-Type"] = tt.contentType
+			}
+			if tt.prefer != "" {
+				request.Headers["Prefer"] = tt.prefer
+			}
+
+			patched, err := handler.PatchUserHandler(context.Background(), request)
+			if err != nil || patched.StatusCode != http.StatusOK {
+				t.Fatalf("PATCH = %d %s, %v; want 200", patched.StatusCode, patched.Body, err)
+			}
+			if got := patched.Headers["Preference-Applied"]; got != tt.wantPreference {
+				t.Errorf("Preference-Applied = %q, want %q", got, tt.wantPreference)
+			}
+
+			got, err := handler.GetUserHandler(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod:     http.MethodGet,
+				PathParameters: map[string]string{"id": existing.ID},
+			})
+			if err != nil || got.StatusCode != http.StatusOK {
+				t.Fatalf("GET = %d %s, %v; want 200", got.StatusCode, got.Body, err)
+			}
+
+			if !reflect.DeepEqual(decodeResponse[models.User](t, patched), decodeResponse[models.User](t, got)) {
+				t.Errorf("PATCH response %s does not match GET %s", patched.Body, got.Body)
+			}
+		})
+	}
+}
+
+func TestGetAllUsersHandlerDefaultLimit(t *testing.T) {
+	tests := []struct {
+		name          string
+		users         int
+		envLimit      string
+		query         map[string]string
+		wantCount     int
+		wantTruncated bool
+	}{
+		{name: "truncated at the default limit", users: DefaultListLimit + 1, wantCount: DefaultListLimit,
+			wantTruncated: true},
+		{name: "exactly the default limit", users: DefaultListLimit, wantCount: DefaultListLimit},
+		{name: "DEFAULT_LIST_LIMIT", users: 5, envLimit: "3", wantCount: 3, wantTruncated: true},
+		{name: "explicit limit", users: 5, envLimit: "3", query: map[string]string{"limit": "5"}, wantCount: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DEFAULT_LIST_LIMIT", tt.envLimit)
+
+			users := make([]models.User, tt.users)
+			for i := range users {
+				users[i] = models.User{ID: fmt.Sprintf("user-%03d", i), Name: "User", Email: fmt.Sprintf("user%d@example.com", i)}
+			}
+			handler := NewUserHandler(seedUsers(t, users...))
+
+			response, err := handler.GetAllUsersHandler(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod:            http.MethodGet,
+				QueryStringParameters: tt.query,
+			})
+			if err != nil || response.StatusCode != http.StatusOK {
+				t.Fatalf("GET /users = %d %s, %v; want 200", response.StatusCode, response.Body, err)
+			}
+
+			page := decodeResponse[map[string]json.RawMessage](t, response)
+			var listed []models.User
+			if err := json.Unmarshal(page[DefaultListRootKey], &listed); err != nil {
+				t.Fatalf("decoding %s: %v", DefaultListRootKey, err)
+			}
+			if len(listed) != tt.wantCount {
+				t.Errorf("listed %d users, want %d", len(listed), tt.wantCount)
+			}
+			if _, hasCursor := page["next_cursor"]; hasCursor != tt.wantTruncated {
+				t.Errorf("next_cursor present = %v, want %v", hasCursor, tt.wantTruncated)
+			}
+			if truncated := response.Headers["X-Truncated"] == "true"; truncated != tt.wantTruncated {
+				t.Errorf("X-Truncated = %q, want truncated %v", response.Headers["X-Truncated"], tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestGetAllUsersHandlerListRootKey(t *testing.T) {
+	tests := []struct {
+		name       string
+		rootKey    string
+		limit      string
+		wantKey    string
+		wantCursor bool
+	}{
+		{name: "default", wantKey: DefaultListRootKey},
+		{name: "configured", rootKey: "data", wantKey: "data"},
+		{name: "configured with a next page", rootKey: "items", limit: "1", wantKey: "items", wantCursor: true},
+		{name: "next_cursor is not a root key", rootKey: "next_cursor", wantKey: DefaultListRootKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("LIST_ROOT_KEY", tt.rootKey)
+			handler := NewUserHandler(seedUsers(t,
+				models.User{ID: "user-1", Name: "Ann", Email: "ann@example.com"},
+				models.User{ID: "user-2", Name: "Bob", Email: "bob@example.com"},
+			))
+
+			request := events.APIGatewayProxyRequest{HTTPMethod: http.MethodGet}
+			if tt.limit != "" {
+				request.QueryStringParameters = map[string]string{"limit": tt.limit}
+			}
+			response, err := handler.GetAllUsersHandler(context.Background(), request)
+			if err != nil || response.StatusCode != http.StatusOK {
+				t.Fatalf("GET /users = %d %s, %v; want 200", response.StatusCode, response.Body, err)
+			}
+
+			page := decodeResponse[map[string]json.RawMessage](t, response)
+			wantKeys := 1
+			if tt.wantCursor {
+				wantKeys++
+			}
+			if len(page) != wantKeys {
+				t.Errorf("body = %s, want only %q and next_cursor %v", response.Body, tt.wantKey, tt.wantCursor)
+			}
+			var listed []models.User
+			if err := json.Unmarshal(page[tt.wantKey], &listed); err != nil || len(listed) == 0 {
+				t.Fatalf("list under %q = %s, %v; want users", tt.wantKey, page[tt.wantKey], err)
+			}
+			if _, ok := page["next_cursor"]; ok != tt.wantCursor {
+				t.Errorf("next_cursor present = %v, want %v", ok, tt.wantCursor)
+			}
+		})
+	}
+}
+
+func TestGetAllUsersHandlerNameFilter(t *testing.T) {
+	users := []models.User{
+		{ID: "user-1", Name: "John", Email: "john@example.com"},
+		{ID: "user-2", Name: "JOANNA", Email: "joanna@example.com"},
+		{ID: "user-3", Name: "Ann Mojo", Email: "ann@example.com"},
+		{ID: "user-4", Name: "Bob", Email: "bob@example.com"},
+		{ID: "user-5", Name: "Jos\u00e9", Email: "jose@example.com"},
+	}
+
+	tests := []struct {
+		name    string
+		filter  string
+		wantIDs []string
+	}{
+		{name: "lower-case filter", filter: "jo", wantIDs: []string{"user-1", "user-2", "user-3", "user-5"}},
+		{name: "upper-case filter", filter: "JO", wantIDs: []string{"user-1", "user-2", "user-3", "user-5"}},
+		{name: "mixed-case filter", filter: "aNn", wantIDs: []string{"user-2", "user-3"}},
+		{name: "decomposed filter", filter: "JOSE\u0301", wantIDs: []string{"user-5"}},
+		{name: "empty filter returns all", wantIDs: []string{"user-1", "user-2", "user-3", "user-4", "user-5"}},
+		{name: "no match", filter: "zed", wantIDs: []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewUserHandler(seedUsers(t, users...))
+
+			response, err := handler.GetAllUsersHandler(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod:            http.MethodGet,
+				QueryStringParameters: map[string]string{"name": tt.filter},
+			})
+			if err != nil || response.StatusCode != http.StatusOK {
+				t.Fatalf("GET /users = %d %s, %v; want 200", response.StatusCode, response.Body, err)
+			}
+			if ids := listedIDs(t, response); !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("ids = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+
+	t.Run("filtered before paging", func(t *testing.T) {
+		handler := NewUserHandler(seedUsers(t, users...))
+
+		var ids []string
+		query := map[string]string{"name": "Jo", "limit": "2"}
+		for page := 0; page == 0 || query["cursor"] != ""; page++ {
+			if page > len(users) {
+				t.Fatal("paging did not end")
+			}
+			response, err := handler.GetAllUsersHandler(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod:            http.MethodGet,
+				QueryStringParameters: query,
+			})
+			if err != nil || response.StatusCode != http.StatusOK {
+				t.Fatalf("GET /users = %d %s, %v; want 200", response.StatusCode, response.Body, err)
+			}
+
+			pageIDs := listedIDs(t, response)
+			cursor, _ := decodeResponse[map[string]any](t, response)["next_cursor"].(string)
+			if cursor != "" && len(pageIDs) != 2 {
+				t.Errorf("page %d has %d users before the last page, want 2", page, len(pageIDs))
+			}
+			ids = append(ids, pageIDs...)
+			query["cursor"] = cursor
+		}
+		if want := []string{"user-1", "user-2", "user-3", "user-5"}; !reflect.DeepEqual(ids, want) {
+			t.Errorf("paged ids = %v, want %v", ids, want)
+		}
+	})
+}
+
+func TestCreateUserHandlerMetadata(t *testing.T) {
+	tests := []struct {
+		name         string
+		body         string
+		wantStatus   int
+		wantMetadata map[string]string
+	}{
+		{
+			name:         "metadata is stored",
+			body:         `{"name":"Ann","email":"ann@example.com","metadata":{"team":"payments"}}`,
+			wantStatus:   http.StatusCreated,
+			wantMetadata: map[string]string{"team": "payments"},
+		},
+		{name: "no metadata", body: `{"name":"Ann","email":"ann@example.com"}`, wantStatus: http.StatusCreated},
+		{
+			name:       "value over the limit",
+			body:       `{"name":"Ann","email":"ann@example.com","metadata":{"team":"` + strings.Repeat("x", 300) + `"}}`,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "non-string value",
+			body:       `{"name":"Ann","email":"ann@example.com","metadata":{"count":1}}`,
+			wantStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := seedUsers(t)
+			handler := NewUserHandler(repo)
+
+			response, err := handler.CreateUserHandler(context.Background(), jsonRequest(http.MethodPost, tt.body))
+			if err != nil {
+				t.Fatalf("CreateUserHandler() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", response.StatusCode, tt.wantStatus, response.Body)
+			}
+			if tt.wantStatus != http.StatusCreated {
+				return
+			}
+
+			created := decodeResponse[models.User](t, response)
+			stored, err := repo.GetUserByID(context.Background(), created.ID)
+			if err != nil {
+				t.Fatalf("GetUserByID() error = %v", err)
+			}
+			if !reflect.DeepEqual(created.Metadata, tt.wantMetadata) || !reflect.DeepEqual(stored.Metadata, tt.wantMetadata) {
+				t.Errorf("metadata = %v in the response, %v stored; want %v", created.Metadata, stored.Metadata, tt.wantMetadata)
+			}
+		})
+	}
+}
+
+func TestCreateUserHandlerTestMode(t *testing.T) {
+	t.Setenv("TEST_MODE", "true")
+	t.Setenv("AWS_LAMBDA_FUNCTION_NAME", "")
+	utils.ResetTestMode()
+	t.Cleanup(utils.ResetTestMode)
+	handler := NewUserHandler(seedUsers(t))
+
+	tests := []struct {
+		name     string
+		body     string
+		wantBody string
+	}{
+		{
+			name: "first create",
+			body: `{"name":"Ann","email":"ann@example.com"}`,
+			wantBody: `{"id":"00000000-0000-0000-0000-000000000001","name":"Ann","email":"ann@example.com",` +
+				`"created_at":"2024-01-01T00:00:00Z","updated_at":"2024-01-01T00:00:00Z","version":1}`,
+		},
+		{
+			name: "second create",
+			body: `{"name":"Bob","email":"bob@example.com"}`,
+			wantBody: `{"id":"00000000-0000-0000-0000-000000000002","name":"Bob","email":"bob@example.com",` +
+				`"created_at":"2024-01-01T00:00:00Z","updated_at":"2024-01-01T00:00:00Z","version":1}`,
+		},
+	}
+
+	// The creates run in order, as the IDs are sequential
+	for _, tt := range tests {
+		response, err := handler.CreateUserHandler(context.Background(), jsonRequest(http.MethodPost, tt.body))
+		if err != nil || response.StatusCode != http.StatusCreated {
+			t.Fatalf("%s: status %d, error %v; want 201", tt.name, response.StatusCode, err)
+		}
+		if response.Body != tt.wantBody {
+			t.Errorf("%s: body = %s, want %s", tt.name, response.Body, tt.wantBody)
+		}
+	}
+}
+
+func TestDeleteUserHandlerConfirmation(t *testing.T) {
+	existing := models.User{ID: "user-1", Name: "Ann", Email: "ann@example.com"}
+
+	tests := []struct {
+		name           string
+		returnBody     string
+		prefer         string
+		id             string
+		wantStatus     int
+		wantBody       bool
+		wantPreference string
+	}{
+		{name: "204 by default", id: existing.ID, wantStatus: http.StatusNoContent},
+		{name: "DELETE_RETURN_BODY", returnBody: "true", id: existing.ID, wantStatus: http.StatusOK, wantBody: true},
+		{
+			name:           "Prefer: return=representation",
+			prefer:         "return=representation",
+			id:             existing.ID,
+			wantStatus:     http.StatusOK,
+			wantBody:       true,
+			wantPreference: "return=representation",
+		},
+		{
+			name:           "Prefer: return=minimal overrides DELETE_RETURN_BODY",
+			returnBody:     "true",
+			prefer:         "return=minimal",
+			id:             existing.ID,
+			wantStatus:     http.StatusNoContent,
+			wantPreference: "return=minimal",
+		},
+		{name: "unknown user", returnBody: "true", id: "missing", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DELETE_RETURN_BODY", tt.returnBody)
+			handler := NewUserHandler(seedUsers(t, existing))
+
+			request := events.APIGatewayProxyRequest{
+				HTTPMethod:     http.MethodDelete,
+				Headers:        map[string]string{},
+				PathParameters: map[string]string{"id": tt.id},
+			}
+			if tt.prefer != "" {
+				request.Headers["Prefer"] = tt.prefer
+			}
+
+			response, err := handler.DeleteUserHandler(context.Background(), request)
+			if err != nil {
+				t.Fatalf("DeleteUserHandler() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", response.StatusCode, tt.wantStatus, response.Body)
+			}
+			if got := response.Headers["Preference-Applied"]; got != tt.wantPreference {
+				t.Errorf("Preference-Applied = %q, want %q", got, tt.wantPreference)
+			}
+
+			switch {
+			case tt.wantBody:
+				body := decodeResponse[map[string]any](t, response)
+				if body["deleted"] != true || body["id"] != tt.id {
+					t.Errorf("body = %v, want deleted true and id %q", body, tt.id)
+				}
+			case tt.wantStatus == http.StatusNoContent && response.Body != "":
+				t.Errorf("body = %q, want none", response.Body)
+			}
+		})
+	}
+}
+
+func TestDeleteAllUsersHandler(t *testing.T) {
+	users := []models.User{
+		{ID: "user-1", Name: "Ann", Email: "ann@example.com"},
+		{ID: "user-2", Name: "Bob", Email: "bob@example.com"},
+	}
+
+	tests := []struct {
+		name        string
+		allow       string
+		confirm     string
+		wantStatus  int
+		wantDeleted int
+	}{
+		{name: "guard unset", confirm: "true", wantStatus: http.StatusForbidden},
+		{name: "guard off", allow: "false", confirm: "true", wantStatus: http.StatusForbidden},
+		{name: "not confirmed", allow: "true", wantStatus: http.StatusBadRequest},
+		{name: "confirmed otherwise", allow: "true", confirm: "yes", wantStatus: http.StatusBadRequest},
+		{name: "allowed and confirmed", allow: "true", confirm: "true", wantStatus: http.StatusOK, wantDeleted: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ALLOW_BULK_DELETE", tt.allow)
+			repo := seedUsers(t, users...)
+			handler := NewUserHandler(repo)
+			ctx := context.Background()
+
+			request := events.APIGatewayProxyRequest{HTTPMethod: http.MethodDelete}
+			if tt.confirm != "" {
+				request.QueryStringParameters = map[string]string{"confirm": tt.confirm}
+			}
+			response, err := handler.DeleteAllUsersHandler(ctx, request)
+			if err != nil {
+				t.Fatalf("DeleteAllUsersHandler() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", response.StatusCode, tt.wantStatus, response.Body)
+			}
+			if tt.wantStatus == http.StatusOK {
+				if got := decodeResponse[map[string]int](t, response)["deleted"]; got != tt.wantDeleted {
+					t.Errorf("deleted = %d, want %d", got, tt.wantDeleted)
+				}
+			}
+
+			left, _, err := repo.GetAllUsers(ctx, models.ListQuery{})
+			if err != nil {
+				t.Fatalf("GetAllUsers() error = %v", err)
+			}
+			if want := len(users) - tt.wantDeleted; len(left) != want {
+				t.Errorf("%d users left, want %d", len(left), want)
+			}
+		})
+	}
+}
+
+func TestUserHandlerUpdatedAt(t *testing.T) {
+	createdAt := time.Date(2020, time.January, 2, 3, 4, 5, 0, time.UTC)
+	existing := models.User{
+		ID: "user-1", Name: "Ann", Email: "ann@example.com", CreatedAt: createdAt, UpdatedAt: createdAt,
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+	}{
+		{name: "PUT", method: http.MethodPut, body: `{"name":"Ann Updated","email":"ann@example.com"}`},
+		{name: "PATCH", method: http.MethodPatch, body: `{"name":"Ann Updated"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := seedUsers(t, existing)
+			handler := NewUserHandler(repo)
+
+			request := jsonRequest(tt.method, tt.body)
+			request.PathParameters = map[string]string{"id": existing.ID}
+			update := handler.UpdateUserHandler
+			if tt.method == http.MethodPatch {
+				update = handler.PatchUserHandler
+			}
+
+			before := time.Now().UTC()
+			response, err := update(context.Background(), request)
+			if err != nil {
+				t.Fatalf("%s error = %v", tt.method, err)
+			}
+			if response.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d, want %d (body %s)", response.StatusCode, http.StatusOK, response.Body)
+			}
+
+			stored, err := repo.GetUserByID(context.Background(), existing.ID)
+			if err != nil {
+				t.Fatalf("GetUserByID() error = %v", err)
+			}
+			returned := decodeResponse[models.User](t, response)
+			for source, user := range map[string]models.User{"stored": stored, "response": returned} {
+				if !user.CreatedAt.Equal(createdAt) {
+					t.Errorf("%s CreatedAt = %s, want %s", source, user.CreatedAt, createdAt)
+				}
+				if user.UpdatedAt.Before(before) {
+					t.Errorf("%s UpdatedAt = %s, want at or after %s", source, user.UpdatedAt, before)
+				}
+				if user.UpdatedAt.Location() != time.UTC {
+					t.Errorf("%s UpdatedAt location = %s, want UTC", source, user.UpdatedAt.Location())
+				}
+			}
+		})
+	}
+}
+
+func TestCreateUserHandlerUpdatedAt(t *testing.T) {
+	handler := NewUserHandler(seedUsers(t))
+
+	body := `{"name":"Ann","email":"ann@example.com"}`
+	response, err := handler.CreateUserHandler(context.Background(), jsonRequest(http.MethodPost, body))
+	if err != nil {
+		t.Fatalf("CreateUserHandler() error = %v", err)
+	}
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("status = %d, want %d (body %s)", response.StatusCode, http.StatusCreated, response.Body)
+	}
+
+	created := decodeResponse[models.User](t, response)
+	if created.CreatedAt.IsZero() || !created.UpdatedAt.Equal(created.CreatedAt) {
+		t.Errorf("UpdatedAt = %s, want CreatedAt %s", created.UpdatedAt, created.CreatedAt)
+	}
+}
+
+func TestCreateUserHandlerClientID(t *testing.T) {
+	existing := models.User{ID: "ext-1", Name: "Existing", Email: "taken@example.com"}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantID     string
+	}{
+		{
+			name:       "client-supplied ID",
+			body:       `{"id":"crm_42-a","name":"Ann","email":"ann@example.com"}`,
+			wantStatus: http.StatusCreated,
+			wantID:     "crm_42-a",
+		},
+		{
+			name:       "ID collision",
+			body:       `{"id":"ext-1","name":"Ann","email":"ann@example.com"}`,
+			wantStatus: http.StatusConflict,
+		},
+		{
+			name:       "ID with a slash",
+			body:       `{"id":"a/b","name":"Ann","email":"ann@example.com"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "ID with a space",
+			body:       `{"id":"a b","name":"Ann","email":"ann@example.com"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "ID too long",
+			body:       `{"id":"` + strings.Repeat("a", 65) + `","name":"Ann","email":"ann@example.com"}`,
+			wantStatus: http.StatusUnprocessableEntity,
+		},
+		{
+			name:       "generated ID",
+			body:       `{"name":"Ann","email":"ann@example.com"}`,
+			wantStatus: http.StatusCreated,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := seedUsers(t, existing)
+			handler := NewUserHandler(repo)
+
+			response, err := handler.CreateUserHandler(context.Background(), jsonRequest(http.MethodPost, tt.body))
+			if err != nil {
+				t.Fatalf("CreateUserHandler() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", response.StatusCode, tt.wantStatus, response.Body)
+			}
+			if tt.wantStatus != http.StatusCreated {
+				users, err := models.ListAllUsers(context.Background(), repo)
+				if err != nil {
+					t.Fatalf("ListAllUsers() error = %v", err)
+				}
+				if len(users) != 1 {
+					t.Errorf("%d users stored, want 1", len(users))
+				}
+				return
+			}
+
+			created := decodeResponse[models.User](t, response)
+			switch {
+			case tt.wantID != "" && created.ID != tt.wantID:
+				t.Errorf("id = %q, want %q", created.ID, tt.wantID)
+			case tt.wantID == "" && (created.ID == "" || created.ID == existing.ID):
+				t.Errorf("id = %q, want a generated ID", created.ID)
+			}
+			if _, err := repo.GetUserByID(context.Background(), created.ID); err != nil {
+				t.Errorf("GetUserByID(%q) error = %v", created.ID, err)
+			}
+		})
+	}
+}
+
+func TestCreateUserHandlerTwiceWithSameEmail(t *testing.T) {
+	handler := NewUserHandler(seedUsers(t))
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{name: "first create", body: `{"name":"Ann","email":"ann@example.com"}`, wantStatus: http.StatusCreated},
+		{name: "second create", body: `{"name":"Other","email":"ann@example.com"}`, wantStatus: http.StatusConflict},
+	}
+
+	// The creates run in order against one repository
+	for _, tt := range tests {
+		response, err := handler.CreateUserHandler(context.Background(), jsonRequest(http.MethodPost, tt.body))
+		if err != nil {
+			t.Fatalf("%s: CreateUserHandler() error = %v", tt.name, err)
+		}
+		if response.StatusCode != tt.wantStatus {
+			t.Errorf("%s: status = %d, want %d (body %s)", tt.name, response.StatusCode, tt.wantStatus, response.Body)
+		}
+	}
+}
+
+func TestCreateUserHandlerDefaultsApplied(t *testing.T) {
+	tests := []struct {
+		name        string
+		prefer      string
+		body        string
+		wantApplied []string
+	}{
+		{
+			name:        "generated ID",
+			prefer:      "return=representation+defaults",
+			body:        `{"name":"Ann","email":"ann@example.com"}`,
+			wantApplied: []string{"id", "created_at", "updated_at"},
+		},
+		{
+			name:        "client-supplied ID",
+			prefer:      "return=representation+defaults",
+			body:        `{"id":"ext-1","name":"Ann","email":"ann@example.com"}`,
+			wantApplied: []string{"created_at", "updated_at"},
+		},
+		{
+			name:        "normalized name and email",
+			prefer:      "return=representation+defaults",
+			body:        `{"name":" Ann ","email":"ann@EXAMPLE.com"}`,
+			wantApplied: []string{"id", "created_at", "updated_at", "name", "email"},
+		},
+		{name: "not requested", body: `{"name":"Ann","email":"ann@example.com"}`},
+		{name: "plain representation", prefer: "return=representation", body: `{"name":"Ann","email":"ann@example.com"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewUserHandler(seedUsers(t))
+
+			request := jsonRequest(http.MethodPost, tt.body)
+			if tt.prefer != "" {
+				request.Headers["Prefer"] = tt.prefer
+			}
+			response, err := handler.CreateUserHandler(context.Background(), request)
+			if err != nil {
+				t.Fatalf("CreateUserHandler() error = %v", err)
+			}
+			if response.StatusCode != http.StatusCreated {
+				t.Fatalf("status = %d, want %d (body %s)", response.StatusCode, http.StatusCreated, response.Body)
+			}
+
+			body := decodeResponse[map[string]json.RawMessage](t, response)
+			raw, ok := body["defaults_applied"]
+			if tt.wantApplied == nil {
+				if ok {
+					t.Errorf("defaults_applied = %s, want none", raw)
+				}
+				return
+			}
+
+			var applied []string
+			if err := json.Unmarshal(raw, &applied); err != nil {
+				t.Fatalf("decoding defaults_applied %s: %v", raw, err)
+			}
+			if !reflect.DeepEqual(applied, tt.wantApplied) {
+				t.Errorf("defaults_applied = %v, want %v", applied, tt.wantApplied)
+			}
+			if _, ok := body["id"]; !ok {
+				t.Errorf("representation has no id: %s", response.Body)
+			}
+		})
+	}
+}
+
+func TestCreateUserHandlerFindOrCreate(t *testing.T) {
+	existing := models.User{ID: "existing", Name: "Existing", Email: "taken@example.com"}
+
+	tests := []struct {
+		name         string
+		findOrCreate string
+		body         string
+		wantStatus   int
+		wantID       string
+		wantUsers    int
+	}{
+		{
+			name:         "found",
+			findOrCreate: "true",
+			body:         `{"name":"Other","email":"taken@example.com"}`,
+			wantStatus:   http.StatusOK,
+			wantID:       "existing",
+			wantUsers:    1,
+		},
+		{
+			name:         "found with the domain in another case",
+			findOrCreate: "true",
+			body:         `{"name":"Other","email":"taken@EXAMPLE.com"}`,
+			wantStatus:   http.StatusOK,
+			wantID:       "existing",
+			wantUsers:    1,
+		},
+		{
+			name:         "created",
+			findOrCreate: "true",
+			body:         `{"name":"Ann","email":"ann@example.com"}`,
+			wantStatus:   http.StatusCreated,
+			wantUsers:    2,
+		},
+		{
+			name:       "conflict without findOrCreate",
+			body:       `{"name":"Other","email":"taken@example.com"}`,
+			wantStatus: http.StatusConflict,
+			wantUsers:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := seedUsers(t, existing)
+			handler := NewUserHandler(repo)
+
+			request := jsonRequest(http.MethodPost, tt.body)
+			if tt.findOrCreate != "" {
+				request.QueryStringParameters = map[string]string{"findOrCreate": tt.findOrCreate}
+			}
+			response, err := handler.CreateUserHandler(context.Background(), request)
+			if err != nil {
+				t.Fatalf("CreateUserHandler() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", response.StatusCode, tt.wantStatus, response.Body)
+			}
+			if tt.wantID != "" {
+				if got := decodeResponse[models.User](t, response); got.ID != tt.wantID || got.Name != existing.Name {
+					t.Errorf("returned user %s %q, want %s %q", got.ID, got.Name, tt.wantID, existing.Name)
+				}
+			}
+
+			users, err := models.ListAllUsers(context.Background(), repo)
+			if err != nil {
+				t.Fatalf("ListAllUsers() error = %v", err)
+			}
+			if len(users) != tt.wantUsers {
+				t.Errorf("%d users stored, want %d", len(users), tt.wantUsers)
+			}
+		})
+	}
+}
+
+func TestCreateUserHandlerFindOrCreateConcurrent(t *testing.T) {
+	repo := seedUsers(t)
+	handler := NewUserHandler(repo)
+
+	const calls = 20
+	statuses := make(chan int, calls)
+	ids := make(chan string, calls)
+	var wg sync.WaitGroup
+	for range calls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			request := jsonRequest(http.MethodPost, `{"name":"Ann","email":"ann@example.com"}`)
+			request.QueryStringParameters = map[string]string{"findOrCreate": "true"}
+			response, err := handler.CreateUserHandler(context.Background(), request)
+			if err != nil {
+				t.Errorf("CreateUserHandler() error = %v", err)
+				return
+			}
+			var user models.User
+			if err := json.Unmarshal([]byte(response.Body), &user); err != nil {
+				t.Errorf("decoding response body %q: %v", response.Body, err)
+				return
+			}
+			statuses <- response.StatusCode
+			ids <- user.ID
+		}()
+	}
+	wg.Wait()
+	close(statuses)
+	close(ids)
+
+	counts := make(map[int]int)
+	for status := range statuses {
+		counts[status]++
+	}
+	if counts[http.StatusCreated] != 1 || counts[http.StatusOK] != calls-1 {
+		t.Errorf("statuses = %v, want one %d and %d %d", counts, http.StatusCreated, calls-1, http.StatusOK)
+	}
+	distinct := make(map[string]bool)
+	for id := range ids {
+		distinct[id] = true
+	}
+	if len(distinct) != 1 {
+		t.Errorf("returned IDs = %v, want one", distinct)
+	}
+}
+
+// failingRepository fails every read with err.
+type failingRepository struct {
+	models.UserRepository
+
+	err error
+}
+
+func (r failingRepository) GetUserByID(context.Context, string) (models.User, error) {
+	return models.User{}, r.err
+}
+
+func TestGetUserHandlerRepositoryErrors(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{name: "circuit open", err: models.ErrCircuitOpen, wantStatus: http.StatusServiceUnavailable},
+		{name: "wrapped circuit open", err: fmt.Errorf("reading: %w", models.ErrCircuitOpen),
+			wantStatus: http.StatusServiceUnavailable},
+		{name: "not found", err: models.ErrUserNotFound, wantStatus: http.StatusNotFound},
+		{name: "deadline", err: context.DeadlineExceeded, wantStatus: http.StatusGatewayTimeout},
+		{name: "store failure", err: errors.New("service unavailable"), wantStatus: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sharedUserCache.clear()
+			handler := NewUserHandler(failingRepository{err: tt.err})
+
+			request := events.APIGatewayProxyRequest{PathParameters: map[string]string{"id": "user-1"}}
+			response, err := handler.GetUserHandler(context.Background(), request)
+			if err != nil {
+				t.Fatalf("GetUserHandler() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", response.StatusCode, tt.wantStatus, response.Body)
+			}
+		})
+	}
+}
+
+func TestUserHandlerStrictJSON(t *testing.T) {
+	existing := models.User{ID: "user-1", Name: "Ann", Email: "ann@example.com"}
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+		wantError  string
+	}{
+		{name: "create", method: http.MethodPost, body: `{"name":"Bob","email":"bob@example.com"}`,
+			wantStatus: http.StatusCreated},
+		{name: "create with an unknown field", method: http.MethodPost,
+			body: `{"name":"Bob","emial":"bob@example.com"}`, wantStatus: http.StatusBadRequest, wantError: `"emial"`},
+		{name: "create with malformed JSON", method: http.MethodPost, body: `{"name":"Bob",`,
+			wantStatus: http.StatusBadRequest, wantError: "invalid JSON"},
+		{name: "update", method: http.MethodPut, body: `{"name":"Annie","email":"ann@example.com"}`,
+			wantStatus: http.StatusOK},
+		{name: "update with an unknown field", method: http.MethodPut,
+			body: `{"name":"Annie","email":"ann@example.com","admin":true}`, wantStatus: http.StatusBadRequest,
+			wantError: `"admin"`},
+		{name: "update with malformed JSON", method: http.MethodPut, body: `{"name":}`,
+			wantStatus: http.StatusBadRequest, wantError: "invalid JSON"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := NewUserHandler(seedUsers(t, existing))
+
+			request := jsonRequest(tt.method, tt.body)
+			handle := handler.CreateUserHandler
+			if tt.method == http.MethodPut {
+				request.PathParameters = map[string]string{"id": existing.ID}
+				handle = handler.UpdateUserHandler
+			}
+			response, err := handle(context.Background(), request)
+			if err != nil {
+				t.Fatalf("handler error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", response.StatusCode, tt.wantStatus, response.Body)
+			}
+			if tt.wantError != "" {
+				message := decodeResponse[map[string]string](t, response)["error"]
+				if !strings.Contains(message, tt.wantError) {
+					t.Errorf("error = %q, want it to mention %s", message, tt.wantError)
+				}
+			}
+		})
+	}
+}
+
+func TestRepositoryErrorStatus(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "not found", err: models.ErrUserNotFound, want: http.StatusNotFound},
+		{name: "wrapped not found", err: fmt.Errorf("user 2: %w", models.ErrUserNotFound), want: http.StatusNotFound},
+		{name: "duplicate email", err: models.ErrDuplicateEmail, want: http.StatusConflict},
+		{name: "duplicate ID", err: models.ErrDuplicateID, want: http.StatusConflict},
+		{name: "version conflict", err: models.ErrVersionConflict, want: http.StatusConflict},
+		{name: "invalid cursor", err: models.ErrInvalidCursor, want: http.StatusBadRequest},
+		{name: "circuit open", err: models.ErrCircuitOpen, want: http.StatusServiceUnavailable},
+		{name: "deadline", err: context.DeadlineExceeded, want: http.StatusGatewayTimeout},
+		{name: "message alone is not enough", err: errors.New("user not found"), want: http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := repositoryErrorStatus(tt.err); got != tt.want {
+				t.Errorf("repositoryErrorStatus(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUpdateUserHandlerStaleVersion(t *testing.T) {
+	tests := []struct {
+		name       string
+		ifMatch    string
+		body       string
+		wantStatus int
+	}{
+		{name: "no version", body: `{"name":"Annie"}`, wantStatus: http.StatusOK},
+		{name: "current version in If-Match", ifMatch: `"2"`, body: `{"name":"Annie"}`, wantStatus: http.StatusOK},
+		{name: "current ETag in If-Match", ifMatch: `"2-0123abcd"`, body: `{"name":"Annie"}`, wantStatus: http.StatusOK},
+		{name: "any version in If-Match", ifMatch: "*", body: `{"name":"Annie"}`, wantStatus: http.StatusOK},
+		{name: "current version in the body", body: `{"name":"Annie","version":2}`, wantStatus: http.StatusOK},
+		{name: "stale If-Match", ifMatch: `"1"`, body: `{"name":"Annie"}`, wantStatus: http.StatusConflict},
+		{name: "stale ETag in If-Match", ifMatch: `"1-0123abcd"`, body: `{"name":"Annie"}`,
+			wantStatus: http.StatusConflict},
+		{name: "stale body version", body: `{"name":"Annie","version":1}`, wantStatus: http.StatusConflict},
+		{name: "If-Match and body disagree", ifMatch: `"2"`, body: `{"name":"Annie","version":1}`,
+			wantStatus: http.StatusBadRequest},
+		{name: "malformed If-Match", ifMatch: `"latest"`, body: `{"name":"Annie"}`, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := seedUsers(t, models.User{ID: "user-1", Name: "Ann", Email: "ann@example.com"})
+			handler := NewUserHandler(repo)
+			ctx := context.Background()
+
+			// Another client updates the user after this one read version 1
+			read, err := repo.GetUserByID(ctx, "user-1")
+			if err != nil {
+				t.Fatalf("GetUserByID() error = %v", err)
+			}
+			read.Name = "Ann Lee"
+			if _, err := repo.UpdateUser(ctx, read); err != nil {
+				t.Fatalf("concurrent UpdateUser() error = %v", err)
+			}
+
+			request := jsonRequest(http.MethodPut, tt.body)
+			request.PathParameters = map[string]string{"id": "user-1"}
+			if tt.ifMatch != "" {
+				request.Headers["If-Match"] = tt.ifMatch
+			}
+			response, err := handler.UpdateUserHandler(ctx, request)
+			if err != nil {
+				t.Fatalf("UpdateUserHandler() error = %v", err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", response.StatusCode, tt.wantStatus, response.Body)
+			}
+
+			stored, err := repo.GetUserByID(ctx, "user-1")
+			if err != nil {
+				t.Fatalf("GetUserByID() error = %v", err)
+			}
+			wantName, wantVersion := "Ann Lee", 2
+			if tt.wantStatus == http.StatusOK {
+				wantName, wantVersion = "Annie", 3
+			}
+			if stored.Name != wantName || stored.Version != wantVersion {
+				t.Errorf("stored %q at version %d, want %q at version %d", stored.Name, stored.Version, wantName,
+					wantVersion)
+			}
+		})
+	}
+}
+
+func TestUserHandlerNameNormalization(t *testing.T) {
+	long := strings.Repeat("a", models.DefaultNameMaxLength+1)
+
+	tests := []struct {
+		name       string
+		method     string
+		body       string
+		wantStatus int
+		wantName   string
+	}{
+		{name: "create trims", method: http.MethodPost, body: `{"name":" Ann Lee\t","email":"bob@example.com"}`,
+			wantStatus: http.StatusCreated, wantName: "Ann Lee"},
+		{name: "update trims", method: http.MethodPut, body: `{"name":"  Annie ","email":"ann@example.com"}`,
+			wantStatus: http.StatusOK, wantName: "Annie"},
+		{name: "patch trims", method: http.MethodPatch, body: `{"name":"Annie\n"}`, wantStatus: http.StatusOK,
+			wantName: "Annie"},
+		{name: "create whitespace-only", method: http.MethodPost, body: `{"name":" \t ","email":"bob@example.com"}`,
+			wantStatus: http.StatusUnprocessableEntity},
+		{name: "patch whitespace-only", method: http.MethodPatch, body: `{"name":"   "}`,
+			wantStatus: http.StatusUnprocessableEntity},
+		{name: "create too long", method: http.MethodPost, body: `{"name":"` + long + `","email":"bob@example.com"}`,
+			wantStatus: http.StatusUnprocessableEntity},
+		{name: "update control character", method: http.MethodPut, body: `{"name":"Ann\u0007Lee","email":"ann@example.com"}`,
+			wantStatus: http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sharedUserCache.clear()
+			t.Cleanup(sharedUserCache.clear)
+			repo := seedUsers(t, models.User{ID: "user-1", Name: "Ann", Email: "ann@example.com"})
+			handler := NewUserHandler(repo)
+			ctx := context.Background()
+
+			request := jsonRequest(tt.method, tt.body)
+			var response events.APIGatewayProxyResponse
+			var err error
+			switch tt.method {
+			case http.MethodPost:
+				response, err = handler.CreateUserHandler(ctx, request)
+			case http.MethodPut:
+				request.PathParameters = map[string]string{"id": "user-1"}
+				response, err = handler.UpdateUserHandler(ctx, request)
+			default:
+				request.PathParameters = map[string]string{"id": "user-1"}
+				response, err = handler.PatchUserHandler(ctx, request)
+			}
+			if err != nil {
+				t.Fatalf("%s error = %v", tt.method, err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", response.StatusCode, tt.wantStatus, response.Body)
+			}
+			if tt.wantName == "" {
+				return
+			}
+
+			user := decodeResponse[models.User](t, response)
+			stored, err := repo.GetUserByID(ctx, user.ID)
+			if err != nil {
+				t.Fatalf("GetUserByID() error = %v", err)
+			}
+			if user.Name != tt.wantName || stored.Name != tt.wantName {
+				t.Errorf("name = %q, stored %q; want %q", user.Name, stored.Name, tt.wantName)
+			}
+		})
+	}
+}
+
+func TestGetUserHandlerContentNegotiation(t *testing.T) {
+	tests := []struct {
+		name            string
+		accept          string
+		wantContentType string
+		wantBody        string
+	}{
+		{name: "JSON by default", wantContentType: "application/json", wantBody: `"name":"Ann"`},
+		{name: "XML", accept: "application/xml", wantContentType: utils.XMLContentType, wantBody: "<name>Ann</name>"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sharedUserCache.clear()
+			t.Cleanup(sharedUserCache.clear)
+			handler := NewUserHandler(seedUsers(t, models.User{ID: "user-1", Name: "Ann", Email: "ann@example.com"}))
+
+			response, err := handler.GetUserHandler(context.Background(), events.APIGatewayProxyRequest{
+				HTTPMethod:     http.MethodGet,
+				PathParameters: map[string]string{"id": "user-1"},
+				Headers:        map[string]string{"Accept": tt.accept},
+			})
+			if err != nil || response.StatusCode != http.StatusOK {
+				t.Fatalf("GetUserHandler() = %d, %v; want 200", response.StatusCode, err)
+			}
+			if got := response.Headers["Content-Type"]; !strings.HasPrefix(got, tt.wantContentType) {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantContentType)
+			}
+			if !strings.Contains(response.Body, tt.wantBody) {
+				t.Errorf("body = %s, want it to contain %s", response.Body, tt.wantBody)
+			}
+		})
+	}
+}
+
+func TestUserHandlerEmailSuggestion(t *testing.T) {
+	tests := []struct {
+		name           string
+		method         string
+		body           string
+		wantStatus     int
+		wantSuggestion string
+		wantWarning    string
+	}{
+		{name: "invalid with a suggestion", method: http.MethodPost, body: `{"name":"Bob","email":"bob@gmail,com"}`,
+			wantStatus: http.StatusUnprocessableEntity, wantSuggestion: "bob@gmail.com"},
+		{name: "invalid without a suggestion", method: http.MethodPost, body: `{"name":"Bob","email":"notanemail"}`,
+			wantStatus: http.StatusUnprocessableEntity},
+		{name: "update invalid with a suggestion", method: http.MethodPut,
+			body: `{"name":"Ann","email":"ann@example..com"}`, wantStatus: http.StatusUnprocessableEntity,
+			wantSuggestion: "ann@example.com"},
+		{name: "valid but mistyped", method: http.MethodPost, body: `{"name":"Bob","email":"bob@gmial.com"}`,
+			wantStatus: http.StatusCreated, wantWarning: `299 - "email may be mistyped, did you mean bob@gmail.com?"`},
+		{name: "update valid but mistyped", method: http.MethodPut, body: `{"name":"Ann","email":"ann@yahooo.com"}`,
+			wantStatus: http.StatusOK, wantWarning: `299 - "email may be mistyped, did you mean ann@yahoo.com?"`},
+		{name: "valid", method: http.MethodPost, body: `{"name":"Bob","email":"bob@example.com"}`,
+			wantStatus: http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sharedUserCache.clear()
+			t.Cleanup(sharedUserCache.clear)
+			handler := NewUserHandler(seedUsers(t, models.User{ID: "user-1", Name: "Ann", Email: "ann@example.com"}))
+
+			request := jsonRequest(tt.method, tt.body)
+			var response events.APIGatewayProxyResponse
+			var err error
+			if tt.method == http.MethodPost {
+				response, err = handler.CreateUserHandler(context.Background(), request)
+			} else {
+				request.PathParameters = map[string]string{"id": "user-1"}
+				response, err = handler.UpdateUserHandler(context.Background(), request)
+			}
+			if err != nil {
+				t.Fatalf("%s error = %v", tt.method, err)
+			}
+			if response.StatusCode != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", response.StatusCode, tt.wantStatus, response.Body)
+			}
+			if got := response.Headers["Warning"]; got != tt.wantWarning {
+				t.Errorf("Warning = %q, want %q", got, tt.wantWarning)
+			}
+			if tt.wantStatus != http.StatusUnprocessableEntity {
+				return
+			}
+
+			body := decodeResponse[map[string]string](t, response)
+			if body["suggestion"] != tt.wantSuggestion {
+				t.Errorf("suggestion = %q, want %q (body %s)", body["suggestion"], tt.wantSuggestion, response.Body)
+			}
+			if _, ok := body["suggestion"]; ok != (tt.wantSuggestion != "") {
+				t.Errorf("body %s has a suggestion = %v, want %v", response.Body, ok, tt.wantSuggestion != "")
+			}
+		})
+	}
+}
@@ -0,0 +1,88 @@
+package app
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"go-lambda-api/utils"
+)
+
+// accessLogWriter records the status and body size written through it.
+type accessLogWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+	// logged is set by handlers that write their own access log line.
+	logged bool
+}
+
+func (w *accessLogWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *accessLogWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+
+	return n, err
+}
+
+// Flush forwards to the underlying writer, for event streams.
+func (w *accessLogWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap returns the underlying writer, for http.ResponseController.
+func (w *accessLogWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// logAccess writes an access log line for every request when ACCESS_LOG_FORMAT=clf, unless
+// the Lambda middleware behind adapt already wrote one. Long-lived event streams are logged
+// when they end.
+func logAccess(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !utils.AccessLogEnabled() {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		recorder := &accessLogWriter{ResponseWriter: w}
+		next.ServeHTTP(recorder, r)
+		if recorder.logged {
+			return
+		}
+
+		remoteAddr := r.RemoteAddr
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			remoteAddr = host
+		}
+		status := recorder.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		utils.LogAccess(utils.AccessLogEntry{
+			RemoteAddr: remoteAddr,
+			Time:       start,
+			Method:     r.Method,
+			URI:        r.RequestURI,
+			Protocol:   r.Proto,
+			Status:     status,
+			Bytes:      recorder.bytes,
+			Referer:    r.Referer(),
+			UserAgent:  r.UserAgent(),
+			Duration:   time.Since(start),
+		})
+	})
+}
@@ -2,52 +2,32 @@ package app
 
 import (
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	localLambda "go-lambda-api/cmd/lambda"
 	"go-lambda-api/handlers"
 	"go-lambda-api/models"
+	"go-lambda-api/utils"
 
 	"github.com/aws/aws-lambda-go/events"
 	aws_lambda "github.com/aws/aws-lambda-go/lambda"
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/aws/session"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
 	"github.com/joho/godotenv"
 )
 
-// NewDB returns a new DynamoDB client
-func NewDB() dynamodbiface.DynamoDBAPI {
-	// Load environment variables from .env file
-	err := godotenv.Load()
-	if err != nil {
-		log.Printf("Could not load .env file, assuming production environment: %v", err)
-	}
-
-	// Use the AWS_REGION environment variable, if available
-	awsRegion := os.Getenv("AWS_REGION")
-	if awsRegion == "" {
-		awsRegion = "us-east-1" // Default to us-east-1 if not set
-	}
-
-	sess, err := session.NewSession(&aws.Config{
-		Region: aws.String(awsRegion),
-	})
-
-	if err != nil {
-		log.Fatalf("Error creating AWS session: %v", err)
-	}
-
-	return dynamodb.New(sess)
-}
-
 // Main is the entry point for the application.
 // It determines whether to run as a local server or a Lambda function.
 func Main() {
@@ -67,19 +47,32 @@ func Main() {
 func startLocalServer() {
 	log.Println("Starting local server...")
 
-	dbClient := NewDB()
-	userRepo := models.NewDynamoDBUserRepository(dbClient, os.Getenv("DYNAMODB_TABLE_NAME"))
+	userRepo := newUserRepository()
+	subscribeUserEvents()
+	configureIdempotencyStore()
 
-	healthHandler := handlers.NewHealthHandler()
+	healthHandler := newHealthHandler(userRepo)
 
-	r := http.NewServeMux()
+	r := newMethodMux()
 
 	r.HandleFunc("GET /health", adapt(healthHandler.GetHealthHandler))
+	r.HandleFunc("GET /health/live", adapt(healthHandler.GetLivenessHandler))
+	r.HandleFunc("GET /health/ready", adapt(healthHandler.GetReadinessHandler))
 	r.HandleFunc("POST /users", adapt(handlers.NewUserHandler(userRepo).CreateUserHandler))
+	r.HandleFunc("POST /users/batch", adapt(handlers.NewUserHandler(userRepo).BatchCreateUsersHandler))
 	r.HandleFunc("GET /users/{id}", adapt(handlers.NewUserHandler(userRepo).GetUserHandler))
 	r.HandleFunc("GET /users", adapt(handlers.NewUserHandler(userRepo).GetAllUsersHandler))
 	r.HandleFunc("PUT /users/{id}", adapt(handlers.NewUserHandler(userRepo).UpdateUserHandler))
+	r.HandleFunc("PATCH /users/{id}", adapt(handlers.NewUserHandler(userRepo).PatchUserHandler))
+	r.HandleFunc("DELETE /users", adapt(handlers.NewUserHandler(userRepo).DeleteAllUsersHandler))
 	r.HandleFunc("DELETE /users/{id}", adapt(handlers.NewUserHandler(userRepo).DeleteUserHandler))
+	r.HandleFunc("PUT /users/{id}/avatar", adapt(handlers.NewUserHandler(userRepo).UploadAvatarHandler))
+	r.HandleFunc("POST /admin/import", adapt(handlers.NewAdminHandler(userRepo).ImportUsersHandler))
+	r.HandleFunc("POST /admin/reset", adapt(handlers.NewAdminHandler(userRepo).ResetUsersHandler))
+	r.HandleFunc("GET /admin/export", adapt(handlers.NewAdminHandler(userRepo).ExportUsersHandler))
+	r.HandleFunc("GET /users/stats", adapt(handlers.NewAdminHandler(userRepo).UserStatsHandler))
+	r.HandleFunc("GET /users/events", serveUserEvents)
+	r.HandleFunc("GET /_routes", adapt(localLambda.ListRoutesHandler))
 
 	port := os.Getenv("PORT")
 	if port == "" {
@@ -88,8 +81,9 @@ func startLocalServer() {
 
 	server := &http.Server{
 		Addr:    ":" + port,
-		Handler: r,
+		Handler: logAccess(rejectDuringShutdown(r)),
 	}
+	server.RegisterOnShutdown(closeEventStreams)
 
 	go func() {
 		fmt.Printf("Local server listening on port %s\n", port)
@@ -104,6 +98,12 @@ func startLocalServer() {
 	<-stop
 
 	log.Println("Shutting down server...")
+	shuttingDown.Store(true)
+
+	// Keep answering 503 for a while so load balancers notice before the listener closes
+	if drain := utils.GetEnvInt("SHUTDOWN_DRAIN_MS", 0); drain > 0 {
+		time.Sleep(time.Duration(drain) * time.Millisecond)
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -113,65 +113,299 @@ func startLocalServer() {
 	log.Println("Server gracefully stopped.")
 }
 
+// newHealthHandler builds the health handler, adding a readiness check for the repository
+// when it supports one and a description of the repository backend.
+func newHealthHandler(userRepo models.UserRepository) *handlers.HealthHandler {
+	var checks []handlers.HealthCheck
+	if checker, ok := userRepo.(models.HealthChecker); ok {
+		checks = append(checks, handlers.HealthCheck{Name: "repository", Check: checker.HealthCheck})
+	}
+
+	healthHandler := handlers.NewHealthHandler(checks...)
+	backend := userRepositoryBackend()
+	healthHandler.Backend = &backend
+
+	return healthHandler
+}
+
+// subscribeUserEvents registers the process-wide user event subscribers.
+func subscribeUserEvents() {
+	if utils.GetEnvBool("AUDIT_LOG", false) {
+		handlers.SubscribeUserEvents("audit", handlers.AuditUserEvent)
+	}
+}
+
 func startLambda() {
 	log.Println("Starting Lambda function...")
 
-	dbClient := NewDB()
-	userRepo := models.NewDynamoDBUserRepository(dbClient, os.Getenv("DYNAMODB_TABLE_NAME"))
-	healthHandler := handlers.NewHealthHandler()
+	userRepo := newUserRepository()
+	subscribeUserEvents()
+	configureIdempotencyStore()
+	healthHandler := newHealthHandler(userRepo)
 
 	aws_lambda.Start(func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
 		return localLambda.Router(ctx, request, userRepo, healthHandler)
 	})
 }
 
+const defaultMaxUploadBytes = 1 << 20
+
+// methodMux wraps http.ServeMux and remembers the methods registered for each path, so a
+// request with an unregistered method on a known path gets a JSON 405 with an Allow header.
+type methodMux struct {
+	*http.ServeMux
+	methods   map[string][]string
+	paths     []string
+	fallbacks sync.Once
+}
+
+// fallbackMethods are the methods answered with a JSON 405 on known paths, apart from OPTIONS,
+// which gets the CORS pre-flight response. The 405 handlers are registered per method rather
+// than for the whole path, because a methodless "/users/events" would conflict with
+// "GET /users/{id}".
+var fallbackMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+func newMethodMux() *methodMux {
+	return &methodMux{ServeMux: http.NewServeMux(), methods: make(map[string][]string)}
+}
+
+// HandleFunc registers handler for a "METHOD /path" pattern.
+func (m *methodMux) HandleFunc(pattern string, handler http.HandlerFunc) {
+	method, path, _ := strings.Cut(pattern, " ")
+	if _, known := m.methods[path]; !known {
+		m.paths = append(m.paths, path)
+	}
+	m.methods[path] = append(m.methods[path], method)
+	m.ServeMux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		handler(w, r.WithContext(context.WithValue(r.Context(), routePatternKey{}, path)))
+	})
+}
+
+// ServeHTTP registers the 405 handlers on first use, once every route is known.
+func (m *methodMux) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.fallbacks.Do(func() {
+		for _, path := range m.paths {
+			for _, method := range fallbackMethods {
+				if !slices.Contains(m.methods[path], method) {
+					m.ServeMux.HandleFunc(method+" "+path, m.methodNotAllowed(path))
+				}
+			}
+		}
+	})
+
+	m.ServeMux.ServeHTTP(w, r)
+}
+
+type routePatternKey struct{}
+
+// routePattern returns the path pattern the request was routed by, e.g. "/users/{id}",
+// mirroring API Gateway's Resource field.
+func routePattern(r *http.Request) string {
+	if pattern, ok := r.Context().Value(routePatternKey{}).(string); ok {
+		return pattern
+	}
+
+	return r.URL.Path
+}
+
+func (m *methodMux) methodNotAllowed(path string) http.HandlerFunc {
+	// Pre-flight requests are answered by the CORS middleware, as on Lambda
+	preflight := adapt(func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return utils.ErrorResponse(http.StatusMethodNotAllowed, errors.New("method not allowed"))
+	})
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			preflight(w, r)
+			return
+		}
+
+		allowed := append([]string(nil), m.methods[path]...)
+		for _, method := range allowed {
+			if method == http.MethodGet {
+				allowed = append(allowed, http.MethodHead)
+				break
+			}
+		}
+
+		apiResp, _ := utils.ErrorResponse(http.StatusMethodNotAllowed, errors.New("method not allowed"))
+		apiResp.Headers["Allow"] = strings.Join(allowed, ", ")
+		writeAPIResponse(w, apiResp)
+	}
+}
+
 type apiGatewayHandler func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error)
 
 // adapt converts a standard http.HandlerFunc to an apiGatewayHandler signature
-// This allows reusing handler logic designed for Lambda with a local HTTP server.
+// This allows reusing handler logic designed for Lambda with a local HTTP server. The
+// handler runs behind the same middleware as on Lambda, see localLambda.WithMiddleware.
 func adapt(handler apiGatewayHandler) http.HandlerFunc {
+	serve := localLambda.WithMiddleware(withTimeout(handler))
+
 	return func(w http.ResponseWriter, r *http.Request) {
 		// Convert http.Request to APIGatewayProxyRequest
 		apiReq := events.APIGatewayProxyRequest{
+			Resource:              routePattern(r),
 			Path:                  r.URL.Path,
 			HTTPMethod:            r.Method,
 			Headers:               make(map[string]string),
 			QueryStringParameters: make(map[string]string),
 			PathParameters:        make(map[string]string),
 		}
+		apiReq.RequestContext.RequestID = utils.RequestID(apiReq)
+		apiReq.RequestContext.Protocol = r.Proto
+		if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+			apiReq.RequestContext.Identity.SourceIP = host
+		}
+		w.Header().Set(utils.RequestIDHeader, apiReq.RequestContext.RequestID)
 
+		// Header names are lowercased, as HTTP/2 clients send them; read them with utils.GetHeader
 		for name, values := range r.Header {
 			if len(values) > 0 {
-				apiReq.Headers[name] = values[0]
+				apiReq.Headers[strings.ToLower(name)] = values[0]
 			}
 		}
 
-		for name, values := range r.URL.Query() {
-			if len(values) > 0 {
-				apiReq.QueryStringParameters[name] = values[0]
+		apiReq.MultiValueHeaders = make(map[string][]string, len(r.Header))
+		for name, values := range r.Header {
+			apiReq.MultiValueHeaders[strings.ToLower(name)] = values
+		}
+
+		// Repeated parameters are resolved by the middleware, as on Lambda
+		apiReq.MultiValueQueryStringParameters = r.URL.Query()
+
+		// Reject before the body is sent, instead of sending 100 Continue
+		if strings.EqualFold(r.Header.Get("Expect"), "100-continue") {
+			if status, err := checkExpectContinue(r, apiReq); err != nil {
+				apiResp, _ := utils.ErrorResponse(status, err)
+				writeAPIResponse(w, apiResp)
+				return
 			}
 		}
 
+		// Binary bodies are base64-encoded, mirroring API Gateway binary media types
+		maxBytes := int64(utils.GetEnvInt("MAX_UPLOAD_BYTES", defaultMaxUploadBytes))
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBytes))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				apiResp, _ := utils.ErrorResponse(http.StatusRequestEntityTooLarge,
+					fmt.Errorf("request body exceeds %d bytes", maxBytes))
+				writeAPIResponse(w, apiResp)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if isBinaryMediaType(r.Header.Get("Content-Type")) {
+			apiReq.Body = base64.StdEncoding.EncodeToString(body)
+			apiReq.IsBase64Encoded = true
+		} else {
+			apiReq.Body = string(body)
+		}
+
 		// Extract path parameters (simple example, might need more robust parsing)
 		if r.PathValue("id") != "" {
 			apiReq.PathParameters["id"] = r.PathValue("id")
 		}
 
-		// Execute the Lambda handler
-		apiResp, err := handler(r.Context(), apiReq)
-		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+		// The middleware writes the access log line, so logAccess must not write another
+		if recorder, ok := w.(*accessLogWriter); ok {
+			recorder.logged = true
+		}
+
+		apiResp, _ := serve(r.Context(), apiReq)
+		writeAPIResponse(w, apiResp)
+	}
+}
+
+// checkExpectContinue runs the checks that can be made from headers alone, so a client
+// sending "Expect: 100-continue" gets an early 4xx without uploading the body. net/http
+// only sends the 100 Continue once the handler starts reading the body.
+func checkExpectContinue(r *http.Request, apiReq events.APIGatewayProxyRequest) (int, error) {
+	if maxBytes := int64(utils.GetEnvInt("MAX_UPLOAD_BYTES", defaultMaxUploadBytes)); r.ContentLength > maxBytes {
+		return http.StatusRequestEntityTooLarge, fmt.Errorf("request body exceeds %d bytes", maxBytes)
+	}
+
+	if strings.HasPrefix(r.URL.Path, "/admin/") && !handlers.IsAdmin(apiReq) {
+		return http.StatusForbidden, errors.New("admin access required")
+	}
+
+	return 0, nil
+}
+
+// withTimeout runs handler until the deadline the middleware put on its context, answering
+// 504 once it passes. A handler that has not returned by then is left to finish in the
+// background, so a hung repository call cannot hold the connection open. Panics are
+// recovered here too, since they happen on the handler's own goroutine.
+func withTimeout(handler apiGatewayHandler) localLambda.HandlerFunc {
+	return func(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		type result struct {
+			response events.APIGatewayProxyResponse
+			err      error
 		}
+		done := make(chan result, 1)
+
+		go func() {
+			defer func() {
+				if rec := recover(); rec != nil {
+					// Log the panic in full, but keep its message from clients
+					log.Printf("Panic processing request %s: %v\n%s", request.RequestContext.RequestID, rec, debug.Stack())
+					response, _ := utils.ErrorResponse(http.StatusInternalServerError, errors.New("internal server error"))
+					done <- result{response: response}
+				}
+			}()
+
+			response, err := handler(ctx, request)
+			done <- result{response: response, err: err}
+		}()
 
-		// Write response back to http.ResponseWriter
-		for key, value := range apiResp.Headers {
-			w.Header().Set(key, value)
+		var res result
+		select {
+		case res = <-done:
+		case <-ctx.Done():
+			res.err = ctx.Err()
 		}
-		w.WriteHeader(apiResp.StatusCode)
-		_, err = w.Write([]byte(apiResp.Body))
+		if errors.Is(res.err, context.DeadlineExceeded) {
+			return utils.ErrorResponse(http.StatusGatewayTimeout, errors.New("request timed out"))
+		}
+
+		return res.response, res.err
+	}
+}
+
+// writeAPIResponse writes an APIGatewayProxyResponse to an http.ResponseWriter. Bodies over
+// MAX_RESPONSE_BYTES are replaced with a 500, as on Lambda.
+func writeAPIResponse(w http.ResponseWriter, apiResp events.APIGatewayProxyResponse) {
+	if err := utils.CheckResponseSize(apiResp); err != nil {
+		apiResp = utils.ResponseTooLargeResponse(apiResp, err)
+	}
+
+	for key, value := range apiResp.Headers {
+		w.Header().Set(key, value)
+	}
+
+	respBody := []byte(apiResp.Body)
+	if apiResp.IsBase64Encoded {
+		var err error
+		respBody, err = base64.StdEncoding.DecodeString(apiResp.Body)
 		if err != nil {
-			log.Printf("Error writing response: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
 		}
 	}
+
+	w.WriteHeader(apiResp.StatusCode)
+	_, err := w.Write(respBody)
+	if err != nil {
+		log.Printf("Error writing response: %v", err)
+	}
+}
+
+// isBinaryMediaType reports whether API Gateway would treat the content type as binary,
+// matching the binaryMediaTypes configured in serverless.yml and template.yaml.
+func isBinaryMediaType(contentType string) bool {
+	return strings.HasPrefix(contentType, "image/") || strings.HasPrefix(contentType, "application/octet-stream")
 }
@@ -0,0 +1,276 @@
+package app
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	localLambda "go-lambda-api/cmd/lambda"
+	"go-lambda-api/handlers"
+	"go-lambda-api/utils"
+)
+
+func TestAdaptTimeout(t *testing.T) {
+	tests := []struct {
+		name       string
+		handler    apiGatewayHandler
+		wantStatus int
+	}{
+		{
+			name: "fast handler",
+			handler: func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				return utils.APIResponse(http.StatusOK, map[string]string{"status": "ok"})
+			},
+			wantStatus: http.StatusOK,
+		},
+		{
+			name: "handler ignoring the deadline",
+			handler: func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				time.Sleep(200 * time.Millisecond)
+				return utils.APIResponse(http.StatusOK, nil)
+			},
+			wantStatus: http.StatusGatewayTimeout,
+		},
+		{
+			name: "handler returning the deadline error",
+			handler: func(ctx context.Context, _ events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				<-ctx.Done()
+				return events.APIGatewayProxyResponse{}, ctx.Err()
+			},
+			wantStatus: http.StatusGatewayTimeout,
+		},
+		{
+			name: "panicking handler",
+			handler: func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+				panic("boom")
+			},
+			wantStatus: http.StatusInternalServerError,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("REQUEST_TIMEOUT_MS", "20")
+
+			recorder := httptest.NewRecorder()
+			adapt(tt.handler)(recorder, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+		})
+	}
+}
+
+func TestAdaptRunsLambdaMiddleware(t *testing.T) {
+	ok := func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return utils.APIResponse(http.StatusOK, map[string]string{"status": "ok"})
+	}
+	mux := newMethodMux()
+	mux.HandleFunc("GET /users", adapt(ok))
+
+	tests := []struct {
+		name        string
+		method      string
+		wantStatus  int
+		wantHeaders []string
+	}{
+		{name: "route", method: http.MethodGet, wantStatus: http.StatusOK,
+			wantHeaders: []string{
+				"Access-Control-Allow-Origin", localLambda.ColdStartHeader, utils.APIVersionHeader, utils.RequestIDHeader,
+			}},
+		{name: "pre-flight", method: http.MethodOptions, wantStatus: http.StatusOK,
+			wantHeaders: []string{"Access-Control-Allow-Origin", "Access-Control-Allow-Methods"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, httptest.NewRequest(tt.method, "/users", nil))
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			for _, name := range tt.wantHeaders {
+				if recorder.Header().Get(name) == "" {
+					t.Errorf("missing %s header in %v", name, recorder.Header())
+				}
+			}
+		})
+	}
+}
+
+func TestMethodMuxMethodNotAllowed(t *testing.T) {
+	ok := func(w http.ResponseWriter, _ *http.Request) { w.WriteHeader(http.StatusOK) }
+	mux := newMethodMux()
+	mux.HandleFunc("GET /users", ok)
+	mux.HandleFunc("POST /users", ok)
+	mux.HandleFunc("GET /users/{id}", ok)
+	mux.HandleFunc("POST /admin/reset", ok)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		wantStatus int
+		wantAllow  string
+	}{
+		{name: "registered method", method: http.MethodGet, path: "/users", wantStatus: http.StatusOK},
+		{name: "HEAD of a GET route", method: http.MethodHead, path: "/users", wantStatus: http.StatusOK},
+		{name: "unregistered method", method: http.MethodDelete, path: "/users",
+			wantStatus: http.StatusMethodNotAllowed, wantAllow: "GET, POST, HEAD"},
+		{name: "unregistered method on a path with parameters", method: http.MethodPut, path: "/users/1",
+			wantStatus: http.StatusMethodNotAllowed, wantAllow: "GET, HEAD"},
+		{name: "route without GET", method: http.MethodGet, path: "/admin/reset",
+			wantStatus: http.StatusMethodNotAllowed, wantAllow: "POST"},
+		{name: "unknown path", method: http.MethodGet, path: "/unknown", wantStatus: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := httptest.NewRecorder()
+			mux.ServeHTTP(recorder, httptest.NewRequest(tt.method, tt.path, nil))
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", recorder.Code, tt.wantStatus)
+			}
+			if got := recorder.Header().Get("Allow"); got != tt.wantAllow {
+				t.Errorf("Allow = %q, want %q", got, tt.wantAllow)
+			}
+		})
+	}
+}
+
+// trackingReader is a request body that records whether the client sent it.
+type trackingReader struct {
+	body *strings.Reader
+	read atomic.Bool
+}
+
+func (r *trackingReader) Read(p []byte) (int, error) {
+	r.read.Store(true)
+	return r.body.Read(p)
+}
+
+func TestAdaptExpectContinue(t *testing.T) {
+	ok := func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return utils.APIResponse(http.StatusOK, map[string]string{"status": "ok"})
+	}
+	mux := newMethodMux()
+	mux.HandleFunc("POST /upload", adapt(ok))
+	mux.HandleFunc("POST /admin/import", adapt(ok))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client := &http.Client{Transport: &http.Transport{ExpectContinueTimeout: 5 * time.Second}}
+
+	tests := []struct {
+		name         string
+		path         string
+		size         int
+		wantStatus   int
+		wantBodySent bool
+	}{
+		{name: "accepted upload", path: "/upload", size: 16, wantStatus: http.StatusOK, wantBodySent: true},
+		{name: "upload over the limit", path: "/upload", size: 65, wantStatus: http.StatusRequestEntityTooLarge},
+		{name: "admin path without a token", path: "/admin/import", size: 16, wantStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MAX_UPLOAD_BYTES", "64")
+			t.Setenv("ADMIN_TOKEN", "secret")
+
+			body := &trackingReader{body: strings.NewReader(strings.Repeat("x", tt.size))}
+			request, err := http.NewRequest(http.MethodPost, server.URL+tt.path, body)
+			if err != nil {
+				t.Fatalf("creating request: %v", err)
+			}
+			request.ContentLength = int64(tt.size)
+			request.Header.Set("Expect", "100-continue")
+			request.Header.Set("Content-Type", "application/octet-stream")
+
+			response, err := client.Do(request)
+			if err != nil {
+				t.Fatalf("sending request: %v", err)
+			}
+			response.Body.Close()
+
+			if response.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", response.StatusCode, tt.wantStatus)
+			}
+			if sent := body.read.Load(); sent != tt.wantBodySent {
+				t.Errorf("body sent = %v, want %v", sent, tt.wantBodySent)
+			}
+		})
+	}
+}
+
+func TestAdaptBodyLimit(t *testing.T) {
+	ok := func(context.Context, events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		return utils.APIResponse(http.StatusOK, map[string]string{"status": "ok"})
+	}
+
+	tests := []struct {
+		name       string
+		size       int
+		wantStatus int
+	}{
+		{name: "within the limit", size: 64, wantStatus: http.StatusOK},
+		{name: "over the limit", size: 65, wantStatus: http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MAX_UPLOAD_BYTES", "64")
+
+			// No Expect header and an unknown length, so only the read can enforce the limit
+			request := httptest.NewRequest(http.MethodPost, "/upload", strings.NewReader(strings.Repeat("x", tt.size)))
+			request.ContentLength = -1
+			request.Header.Set("Content-Type", "application/octet-stream")
+			recorder := httptest.NewRecorder()
+			adapt(ok)(recorder, request)
+
+			if recorder.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d (body %s)", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+		})
+	}
+}
+
+func TestAdaptLowercasesHeaders(t *testing.T) {
+	// A fresh store, so the Idempotency-Key is never replayed from an earlier run
+	handlers.SetIdempotencyStore(handlers.NewMemoryIdempotencyStore())
+
+	var headers map[string]string
+	var contentType, idempotencyKey string
+	handler := func(_ context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, error) {
+		headers = request.Headers
+		contentType = utils.GetHeader(request, "Content-Type")
+		idempotencyKey = utils.GetHeader(request, "Idempotency-Key")
+		return utils.APIResponse(http.StatusOK, nil)
+	}
+
+	request := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(`{}`))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("IDEMPOTENCY-KEY", "k1")
+	adapt(handler)(httptest.NewRecorder(), request)
+
+	for name := range headers {
+		if name != strings.ToLower(name) {
+			t.Errorf("header %q is not lowercased", name)
+		}
+	}
+	if contentType != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", contentType)
+	}
+	if idempotencyKey != "k1" {
+		t.Errorf("Idempotency-Key = %q, want k1", idempotencyKey)
+	}
+}
@@ -0,0 +1,47 @@
+package app
+
+import (
+	"os"
+	"time"
+
+	"go-lambda-api/handlers"
+	"go-lambda-api/models"
+	"go-lambda-api/utils"
+)
+
+// RepoBackendPostgres selects the PostgreSQL repository with REPO_BACKEND.
+const RepoBackendPostgres = "postgres"
+
+// newUserRepository returns the repository selected by REPO_BACKEND: PostgreSQL for
+// "postgres", and otherwise DynamoDB or, without the dynamodb build tag, the in-memory one.
+// nolint: ireturn
+func newUserRepository() models.UserRepository {
+	if os.Getenv("REPO_BACKEND") == RepoBackendPostgres {
+		return withCircuitBreaker(newPostgresUserRepository())
+	}
+
+	return newDefaultUserRepository()
+}
+
+// userRepositoryBackend describes the repository newUserRepository returns, for the
+// readiness endpoint.
+func userRepositoryBackend() handlers.BackendInfo {
+	if os.Getenv("REPO_BACKEND") == RepoBackendPostgres {
+		return handlers.BackendInfo{Backend: RepoBackendPostgres, Table: "users"}
+	}
+
+	return defaultRepositoryBackend()
+}
+
+// withCircuitBreaker wraps repo in a circuit breaker configured by CIRCUIT_BREAKER_THRESHOLD
+// and CIRCUIT_BREAKER_COOLDOWN_MS.
+// nolint: ireturn
+func withCircuitBreaker(repo models.UserRepository) models.UserRepository {
+	cooldown := utils.GetEnvInt("CIRCUIT_BREAKER_COOLDOWN_MS", int(models.DefaultCircuitBreakerCooldown/time.Millisecond))
+
+	return models.NewCircuitBreakerRepository(
+		repo,
+		utils.GetEnvInt("CIRCUIT_BREAKER_THRESHOLD", models.DefaultCircuitBreakerThreshold),
+		time.Duration(cooldown)*time.Millisecond,
+	)
+}
@@ -0,0 +1,88 @@
+//go:build dynamodb
+
+package app
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"go-lambda-api/handlers"
+	"go-lambda-api/models"
+	"go-lambda-api/utils"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/joho/godotenv"
+)
+
+const schemaValidationTimeout = 5 * time.Second
+
+// defaultDynamoDBTimeoutMS bounds each DynamoDB HTTP request, including retries by the SDK,
+// when DYNAMODB_TIMEOUT_MS is unset.
+const defaultDynamoDBTimeoutMS = 3000
+
+// awsRegion returns the AWS_REGION environment variable, defaulting to us-east-1.
+func awsRegion() string {
+	if region := os.Getenv("AWS_REGION"); region != "" {
+		return region
+	}
+
+	return "us-east-1"
+}
+
+// NewDB returns a new DynamoDB client
+func NewDB() dynamodbiface.DynamoDBAPI {
+	// Load environment variables from .env file
+	err := godotenv.Load()
+	if err != nil {
+		log.Printf("Could not load .env file, assuming production environment: %v", err)
+	}
+
+	timeout := time.Duration(utils.GetEnvInt("DYNAMODB_TIMEOUT_MS", defaultDynamoDBTimeoutMS)) * time.Millisecond
+	sess, err := session.NewSession(&aws.Config{
+		Region:     aws.String(awsRegion()),
+		HTTPClient: &http.Client{Timeout: timeout},
+	})
+
+	if err != nil {
+		log.Fatalf("Error creating AWS session: %v", err)
+	}
+
+	return dynamodb.New(sess)
+}
+
+// newDefaultUserRepository returns the DynamoDB-backed repository behind a circuit breaker,
+// exiting if the table's key schema does not match the repository.
+// nolint: ireturn
+func newDefaultUserRepository() models.UserRepository {
+	repo := models.NewDynamoDBUserRepository(NewDB(), os.Getenv("DYNAMODB_TABLE_NAME"))
+
+	if validator, ok := repo.(models.SchemaValidator); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), schemaValidationTimeout)
+		defer cancel()
+
+		if err := validator.ValidateSchema(ctx); err != nil {
+			log.Fatalf("Invalid DynamoDB table schema: %v", err)
+		}
+	}
+
+	return withCircuitBreaker(repo)
+}
+
+// defaultRepositoryBackend describes the repository newDefaultUserRepository returns.
+func defaultRepositoryBackend() handlers.BackendInfo {
+	return handlers.BackendInfo{Backend: "dynamodb", Table: os.Getenv("DYNAMODB_TABLE_NAME"), Region: awsRegion()}
+}
+
+// configureIdempotencyStore keeps Idempotency-Key records in the DynamoDB table
+// IDEMPOTENCY_TABLE_NAME when it is set, so every Lambda container shares them.
+func configureIdempotencyStore() {
+	if table := os.Getenv("IDEMPOTENCY_TABLE_NAME"); table != "" {
+		handlers.SetIdempotencyStore(handlers.NewDynamoDBIdempotencyStore(NewDB(), table))
+	}
+}
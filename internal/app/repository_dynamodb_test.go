@@ -0,0 +1,49 @@
+//go:build dynamodb
+
+package app
+
+import (
+	"testing"
+
+	"go-lambda-api/handlers"
+)
+
+func TestUserRepositoryBackend(t *testing.T) {
+	tests := []struct {
+		name        string
+		repoBackend string
+		table       string
+		region      string
+		want        handlers.BackendInfo
+	}{
+		{
+			name:   "dynamodb",
+			table:  "users-prod",
+			region: "eu-west-1",
+			want:   handlers.BackendInfo{Backend: "dynamodb", Table: "users-prod", Region: "eu-west-1"},
+		},
+		{
+			name:  "dynamodb in the default region",
+			table: "users-prod",
+			want:  handlers.BackendInfo{Backend: "dynamodb", Table: "users-prod", Region: "us-east-1"},
+		},
+		{
+			name:        "postgres",
+			repoBackend: RepoBackendPostgres,
+			table:       "users-prod",
+			want:        handlers.BackendInfo{Backend: "postgres", Table: "users"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("REPO_BACKEND", tt.repoBackend)
+			t.Setenv("DYNAMODB_TABLE_NAME", tt.table)
+			t.Setenv("AWS_REGION", tt.region)
+
+			if got := userRepositoryBackend(); got != tt.want {
+				t.Errorf("userRepositoryBackend() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
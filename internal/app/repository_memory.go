@@ -0,0 +1,33 @@
+//go:build !dynamodb
+
+package app
+
+import (
+	"log"
+	"os"
+
+	"go-lambda-api/handlers"
+	"go-lambda-api/models"
+)
+
+// newDefaultUserRepository returns the in-memory repository. Build with -tags dynamodb
+// to compile in the DynamoDB backend.
+// nolint: ireturn
+func newDefaultUserRepository() models.UserRepository {
+	log.Println("DynamoDB support not compiled in, using in-memory user repository")
+
+	return models.NewInMemoryUserRepository()
+}
+
+// defaultRepositoryBackend describes the repository newDefaultUserRepository returns.
+func defaultRepositoryBackend() handlers.BackendInfo {
+	return handlers.BackendInfo{Backend: "memory"}
+}
+
+// configureIdempotencyStore keeps the in-memory idempotency store, which is the only one
+// compiled in without the dynamodb build tag.
+func configureIdempotencyStore() {
+	if os.Getenv("IDEMPOTENCY_TABLE_NAME") != "" {
+		log.Println("DynamoDB support not compiled in, ignoring IDEMPOTENCY_TABLE_NAME")
+	}
+}
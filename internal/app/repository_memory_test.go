@@ -0,0 +1,30 @@
+//go:build !dynamodb
+
+package app
+
+import (
+	"testing"
+
+	"go-lambda-api/handlers"
+)
+
+func TestUserRepositoryBackend(t *testing.T) {
+	tests := []struct {
+		name        string
+		repoBackend string
+		want        handlers.BackendInfo
+	}{
+		{name: "default", want: handlers.BackendInfo{Backend: "memory"}},
+		{name: "postgres", repoBackend: RepoBackendPostgres, want: handlers.BackendInfo{Backend: "postgres", Table: "users"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("REPO_BACKEND", tt.repoBackend)
+
+			if got := userRepositoryBackend(); got != tt.want {
+				t.Errorf("userRepositoryBackend() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+//go:build postgres
+
+package app
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"os"
+	"time"
+
+	"go-lambda-api/models"
+
+	_ "github.com/lib/pq" // registers the "postgres" database/sql driver
+)
+
+const postgresConnectTimeout = 5 * time.Second
+
+// newPostgresUserRepository connects to DATABASE_URL and returns the PostgreSQL-backed
+// repository, exiting if the database cannot be reached.
+// nolint: ireturn
+func newPostgresUserRepository() models.UserRepository {
+	db, err := sql.Open("postgres", os.Getenv("DATABASE_URL"))
+	if err != nil {
+		log.Fatalf("Error opening PostgreSQL connection: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), postgresConnectTimeout)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		log.Fatalf("Error connecting to PostgreSQL: %v", err)
+	}
+
+	return models.NewPostgresUserRepository(db)
+}
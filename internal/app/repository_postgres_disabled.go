@@ -0,0 +1,18 @@
+//go:build !postgres
+
+package app
+
+import (
+	"log"
+
+	"go-lambda-api/models"
+)
+
+// newPostgresUserRepository exits, since PostgreSQL support is only compiled in with
+// -tags postgres.
+// nolint: ireturn
+func newPostgresUserRepository() models.UserRepository {
+	log.Fatal("REPO_BACKEND=postgres requires building with -tags postgres")
+
+	return nil
+}
@@ -0,0 +1,27 @@
+package app
+
+import (
+	"errors"
+	"net/http"
+	"sync/atomic"
+
+	"go-lambda-api/utils"
+)
+
+// shuttingDown is set when the local server starts a graceful shutdown.
+var shuttingDown atomic.Bool
+
+// rejectDuringShutdown answers requests arriving after shutdown has begun with a 503 and
+// "Connection: close", while requests already in flight run to completion.
+func rejectDuringShutdown(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if shuttingDown.Load() {
+			apiResp, _ := utils.ErrorResponse(http.StatusServiceUnavailable, errors.New("server shutting down"))
+			apiResp.Headers["Connection"] = "close"
+			writeAPIResponse(w, apiResp)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,65 @@
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRejectDuringShutdown(t *testing.T) {
+	t.Cleanup(func() { shuttingDown.Store(false) })
+
+	tests := []struct {
+		name           string
+		shuttingDown   bool
+		wantStatus     int
+		wantConnection string
+		wantError      string
+		wantServed     bool
+	}{
+		{name: "serving", wantStatus: http.StatusOK, wantServed: true},
+		{
+			name:           "shutting down",
+			shuttingDown:   true,
+			wantStatus:     http.StatusServiceUnavailable,
+			wantConnection: "close",
+			wantError:      "server shutting down",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shuttingDown.Store(tt.shuttingDown)
+
+			served := false
+			handler := rejectDuringShutdown(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				served = true
+				w.WriteHeader(http.StatusOK)
+			}))
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/users", nil))
+
+			if recorder.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d (body %s)", recorder.Code, tt.wantStatus, recorder.Body)
+			}
+			if served != tt.wantServed {
+				t.Errorf("handler served = %t, want %t", served, tt.wantServed)
+			}
+			if got := recorder.Header().Get("Connection"); got != tt.wantConnection {
+				t.Errorf("Connection = %q, want %q", got, tt.wantConnection)
+			}
+			if tt.wantError == "" {
+				return
+			}
+
+			var body map[string]interface{}
+			if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+				t.Fatalf("decoding body %q: %v", recorder.Body, err)
+			}
+			if body["error"] != tt.wantError {
+				t.Errorf("error = %v, want %q", body["error"], tt.wantError)
+			}
+		})
+	}
+}
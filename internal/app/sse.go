@@ -0,0 +1,97 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"go-lambda-api/handlers"
+	"go-lambda-api/utils"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// sseKeepAlive is how often a comment is sent on an idle event stream, so proxies and
+// clients do not time the connection out.
+const sseKeepAlive = 15 * time.Second
+
+var (
+	eventStreamsDone = make(chan struct{})
+	closeStreamsOnce sync.Once
+)
+
+// closeEventStreams ends every open event stream, so graceful shutdown does not wait on them.
+func closeEventStreams() {
+	closeStreamsOnce.Do(func() { close(eventStreamsDone) })
+}
+
+// serveUserEvents streams user create, update and delete events to an admin client as
+// server-sent events, one JSON event per "data:" line, until the client disconnects.
+// Streaming is not possible through API Gateway, so this route exists on the local server only.
+func serveUserEvents(w http.ResponseWriter, r *http.Request) {
+	adminReq := events.APIGatewayProxyRequest{
+		Headers: map[string]string{handlers.AdminTokenHeader: r.Header.Get(handlers.AdminTokenHeader)},
+	}
+	if !handlers.IsAdmin(adminReq) {
+		apiResp, _ := utils.ErrorResponse(http.StatusForbidden, errors.New("admin access required"))
+		writeAPIResponse(w, apiResp)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		apiResp, _ := utils.ErrorResponse(http.StatusInternalServerError, errors.New("streaming unsupported"))
+		writeAPIResponse(w, apiResp)
+		return
+	}
+
+	// The bus buffers events for this stream; forward them to the loop below until it exits
+	userEvents := make(chan handlers.UserEvent)
+	streamDone := make(chan struct{})
+	defer close(streamDone)
+	unsubscribe := handlers.SubscribeUserEvents("sse", func(event handlers.UserEvent) error {
+		select {
+		case userEvents <- event:
+		case <-streamDone:
+		}
+
+		return nil
+	})
+	defer unsubscribe()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-eventStreamsDone:
+			return
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(w, ": keep-alive\n\n"); err != nil {
+				return
+			}
+		case event := <-userEvents:
+			data, err := json.Marshal(event)
+			if err != nil {
+				log.Printf("Error encoding user event: %v", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, data); err != nil {
+				return
+			}
+		}
+		flusher.Flush()
+	}
+}
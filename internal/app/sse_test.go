@@ -0,0 +1,135 @@
+package app
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+
+	"go-lambda-api/handlers"
+	"go-lambda-api/models"
+)
+
+func TestServeUserEvents(t *testing.T) {
+	const token = "secret"
+	t.Setenv("ADMIN_TOKEN", token)
+	models.ClearInMemoryUsers()
+	t.Cleanup(models.ClearInMemoryUsers)
+
+	server := httptest.NewServer(http.HandlerFunc(serveUserEvents))
+	t.Cleanup(server.Close)
+	users := handlers.NewUserHandler(models.NewInMemoryUserRepository())
+
+	tests := []struct {
+		name     string
+		mutate   func(t *testing.T) string
+		wantType string
+	}{
+		{
+			name: "create",
+			mutate: func(t *testing.T) string {
+				t.Helper()
+				response, err := users.CreateUserHandler(context.Background(), events.APIGatewayProxyRequest{
+					HTTPMethod: http.MethodPost,
+					Headers:    map[string]string{"Content-Type": "application/json"},
+					Body:       `{"id":"user-1","name":"Ann","email":"ann@example.com"}`,
+				})
+				if err != nil || response.StatusCode != http.StatusCreated {
+					t.Fatalf("create = %d, %v; want 201", response.StatusCode, err)
+				}
+				return "user-1"
+			},
+			wantType: handlers.UserCreated,
+		},
+		{
+			name: "delete",
+			mutate: func(t *testing.T) string {
+				t.Helper()
+				response, err := users.DeleteUserHandler(context.Background(), events.APIGatewayProxyRequest{
+					HTTPMethod:     http.MethodDelete,
+					PathParameters: map[string]string{"id": "user-1"},
+				})
+				if err != nil || response.StatusCode != http.StatusNoContent {
+					t.Fatalf("delete = %d, %v; want 204", response.StatusCode, err)
+				}
+				return "user-1"
+			},
+			wantType: handlers.UserDeleted,
+		},
+	}
+
+	// The mutations run in order, as the delete removes the created user
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			request, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL, nil)
+			if err != nil {
+				t.Fatalf("creating request: %v", err)
+			}
+			request.Header.Set(handlers.AdminTokenHeader, token)
+			response, err := http.DefaultClient.Do(request)
+			if err != nil {
+				t.Fatalf("connecting: %v", err)
+			}
+			defer response.Body.Close()
+			if got := response.Header.Get("Content-Type"); got != "text/event-stream" {
+				t.Fatalf("Content-Type = %q, want text/event-stream", got)
+			}
+
+			// The stream is subscribed once its headers are sent
+			id := tt.mutate(t)
+
+			scanner := bufio.NewScanner(response.Body)
+			for scanner.Scan() {
+				data, ok := strings.CutPrefix(scanner.Text(), "data: ")
+				if !ok {
+					continue
+				}
+				var event handlers.UserEvent
+				if err := json.Unmarshal([]byte(data), &event); err != nil {
+					t.Fatalf("decoding event %q: %v", data, err)
+				}
+				if event.Type != tt.wantType || event.User.ID != id {
+					t.Errorf("event = %s for %q, want %s for %q", event.Type, event.User.ID, tt.wantType, id)
+				}
+				return
+			}
+			t.Fatalf("stream ended without an event: %v", scanner.Err())
+		})
+	}
+}
+
+func TestServeUserEventsForbidden(t *testing.T) {
+	t.Setenv("ADMIN_TOKEN", "secret")
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{name: "no token"},
+		{name: "wrong token", token: "wrong"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, "/users/events", nil)
+			if tt.token != "" {
+				request.Header.Set(handlers.AdminTokenHeader, tt.token)
+			}
+			recorder := httptest.NewRecorder()
+			serveUserEvents(recorder, request)
+
+			if recorder.Code != http.StatusForbidden {
+				t.Errorf("status = %d, want %d", recorder.Code, http.StatusForbidden)
+			}
+		})
+	}
+}
@@ -0,0 +1,291 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Circuit breaker defaults, used when CIRCUIT_BREAKER_THRESHOLD and
+// CIRCUIT_BREAKER_COOLDOWN_MS are unset.
+const (
+	DefaultCircuitBreakerThreshold = 5
+	DefaultCircuitBreakerCooldown  = 30 * time.Second
+)
+
+// ErrCircuitOpen is returned without calling the backing store while the circuit breaker is open.
+var ErrCircuitOpen = errors.New("user store unavailable, circuit breaker open")
+
+// Circuit breaker states.
+const (
+	CircuitClosed   = "closed"
+	CircuitOpen     = "open"
+	CircuitHalfOpen = "half-open"
+)
+
+// circuitBreakerRepository wraps a repository so that, after threshold consecutive store
+// failures, calls fail fast with ErrCircuitOpen for cooldown. The first call after the
+// cooldown is let through as a probe: its success closes the circuit and its failure opens
+// it again. Errors describing the request, such as a missing user or a duplicate email, are
+// not failures.
+type circuitBreakerRepository struct {
+	repo      UserRepository
+	threshold int
+	cooldown  time.Duration
+	now       func() time.Time
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+// NewCircuitBreakerRepository wraps repo in a circuit breaker that opens after threshold
+// consecutive failures and half-opens after cooldown. A threshold of zero or less returns
+// repo unchanged.
+// nolint: ireturn
+func NewCircuitBreakerRepository(repo UserRepository, threshold int, cooldown time.Duration) UserRepository {
+	if threshold <= 0 {
+		return repo
+	}
+
+	return &circuitBreakerRepository{
+		repo:      repo,
+		threshold: threshold,
+		cooldown:  cooldown,
+		now:       time.Now,
+		state:     CircuitClosed,
+	}
+}
+
+// State returns the breaker's current state.
+func (b *circuitBreakerRepository) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitOpen && b.now().Sub(b.openedAt) >= b.cooldown {
+		return CircuitHalfOpen
+	}
+
+	return b.state
+}
+
+// allow reports whether a call may reach the store, moving an open circuit whose cooldown
+// has passed to half-open and admitting a single probe.
+func (b *circuitBreakerRepository) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == CircuitOpen {
+		if b.now().Sub(b.openedAt) < b.cooldown {
+			return false
+		}
+		b.state = CircuitHalfOpen
+	}
+	if b.state == CircuitHalfOpen {
+		if b.probing {
+			return false
+		}
+		b.probing = true
+	}
+
+	return true
+}
+
+// record updates the breaker with the outcome of a call admitted by allow.
+func (b *circuitBreakerRepository) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.probing = false
+	if !isStoreFailure(err) {
+		b.state = CircuitClosed
+		b.failures = 0
+
+		return
+	}
+
+	b.failures++
+	if b.state == CircuitHalfOpen || b.failures >= b.threshold {
+		b.state = CircuitOpen
+		b.openedAt = b.now()
+	}
+}
+
+// isStoreFailure reports whether err means the store itself failed, as opposed to the
+// request being rejected or abandoned by the caller.
+func isStoreFailure(err error) bool {
+	if err == nil || errors.Is(err, ErrUserNotFound) || errors.Is(err, context.Canceled) {
+		return false
+	}
+
+	var validationErr *ValidationError
+
+	return !errors.Is(err, ErrDuplicateEmail) && !errors.Is(err, ErrDuplicateID) &&
+		!errors.Is(err, ErrBatchTooLarge) && !errors.Is(err, ErrEmptyBatch) && !errors.Is(err, ErrVersionConflict) &&
+		!errors.Is(err, ErrInvalidCursor) && !errors.As(err, &validationErr)
+}
+
+func (b *circuitBreakerRepository) CreateUser(ctx context.Context, user User) (User, error) {
+	if !b.allow() {
+		return User{}, ErrCircuitOpen
+	}
+	created, err := b.repo.CreateUser(ctx, user)
+	b.record(err)
+
+	return created, err
+}
+
+// CreateUsers forwards to the wrapped repository, returning ErrBatchCreateUnsupported when
+// it cannot create users atomically.
+func (b *circuitBreakerRepository) CreateUsers(ctx context.Context, users []User) ([]User, error) {
+	creator, ok := b.repo.(BatchCreator)
+	if !ok {
+		return nil, ErrBatchCreateUnsupported
+	}
+	if !b.allow() {
+		return nil, ErrCircuitOpen
+	}
+	created, err := creator.CreateUsers(ctx, users)
+	b.record(err)
+
+	return created, err
+}
+
+// BulkCreateUsers forwards to the wrapped repository. The batch counts as a single call, which
+// failed if any user failed because of the store.
+func (b *circuitBreakerRepository) BulkCreateUsers(ctx context.Context, users []User) []BulkCreateResult {
+	if !b.allow() {
+		results := make([]BulkCreateResult, len(users))
+		for i := range results {
+			results[i].Err = ErrCircuitOpen
+		}
+
+		return results
+	}
+
+	results := BulkCreateUsers(ctx, b.repo, users)
+	var failure error
+	for _, result := range results {
+		if isStoreFailure(result.Err) {
+			failure = result.Err
+			break
+		}
+	}
+	b.record(failure)
+
+	return results
+}
+
+func (b *circuitBreakerRepository) GetUserByID(ctx context.Context, id string) (User, error) {
+	if !b.allow() {
+		return User{}, ErrCircuitOpen
+	}
+	user, err := b.repo.GetUserByID(ctx, id)
+	b.record(err)
+
+	return user, err
+}
+
+func (b *circuitBreakerRepository) GetUserByIDProjected(
+	ctx context.Context, id string, projection Projection,
+) (User, error) {
+	if !b.allow() {
+		return User{}, ErrCircuitOpen
+	}
+
+	var user User
+	var err error
+	if reader, ok := b.repo.(ProjectedReader); ok {
+		user, err = reader.GetUserByIDProjected(ctx, id, projection)
+	} else {
+		user, err = b.repo.GetUserByID(ctx, id)
+		user = projection.Apply(user)
+	}
+	b.record(err)
+
+	return user, err
+}
+
+func (b *circuitBreakerRepository) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	if !b.allow() {
+		return User{}, ErrCircuitOpen
+	}
+	user, err := b.repo.GetUserByEmail(ctx, email)
+	b.record(err)
+
+	return user, err
+}
+
+func (b *circuitBreakerRepository) GetAllUsers(ctx context.Context, query ListQuery) ([]User, string, error) {
+	if !b.allow() {
+		return nil, "", ErrCircuitOpen
+	}
+	users, next, err := b.repo.GetAllUsers(ctx, query)
+	b.record(err)
+
+	return users, next, err
+}
+
+func (b *circuitBreakerRepository) UpdateUser(ctx context.Context, user User) (User, error) {
+	if !b.allow() {
+		return User{}, ErrCircuitOpen
+	}
+	updated, err := b.repo.UpdateUser(ctx, user)
+	b.record(err)
+
+	return updated, err
+}
+
+// UpdateUserFunc forwards to the wrapped repository, returning ErrAtomicUpdateUnsupported
+// when it cannot update users atomically.
+func (b *circuitBreakerRepository) UpdateUserFunc(
+	ctx context.Context, id string, update func(User) User,
+) (User, error) {
+	updater, ok := b.repo.(AtomicUpdater)
+	if !ok {
+		return User{}, ErrAtomicUpdateUnsupported
+	}
+	if !b.allow() {
+		return User{}, ErrCircuitOpen
+	}
+	updated, err := updater.UpdateUserFunc(ctx, id, update)
+	b.record(err)
+
+	return updated, err
+}
+
+func (b *circuitBreakerRepository) DeleteUser(ctx context.Context, id string) error {
+	if !b.allow() {
+		return ErrCircuitOpen
+	}
+	err := b.repo.DeleteUser(ctx, id)
+	b.record(err)
+
+	return err
+}
+
+func (b *circuitBreakerRepository) DeleteAllUsers(ctx context.Context) (int, error) {
+	if !b.allow() {
+		return 0, ErrCircuitOpen
+	}
+	deleted, err := b.repo.DeleteAllUsers(ctx)
+	b.record(err)
+
+	return deleted, err
+}
+
+// HealthCheck reports ErrCircuitOpen while the circuit is open, and otherwise checks the
+// wrapped repository if it can be checked. Health checks do not affect the breaker.
+func (b *circuitBreakerRepository) HealthCheck(ctx context.Context) error {
+	if b.State() == CircuitOpen {
+		return ErrCircuitOpen
+	}
+	if checker, ok := b.repo.(HealthChecker); ok {
+		return checker.HealthCheck(ctx)
+	}
+
+	return nil
+}
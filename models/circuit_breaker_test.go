@@ -0,0 +1,114 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// flakyRepository fails GetUserByID with err, counting the calls that reach it.
+type flakyRepository struct {
+	UserRepository
+
+	err   error
+	calls int
+}
+
+func (r *flakyRepository) GetUserByID(context.Context, string) (User, error) {
+	r.calls++
+	if r.err != nil {
+		return User{}, r.err
+	}
+
+	return User{ID: "user-1"}, nil
+}
+
+func TestCircuitBreakerStates(t *testing.T) {
+	errStore := errors.New("service unavailable")
+	cooldown := 10 * time.Second
+
+	// The steps run in order against one breaker with a threshold of 2
+	tests := []struct {
+		name      string
+		advance   time.Duration
+		storeErr  error
+		wantErr   error
+		wantCalls int
+		wantState string
+	}{
+		{name: "success while closed", wantCalls: 1, wantState: CircuitClosed},
+		{name: "first failure", storeErr: errStore, wantErr: errStore, wantCalls: 2, wantState: CircuitClosed},
+		{name: "request errors are not failures", storeErr: ErrUserNotFound, wantErr: ErrUserNotFound, wantCalls: 3,
+			wantState: CircuitClosed},
+		{name: "failure after a request error", storeErr: errStore, wantErr: errStore, wantCalls: 4,
+			wantState: CircuitClosed},
+		{name: "threshold reached", storeErr: errStore, wantErr: errStore, wantCalls: 5, wantState: CircuitOpen},
+		{name: "fails fast while open", wantErr: ErrCircuitOpen, wantCalls: 5, wantState: CircuitOpen},
+		{name: "still open before the cooldown", advance: cooldown - time.Second, wantErr: ErrCircuitOpen,
+			wantCalls: 5, wantState: CircuitOpen},
+		{name: "failed probe reopens", advance: time.Second, storeErr: errStore, wantErr: errStore, wantCalls: 6,
+			wantState: CircuitOpen},
+		{name: "open again after the failed probe", wantErr: ErrCircuitOpen, wantCalls: 6, wantState: CircuitOpen},
+		{name: "successful probe after the cooldown closes", advance: cooldown, wantCalls: 7, wantState: CircuitClosed},
+		{name: "success after closing", wantCalls: 8, wantState: CircuitClosed},
+	}
+
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	store := &flakyRepository{}
+	breaker := NewCircuitBreakerRepository(store, 2, cooldown).(*circuitBreakerRepository)
+	breaker.now = func() time.Time { return now }
+
+	for _, tt := range tests {
+		now = now.Add(tt.advance)
+		store.err = tt.storeErr
+
+		_, err := breaker.GetUserByID(context.Background(), "user-1")
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: GetUserByID() error = %v, want %v", tt.name, err, tt.wantErr)
+		}
+		if store.calls != tt.wantCalls {
+			t.Errorf("%s: %d calls reached the store, want %d", tt.name, store.calls, tt.wantCalls)
+		}
+		if state := breaker.State(); state != tt.wantState {
+			t.Errorf("%s: state = %s, want %s", tt.name, state, tt.wantState)
+		}
+	}
+}
+
+func TestCircuitBreakerHalfOpenState(t *testing.T) {
+	now := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	store := &flakyRepository{err: errors.New("service unavailable")}
+	breaker := NewCircuitBreakerRepository(store, 1, time.Second).(*circuitBreakerRepository)
+	breaker.now = func() time.Time { return now }
+
+	if _, err := breaker.GetUserByID(context.Background(), "user-1"); err == nil {
+		t.Fatal("GetUserByID() error = nil, want the store error")
+	}
+
+	tests := []struct {
+		name      string
+		advance   time.Duration
+		wantState string
+	}{
+		{name: "open during the cooldown", wantState: CircuitOpen},
+		{name: "half-open once the cooldown has passed", advance: time.Second, wantState: CircuitHalfOpen},
+	}
+
+	for _, tt := range tests {
+		now = now.Add(tt.advance)
+		if state := breaker.State(); state != tt.wantState {
+			t.Errorf("%s: state = %s, want %s", tt.name, state, tt.wantState)
+		}
+		if err := breaker.HealthCheck(context.Background()); (err != nil) != (tt.wantState == CircuitOpen) {
+			t.Errorf("%s: HealthCheck() error = %v", tt.name, err)
+		}
+	}
+}
+
+func TestNewCircuitBreakerRepositoryDisabled(t *testing.T) {
+	store := &flakyRepository{}
+	if repo := NewCircuitBreakerRepository(store, 0, time.Second); repo != store {
+		t.Errorf("NewCircuitBreakerRepository() with threshold 0 = %T, want the wrapped repository", repo)
+	}
+}
@@ -0,0 +1,988 @@
+//go:build dynamodb
+
+package models
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"github.com/google/uuid"
+	"golang.org/x/text/unicode/norm"
+)
+
+// DefaultDynamoDBKeyAttribute is the partition key attribute when DYNAMODB_KEY_ATTRIBUTE is
+// unset. It is the dynamodbav name of User.ID, so items need no renaming.
+const DefaultDynamoDBKeyAttribute = "ID"
+
+// dynamoDBNameSearchAttribute holds each user's lower-cased name, which the ?name= filter
+// matches, since DynamoDB's contains is case-sensitive. It is not part of User.
+const dynamoDBNameSearchAttribute = "NameSearch"
+
+// dynamoDBListAttribute holds dynamoDBListPartition on every user, so the
+// DYNAMODB_CREATED_AT_INDEX global secondary index keeps all users in one partition, sorted
+// by dynamoDBCreatedAtSortAttribute. Neither is part of User.
+const (
+	dynamoDBListAttribute = "List"
+	dynamoDBListPartition = "users"
+)
+
+// dynamoDBCreatedAtSortAttribute holds CreatedAt in Unix microseconds, which sort as numbers,
+// where the stored RFC 3339 strings with trimmed fractions do not sort by time.
+const dynamoDBCreatedAtSortAttribute = "CreatedAtMicros"
+
+// Each user's email is claimed by an email sentinel item in the same table, keyed by
+// dynamoDBEmailKeyPrefix and the normalized email, whose dynamoDBEmailOwnerAttribute holds
+// the user's ID. A user is written in one transaction with its sentinel, on the condition
+// that no other user holds it, which makes emails unique as a unique index would. Client IDs
+// cannot contain '#', so a sentinel's key is never a user's.
+const (
+	dynamoDBEmailKeyPrefix      = "EMAIL#"
+	dynamoDBEmailOwnerAttribute = "UserID"
+)
+
+// dynamoDBDeleteAttempts is how many times DeleteUser reads the user and deletes it before
+// giving up on a user whose email keeps changing in between.
+const dynamoDBDeleteAttempts = 3
+
+// userAttributes maps each JSON field name of User to its DynamoDB attribute name.
+var userAttributes = func() map[string]string {
+	attributes := make(map[string]string)
+	t := reflect.TypeOf(User{})
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		attribute, _, _ := strings.Cut(field.Tag.Get("dynamodbav"), ",")
+		attributes[name] = attribute
+	}
+
+	return attributes
+}()
+
+// dynamoDBUserRepository implements UserRepository for DynamoDB.
+type dynamoDBUserRepository struct {
+	db        dynamodbiface.DynamoDBAPI
+	tableName string
+	// keyAttribute is the table's partition key attribute, which holds User.ID.
+	keyAttribute string
+}
+
+// NewDynamoDBUserRepository creates a new instance of dynamoDBUserRepository. The table's
+// partition key attribute is DYNAMODB_KEY_ATTRIBUTE, "ID" by default.
+func NewDynamoDBUserRepository(db dynamodbiface.DynamoDBAPI, tableName string) UserRepository {
+	keyAttribute := os.Getenv("DYNAMODB_KEY_ATTRIBUTE")
+	if keyAttribute == "" {
+		keyAttribute = DefaultDynamoDBKeyAttribute
+	}
+
+	return &dynamoDBUserRepository{db: db, tableName: tableName, keyAttribute: keyAttribute}
+}
+
+// attribute returns the DynamoDB attribute name of a User JSON field.
+func (r *dynamoDBUserRepository) attribute(field string) string {
+	if field == "id" {
+		return r.keyAttribute
+	}
+
+	return userAttributes[field]
+}
+
+// key returns the primary key of the user with id.
+func (r *dynamoDBUserRepository) key(id string) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{r.keyAttribute: {S: aws.String(id)}}
+}
+
+// emailKey returns the key of the sentinel item of email.
+func (r *dynamoDBUserRepository) emailKey(email string) map[string]*dynamodb.AttributeValue {
+	return r.key(dynamoDBEmailKeyPrefix + NormalizeEmail(email))
+}
+
+// isEmailItem reports whether item, or a key, is an email sentinel rather than a user.
+func (r *dynamoDBUserRepository) isEmailItem(item map[string]*dynamodb.AttributeValue) bool {
+	key := item[r.keyAttribute]
+
+	return key != nil && strings.HasPrefix(aws.StringValue(key.S), dynamoDBEmailKeyPrefix)
+}
+
+// putNewUser returns the transaction item that puts av, a marshaled user, unless its ID is taken.
+func (r *dynamoDBUserRepository) putNewUser(av map[string]*dynamodb.AttributeValue) *dynamodb.TransactWriteItem {
+	return &dynamodb.TransactWriteItem{
+		Put: &dynamodb.Put{
+			Item:                     av,
+			TableName:                aws.String(r.tableName),
+			ConditionExpression:      aws.String("attribute_not_exists(#ID)"),
+			ExpressionAttributeNames: map[string]*string{"#ID": aws.String(r.keyAttribute)},
+		},
+	}
+}
+
+// claimEmail returns the transaction item that puts the sentinel of email for the user with
+// id. Its condition fails when another user holds the email.
+func (r *dynamoDBUserRepository) claimEmail(email, id string) *dynamodb.TransactWriteItem {
+	item := r.emailKey(email)
+	item[dynamoDBEmailOwnerAttribute] = &dynamodb.AttributeValue{S: aws.String(id)}
+	condition, names, values := r.emailOwnerCondition(id)
+
+	return &dynamodb.TransactWriteItem{
+		Put: &dynamodb.Put{
+			Item:                      item,
+			TableName:                 aws.String(r.tableName),
+			ConditionExpression:       condition,
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+		},
+	}
+}
+
+// releaseEmail returns the transaction item that deletes the sentinel of email held by the
+// user with id. Its condition fails when another user holds the email.
+func (r *dynamoDBUserRepository) releaseEmail(email, id string) *dynamodb.TransactWriteItem {
+	condition, names, values := r.emailOwnerCondition(id)
+
+	return &dynamodb.TransactWriteItem{
+		Delete: &dynamodb.Delete{
+			Key:                       r.emailKey(email),
+			TableName:                 aws.String(r.tableName),
+			ConditionExpression:       condition,
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+		},
+	}
+}
+
+// emailOwnerCondition returns the condition that an email sentinel is missing or held by
+// the user with id, with its names and values.
+func (r *dynamoDBUserRepository) emailOwnerCondition(
+	id string,
+) (*string, map[string]*string, map[string]*dynamodb.AttributeValue) {
+	names := map[string]*string{
+		"#ID":     aws.String(r.keyAttribute),
+		"#UserID": aws.String(dynamoDBEmailOwnerAttribute),
+	}
+	values := map[string]*dynamodb.AttributeValue{":owner": {S: aws.String(id)}}
+
+	return aws.String("attribute_not_exists(#ID) OR #UserID = :owner"), names, values
+}
+
+// transactWrite writes items in one TransactWriteItems call, retrying transient errors like
+// any other write. Retries reuse the client request token, so DynamoDB applies the
+// transaction at most once.
+func (r *dynamoDBUserRepository) transactWrite(ctx context.Context, items []*dynamodb.TransactWriteItem) error {
+	input := &dynamodb.TransactWriteItemsInput{TransactItems: items, ClientRequestToken: aws.String(uuid.NewString())}
+
+	return retryWrite(ctx, func() error {
+		_, err := r.db.TransactWriteItemsWithContext(ctx, input, withoutSDKRetries)
+		return err
+	})
+}
+
+// failedCondition reports whether item i of the transaction that err canceled failed its
+// condition, returning the stored item when the transaction item asked for it.
+func failedCondition(err error, i int) (map[string]*dynamodb.AttributeValue, bool) {
+	var canceledErr *dynamodb.TransactionCanceledException
+	if !errors.As(err, &canceledErr) || i >= len(canceledErr.CancellationReasons) {
+		return nil, false
+	}
+	reason := canceledErr.CancellationReasons[i]
+
+	return reason.Item, aws.StringValue(reason.Code) == "ConditionalCheckFailed"
+}
+
+// marshalUser marshals user into an item, storing its ID under the key attribute, its
+// lower-cased name under dynamoDBNameSearchAttribute and the created-at index keys.
+func (r *dynamoDBUserRepository) marshalUser(user User) (map[string]*dynamodb.AttributeValue, error) {
+	item, err := dynamodbattribute.MarshalMap(user)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal user: %w", err)
+	}
+	item[dynamoDBNameSearchAttribute] = &dynamodb.AttributeValue{S: aws.String(strings.ToLower(user.Name))}
+	item[dynamoDBListAttribute] = &dynamodb.AttributeValue{S: aws.String(dynamoDBListPartition)}
+	item[dynamoDBCreatedAtSortAttribute] = &dynamodb.AttributeValue{
+		N: aws.String(strconv.FormatInt(user.CreatedAt.UnixMicro(), 10)),
+	}
+
+	if idAttribute := userAttributes["id"]; r.keyAttribute != idAttribute {
+		item[r.keyAttribute] = item[idAttribute]
+		delete(item, idAttribute)
+	}
+
+	return item, nil
+}
+
+// unmarshalUser unmarshals an item written by marshalUser, or a projection of one.
+func (r *dynamoDBUserRepository) unmarshalUser(item map[string]*dynamodb.AttributeValue) (User, error) {
+	if idAttribute := userAttributes["id"]; r.keyAttribute != idAttribute {
+		renamed := make(map[string]*dynamodb.AttributeValue, len(item))
+		for name, value := range item {
+			renamed[name] = value
+		}
+		if id, ok := renamed[r.keyAttribute]; ok {
+			renamed[idAttribute] = id
+			delete(renamed, r.keyAttribute)
+		}
+		item = renamed
+	}
+
+	var user User
+	if err := dynamodbattribute.UnmarshalMap(item, &user); err != nil {
+		return User{}, fmt.Errorf("failed to unmarshal item: %w", err)
+	}
+
+	return user, nil
+}
+
+// HealthCheck verifies the table is reachable and active.
+func (r *dynamoDBUserRepository) HealthCheck(ctx context.Context) error {
+	result, err := r.db.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(r.tableName),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe DynamoDB table: %w", contextErr(ctx, err))
+	}
+
+	if status := aws.StringValue(result.Table.TableStatus); status != dynamodb.TableStatusActive {
+		return fmt.Errorf("DynamoDB table %s is %s", r.tableName, status)
+	}
+
+	return nil
+}
+
+// ValidateSchema verifies the table's partition key is the attribute the repository reads
+// and writes, so a misconfigured table fails at startup instead of silently missing items.
+func (r *dynamoDBUserRepository) ValidateSchema(ctx context.Context) error {
+	result, err := r.db.DescribeTableWithContext(ctx, &dynamodb.DescribeTableInput{
+		TableName: aws.String(r.tableName),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to describe DynamoDB table: %w", contextErr(ctx, err))
+	}
+
+	for _, key := range result.Table.KeySchema {
+		if aws.StringValue(key.KeyType) != dynamodb.KeyTypeHash {
+			continue
+		}
+		if name := aws.StringValue(key.AttributeName); name != r.keyAttribute {
+			return fmt.Errorf("DynamoDB table %s has partition key %q, expected %q; set DYNAMODB_KEY_ATTRIBUTE",
+				r.tableName, name, r.keyAttribute)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("DynamoDB table %s has no partition key", r.tableName)
+}
+
+// CreateUser inserts a new user into DynamoDB in one transaction with the sentinel claiming
+// its email, so of concurrent creates with one email exactly one succeeds.
+func (r *dynamoDBUserRepository) CreateUser(ctx context.Context, user User) (User, error) {
+	if user.UpdatedAt.IsZero() {
+		user.UpdatedAt = user.CreatedAt
+	}
+	user.Version = 1
+	user.truncateTimestamps()
+
+	av, err := r.marshalUser(user)
+	if err != nil {
+		return User{}, err
+	}
+
+	err = r.transactWrite(ctx, []*dynamodb.TransactWriteItem{r.putNewUser(av), r.claimEmail(user.Email, user.ID)})
+	if _, failed := failedCondition(err, 0); failed {
+		return User{}, ErrDuplicateID
+	}
+	if _, failed := failedCondition(err, 1); failed {
+		return User{}, ErrDuplicateEmail
+	}
+	if err != nil {
+		return User{}, fmt.Errorf("failed to write transaction to DynamoDB: %w", contextErr(ctx, err))
+	}
+
+	return user, nil
+}
+
+// CreateUsers creates users in a single TransactWriteItems call, each with the sentinel
+// claiming its email, so either all of them are written or none is. A taken ID or email
+// cancels the whole transaction.
+func (r *dynamoDBUserRepository) CreateUsers(ctx context.Context, users []User) ([]User, error) {
+	if len(users) == 0 {
+		return nil, ErrEmptyBatch
+	}
+	if len(users) > MaxBatchCreateSize {
+		return nil, ErrBatchTooLarge
+	}
+
+	created := make([]User, len(users))
+	items := make([]*dynamodb.TransactWriteItem, 0, 2*len(users))
+	for i, user := range users {
+		// A transaction cannot write one item twice, so repeats are rejected here
+		for _, earlier := range created[:i] {
+			if earlier.ID == user.ID {
+				return nil, fmt.Errorf("user %d: %w", i+1, ErrDuplicateID)
+			}
+			if SameEmail(earlier.Email, user.Email) {
+				return nil, fmt.Errorf("user %d: %w", i+1, ErrDuplicateEmail)
+			}
+		}
+
+		if user.UpdatedAt.IsZero() {
+			user.UpdatedAt = user.CreatedAt
+		}
+		user.Version = 1
+		user.truncateTimestamps()
+		av, err := r.marshalUser(user)
+		if err != nil {
+			return nil, err
+		}
+
+		created[i] = user
+		items = append(items, r.putNewUser(av), r.claimEmail(user.Email, user.ID))
+	}
+
+	err := r.transactWrite(ctx, items)
+	for i := range users {
+		if _, failed := failedCondition(err, 2*i); failed {
+			return nil, fmt.Errorf("user %d: %w", i+1, ErrDuplicateID)
+		}
+		if _, failed := failedCondition(err, 2*i+1); failed {
+			return nil, fmt.Errorf("user %d: %w", i+1, ErrDuplicateEmail)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to write transaction to DynamoDB: %w", contextErr(ctx, err))
+	}
+
+	return created, nil
+}
+
+// dynamoDBBatchWriteSize is the most requests a BatchWriteItem call accepts.
+const dynamoDBBatchWriteSize = 25
+
+// batchWrite sends at most dynamoDBBatchWriteSize write requests with BatchWriteItem,
+// retrying unprocessed items and transient errors like any other write. On error it also
+// returns exactly the requests no call has written.
+func (r *dynamoDBUserRepository) batchWrite(
+	ctx context.Context, requests []*dynamodb.WriteRequest,
+) ([]*dynamodb.WriteRequest, error) {
+	isRetryable := func(err error) bool {
+		return errors.Is(err, ErrWriteUnprocessed) || isTransientDynamoDBError(err)
+	}
+	err := Retry(ctx, dynamoDBMaxRetries(), isRetryable, func() error {
+		output, err := r.db.BatchWriteItemWithContext(ctx, &dynamodb.BatchWriteItemInput{
+			RequestItems: map[string][]*dynamodb.WriteRequest{r.tableName: requests},
+		}, withoutSDKRetries)
+		if err != nil {
+			return fmt.Errorf("failed to batch write to DynamoDB: %w", contextErr(ctx, err))
+		}
+
+		requests = output.UnprocessedItems[r.tableName]
+		if len(requests) > 0 {
+			return ErrWriteUnprocessed
+		}
+
+		return nil
+	})
+	if err != nil {
+		return requests, err
+	}
+
+	return nil, nil
+}
+
+// GetUserByEmail finds the user with email. It reads the email's sentinel, consistently, and
+// then its owner. Users written before sentinels have none, so without one it queries the
+// DYNAMODB_EMAIL_INDEX global secondary index (partition key "Email") when configured, and
+// otherwise scans the table with a filter, which reads every item. Emails are stored
+// normalized, so the lookup normalizes email and matches exactly.
+func (r *dynamoDBUserRepository) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	sentinel, err := r.db.GetItemWithContext(ctx, &dynamodb.GetItemInput{
+		Key:            r.emailKey(email),
+		TableName:      aws.String(r.tableName),
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return User{}, fmt.Errorf("failed to get item from DynamoDB: %w", contextErr(ctx, err))
+	}
+	if owner := sentinel.Item[dynamoDBEmailOwnerAttribute]; owner != nil {
+		return r.currentUser(ctx, aws.StringValue(owner.S))
+	}
+
+	names := map[string]*string{"#Email": aws.String(r.attribute("email"))}
+	values := map[string]*dynamodb.AttributeValue{":email": {S: aws.String(NormalizeEmail(email))}}
+
+	var item map[string]*dynamodb.AttributeValue
+	if index := os.Getenv("DYNAMODB_EMAIL_INDEX"); index != "" {
+		result, err := r.db.QueryWithContext(ctx, &dynamodb.QueryInput{
+			TableName:                 aws.String(r.tableName),
+			IndexName:                 aws.String(index),
+			KeyConditionExpression:    aws.String("#Email = :email"),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+			Limit:                     aws.Int64(1),
+		})
+		if err != nil {
+			return User{}, fmt.Errorf("failed to query email index: %w", contextErr(ctx, err))
+		}
+		if len(result.Items) > 0 {
+			item = result.Items[0]
+		}
+	} else {
+		err := r.db.ScanPagesWithContext(ctx, &dynamodb.ScanInput{
+			TableName:                 aws.String(r.tableName),
+			FilterExpression:          aws.String("#Email = :email"),
+			ExpressionAttributeNames:  names,
+			ExpressionAttributeValues: values,
+		}, func(page *dynamodb.ScanOutput, _ bool) bool {
+			if len(page.Items) > 0 {
+				item = page.Items[0]
+			}
+			return item == nil
+		})
+		if err != nil {
+			return User{}, fmt.Errorf("failed to scan for email: %w", contextErr(ctx, err))
+		}
+	}
+
+	if item == nil {
+		return User{}, ErrUserNotFound
+	}
+
+	// The index may project only keys, so read the full item
+	id := aws.StringValue(item[r.keyAttribute].S)
+
+	return r.GetUserByID(ctx, id)
+}
+
+// contextErr returns ctx's error when ctx is done, so callers can match a cancelled or
+// timed-out call with errors.Is, which the SDK's own error does not support.
+func contextErr(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+
+	return err
+}
+
+// GetUserByID retrieves a user from DynamoDB by ID.
+func (r *dynamoDBUserRepository) GetUserByID(ctx context.Context, id string) (User, error) {
+	return r.GetUserByIDProjected(ctx, id, nil)
+}
+
+// GetUserByIDProjected retrieves only the projected attributes of a user, with a
+// ProjectionExpression naming the attributes of the projected JSON fields.
+func (r *dynamoDBUserRepository) GetUserByIDProjected(
+	ctx context.Context, id string, projection Projection,
+) (User, error) {
+	input := &dynamodb.GetItemInput{
+		Key:       r.key(id),
+		TableName: aws.String(r.tableName),
+	}
+	if projection != nil {
+		names := make(map[string]*string, len(projection))
+		placeholders := make([]string, 0, len(projection))
+		for i, field := range projection {
+			placeholder := fmt.Sprintf("#f%d", i)
+			names[placeholder] = aws.String(r.attribute(field))
+			placeholders = append(placeholders, placeholder)
+		}
+		input.ProjectionExpression = aws.String(strings.Join(placeholders, ", "))
+		input.ExpressionAttributeNames = names
+	}
+
+	user, err := r.getUser(ctx, input)
+	if err != nil {
+		return User{}, err
+	}
+
+	// Projecting again keeps the result identical to the in-memory repository's
+	return projection.Apply(user), nil
+}
+
+// currentUser reads the user with id with a strongly consistent read, for writes that
+// depend on the stored item.
+func (r *dynamoDBUserRepository) currentUser(ctx context.Context, id string) (User, error) {
+	return r.getUser(ctx, &dynamodb.GetItemInput{
+		Key:            r.key(id),
+		TableName:      aws.String(r.tableName),
+		ConsistentRead: aws.Bool(true),
+	})
+}
+
+// getUser reads the user input names. An email sentinel is not a user, so it is not found.
+func (r *dynamoDBUserRepository) getUser(ctx context.Context, input *dynamodb.GetItemInput) (User, error) {
+	if r.isEmailItem(input.Key) {
+		return User{}, ErrUserNotFound
+	}
+
+	result, err := r.db.GetItemWithContext(ctx, input)
+	if err != nil {
+		return User{}, fmt.Errorf("failed to get item from DynamoDB: %w", contextErr(ctx, err))
+	}
+
+	if result.Item == nil {
+		return User{}, ErrUserNotFound
+	}
+
+	return r.unmarshalUser(result.Item)
+}
+
+// GetAllUsers scans one page of users from DynamoDB, skipping email sentinels. Unsorted, the page is in the table's
+// scan order, which is stable but not by ID, and the cursor is the base64-encoded key to
+// resume the scan after. Sorted by created_at with DYNAMODB_CREATED_AT_INDEX set, the page
+// is queried from that index instead. Scan cannot sort, so any other sorted query reads the
+// whole table on every page and sorts it here, like the in-memory repository. The name
+// filter is a FilterExpression, which DynamoDB applies after Limit, so Scan or Query is
+// repeated until the page is full.
+func (r *dynamoDBUserRepository) GetAllUsers(ctx context.Context, query ListQuery) ([]User, string, error) {
+	if index := os.Getenv("DYNAMODB_CREATED_AT_INDEX"); index != "" && query.SortField == "created_at" {
+		return r.queryByCreatedAt(ctx, index, query)
+	}
+	if query.SortField != "" {
+		users, err := r.scanAllUsers(ctx, query)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return pageUsers(users, query)
+	}
+
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(r.tableName),
+	}
+	input.FilterExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues = r.nameFilter(query)
+	if query.Limit > 0 {
+		input.Limit = aws.Int64(int64(query.Limit))
+	}
+	if query.Cursor != "" {
+		startKey, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		input.ExclusiveStartKey = startKey
+	}
+
+	users := make([]User, 0)
+	for {
+		result, err := r.db.ScanWithContext(ctx, input)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to scan items from DynamoDB: %w", contextErr(ctx, err))
+		}
+
+		for _, item := range result.Items {
+			if r.isEmailItem(item) {
+				continue
+			}
+			user, err := r.unmarshalUser(item)
+			if err != nil {
+				return nil, "", err
+			}
+			users = append(users, user)
+		}
+
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+		if len(result.LastEvaluatedKey) == 0 || (query.Limit > 0 && len(users) >= query.Limit) {
+			break
+		}
+	}
+
+	startKey := input.ExclusiveStartKey
+	if query.Limit > 0 && len(users) > query.Limit {
+		// The last scan overshot the page, so resume after its last user instead
+		users = users[:query.Limit]
+		startKey = r.key(users[query.Limit-1].ID)
+	}
+	if len(startKey) == 0 {
+		return users, "", nil
+	}
+
+	next, err := encodeCursor(startKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return users, next, nil
+}
+
+// queryByCreatedAt queries one page of users from the DYNAMODB_CREATED_AT_INDEX global
+// secondary index, whose partition key is dynamoDBListAttribute and whose sort key is
+// dynamoDBCreatedAtSortAttribute, newest first when the query is descending. Users created
+// in the same microsecond are in the index's order rather than by ID.
+func (r *dynamoDBUserRepository) queryByCreatedAt(
+	ctx context.Context, index string, query ListQuery,
+) ([]User, string, error) {
+	input := &dynamodb.QueryInput{
+		TableName:              aws.String(r.tableName),
+		IndexName:              aws.String(index),
+		KeyConditionExpression: aws.String("#List = :list"),
+		ScanIndexForward:       aws.Bool(!query.Descending),
+	}
+	filter, names, values := r.nameFilter(query)
+	input.FilterExpression = filter
+	input.ExpressionAttributeNames = map[string]*string{"#List": aws.String(dynamoDBListAttribute)}
+	input.ExpressionAttributeValues = map[string]*dynamodb.AttributeValue{":list": {S: aws.String(dynamoDBListPartition)}}
+	for placeholder, name := range names {
+		input.ExpressionAttributeNames[placeholder] = name
+	}
+	for placeholder, value := range values {
+		input.ExpressionAttributeValues[placeholder] = value
+	}
+	if query.Limit > 0 {
+		input.Limit = aws.Int64(int64(query.Limit))
+	}
+	if query.Cursor != "" {
+		startKey, err := decodeCursor(query.Cursor)
+		if err != nil {
+			return nil, "", ErrInvalidCursor
+		}
+		input.ExclusiveStartKey = startKey
+	}
+
+	users := make([]User, 0)
+	var last map[string]*dynamodb.AttributeValue
+	for {
+		result, err := r.db.QueryWithContext(ctx, input)
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to query created-at index: %w", contextErr(ctx, err))
+		}
+
+		for _, item := range result.Items {
+			if query.Limit > 0 && len(users) == query.Limit {
+				// The last query overshot the page, so resume after its last user instead
+				result.LastEvaluatedKey = r.indexKey(last)
+				break
+			}
+			user, err := r.unmarshalUser(item)
+			if err != nil {
+				return nil, "", err
+			}
+			users = append(users, user)
+			last = item
+		}
+
+		input.ExclusiveStartKey = result.LastEvaluatedKey
+		if len(result.LastEvaluatedKey) == 0 || (query.Limit > 0 && len(users) >= query.Limit) {
+			break
+		}
+	}
+
+	if len(input.ExclusiveStartKey) == 0 {
+		return users, "", nil
+	}
+
+	next, err := encodeCursor(input.ExclusiveStartKey)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return users, next, nil
+}
+
+// indexKey returns the key of item in the created-at index: the table key and the index keys.
+func (r *dynamoDBUserRepository) indexKey(item map[string]*dynamodb.AttributeValue) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		r.keyAttribute:                 item[r.keyAttribute],
+		dynamoDBListAttribute:          item[dynamoDBListAttribute],
+		dynamoDBCreatedAtSortAttribute: item[dynamoDBCreatedAtSortAttribute],
+	}
+}
+
+// nameFilter returns the filter expression, names and values of the query's name filter, or
+// nils without one. It matches the lower-cased name marshalUser stores under
+// dynamoDBNameSearchAttribute, and the name itself, case-sensitively, on items written
+// before that attribute existed.
+func (r *dynamoDBUserRepository) nameFilter(
+	query ListQuery,
+) (*string, map[string]*string, map[string]*dynamodb.AttributeValue) {
+	if query.NameContains == "" {
+		return nil, nil, nil
+	}
+
+	filter := aws.String(
+		"contains(#NameSearch, :nameSearch) OR (attribute_not_exists(#NameSearch) AND contains(#Name, :name))")
+	names := map[string]*string{
+		"#NameSearch": aws.String(dynamoDBNameSearchAttribute),
+		"#Name":       aws.String(userAttributes["name"]),
+	}
+	values := map[string]*dynamodb.AttributeValue{
+		":nameSearch": {S: aws.String(query.nameFilter())},
+		":name":       {S: aws.String(norm.NFC.String(query.NameContains))},
+	}
+
+	return filter, names, values
+}
+
+// scanAllUsers reads every user in the table that passes the query's name filter, skipping
+// email sentinels.
+func (r *dynamoDBUserRepository) scanAllUsers(ctx context.Context, query ListQuery) ([]User, error) {
+	input := &dynamodb.ScanInput{
+		TableName: aws.String(r.tableName),
+	}
+	input.FilterExpression, input.ExpressionAttributeNames, input.ExpressionAttributeValues = r.nameFilter(query)
+
+	users := make([]User, 0)
+	var unmarshalErr error
+	err := r.db.ScanPagesWithContext(ctx, input, func(page *dynamodb.ScanOutput, _ bool) bool {
+		for _, item := range page.Items {
+			if r.isEmailItem(item) {
+				continue
+			}
+			user, err := r.unmarshalUser(item)
+			if err != nil {
+				unmarshalErr = err
+				return false
+			}
+			users = append(users, user)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan items from DynamoDB: %w", contextErr(ctx, err))
+	}
+
+	return users, unmarshalErr
+}
+
+// encodeCursor encodes a DynamoDB key as an opaque URL-safe cursor.
+func encodeCursor(key map[string]*dynamodb.AttributeValue) (string, error) {
+	data, err := json.Marshal(key)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodeCursor decodes a cursor produced by encodeCursor.
+func decodeCursor(cursor string) (map[string]*dynamodb.AttributeValue, error) {
+	data, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	var key map[string]*dynamodb.AttributeValue
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, err
+	}
+	if len(key) == 0 {
+		return nil, errors.New("empty cursor")
+	}
+
+	return key, nil
+}
+
+// UpdateUser updates an existing user in DynamoDB.
+// It uses an Update rather than a Put so that CreatedAt is never written by an update,
+// even if the caller passes a partially populated user. The write is conditional on the
+// stored version matching user.Version; items written before versioning have no version
+// attribute, which matches version 0.
+//
+// The user is read first for its stored email. The update is written in one transaction
+// with the sentinel of user.Email, claimed unless another user holds it, and, when the
+// email changes, with the release of the old email's sentinel. Claiming also writes the
+// sentinel of a user written before sentinels existed.
+func (r *dynamoDBUserRepository) UpdateUser(ctx context.Context, user User) (User, error) {
+	stored, err := r.currentUser(ctx, user.ID)
+	if err != nil {
+		return User{}, err
+	}
+	if stored.Version != user.Version {
+		return User{}, ErrVersionConflict
+	}
+
+	user.truncateTimestamps()
+	update, err := r.userUpdate(user)
+	if err != nil {
+		return User{}, err
+	}
+
+	items := []*dynamodb.TransactWriteItem{{Update: update}, r.claimEmail(user.Email, user.ID)}
+	if !SameEmail(stored.Email, user.Email) {
+		items = append(items, r.releaseEmail(stored.Email, user.ID))
+	}
+	err = r.transactWrite(ctx, items)
+	if _, failed := failedCondition(err, 2); failed {
+		// Users written before sentinels may share an email, and the other one holds the old one
+		err = r.transactWrite(ctx, items[:2])
+	}
+
+	if item, failed := failedCondition(err, 0); failed {
+		if len(item) == 0 {
+			return User{}, ErrUserNotFound
+		}
+
+		return User{}, ErrVersionConflict
+	}
+	if _, failed := failedCondition(err, 1); failed {
+		return User{}, ErrDuplicateEmail
+	}
+	if err != nil {
+		return User{}, fmt.Errorf("failed to write transaction to DynamoDB: %w", contextErr(ctx, err))
+	}
+
+	// The update leaves CreatedAt as stored and writes every other attribute
+	user.Version++
+	user.CreatedAt = stored.CreatedAt
+
+	return user, nil
+}
+
+// userUpdate returns the transaction item's Update that writes user, conditional on the
+// stored version being user.Version, and increments the version.
+func (r *dynamoDBUserRepository) userUpdate(user User) (*dynamodb.Update, error) {
+	expectedVersion := user.Version
+	user.Version++
+	av, err := r.marshalUser(user)
+	if err != nil {
+		return nil, err
+	}
+
+	delete(av, r.keyAttribute)
+	delete(av, r.attribute("created_at"))
+
+	attributes := make([]string, 0, len(av))
+	for name := range av {
+		attributes = append(attributes, name)
+	}
+	sort.Strings(attributes)
+
+	names := map[string]*string{"#ID": aws.String(r.keyAttribute)}
+	values := make(map[string]*dynamodb.AttributeValue, len(av)+1)
+	sets := make([]string, 0, len(av))
+	for _, name := range attributes {
+		names["#"+name] = aws.String(name)
+		values[":"+name] = av[name]
+		sets = append(sets, fmt.Sprintf("#%s = :%s", name, name))
+	}
+	update := "SET " + strings.Join(sets, ", ")
+
+	// Empty optional fields are left out of av, so they must be removed to be cleared
+	var removes []string
+	for _, field := range []string{"avatar_url", "metadata"} {
+		if name := r.attribute(field); av[name] == nil {
+			names["#"+name] = aws.String(name)
+			removes = append(removes, "#"+name)
+		}
+	}
+	if len(removes) > 0 {
+		update += " REMOVE " + strings.Join(removes, ", ")
+	}
+
+	condition := "attribute_exists(#ID) AND #Version = :expectedVersion"
+	if expectedVersion == 0 {
+		condition = "attribute_exists(#ID) AND (attribute_not_exists(#Version) OR #Version = :expectedVersion)"
+	}
+	names["#Version"] = aws.String(r.attribute("version"))
+	values[":expectedVersion"] = &dynamodb.AttributeValue{N: aws.String(strconv.Itoa(expectedVersion))}
+
+	return &dynamodb.Update{
+		Key:                       r.key(user.ID),
+		TableName:                 aws.String(r.tableName),
+		UpdateExpression:          aws.String(update),
+		ConditionExpression:       aws.String(condition),
+		ExpressionAttributeNames:  names,
+		ExpressionAttributeValues: values,
+		// A missing item tells a deleted user apart from a stale version
+		ReturnValuesOnConditionCheckFailure: aws.String(dynamodb.ReturnValuesOnConditionCheckFailureAllOld),
+	}, nil
+}
+
+// DeleteUser deletes a user from DynamoDB by ID in one transaction with the sentinel of its
+// email, returning ErrUserNotFound when there is no such user. The user is read first for
+// its email, and the delete is conditional on the email being unchanged; when it changed in
+// between, the delete starts over, at most dynamoDBDeleteAttempts times.
+func (r *dynamoDBUserRepository) DeleteUser(ctx context.Context, id string) error {
+	for attempt := 1; ; attempt++ {
+		stored, err := r.currentUser(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		items := []*dynamodb.TransactWriteItem{
+			{
+				Delete: &dynamodb.Delete{
+					Key:                       r.key(id),
+					TableName:                 aws.String(r.tableName),
+					ConditionExpression:       aws.String("#Email = :email"),
+					ExpressionAttributeNames:  map[string]*string{"#Email": aws.String(r.attribute("email"))},
+					ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{":email": {S: aws.String(stored.Email)}},
+					// A missing item tells a deleted user apart from a changed email
+					ReturnValuesOnConditionCheckFailure: aws.String(dynamodb.ReturnValuesOnConditionCheckFailureAllOld),
+				},
+			},
+			r.releaseEmail(stored.Email, id),
+		}
+		err = r.transactWrite(ctx, items)
+		if _, failed := failedCondition(err, 1); failed {
+			// Users written before sentinels may share an email, and the other one holds it
+			err = r.transactWrite(ctx, items[:1])
+		}
+
+		item, failed := failedCondition(err, 0)
+		switch {
+		case failed && len(item) == 0:
+			return ErrUserNotFound
+		case failed && attempt < dynamoDBDeleteAttempts:
+			continue
+		case failed:
+			return fmt.Errorf("user %s changed during delete: %w", id, ErrVersionConflict)
+		case err != nil:
+			return fmt.Errorf("failed to write transaction to DynamoDB: %w", contextErr(ctx, err))
+		}
+
+		return nil
+	}
+}
+
+// DeleteAllUsers scans the table for every key and deletes the items with BatchWriteItem,
+// dynamoDBBatchWriteSize at a time. Items written during the scan may survive it. Email
+// sentinels are deleted too, but only users are counted.
+func (r *dynamoDBUserRepository) DeleteAllUsers(ctx context.Context) (int, error) {
+	var keys []map[string]*dynamodb.AttributeValue
+	err := r.db.ScanPagesWithContext(ctx, &dynamodb.ScanInput{
+		TableName:                aws.String(r.tableName),
+		ProjectionExpression:     aws.String("#ID"),
+		ExpressionAttributeNames: map[string]*string{"#ID": aws.String(r.keyAttribute)},
+	}, func(page *dynamodb.ScanOutput, _ bool) bool {
+		keys = append(keys, page.Items...)
+		return true
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to scan DynamoDB: %w", contextErr(ctx, err))
+	}
+
+	deleted := 0
+	for start := 0; start < len(keys); start += dynamoDBBatchWriteSize {
+		batch := keys[start:min(start+dynamoDBBatchWriteSize, len(keys))]
+		requests := make([]*dynamodb.WriteRequest, len(batch))
+		for i, key := range batch {
+			requests[i] = &dynamodb.WriteRequest{DeleteRequest: &dynamodb.DeleteRequest{Key: key}}
+		}
+
+		unwritten, err := r.batchWrite(ctx, requests)
+		for _, key := range batch {
+			if !r.isEmailItem(key) {
+				deleted++
+			}
+		}
+		for _, request := range unwritten {
+			if !r.isEmailItem(request.DeleteRequest.Key) {
+				deleted--
+			}
+		}
+		if err != nil {
+			return deleted, err
+		}
+	}
+
+	return deleted, nil
+}
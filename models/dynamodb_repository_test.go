@@ -0,0 +1,1413 @@
+//go:build dynamodb
+
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// mockDynamoDB answers the DynamoDB calls a test sets a func for; any other call panics.
+type mockDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+
+	describeTable func(input *dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error)
+	scan          func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error)
+	query         func(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error)
+	getItem       func(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error)
+
+	transactWriteItems func(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error)
+	batchWriteItem     func(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error)
+
+	scanInputs   []dynamodb.ScanInput
+	transactions int
+	batchWrites  []int
+}
+
+func (m *mockDynamoDB) DescribeTableWithContext(
+	_ aws.Context, input *dynamodb.DescribeTableInput, _ ...request.Option,
+) (*dynamodb.DescribeTableOutput, error) {
+	return m.describeTable(input)
+}
+
+func (m *mockDynamoDB) ScanWithContext(
+	_ aws.Context, input *dynamodb.ScanInput, _ ...request.Option,
+) (*dynamodb.ScanOutput, error) {
+	m.scanInputs = append(m.scanInputs, *input)
+
+	return m.scan(input)
+}
+
+func (m *mockDynamoDB) ScanPagesWithContext(
+	ctx aws.Context, input *dynamodb.ScanInput, fn func(*dynamodb.ScanOutput, bool) bool, opts ...request.Option,
+) error {
+	output, err := m.ScanWithContext(ctx, input, opts...)
+	if err != nil {
+		return err
+	}
+	fn(output, true)
+
+	return nil
+}
+
+func (m *mockDynamoDB) QueryWithContext(
+	_ aws.Context, input *dynamodb.QueryInput, _ ...request.Option,
+) (*dynamodb.QueryOutput, error) {
+	return m.query(input)
+}
+
+func (m *mockDynamoDB) TransactWriteItemsWithContext(
+	_ aws.Context, input *dynamodb.TransactWriteItemsInput, _ ...request.Option,
+) (*dynamodb.TransactWriteItemsOutput, error) {
+	m.transactions++
+
+	return m.transactWriteItems(input)
+}
+
+func (m *mockDynamoDB) BatchWriteItemWithContext(
+	_ aws.Context, input *dynamodb.BatchWriteItemInput, _ ...request.Option,
+) (*dynamodb.BatchWriteItemOutput, error) {
+	for _, requests := range input.RequestItems {
+		m.batchWrites = append(m.batchWrites, len(requests))
+	}
+
+	return m.batchWriteItem(input)
+}
+
+func (m *mockDynamoDB) GetItemWithContext(
+	_ aws.Context, input *dynamodb.GetItemInput, _ ...request.Option,
+) (*dynamodb.GetItemOutput, error) {
+	return m.getItem(input)
+}
+
+// newMockRepository returns a repository over db for the "users" table.
+func newMockRepository(t *testing.T, db dynamodbiface.DynamoDBAPI) *dynamoDBUserRepository {
+	t.Helper()
+	t.Setenv("DYNAMODB_KEY_ATTRIBUTE", "")
+
+	return NewDynamoDBUserRepository(db, "users").(*dynamoDBUserRepository)
+}
+
+func TestDynamoDBMarshalUserRoundTrip(t *testing.T) {
+	createdAt := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		keyAttribute string
+		user         User
+	}{
+		{
+			name: "metadata",
+			user: User{ID: "user-1", Name: "Ann", Email: "ann@example.com", CreatedAt: createdAt, UpdatedAt: createdAt,
+				Version: 1, Metadata: map[string]string{"team": "payments", "tier": "gold"}},
+		},
+		{
+			name: "no metadata",
+			user: User{ID: "user-2", Name: "Bob", Email: "bob@example.com", CreatedAt: createdAt, UpdatedAt: createdAt,
+				Version: 3},
+		},
+		{
+			name:         "custom key attribute",
+			keyAttribute: "pk",
+			user: User{ID: "user-3", Name: "Cy", Email: "cy@example.com", CreatedAt: createdAt, UpdatedAt: createdAt,
+				Version: 1, Metadata: map[string]string{"team": "growth"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := newMockRepository(t, nil)
+			if tt.keyAttribute != "" {
+				repo.keyAttribute = tt.keyAttribute
+			}
+
+			item, err := repo.marshalUser(tt.user)
+			if err != nil {
+				t.Fatalf("marshalUser() error = %v", err)
+			}
+			if id := item[repo.keyAttribute]; id == nil || id.S == nil || *id.S != tt.user.ID {
+				t.Errorf("item[%s] = %v, want %q", repo.keyAttribute, id, tt.user.ID)
+			}
+
+			metadata, stored := item[userAttributes["metadata"]]
+			if stored != (tt.user.Metadata != nil) {
+				t.Fatalf("metadata attribute stored = %v, want %v", stored, tt.user.Metadata != nil)
+			}
+			if stored && len(metadata.M) != len(tt.user.Metadata) {
+				t.Errorf("metadata attribute = %v, want a map of %d entries", metadata, len(tt.user.Metadata))
+			}
+
+			got, err := repo.unmarshalUser(item)
+			if err != nil {
+				t.Fatalf("unmarshalUser() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.user) {
+				t.Errorf("round trip = %+v, want %+v", got, tt.user)
+			}
+		})
+	}
+}
+
+func TestDynamoDBGetAllUsersScan(t *testing.T) {
+	errScan := errors.New("scan failed")
+	repo := newMockRepository(t, nil)
+	item := func(id string) map[string]*dynamodb.AttributeValue {
+		item, err := repo.marshalUser(User{ID: id, Name: "User " + id, Email: id + "@example.com"})
+		if err != nil {
+			t.Fatalf("marshalUser() error = %v", err)
+		}
+		return item
+	}
+
+	tests := []struct {
+		name       string
+		outputs    []*dynamodb.ScanOutput
+		scanErr    error
+		limit      int
+		wantIDs    []string
+		wantCursor bool
+		wantErr    error
+	}{
+		{name: "nil items", outputs: []*dynamodb.ScanOutput{{}}, wantIDs: []string{}},
+		{name: "scan error", scanErr: errScan, wantErr: errScan},
+		{
+			name:    "items",
+			outputs: []*dynamodb.ScanOutput{{Items: []map[string]*dynamodb.AttributeValue{item("a"), item("b")}}},
+			wantIDs: []string{"a", "b"},
+		},
+		{
+			name: "email sentinels skipped",
+			outputs: []*dynamodb.ScanOutput{{Items: []map[string]*dynamodb.AttributeValue{
+				item("a"),
+				{"ID": {S: aws.String("EMAIL#a@example.com")}, "UserID": {S: aws.String("a")}},
+				item("b"),
+			}}},
+			wantIDs: []string{"a", "b"},
+		},
+		{
+			name: "more pages",
+			outputs: []*dynamodb.ScanOutput{
+				{Items: []map[string]*dynamodb.AttributeValue{item("a")}, LastEvaluatedKey: repo.key("a")},
+			},
+			limit:      1,
+			wantIDs:    []string{"a"},
+			wantCursor: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &mockDynamoDB{}
+			db.scan = func(*dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+				if tt.scanErr != nil {
+					return nil, tt.scanErr
+				}
+				return tt.outputs[len(db.scanInputs)-1], nil
+			}
+			repo.db = db
+
+			users, cursor, err := repo.GetAllUsers(context.Background(), ListQuery{Limit: tt.limit})
+			if !errors.Is(err, tt.wantErr) || (err == nil) != (tt.wantErr == nil) {
+				t.Fatalf("GetAllUsers() error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if users == nil {
+				t.Fatal("GetAllUsers() returned a nil slice, want an empty one")
+			}
+			ids := make([]string, len(users))
+			for i, user := range users {
+				ids[i] = user.ID
+			}
+			if !reflect.DeepEqual(ids, tt.wantIDs) {
+				t.Errorf("ids = %v, want %v", ids, tt.wantIDs)
+			}
+			if (cursor != "") != tt.wantCursor {
+				t.Errorf("cursor = %q, want one %v", cursor, tt.wantCursor)
+			}
+		})
+	}
+}
+
+func TestDynamoDBGetAllUsersNameFilter(t *testing.T) {
+	tests := []struct {
+		name           string
+		nameContains   string
+		wantFilter     bool
+		wantNameSearch string
+		wantName       string
+	}{
+		{name: "no filter"},
+		{name: "mixed case", nameContains: "Jo", wantFilter: true, wantNameSearch: "jo", wantName: "Jo"},
+		{name: "decomposed", nameContains: "Jose\u0301", wantFilter: true, wantNameSearch: "jos\u00e9",
+			wantName: "Jos\u00e9"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &mockDynamoDB{scan: func(*dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+				return &dynamodb.ScanOutput{}, nil
+			}}
+			repo := newMockRepository(t, db)
+
+			if _, _, err := repo.GetAllUsers(context.Background(), ListQuery{NameContains: tt.nameContains}); err != nil {
+				t.Fatalf("GetAllUsers() error = %v", err)
+			}
+
+			input := db.scanInputs[0]
+			if (input.FilterExpression != nil) != tt.wantFilter {
+				t.Fatalf("FilterExpression = %q, want one %v", aws.StringValue(input.FilterExpression), tt.wantFilter)
+			}
+			if !tt.wantFilter {
+				return
+			}
+			if got := aws.StringValue(input.ExpressionAttributeValues[":nameSearch"].S); got != tt.wantNameSearch {
+				t.Errorf(":nameSearch = %+q, want %+q", got, tt.wantNameSearch)
+			}
+			if got := aws.StringValue(input.ExpressionAttributeValues[":name"].S); got != tt.wantName {
+				t.Errorf(":name = %+q, want %+q", got, tt.wantName)
+			}
+			if got := aws.StringValue(input.ExpressionAttributeNames["#NameSearch"]); got != dynamoDBNameSearchAttribute {
+				t.Errorf("#NameSearch = %q, want %q", got, dynamoDBNameSearchAttribute)
+			}
+		})
+	}
+
+	// The filter matches the lower-cased name stored with every user
+	item, err := newMockRepository(t, nil).marshalUser(User{ID: "user-1", Name: "JoAnna", Email: "jo@example.com"})
+	if err != nil {
+		t.Fatalf("marshalUser() error = %v", err)
+	}
+	if got := aws.StringValue(item[dynamoDBNameSearchAttribute].S); got != "joanna" {
+		t.Errorf("stored %s = %q, want %q", dynamoDBNameSearchAttribute, got, "joanna")
+	}
+}
+
+func TestDynamoDBGetAllUsersCreatedAtIndex(t *testing.T) {
+	base := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	repo := newMockRepository(t, nil)
+	item := func(id string, createdAt time.Time) map[string]*dynamodb.AttributeValue {
+		item, err := repo.marshalUser(User{ID: id, Name: "User " + id, Email: id + "@example.com", CreatedAt: createdAt})
+		if err != nil {
+			t.Fatalf("marshalUser() error = %v", err)
+		}
+		return item
+	}
+	// Oldest first, as the index holds them
+	items := []map[string]*dynamodb.AttributeValue{item("a", base), item("b", base.Add(time.Hour)),
+		item("c", base.Add(2*time.Hour))}
+
+	tests := []struct {
+		name        string
+		index       string
+		query       ListQuery
+		wantQuery   bool
+		wantForward bool
+		wantIDs     []string
+		wantCursor  bool
+	}{
+		{name: "default newest first", index: "created-at-index",
+			query: ListQuery{SortField: "created_at", Descending: true}, wantQuery: true, wantIDs: []string{"c", "b", "a"}},
+		{name: "order overridden to oldest first", index: "created-at-index", query: ListQuery{SortField: "created_at"},
+			wantQuery: true, wantForward: true, wantIDs: []string{"a", "b", "c"}},
+		{name: "page", index: "created-at-index", query: ListQuery{SortField: "created_at", Descending: true, Limit: 2},
+			wantQuery: true, wantIDs: []string{"c", "b"}, wantCursor: true},
+		{name: "sort overridden to name", index: "created-at-index", query: ListQuery{SortField: "name"},
+			wantIDs: []string{"a", "b", "c"}},
+		{name: "no index", query: ListQuery{SortField: "created_at", Descending: true}, wantIDs: []string{"c", "b", "a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DYNAMODB_CREATED_AT_INDEX", tt.index)
+			var queries []dynamodb.QueryInput
+			db := &mockDynamoDB{
+				scan: func(*dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+					return &dynamodb.ScanOutput{Items: items}, nil
+				},
+				query: func(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+					queries = append(queries, *input)
+					ordered := slices.Clone(items)
+					if !aws.BoolValue(input.ScanIndexForward) {
+						slices.Reverse(ordered)
+					}
+					return &dynamodb.QueryOutput{Items: ordered}, nil
+				},
+			}
+			repo.db = db
+
+			users, cursor, err := repo.GetAllUsers(context.Background(), tt.query)
+			if err != nil {
+				t.Fatalf("GetAllUsers() error = %v", err)
+			}
+			if (len(queries) > 0) != tt.wantQuery {
+				t.Fatalf("%d queries, want a query %v", len(queries), tt.wantQuery)
+			}
+			if tt.wantQuery {
+				input := queries[0]
+				if got := aws.StringValue(input.IndexName); got != tt.index {
+					t.Errorf("IndexName = %q, want %q", got, tt.index)
+				}
+				if input.ScanIndexForward == nil || *input.ScanIndexForward != tt.wantForward {
+					t.Errorf("ScanIndexForward = %v, want %v", aws.BoolValue(input.ScanIndexForward), tt.wantForward)
+				}
+			}
+
+			ids := make([]string, len(users))
+			for i, user := range users {
+				ids[i] = user.ID
+			}
+			if !slices.Equal(ids, tt.wantIDs) {
+				t.Errorf("ids = %v, want %v", ids, tt.wantIDs)
+			}
+			if (cursor != "") != tt.wantCursor {
+				t.Errorf("cursor = %q, want one %v", cursor, tt.wantCursor)
+			}
+		})
+	}
+}
+
+func TestDynamoDBValidateSchema(t *testing.T) {
+	errDescribe := errors.New("access denied")
+	keySchema := func(hash string) []*dynamodb.KeySchemaElement {
+		return []*dynamodb.KeySchemaElement{
+			{AttributeName: aws.String("Tenant"), KeyType: aws.String(dynamodb.KeyTypeRange)},
+			{AttributeName: aws.String(hash), KeyType: aws.String(dynamodb.KeyTypeHash)},
+		}
+	}
+
+	tests := []struct {
+		name         string
+		keyAttribute string
+		keySchema    []*dynamodb.KeySchemaElement
+		describeErr  error
+		wantErr      string
+	}{
+		{name: "matching partition key", keySchema: keySchema("ID")},
+		{
+			name:      "mismatched partition key",
+			keySchema: keySchema("id"),
+			wantErr:   `DynamoDB table users has partition key "id", expected "ID"; set DYNAMODB_KEY_ATTRIBUTE`,
+		},
+		{name: "configured partition key", keyAttribute: "id", keySchema: keySchema("id")},
+		{
+			name:      "no partition key",
+			keySchema: []*dynamodb.KeySchemaElement{},
+			wantErr:   "DynamoDB table users has no partition key",
+		},
+		{
+			name:        "describe failure",
+			describeErr: errDescribe,
+			wantErr:     "failed to describe DynamoDB table: access denied",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db := &mockDynamoDB{
+				describeTable: func(input *dynamodb.DescribeTableInput) (*dynamodb.DescribeTableOutput, error) {
+					if aws.StringValue(input.TableName) != "users" {
+						t.Errorf("DescribeTable(%q), want users", aws.StringValue(input.TableName))
+					}
+					if tt.describeErr != nil {
+						return nil, tt.describeErr
+					}
+					return &dynamodb.DescribeTableOutput{Table: &dynamodb.TableDescription{KeySchema: tt.keySchema}}, nil
+				},
+			}
+			repo := newMockRepository(t, db)
+			if tt.keyAttribute != "" {
+				repo.keyAttribute = tt.keyAttribute
+			}
+
+			err := repo.ValidateSchema(context.Background())
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("ValidateSchema() error = %v, want nil", err)
+				}
+				return
+			}
+			if err == nil || err.Error() != tt.wantErr {
+				t.Errorf("ValidateSchema() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// fakeUsersTable backs a mockDynamoDB with a map of items by key. It evaluates the
+// conditions the repository writes, applies transactions all or nothing and once per client
+// request token, and implements the projection of gets, the sets and removals of updates
+// and the email filter of scans and queries.
+type fakeUsersTable map[string]map[string]*dynamodb.AttributeValue
+
+func (table fakeUsersTable) mock() *mockDynamoDB {
+	str := func(value *dynamodb.AttributeValue) string {
+		if value == nil {
+			return ""
+		}
+		return aws.StringValue(value.S) + aws.StringValue(value.N)
+	}
+
+	// byEmail matches "#Email = :email" and "#Email IN (:email0, ...)" filters alike, and
+	// returns every item without a filter
+	byEmail := func(values map[string]*dynamodb.AttributeValue) []map[string]*dynamodb.AttributeValue {
+		emails := make(map[string]bool, len(values))
+		for _, value := range values {
+			emails[str(value)] = true
+		}
+
+		var items []map[string]*dynamodb.AttributeValue
+		for _, item := range table {
+			if len(values) == 0 || emails[str(item["Email"])] {
+				items = append(items, item)
+			}
+		}
+		return items
+	}
+
+	holds := func(id string, condition *string, values map[string]*dynamodb.AttributeValue) bool {
+		stored := table[id]
+		switch expression := aws.StringValue(condition); {
+		case expression == "attribute_not_exists(#ID)":
+			return stored == nil
+		case strings.HasPrefix(expression, "attribute_not_exists(#ID) OR "):
+			return stored == nil || str(stored["UserID"]) == str(values[":owner"])
+		case expression == "#Email = :email":
+			return stored != nil && str(stored["Email"]) == str(values[":email"])
+		case strings.HasPrefix(expression, "attribute_exists(#ID)"):
+			version := str(stored["Version"])
+			if version == "" && strings.Contains(expression, "attribute_not_exists(#Version)") {
+				version = "0"
+			}
+			return stored != nil && version == str(values[":expectedVersion"])
+		default:
+			panic("unexpected condition " + expression)
+		}
+	}
+
+	// update sets each attribute from the value named after it, as the repository writes
+	// updates, and removes the attributes after REMOVE
+	update := func(stored map[string]*dynamodb.AttributeValue, expression *string, names map[string]*string,
+		values map[string]*dynamodb.AttributeValue,
+	) {
+		for placeholder, value := range values {
+			if placeholder != ":expectedVersion" {
+				stored[strings.TrimPrefix(placeholder, ":")] = value
+			}
+		}
+		if _, removes, ok := strings.Cut(aws.StringValue(expression), " REMOVE "); ok {
+			for _, placeholder := range strings.Split(removes, ", ") {
+				delete(stored, aws.StringValue(names[placeholder]))
+			}
+		}
+	}
+
+	applied := make(map[string]bool)
+	return &mockDynamoDB{
+		scan: func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+			return &dynamodb.ScanOutput{Items: byEmail(input.ExpressionAttributeValues)}, nil
+		},
+		query: func(input *dynamodb.QueryInput) (*dynamodb.QueryOutput, error) {
+			return &dynamodb.QueryOutput{Items: byEmail(input.ExpressionAttributeValues)}, nil
+		},
+		getItem: func(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+			item := table[str(input.Key["ID"])]
+			if item == nil || input.ProjectionExpression == nil {
+				return &dynamodb.GetItemOutput{Item: item}, nil
+			}
+
+			projected := make(map[string]*dynamodb.AttributeValue)
+			for _, placeholder := range strings.Split(*input.ProjectionExpression, ", ") {
+				name := aws.StringValue(input.ExpressionAttributeNames[placeholder])
+				if value, ok := item[name]; ok {
+					projected[name] = value
+				}
+			}
+			return &dynamodb.GetItemOutput{Item: projected}, nil
+		},
+		transactWriteItems: func(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+			token := aws.StringValue(input.ClientRequestToken)
+			if applied[token] {
+				return &dynamodb.TransactWriteItemsOutput{}, nil
+			}
+
+			reasons := make([]*dynamodb.CancellationReason, len(input.TransactItems))
+			canceled := false
+			for i, item := range input.TransactItems {
+				var id string
+				var condition, returnValues *string
+				var values map[string]*dynamodb.AttributeValue
+				switch {
+				case item.Put != nil:
+					id, condition, values = str(item.Put.Item["ID"]), item.Put.ConditionExpression, item.Put.ExpressionAttributeValues
+					returnValues = item.Put.ReturnValuesOnConditionCheckFailure
+				case item.Delete != nil:
+					id, condition, values = str(item.Delete.Key["ID"]), item.Delete.ConditionExpression, item.Delete.ExpressionAttributeValues
+					returnValues = item.Delete.ReturnValuesOnConditionCheckFailure
+				case item.Update != nil:
+					id, condition, values = str(item.Update.Key["ID"]), item.Update.ConditionExpression, item.Update.ExpressionAttributeValues
+					returnValues = item.Update.ReturnValuesOnConditionCheckFailure
+				}
+
+				reasons[i] = &dynamodb.CancellationReason{Code: aws.String("None")}
+				if !holds(id, condition, values) {
+					reasons[i].Code = aws.String("ConditionalCheckFailed")
+					if returnValues != nil {
+						reasons[i].Item = table[id]
+					}
+					canceled = true
+				}
+			}
+			if canceled {
+				return nil, &dynamodb.TransactionCanceledException{CancellationReasons: reasons}
+			}
+
+			for _, item := range input.TransactItems {
+				switch {
+				case item.Put != nil:
+					table[str(item.Put.Item["ID"])] = item.Put.Item
+				case item.Delete != nil:
+					delete(table, str(item.Delete.Key["ID"]))
+				case item.Update != nil:
+					update(table[str(item.Update.Key["ID"])], item.Update.UpdateExpression,
+						item.Update.ExpressionAttributeNames, item.Update.ExpressionAttributeValues)
+				}
+			}
+			applied[token] = true
+			return &dynamodb.TransactWriteItemsOutput{}, nil
+		},
+	}
+}
+
+func TestDynamoDBCreateUserDuplicateEmail(t *testing.T) {
+	tests := []struct {
+		name       string
+		second     User
+		wantErr    error
+		wantStored []string
+	}{
+		{
+			name:       "duplicate email",
+			second:     User{ID: "user-2", Name: "Other", Email: "ann@example.com"},
+			wantErr:    ErrDuplicateEmail,
+			wantStored: []string{"EMAIL#ann@example.com", "user-1"},
+		},
+		{
+			name:       "duplicate ID",
+			second:     User{ID: "user-1", Name: "Other", Email: "other@example.com"},
+			wantErr:    ErrDuplicateID,
+			wantStored: []string{"EMAIL#ann@example.com", "user-1"},
+		},
+		{
+			name:       "another email",
+			second:     User{ID: "user-2", Name: "Other", Email: "other@example.com"},
+			wantStored: []string{"EMAIL#ann@example.com", "EMAIL#other@example.com", "user-1", "user-2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := fakeUsersTable{}
+			db := table.mock()
+			repo := newMockRepository(t, db)
+			ctx := context.Background()
+
+			if _, err := repo.CreateUser(ctx, User{ID: "user-1", Name: "Ann", Email: "ann@example.com"}); err != nil {
+				t.Fatalf("first CreateUser() error = %v", err)
+			}
+			_, err := repo.CreateUser(ctx, tt.second)
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("second CreateUser() error = %v, want %v", err, tt.wantErr)
+			}
+			if db.transactions != 2 || len(db.scanInputs) != 0 {
+				t.Errorf("%d transactions and %d scans, want 2 and none", db.transactions, len(db.scanInputs))
+			}
+			if stored := table.keys(); !reflect.DeepEqual(stored, tt.wantStored) {
+				t.Errorf("stored keys = %v, want %v", stored, tt.wantStored)
+			}
+		})
+	}
+}
+
+// keys returns the sorted keys of the table's items.
+func (table fakeUsersTable) keys() []string {
+	keys := make([]string, 0, len(table))
+	for key := range table {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
+
+func TestDynamoDBEmailSentinels(t *testing.T) {
+	table := fakeUsersTable{}
+	repo := newMockRepository(t, table.mock())
+	ctx := context.Background()
+
+	if _, err := repo.CreateUser(ctx, User{ID: "user-1", Name: "Ann", Email: "ann@example.com"}); err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if owner := table["EMAIL#ann@example.com"]["UserID"]; owner == nil || aws.StringValue(owner.S) != "user-1" {
+		t.Fatalf("sentinel owner = %v, want user-1", owner)
+	}
+
+	if _, err := repo.GetUserByID(ctx, "EMAIL#ann@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("GetUserByID(sentinel) error = %v, want %v", err, ErrUserNotFound)
+	}
+	if err := repo.DeleteUser(ctx, "EMAIL#ann@example.com"); !errors.Is(err, ErrUserNotFound) {
+		t.Errorf("DeleteUser(sentinel) error = %v, want %v", err, ErrUserNotFound)
+	}
+	users, _, err := repo.GetAllUsers(ctx, ListQuery{})
+	if err != nil || len(users) != 1 || users[0].ID != "user-1" {
+		t.Errorf("GetAllUsers() = %v, %v; want only user-1", users, err)
+	}
+
+	if err := repo.DeleteUser(ctx, "user-1"); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+	if stored := table.keys(); len(stored) != 0 {
+		t.Errorf("stored keys after delete = %v, want none", stored)
+	}
+	if _, err := repo.CreateUser(ctx, User{ID: "user-2", Name: "Ann", Email: "ann@example.com"}); err != nil {
+		t.Fatalf("CreateUser() with the released email error = %v", err)
+	}
+
+	// A user written before sentinels may share the email of another, which holds the sentinel
+	legacy, err := repo.marshalUser(User{ID: "legacy", Name: "Old", Email: "ann@example.com", Version: 1})
+	if err != nil {
+		t.Fatalf("marshalUser() error = %v", err)
+	}
+	table["legacy"] = legacy
+	if err := repo.DeleteUser(ctx, "legacy"); err != nil {
+		t.Fatalf("DeleteUser(legacy) error = %v", err)
+	}
+	if stored := table.keys(); !reflect.DeepEqual(stored, []string{"EMAIL#ann@example.com", "user-2"}) {
+		t.Errorf("stored keys after the legacy delete = %v, want user-2 and its sentinel", stored)
+	}
+
+	// Updating a user written before sentinels claims its email
+	legacy, err = repo.marshalUser(User{ID: "legacy", Name: "Old", Email: "old@example.com", Version: 1})
+	if err != nil {
+		t.Fatalf("marshalUser() error = %v", err)
+	}
+	table["legacy"] = legacy
+	if _, err := repo.UpdateUser(ctx, User{ID: "legacy", Name: "Older", Email: "old@example.com", Version: 1}); err != nil {
+		t.Fatalf("UpdateUser(legacy) error = %v", err)
+	}
+	if owner := table["EMAIL#old@example.com"]["UserID"]; owner == nil || aws.StringValue(owner.S) != "legacy" {
+		t.Errorf("sentinel owner after the legacy update = %v, want legacy", owner)
+	}
+}
+
+func TestDynamoDBProjectionMatchesInMemory(t *testing.T) {
+	createdAt := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	user := User{
+		ID: "user-1", Name: "Ann", Email: "ann@example.com", CreatedAt: createdAt,
+		Metadata: map[string]string{"team": "a"},
+	}
+
+	tests := []struct {
+		name    string
+		fields  string
+		wantErr bool
+	}{
+		{name: "id only", fields: "id"},
+		{name: "name", fields: "name"},
+		{name: "name and email", fields: "email,name"},
+		{name: "timestamps", fields: "created_at,updated_at"},
+		{name: "metadata and version", fields: "metadata,version"},
+		{name: "every field", fields: "id,name,email,created_at,updated_at,avatar_url,version,metadata"},
+		{name: "unknown field", fields: "name,password", wantErr: true},
+	}
+
+	ClearInMemoryUsers()
+	t.Cleanup(ClearInMemoryUsers)
+	ctx := context.Background()
+	memory := NewInMemoryUserRepository().(ProjectedReader)
+	if _, err := memory.(UserRepository).CreateUser(ctx, user); err != nil {
+		t.Fatalf("in-memory CreateUser() error = %v", err)
+	}
+	dynamo := newMockRepository(t, fakeUsersTable{}.mock())
+	if _, err := dynamo.CreateUser(ctx, user); err != nil {
+		t.Fatalf("DynamoDB CreateUser() error = %v", err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			projection, err := ParseProjection(tt.fields)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseProjection(%q) error = nil, want an error", tt.fields)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseProjection(%q) error = %v", tt.fields, err)
+			}
+
+			fromMemory, err := memory.GetUserByIDProjected(ctx, user.ID, projection)
+			if err != nil {
+				t.Fatalf("in-memory GetUserByIDProjected() error = %v", err)
+			}
+			fromDynamoDB, err := dynamo.GetUserByIDProjected(ctx, user.ID, projection)
+			if err != nil {
+				t.Fatalf("DynamoDB GetUserByIDProjected() error = %v", err)
+			}
+			if !reflect.DeepEqual(fromDynamoDB, fromMemory) {
+				t.Errorf("DynamoDB projection = %+v, in-memory projection = %+v", fromDynamoDB, fromMemory)
+			}
+			if fromMemory.ID != user.ID {
+				t.Errorf("projected id = %q, want %q", fromMemory.ID, user.ID)
+			}
+		})
+	}
+}
+
+// blockingDynamoDB never answers: each call blocks until its context is done and then fails
+// the way the SDK does.
+type blockingDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+}
+
+func (blockingDynamoDB) wait(ctx aws.Context) error {
+	<-ctx.Done()
+
+	return awserr.New(request.CanceledErrorCode, "request context canceled", ctx.Err())
+}
+
+func (b blockingDynamoDB) GetItemWithContext(
+	ctx aws.Context, _ *dynamodb.GetItemInput, _ ...request.Option,
+) (*dynamodb.GetItemOutput, error) {
+	return nil, b.wait(ctx)
+}
+
+func (b blockingDynamoDB) TransactWriteItemsWithContext(
+	ctx aws.Context, _ *dynamodb.TransactWriteItemsInput, _ ...request.Option,
+) (*dynamodb.TransactWriteItemsOutput, error) {
+	return nil, b.wait(ctx)
+}
+
+func (b blockingDynamoDB) ScanWithContext(
+	ctx aws.Context, _ *dynamodb.ScanInput, _ ...request.Option,
+) (*dynamodb.ScanOutput, error) {
+	return nil, b.wait(ctx)
+}
+
+func (b blockingDynamoDB) ScanPagesWithContext(
+	ctx aws.Context, _ *dynamodb.ScanInput, _ func(*dynamodb.ScanOutput, bool) bool, _ ...request.Option,
+) error {
+	return b.wait(ctx)
+}
+
+func TestDynamoDBContextCancellation(t *testing.T) {
+	user := User{ID: "user-1", Name: "Ann", Email: "ann@example.com", Version: 1}
+	operations := map[string]func(context.Context, *dynamoDBUserRepository) error{
+		"GetUserByID": func(ctx context.Context, repo *dynamoDBUserRepository) error {
+			_, err := repo.GetUserByID(ctx, user.ID)
+			return err
+		},
+		"CreateUser": func(ctx context.Context, repo *dynamoDBUserRepository) error {
+			_, err := repo.CreateUser(ctx, user)
+			return err
+		},
+		"GetAllUsers": func(ctx context.Context, repo *dynamoDBUserRepository) error {
+			_, _, err := repo.GetAllUsers(ctx, ListQuery{})
+			return err
+		},
+		"UpdateUser": func(ctx context.Context, repo *dynamoDBUserRepository) error {
+			_, err := repo.UpdateUser(ctx, user)
+			return err
+		},
+		"DeleteUser": func(ctx context.Context, repo *dynamoDBUserRepository) error {
+			return repo.DeleteUser(ctx, user.ID)
+		},
+	}
+
+	tests := []struct {
+		name    string
+		context func() (context.Context, context.CancelFunc)
+		wantErr error
+	}{
+		{
+			name: "cancelled",
+			context: func() (context.Context, context.CancelFunc) {
+				ctx, cancel := context.WithCancel(context.Background())
+				cancel()
+				return ctx, cancel
+			},
+			wantErr: context.Canceled,
+		},
+		{
+			name: "deadline",
+			context: func() (context.Context, context.CancelFunc) {
+				return context.WithTimeout(context.Background(), 20*time.Millisecond)
+			},
+			wantErr: context.DeadlineExceeded,
+		},
+	}
+
+	for _, tt := range tests {
+		for operation, call := range operations {
+			t.Run(tt.name+" "+operation, func(t *testing.T) {
+				repo := newMockRepository(t, blockingDynamoDB{})
+				ctx, cancel := tt.context()
+				defer cancel()
+
+				start := time.Now()
+				err := call(ctx, repo)
+				if !errors.Is(err, tt.wantErr) {
+					t.Errorf("error = %v, want %v", err, tt.wantErr)
+				}
+				if elapsed := time.Since(start); elapsed > time.Second {
+					t.Errorf("returned after %s, want promptly", elapsed)
+				}
+			})
+		}
+	}
+}
+
+func TestDynamoDBCreateUsersTransaction(t *testing.T) {
+	existing := User{ID: "existing", Name: "Existing", Email: "taken@example.com"}
+	ann := User{ID: "user-1", Name: "Ann", Email: "ann@example.com"}
+	bob := User{ID: "user-2", Name: "Bob", Email: "bob@example.com"}
+	unchanged := []string{"EMAIL#taken@example.com", "existing"}
+
+	tests := []struct {
+		name             string
+		users            []User
+		wantErr          error
+		wantErrText      string
+		wantTransactions int
+		wantStored       []string
+	}{
+		{
+			name:             "all created with their sentinels",
+			users:            []User{ann, bob},
+			wantTransactions: 1,
+			wantStored: []string{
+				"EMAIL#ann@example.com", "EMAIL#bob@example.com", "EMAIL#taken@example.com", "existing", "user-1", "user-2",
+			},
+		},
+		{
+			name:             "taken ID aborts the whole transaction",
+			users:            []User{ann, {ID: "existing", Name: "Other", Email: "other@example.com"}, bob},
+			wantErr:          ErrDuplicateID,
+			wantErrText:      "user 2: ",
+			wantTransactions: 1,
+			wantStored:       unchanged,
+		},
+		{
+			name:             "taken email aborts the whole transaction",
+			users:            []User{ann, {ID: "user-3", Name: "Other", Email: "taken@example.com"}},
+			wantErr:          ErrDuplicateEmail,
+			wantErrText:      "user 2: ",
+			wantTransactions: 1,
+			wantStored:       unchanged,
+		},
+		{
+			name:        "ID repeated within the batch",
+			users:       []User{ann, {ID: "user-1", Name: "Other", Email: "other@example.com"}},
+			wantErr:     ErrDuplicateID,
+			wantErrText: "user 2: ",
+			wantStored:  unchanged,
+		},
+		{
+			name:        "email repeated within the batch",
+			users:       []User{ann, {ID: "user-3", Name: "Other", Email: "ann@example.com"}},
+			wantErr:     ErrDuplicateEmail,
+			wantErrText: "user 2: ",
+			wantStored:  unchanged,
+		},
+		{name: "empty batch", wantErr: ErrEmptyBatch, wantStored: unchanged},
+		{
+			name:       "batch too large",
+			users:      make([]User, MaxBatchCreateSize+1),
+			wantErr:    ErrBatchTooLarge,
+			wantStored: unchanged,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := fakeUsersTable{}
+			db := table.mock()
+			repo := newMockRepository(t, db)
+			ctx := context.Background()
+			if _, err := repo.CreateUser(ctx, existing); err != nil {
+				t.Fatalf("CreateUser() error = %v", err)
+			}
+			db.transactions = 0
+
+			created, err := repo.CreateUsers(ctx, tt.users)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("CreateUsers() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil && !strings.HasPrefix(err.Error(), tt.wantErrText) {
+				t.Errorf("CreateUsers() error = %q, want it to start with %q", err, tt.wantErrText)
+			}
+			if err == nil && len(created) != len(tt.users) {
+				t.Errorf("%d users created, want %d", len(created), len(tt.users))
+			}
+			if db.transactions != tt.wantTransactions {
+				t.Errorf("%d transactions, want %d", db.transactions, tt.wantTransactions)
+			}
+			if stored := table.keys(); !reflect.DeepEqual(stored, tt.wantStored) {
+				t.Errorf("stored keys = %v, want %v", stored, tt.wantStored)
+			}
+		})
+	}
+}
+
+func TestDynamoDBSentinelErrors(t *testing.T) {
+	testSentinelErrors(t, func(t *testing.T) UserRepository {
+		repo := newMockRepository(t, fakeUsersTable{}.mock())
+		ann := User{ID: "user-1", Name: "Ann", Email: "ann@example.com"}
+		if _, err := repo.CreateUser(context.Background(), ann); err != nil {
+			t.Fatalf("seeding user-1: %v", err)
+		}
+
+		return repo
+	})
+}
+
+func TestDynamoDBUpdateUserStaleVersion(t *testing.T) {
+	table := fakeUsersTable{}
+	repo := newMockRepository(t, table.mock())
+	ctx := context.Background()
+
+	read, err := repo.CreateUser(ctx, User{ID: "user-1", Name: "Ann", Email: "ann@example.com"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		userName    string
+		wantErr     error
+		wantName    string
+		wantVersion int
+	}{
+		{name: "first writer", userName: "Ann Lee", wantName: "Ann Lee", wantVersion: 2},
+		{name: "second writer with the same read", userName: "Annie", wantErr: ErrVersionConflict, wantName: "Ann Lee",
+			wantVersion: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			update := read
+			update.Name = tt.userName
+			if _, err := repo.UpdateUser(ctx, update); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateUser() error = %v, want %v", err, tt.wantErr)
+			}
+
+			stored, err := repo.GetUserByID(ctx, "user-1")
+			if err != nil {
+				t.Fatalf("GetUserByID() error = %v", err)
+			}
+			if stored.Name != tt.wantName || stored.Version != tt.wantVersion {
+				t.Errorf("stored %q at version %d, want %q at version %d", stored.Name, stored.Version, tt.wantName,
+					tt.wantVersion)
+			}
+		})
+	}
+}
+
+func TestDynamoDBUpdateUserDuplicateEmail(t *testing.T) {
+	tests := []struct {
+		name       string
+		email      string
+		wantErr    error
+		wantEmail  string
+		wantStored []string
+	}{
+		{
+			name:       "another user's email",
+			email:      "bob@example.com",
+			wantErr:    ErrDuplicateEmail,
+			wantEmail:  "ann@example.com",
+			wantStored: []string{"EMAIL#ann@example.com", "EMAIL#bob@example.com", "user-1", "user-2"},
+		},
+		{
+			name:       "unused email moves the sentinel",
+			email:      "other@example.com",
+			wantEmail:  "other@example.com",
+			wantStored: []string{"EMAIL#bob@example.com", "EMAIL#other@example.com", "user-1", "user-2"},
+		},
+		{
+			name:       "same email",
+			email:      "ann@example.com",
+			wantEmail:  "ann@example.com",
+			wantStored: []string{"EMAIL#ann@example.com", "EMAIL#bob@example.com", "user-1", "user-2"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := fakeUsersTable{}
+			db := table.mock()
+			repo := newMockRepository(t, db)
+			ctx := context.Background()
+
+			ann, err := repo.CreateUser(ctx, User{ID: "user-1", Name: "Ann", Email: "ann@example.com"})
+			if err != nil {
+				t.Fatalf("CreateUser() error = %v", err)
+			}
+			if _, err := repo.CreateUser(ctx, User{ID: "user-2", Name: "Bob", Email: "bob@example.com"}); err != nil {
+				t.Fatalf("CreateUser() error = %v", err)
+			}
+
+			ann.Name = "Ann Lee"
+			ann.Email = tt.email
+			if _, err := repo.UpdateUser(ctx, ann); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateUser() error = %v, want %v", err, tt.wantErr)
+			}
+			if len(db.scanInputs) != 0 {
+				t.Errorf("%d scans, want the sentinels to need none", len(db.scanInputs))
+			}
+
+			stored, err := repo.GetUserByID(ctx, "user-1")
+			if err != nil {
+				t.Fatalf("GetUserByID() error = %v", err)
+			}
+			if stored.Email != tt.wantEmail {
+				t.Errorf("stored email %q, want %q", stored.Email, tt.wantEmail)
+			}
+			if keys := table.keys(); !reflect.DeepEqual(keys, tt.wantStored) {
+				t.Errorf("stored keys = %v, want %v", keys, tt.wantStored)
+			}
+		})
+	}
+}
+
+func TestDynamoDBUpdateUserClearsOptionalFields(t *testing.T) {
+	repo := newMockRepository(t, fakeUsersTable{}.mock())
+	ctx := context.Background()
+
+	user, err := repo.CreateUser(ctx, User{ID: "user-1", Name: "Ann", Email: "ann@example.com"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		metadata  map[string]string
+		avatarURL string
+	}{
+		{name: "set", metadata: map[string]string{"plan": "pro"}, avatarURL: "https://cdn.example.com/ann.png"},
+		{name: "cleared to empty", metadata: map[string]string{}},
+		{name: "set again", metadata: map[string]string{"plan": "free"}, avatarURL: "https://cdn.example.com/a.png"},
+		{name: "cleared to nil"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			user.Metadata = tt.metadata
+			user.AvatarURL = tt.avatarURL
+			updated, err := repo.UpdateUser(ctx, user)
+			if err != nil {
+				t.Fatalf("UpdateUser() error = %v", err)
+			}
+			user = updated
+
+			stored, err := repo.GetUserByID(ctx, "user-1")
+			if err != nil {
+				t.Fatalf("GetUserByID() error = %v", err)
+			}
+			for _, got := range []User{updated, stored} {
+				if len(got.Metadata) != len(tt.metadata) || (len(tt.metadata) > 0 && !reflect.DeepEqual(got.Metadata, tt.metadata)) {
+					t.Errorf("metadata = %v, want %v", got.Metadata, tt.metadata)
+				}
+				if got.AvatarURL != tt.avatarURL {
+					t.Errorf("avatar URL = %q, want %q", got.AvatarURL, tt.avatarURL)
+				}
+			}
+		})
+	}
+}
+
+func TestDynamoDBBulkCreateUsers(t *testing.T) {
+	users := []User{
+		{ID: "user-1", Name: "Ann", Email: "ann@example.com"},
+		{ID: "existing", Name: "Taken", Email: "new@example.com"},
+		{ID: "user-2", Name: "Taken", Email: "taken@example.com"},
+		{ID: "user-3", Name: "Repeat", Email: "ann@example.com"},
+		{ID: "user-4", Name: "Bob", Email: "bob@example.com"},
+	}
+	wantErrs := map[int]error{1: ErrDuplicateID, 2: ErrDuplicateEmail, 3: ErrDuplicateEmail}
+
+	table := fakeUsersTable{}
+	db := table.mock()
+	repo := newMockRepository(t, db)
+	ctx := context.Background()
+	if _, err := repo.CreateUser(ctx, User{ID: "existing", Name: "Existing", Email: "taken@example.com"}); err != nil {
+		t.Fatalf("seeding the existing user: %v", err)
+	}
+	db.transactions = 0
+
+	// Each user is created with its sentinel in a conditional transaction of its own
+	results := BulkCreateUsers(ctx, repo, users)
+	for i, result := range results {
+		if !errors.Is(result.Err, wantErrs[i]) {
+			t.Errorf("result %d error = %v, want %v", i, result.Err, wantErrs[i])
+		}
+		if result.Err == nil && result.User.ID != users[i].ID {
+			t.Errorf("result %d is user %q, want %q", i, result.User.ID, users[i].ID)
+		}
+	}
+	if db.transactions != len(users) {
+		t.Errorf("%d transactions, want %d", db.transactions, len(users))
+	}
+	want := []string{
+		"EMAIL#ann@example.com", "EMAIL#bob@example.com", "EMAIL#taken@example.com", "existing", "user-1", "user-4",
+	}
+	if stored := table.keys(); !reflect.DeepEqual(stored, want) {
+		t.Errorf("stored keys = %v, want %v", stored, want)
+	}
+}
+
+func TestDynamoDBDeleteAllUsers(t *testing.T) {
+	tests := []struct {
+		name            string
+		users           int
+		unprocessedOnce bool
+		wantBatchWrites []int
+	}{
+		{name: "empty table", users: 0},
+		{name: "one batch", users: 3, wantBatchWrites: []int{6}},
+		{name: "split into batches of 25", users: 30, wantBatchWrites: []int{25, 25, 10}},
+		{name: "unprocessed item retried", users: 3, unprocessedOnce: true, wantBatchWrites: []int{6, 1}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := fakeUsersTable{}
+			for i := range tt.users {
+				id := fmt.Sprintf("user-%02d", i)
+				table[id] = map[string]*dynamodb.AttributeValue{"ID": {S: aws.String(id)}, "Name": {S: aws.String("User")}}
+				// Each user's email sentinel is deleted with it but not counted
+				sentinel := "EMAIL#" + id + "@example.com"
+				table[sentinel] = map[string]*dynamodb.AttributeValue{"ID": {S: aws.String(sentinel)}, "UserID": {S: aws.String(id)}}
+			}
+			db := table.mock()
+			db.scan = func(input *dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+				if aws.StringValue(input.ProjectionExpression) != "#ID" {
+					t.Errorf("scan projection = %q, want only the key", aws.StringValue(input.ProjectionExpression))
+				}
+				output := &dynamodb.ScanOutput{}
+				for id := range table {
+					output.Items = append(output.Items, map[string]*dynamodb.AttributeValue{"ID": {S: aws.String(id)}})
+				}
+				return output, nil
+			}
+			unprocessed := tt.unprocessedOnce
+			db.batchWriteItem = func(input *dynamodb.BatchWriteItemInput) (*dynamodb.BatchWriteItemOutput, error) {
+				requests := input.RequestItems["users"]
+				output := &dynamodb.BatchWriteItemOutput{}
+				if unprocessed {
+					unprocessed = false
+					output.UnprocessedItems = map[string][]*dynamodb.WriteRequest{"users": requests[len(requests)-1:]}
+					requests = requests[:len(requests)-1]
+				}
+				for _, request := range requests {
+					delete(table, aws.StringValue(request.DeleteRequest.Key["ID"].S))
+				}
+				return output, nil
+			}
+			repo := newMockRepository(t, db)
+
+			deleted, err := repo.DeleteAllUsers(context.Background())
+			if err != nil {
+				t.Fatalf("DeleteAllUsers() error = %v", err)
+			}
+			if deleted != tt.users {
+				t.Errorf("DeleteAllUsers() = %d, want %d", deleted, tt.users)
+			}
+			if len(table) != 0 {
+				t.Errorf("%d items left, want none", len(table))
+			}
+			if !reflect.DeepEqual(db.batchWrites, tt.wantBatchWrites) {
+				t.Errorf("BatchWriteItem sizes = %v, want %v", db.batchWrites, tt.wantBatchWrites)
+			}
+		})
+	}
+}
+
+func TestDynamoDBKeyAttribute(t *testing.T) {
+	tests := []struct {
+		name         string
+		keyAttribute string
+		want         string
+	}{
+		{name: "default", want: DefaultDynamoDBKeyAttribute},
+		{name: "configured", keyAttribute: "pk", want: "pk"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DYNAMODB_KEY_ATTRIBUTE", tt.keyAttribute)
+			t.Setenv("DYNAMODB_EMAIL_INDEX", "")
+
+			// keys collects the key attribute names of each call, and names what #ID stands for
+			var keys [][]string
+			var names []string
+			keyOf := func(key map[string]*dynamodb.AttributeValue) {
+				attributes := make([]string, 0, len(key))
+				for name := range key {
+					attributes = append(attributes, name)
+				}
+				keys = append(keys, attributes)
+			}
+			nameOf := func(expressionNames map[string]*string) {
+				if name, ok := expressionNames["#ID"]; ok {
+					names = append(names, aws.StringValue(name))
+				}
+			}
+			stored := map[string]*dynamodb.AttributeValue{
+				tt.want:   {S: aws.String("user-1")},
+				"Name":    {S: aws.String("Ann")},
+				"Email":   {S: aws.String("ann@example.com")},
+				"Version": {N: aws.String("1")},
+			}
+			db := &mockDynamoDB{
+				getItem: func(input *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+					keyOf(input.Key)
+					return &dynamodb.GetItemOutput{Item: stored}, nil
+				},
+				transactWriteItems: func(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+					for _, item := range input.TransactItems {
+						switch {
+						case item.Put != nil:
+							nameOf(item.Put.ExpressionAttributeNames)
+							if _, ok := item.Put.Item[tt.want]; !ok {
+								t.Errorf("put item %v has no %s attribute", item.Put.Item, tt.want)
+							}
+							if _, ok := item.Put.Item["ID"]; ok && tt.want != "ID" {
+								t.Errorf("put item %v also has an ID attribute", item.Put.Item)
+							}
+						case item.Delete != nil:
+							keyOf(item.Delete.Key)
+							nameOf(item.Delete.ExpressionAttributeNames)
+						case item.Update != nil:
+							keyOf(item.Update.Key)
+							nameOf(item.Update.ExpressionAttributeNames)
+						}
+					}
+					return &dynamodb.TransactWriteItemsOutput{}, nil
+				},
+				scan: func(*dynamodb.ScanInput) (*dynamodb.ScanOutput, error) {
+					return &dynamodb.ScanOutput{}, nil
+				},
+			}
+			repo := NewDynamoDBUserRepository(db, "users")
+			ctx := context.Background()
+
+			user, err := repo.GetUserByID(ctx, "user-1")
+			if err != nil || user.ID != "user-1" {
+				t.Fatalf("GetUserByID() = %q, %v; want user-1", user.ID, err)
+			}
+			if _, err := repo.UpdateUser(ctx, user); err != nil {
+				t.Fatalf("UpdateUser() error = %v", err)
+			}
+			if err := repo.DeleteUser(ctx, "user-1"); err != nil {
+				t.Fatalf("DeleteUser() error = %v", err)
+			}
+			if _, err := repo.CreateUser(ctx, User{ID: "user-2", Name: "Bob", Email: "bob@example.com"}); err != nil {
+				t.Fatalf("CreateUser() error = %v", err)
+			}
+
+			for i, attributes := range keys {
+				if !reflect.DeepEqual(attributes, []string{tt.want}) {
+					t.Errorf("call %d keyed by %v, want [%s]", i, attributes, tt.want)
+				}
+			}
+			for i, name := range names {
+				if name != tt.want {
+					t.Errorf("condition %d names #ID %q, want %q", i, name, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDynamoDBRetryTransientWrites(t *testing.T) {
+	throttled := func() error {
+		return awserr.New(dynamodb.ErrCodeProvisionedThroughputExceededException, "throttled", nil)
+	}
+	unavailable := func() error {
+		return awserr.NewRequestFailure(awserr.New("ServiceUnavailable", "unavailable", nil), 503, "request-1")
+	}
+	invalid := func() error {
+		return awserr.NewRequestFailure(awserr.New("ValidationException", "invalid", nil), 400, "request-1")
+	}
+
+	tests := []struct {
+		name         string
+		maxRetries   string
+		failures     int
+		failure      func() error
+		applyFailing bool
+		wantErr      bool
+		wantAttempts int
+	}{
+		{name: "throttled twice then succeeds", failures: 2, failure: throttled, wantAttempts: 3},
+		{name: "5xx twice then succeeds", failures: 2, failure: unavailable, wantAttempts: 3},
+		{name: "out of retries", maxRetries: "1", failures: 2, failure: throttled, wantErr: true, wantAttempts: 2},
+		{name: "client error is not retried", failures: 1, failure: invalid, wantErr: true, wantAttempts: 1},
+		{
+			name:         "failed attempt that was applied",
+			failures:     1,
+			failure:      unavailable,
+			applyFailing: true,
+			wantAttempts: 2,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DDB_MAX_RETRIES", tt.maxRetries)
+			table := fakeUsersTable{}
+			db := table.mock()
+			transact := db.transactWriteItems
+			db.transactWriteItems = func(input *dynamodb.TransactWriteItemsInput) (*dynamodb.TransactWriteItemsOutput, error) {
+				if db.transactions > tt.failures {
+					return transact(input)
+				}
+				if tt.applyFailing {
+					if _, err := transact(input); err != nil {
+						return nil, err
+					}
+				}
+				return nil, tt.failure()
+			}
+			repo := newMockRepository(t, db)
+
+			user := User{ID: "user-1", Name: "Ann", Email: "ann@example.com", UpdatedAt: time.Now().UTC()}
+			_, err := repo.CreateUser(context.Background(), user)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CreateUser() error = %v, want error %v", err, tt.wantErr)
+			}
+			if db.transactions != tt.wantAttempts {
+				t.Errorf("TransactWriteItems called %d times, want %d", db.transactions, tt.wantAttempts)
+			}
+			if _, stored := table["user-1"]; stored == tt.wantErr {
+				t.Errorf("user stored = %v, want %v", stored, !tt.wantErr)
+			}
+		})
+	}
+}
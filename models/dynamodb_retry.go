@@ -0,0 +1,60 @@
+//go:build dynamodb
+
+package models
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/client"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+
+	"go-lambda-api/utils"
+)
+
+// DefaultDynamoDBMaxRetries is the most times one DynamoDB write is retried when
+// DDB_MAX_RETRIES is unset.
+const DefaultDynamoDBMaxRetries = 3
+
+// isTransientDynamoDBError reports whether err is throttling or a server-side failure that a
+// later attempt may get past.
+func isTransientDynamoDBError(err error) bool {
+	var requestErr awserr.RequestFailure
+	if errors.As(err, &requestErr) && requestErr.StatusCode() >= http.StatusInternalServerError {
+		return true
+	}
+
+	var awsErr awserr.Error
+	if !errors.As(err, &awsErr) {
+		return false
+	}
+	switch awsErr.Code() {
+	case dynamodb.ErrCodeProvisionedThroughputExceededException, dynamodb.ErrCodeRequestLimitExceeded,
+		dynamodb.ErrCodeInternalServerError, "ThrottlingException":
+		return true
+	default:
+		return false
+	}
+}
+
+// withoutSDKRetries turns off the SDK's own retries for one call. Writes pass it because
+// retryWrite retries them, and the two would otherwise multiply.
+var withoutSDKRetries request.Option = func(r *request.Request) {
+	r.Retryer = client.NoOpRetryer{}
+}
+
+// retryWrite runs a DynamoDB write, retrying transient errors with backoff up to
+// DDB_MAX_RETRIES times within the request's retry budget. op must return the SDK's error
+// unwrapped, or at least wrapped with %w, so it can be classified, and must make its call
+// with withoutSDKRetries.
+func retryWrite(ctx context.Context, op func() error) error {
+	return Retry(ctx, dynamoDBMaxRetries(), isTransientDynamoDBError, op)
+}
+
+// dynamoDBMaxRetries returns DDB_MAX_RETRIES, defaulting to DefaultDynamoDBMaxRetries.
+func dynamoDBMaxRetries() int {
+	return utils.GetEnvInt("DDB_MAX_RETRIES", DefaultDynamoDBMaxRetries)
+}
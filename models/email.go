@@ -0,0 +1,163 @@
+package models
+
+import (
+	"fmt"
+	"net/mail"
+	"os"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+
+	"go-lambda-api/utils"
+)
+
+// Email normalization policies, selected with EMAIL_NORMALIZATION.
+const (
+	// EmailNormalizeDomain lowercases only the domain; local parts are case-sensitive per RFC 5321.
+	EmailNormalizeDomain = "domain"
+	// EmailNormalizeLowercase lowercases the whole address, so "A@x.com" and "a@x.com" are the same user.
+	EmailNormalizeLowercase = "lowercase"
+	// EmailNormalizeNone stores and compares addresses exactly as given.
+	EmailNormalizeNone = "none"
+)
+
+const (
+	// DefaultEmailMaxLength is the maximum email length when EMAIL_MAX_LENGTH is unset.
+	DefaultEmailMaxLength = 320
+	// MaxEmailLocalLength is the RFC 5321 limit on the part before the "@".
+	MaxEmailLocalLength = 64
+	// MaxEmailDomainLength is the RFC 5321 limit on the part after the "@".
+	MaxEmailDomainLength = 255
+)
+
+// NormalizeEmail returns email normalized according to the EMAIL_NORMALIZATION policy
+// (default "domain"). It is used for stored values and every uniqueness comparison. Except
+// under "none", internationalized addresses are also put in Unicode NFC, so differently
+// composed spellings of one address are the same user.
+func NormalizeEmail(email string) string {
+	email = strings.TrimSpace(email)
+
+	policy := emailNormalizationPolicy()
+	if policy != EmailNormalizeNone {
+		email = norm.NFC.String(email)
+	}
+
+	switch policy {
+	case EmailNormalizeNone:
+		return email
+	case EmailNormalizeLowercase:
+		return strings.ToLower(email)
+	default:
+		at := strings.LastIndex(email, "@")
+		if at < 0 {
+			return email
+		}
+
+		return email[:at] + strings.ToLower(email[at:])
+	}
+}
+
+// SameEmail reports whether two addresses identify the same user under the normalization policy.
+func SameEmail(a, b string) bool {
+	return NormalizeEmail(a) == NormalizeEmail(b)
+}
+
+func emailNormalizationPolicy() string {
+	switch policy := strings.ToLower(os.Getenv("EMAIL_NORMALIZATION")); policy {
+	case EmailNormalizeLowercase, EmailNormalizeNone:
+		return policy
+	default:
+		return EmailNormalizeDomain
+	}
+}
+
+// validateEmail checks that email is a bare address such as "a@example.com". Display names
+// ("Alice <a@example.com>") and anything net/mail cannot parse are rejected, as are addresses
+// over the RFC 5321 length limits, which are counted in bytes.
+func validateEmail(email string) error {
+	maxLength := utils.GetEnvInt("EMAIL_MAX_LENGTH", DefaultEmailMaxLength)
+	if len(email) > maxLength {
+		return &ValidationError{Field: "email", Message: fmt.Sprintf("email must be at most %d characters", maxLength)}
+	}
+	if at := strings.LastIndex(email, "@"); at >= 0 {
+		if len(email[:at]) > MaxEmailLocalLength {
+			return &ValidationError{
+				Field:   "email",
+				Message: fmt.Sprintf("email local part must be at most %d characters", MaxEmailLocalLength),
+			}
+		}
+		if len(email[at+1:]) > MaxEmailDomainLength {
+			return &ValidationError{
+				Field:   "email",
+				Message: fmt.Sprintf("email domain must be at most %d characters", MaxEmailDomainLength),
+			}
+		}
+	}
+
+	address, err := mail.ParseAddress(email)
+	if err != nil || address.Name != "" || address.Address != email {
+		return &ValidationError{Field: "email", Message: "invalid email format", Suggestion: SuggestEmail(email)}
+	}
+
+	return nil
+}
+
+// emailDomainTypos maps misspellings of common mail domains to the intended domain.
+var emailDomainTypos = map[string]string{
+	"gmial.com":   "gmail.com",
+	"gmai.com":    "gmail.com",
+	"gamil.com":   "gmail.com",
+	"gnail.com":   "gmail.com",
+	"hotmial.com": "hotmail.com",
+	"hotmal.com":  "hotmail.com",
+	"yahooo.com":  "yahoo.com",
+	"yaho.com":    "yahoo.com",
+	"outlok.com":  "outlook.com",
+}
+
+// emailTLDTypos maps misspelled top-level domains to the intended one.
+var emailTLDTypos = map[string]string{
+	"con":  "com",
+	"cmo":  "com",
+	"ocm":  "com",
+	"comm": "com",
+	"nte":  "net",
+	"ent":  "net",
+	"ogr":  "org",
+}
+
+// SuggestEmail guesses the address meant by email, fixing obvious typos around the domain:
+// a doubled "@", commas or semicolons for dots, doubled or trailing dots, and misspelled
+// common domains and TLDs. The last two also catch valid addresses such as "a@gmial.com".
+// It returns "" when the fixed address is invalid or the same but for domain case.
+func SuggestEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return ""
+	}
+	local, domain := strings.TrimRight(email[:at], "@"), strings.ToLower(email[at+1:])
+
+	domain = strings.NewReplacer(",", ".", ";", ".").Replace(domain)
+	for strings.Contains(domain, "..") {
+		domain = strings.ReplaceAll(domain, "..", ".")
+	}
+	domain = strings.Trim(domain, ".")
+	if dot := strings.LastIndex(domain, "."); dot >= 0 {
+		if tld, ok := emailTLDTypos[domain[dot+1:]]; ok {
+			domain = domain[:dot+1] + tld
+		}
+	}
+	if fixed, ok := emailDomainTypos[domain]; ok {
+		domain = fixed
+	}
+
+	suggestion := local + "@" + domain
+	if strings.EqualFold(suggestion, email) || !strings.Contains(domain, ".") {
+		return ""
+	}
+	if address, err := mail.ParseAddress(suggestion); err != nil || address.Name != "" || address.Address != suggestion {
+		return ""
+	}
+
+	return suggestion
+}
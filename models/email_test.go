@@ -0,0 +1,165 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy string
+		input  string
+		want   string
+	}{
+		{name: "default lowercases the domain", input: "Ann.Lee@Example.COM", want: "Ann.Lee@example.com"},
+		{name: "domain", policy: EmailNormalizeDomain, input: "A@X.com", want: "A@x.com"},
+		{name: "lowercase", policy: EmailNormalizeLowercase, input: "A@X.com", want: "a@x.com"},
+		{name: "policy is case-insensitive", policy: "LOWERCASE", input: "A@X.com", want: "a@x.com"},
+		{name: "none", policy: EmailNormalizeNone, input: " A@X.com ", want: "A@X.com"},
+		{name: "unknown policy uses domain", policy: "bogus", input: "A@X.com", want: "A@x.com"},
+		{name: "decomposed to NFC", input: "jose\u0301@example.com", want: "jos\u00e9@example.com"},
+		{name: "no NFC under none", policy: EmailNormalizeNone, input: "jose\u0301@x.com", want: "jose\u0301@x.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("EMAIL_NORMALIZATION", tt.policy)
+
+			if got := NormalizeEmail(tt.input); got != tt.want {
+				t.Errorf("NormalizeEmail(%q) = %q, want %q", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmailUniquenessPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		policy      string
+		first       string
+		second      string
+		wantCollide bool
+	}{
+		{name: "lowercase", policy: EmailNormalizeLowercase, first: "A@x.com", second: "a@x.com", wantCollide: true},
+		{name: "domain", policy: EmailNormalizeDomain, first: "A@x.com", second: "a@x.com"},
+		{name: "domain with another domain case", policy: EmailNormalizeDomain, first: "a@X.com", second: "a@x.com",
+			wantCollide: true},
+		{name: "none", policy: EmailNormalizeNone, first: "a@X.com", second: "a@x.com"},
+		{name: "differently composed", first: "jos\u00e9@x.com", second: "jose\u0301@x.com", wantCollide: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("EMAIL_NORMALIZATION", tt.policy)
+			ClearInMemoryUsers()
+			t.Cleanup(ClearInMemoryUsers)
+
+			if got := SameEmail(tt.first, tt.second); got != tt.wantCollide {
+				t.Errorf("SameEmail(%q, %q) = %v, want %v", tt.first, tt.second, got, tt.wantCollide)
+			}
+
+			ctx := context.Background()
+			repo := NewInMemoryUserRepository()
+			if _, err := repo.CreateUser(ctx, User{ID: "first", Name: "First", Email: NormalizeEmail(tt.first)}); err != nil {
+				t.Fatalf("creating the first user: %v", err)
+			}
+			_, err := repo.CreateUser(ctx, User{ID: "second", Name: "Second", Email: NormalizeEmail(tt.second)})
+			if collided := errors.Is(err, ErrDuplicateEmail); collided != tt.wantCollide {
+				t.Errorf("second create error = %v, want duplicate %v", err, tt.wantCollide)
+			}
+		})
+	}
+}
+
+func TestValidateEmailLength(t *testing.T) {
+	label := strings.Repeat("d", 63)
+	maxDomain := strings.Join([]string{label, label, label, label}, ".")
+	maxLocal := strings.Repeat("l", MaxEmailLocalLength)
+
+	tests := []struct {
+		name      string
+		email     string
+		maxLength string
+		wantErr   string
+	}{
+		{name: "valid maximal address", email: maxLocal + "@" + maxDomain},
+		{
+			name:    "over-length local part",
+			email:   maxLocal + "l@example.com",
+			wantErr: "email local part must be at most 64 characters",
+		},
+		{
+			name:    "over-length domain",
+			email:   "ann@d" + maxDomain,
+			wantErr: "email domain must be at most 255 characters",
+		},
+		{
+			name:    "over the total limit",
+			email:   maxLocal + "@d" + maxDomain,
+			wantErr: "email must be at most 320 characters",
+		},
+		{
+			name:      "over the configured limit",
+			email:     "ann.lee@example.com",
+			maxLength: "10",
+			wantErr:   "email must be at most 10 characters",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("EMAIL_MAX_LENGTH", tt.maxLength)
+
+			err := validateEmail(tt.email)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateEmail() error = %v, want nil", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("validateEmail() error = %v, want a *ValidationError", err)
+			}
+			if validationErr.Field != "email" || validationErr.Message != tt.wantErr {
+				t.Errorf("error = %s: %q, want email: %q", validationErr.Field, validationErr.Message, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestSuggestEmail(t *testing.T) {
+	tests := []struct {
+		name  string
+		email string
+		want  string
+	}{
+		{name: "comma for a dot", email: "user@gmail,com", want: "user@gmail.com"},
+		{name: "semicolon for a dot", email: "user@example;org", want: "user@example.org"},
+		{name: "doubled @", email: "user@@example.com", want: "user@example.com"},
+		{name: "doubled dot", email: "user@example..com", want: "user@example.com"},
+		{name: "trailing dot", email: "user@example.com.", want: "user@example.com"},
+		{name: "misspelled TLD", email: "user@example.con", want: "user@example.com"},
+		{name: "misspelled domain", email: "user@gmial.com", want: "user@gmail.com"},
+		{name: "misspelled domain and TLD", email: "user@hotmial,cmo", want: "user@hotmail.com"},
+		{name: "valid address", email: "user@example.com"},
+		{name: "only the domain case differs", email: "User@Example.COM"},
+		{name: "no @", email: "notanemail"},
+		{name: "no local part", email: "@gmail.com"},
+		{name: "no domain", email: "user@"},
+		{name: "domain without a dot", email: "user@localhost"},
+		{name: "local part still invalid", email: "us er@gmail,com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := SuggestEmail(tt.email); got != tt.want {
+				t.Errorf("SuggestEmail(%q) = %q, want %q", tt.email, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// testSentinelErrors checks that repo, holding only user-1 <ann@example.com> at version 1,
+// reports each failure with the sentinel error handlers map to a status.
+func testSentinelErrors(t *testing.T, newRepo func(t *testing.T) UserRepository) {
+	t.Helper()
+
+	tests := []struct {
+		name    string
+		call    func(ctx context.Context, repo UserRepository) error
+		wantErr error
+	}{
+		{
+			name: "get a missing user",
+			call: func(ctx context.Context, repo UserRepository) error {
+				_, err := repo.GetUserByID(ctx, "nobody")
+				return err
+			},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name: "get a missing email",
+			call: func(ctx context.Context, repo UserRepository) error {
+				_, err := repo.GetUserByEmail(ctx, "nobody@example.com")
+				return err
+			},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name: "update a missing user",
+			call: func(ctx context.Context, repo UserRepository) error {
+				_, err := repo.UpdateUser(ctx, User{ID: "nobody", Name: "Nobody", Email: "nobody@example.com", Version: 1})
+				return err
+			},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name: "stale update",
+			call: func(ctx context.Context, repo UserRepository) error {
+				_, err := repo.UpdateUser(ctx, User{ID: "user-1", Name: "Annie", Email: "ann@example.com", Version: 5})
+				return err
+			},
+			wantErr: ErrVersionConflict,
+		},
+		{
+			name: "delete a missing user",
+			call: func(ctx context.Context, repo UserRepository) error {
+				return repo.DeleteUser(ctx, "nobody")
+			},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name: "create with a taken ID",
+			call: func(ctx context.Context, repo UserRepository) error {
+				_, err := repo.CreateUser(ctx, User{ID: "user-1", Name: "Bob", Email: "bob@example.com"})
+				return err
+			},
+			wantErr: ErrDuplicateID,
+		},
+		{
+			name: "create with a taken email",
+			call: func(ctx context.Context, repo UserRepository) error {
+				_, err := repo.CreateUser(ctx, User{ID: "user-2", Name: "Bob", Email: "ann@example.com"})
+				return err
+			},
+			wantErr: ErrDuplicateEmail,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(context.Background(), newRepo(t)); !errors.Is(err, tt.wantErr) {
+				t.Errorf("error = %v, want errors.Is %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInMemorySentinelErrors(t *testing.T) {
+	testSentinelErrors(t, func(t *testing.T) UserRepository {
+		ClearInMemoryUsers()
+		t.Cleanup(ClearInMemoryUsers)
+
+		repo := NewInMemoryUserRepository()
+		ann := User{ID: "user-1", Name: "Ann", Email: "ann@example.com"}
+		if _, err := repo.CreateUser(context.Background(), ann); err != nil {
+			t.Fatalf("seeding user-1: %v", err)
+		}
+
+		return repo
+	})
+}
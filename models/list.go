@@ -0,0 +1,140 @@
+package models
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// ListQuery selects a page of users for GetAllUsers.
+type ListQuery struct {
+	// Limit is the most users returned; 0 returns every user.
+	Limit int
+	// Cursor is the next cursor of the previous page, or "" for the first page. It is only
+	// valid with the sort of the query that returned it.
+	Cursor string
+	// SortField is a field IsSortField accepts, or "" for the repository's order, which is
+	// by ID except in DynamoDB. Users with equal values are ordered by ID.
+	SortField string
+	// Descending reverses the order.
+	Descending bool
+	// NameContains, when set, selects only users whose name contains it, ignoring case. The
+	// filter applies before paging, so only the last page holds fewer than Limit users.
+	NameContains string
+}
+
+// userSortFields maps sortable field names to comparisons of two users by that field.
+var userSortFields = map[string]func(a, b User) int{
+	"name":       func(a, b User) int { return strings.Compare(a.Name, b.Name) },
+	"email":      func(a, b User) int { return strings.Compare(a.Email, b.Email) },
+	"created_at": func(a, b User) int { return a.CreatedAt.Compare(b.CreatedAt) },
+	"updated_at": func(a, b User) int { return a.UpdatedAt.Compare(b.UpdatedAt) },
+}
+
+// IsSortField reports whether users can be sorted by field.
+func IsSortField(field string) bool {
+	_, ok := userSortFields[field]
+	return ok
+}
+
+// nameFilter returns NameContains as names are matched against it: in NFC, like stored
+// names, and lower case.
+func (q ListQuery) nameFilter() string {
+	return strings.ToLower(norm.NFC.String(q.NameContains))
+}
+
+// matches reports whether user passes the query's name filter.
+func (q ListQuery) matches(user User) bool {
+	return strings.Contains(strings.ToLower(user.Name), q.nameFilter())
+}
+
+// compare orders a and b by the query's sort field and then ID, in the query's direction.
+func (q ListQuery) compare(a, b User) int {
+	c := 0
+	if compare, ok := userSortFields[q.SortField]; ok {
+		c = compare(a, b)
+	}
+	if c == 0 {
+		c = strings.Compare(a.ID, b.ID)
+	}
+	if q.Descending {
+		return -c
+	}
+
+	return c
+}
+
+// encodeCursor returns the cursor of the page ending at last. In ID order it is the encoded
+// ID, as it has always been; sorted, it also holds the sort field so the next page can
+// resume after last even if last has since been deleted.
+func (q ListQuery) encodeCursor(last User) string {
+	if q.SortField == "" {
+		return base64.RawURLEncoding.EncodeToString([]byte(last.ID))
+	}
+
+	key := User{ID: last.ID}
+	switch q.SortField {
+	case "name":
+		key.Name = last.Name
+	case "email":
+		key.Email = last.Email
+	case "created_at":
+		key.CreatedAt = last.CreatedAt
+	case "updated_at":
+		key.UpdatedAt = last.UpdatedAt
+	}
+	// A User of strings and times always marshals
+	data, _ := json.Marshal(key)
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeCursor returns the last user of the previous page, holding only its ID and sort field.
+func (q ListQuery) decodeCursor() (User, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return User{}, ErrInvalidCursor
+	}
+	if q.SortField == "" {
+		return User{ID: string(decoded)}, nil
+	}
+
+	var last User
+	if err := json.Unmarshal(decoded, &last); err != nil || last.ID == "" {
+		return User{}, ErrInvalidCursor
+	}
+
+	return last, nil
+}
+
+// pageUsers sorts users in the query's order and returns the page after the query's cursor,
+// with the cursor of the next page. It is for repositories that cannot sort in the store.
+func pageUsers(users []User, q ListQuery) ([]User, string, error) {
+	if q.Cursor != "" {
+		after, err := q.decodeCursor()
+		if err != nil {
+			return nil, "", err
+		}
+
+		remaining := users[:0]
+		for _, user := range users {
+			if q.compare(user, after) > 0 {
+				remaining = append(remaining, user)
+			}
+		}
+		users = remaining
+	}
+
+	sort.Slice(users, func(i, j int) bool { return q.compare(users[i], users[j]) < 0 })
+
+	if q.Limit <= 0 || len(users) <= q.Limit {
+		return users, "", nil
+	}
+
+	page := users[:q.Limit]
+
+	return page, q.encodeCursor(page[q.Limit-1]), nil
+}
@@ -0,0 +1,369 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// postgresUniqueViolation is the SQLSTATE of a unique constraint violation.
+const postgresUniqueViolation = "23505"
+
+// postgresUserColumns are the users table columns, in the order scanUser reads them.
+const postgresUserColumns = "id, name, email, created_at, updated_at, avatar_url, metadata, version"
+
+// postgresUserRepository implements UserRepository for PostgreSQL. It expects a users
+// table with a primary key on id and a unique index on email; see the README for the DDL.
+// It only uses database/sql, so any driver registered under the name passed to sql.Open works.
+type postgresUserRepository struct {
+	db *sql.DB
+}
+
+// NewPostgresUserRepository creates a new instance of postgresUserRepository.
+// nolint: ireturn
+func NewPostgresUserRepository(db *sql.DB) UserRepository {
+	return &postgresUserRepository{db: db}
+}
+
+// rowScanner is implemented by *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// scanUser reads a row of postgresUserColumns.
+func scanUser(row rowScanner) (User, error) {
+	var user User
+	var metadata []byte
+	if err := row.Scan(&user.ID, &user.Name, &user.Email, &user.CreatedAt, &user.UpdatedAt, &user.AvatarURL, &metadata,
+		&user.Version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return User{}, ErrUserNotFound
+		}
+
+		return User{}, fmt.Errorf("failed to read user from PostgreSQL: %w", err)
+	}
+	if metadata != nil {
+		if err := json.Unmarshal(metadata, &user.Metadata); err != nil {
+			return User{}, fmt.Errorf("failed to unmarshal metadata: %w", err)
+		}
+	}
+
+	return user, nil
+}
+
+// metadataColumn returns the JSONB value stored for metadata, NULL when it is empty.
+func metadataColumn(metadata map[string]string) (any, error) {
+	if len(metadata) == 0 {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal metadata: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// isUniqueViolation reports whether err is a unique constraint violation. Drivers such as
+// lib/pq and pgx expose the SQLSTATE through an SQLState method.
+func isUniqueViolation(err error) bool {
+	var stateErr interface{ SQLState() string }
+
+	return errors.As(err, &stateErr) && stateErr.SQLState() == postgresUniqueViolation
+}
+
+// HealthCheck verifies the database is reachable.
+func (r *postgresUserRepository) HealthCheck(ctx context.Context) error {
+	if err := r.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("failed to reach PostgreSQL: %w", err)
+	}
+
+	return nil
+}
+
+// execer is implemented by *sql.DB and *sql.Tx.
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// insertUser inserts user, reporting a taken ID as ErrDuplicateID and a taken email, which
+// the unique index on email rejects, as ErrDuplicateEmail.
+func insertUser(ctx context.Context, db execer, user User) (User, error) {
+	if user.UpdatedAt.IsZero() {
+		user.UpdatedAt = user.CreatedAt
+	}
+	user.Version = 1
+	user.truncateTimestamps()
+
+	metadata, err := metadataColumn(user.Metadata)
+	if err != nil {
+		return User{}, err
+	}
+
+	result, err := db.ExecContext(ctx,
+		"INSERT INTO users ("+postgresUserColumns+") VALUES ($1, $2, $3, $4, $5, $6, $7, $8) ON CONFLICT (id) DO NOTHING",
+		user.ID, user.Name, user.Email, user.CreatedAt, user.UpdatedAt, user.AvatarURL, metadata, user.Version)
+	if isUniqueViolation(err) {
+		return User{}, ErrDuplicateEmail
+	}
+	if err != nil {
+		return User{}, fmt.Errorf("failed to insert user into PostgreSQL: %w", err)
+	}
+
+	if inserted, err := result.RowsAffected(); err == nil && inserted == 0 {
+		return User{}, ErrDuplicateID
+	}
+
+	return user, nil
+}
+
+// CreateUser inserts a new user.
+func (r *postgresUserRepository) CreateUser(ctx context.Context, user User) (User, error) {
+	return insertUser(ctx, r.db, user)
+}
+
+// CreateUsers inserts users in one transaction, rolling back every insert if any fails.
+func (r *postgresUserRepository) CreateUsers(ctx context.Context, users []User) ([]User, error) {
+	if len(users) == 0 {
+		return nil, ErrEmptyBatch
+	}
+	if len(users) > MaxBatchCreateSize {
+		return nil, ErrBatchTooLarge
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin PostgreSQL transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	created := make([]User, len(users))
+	for i, user := range users {
+		created[i], err = insertUser(ctx, tx, user)
+		if err != nil {
+			return nil, fmt.Errorf("user %d: %w", i+1, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit PostgreSQL transaction: %w", err)
+	}
+
+	return created, nil
+}
+
+// GetUserByID reads a user by ID.
+func (r *postgresUserRepository) GetUserByID(ctx context.Context, id string) (User, error) {
+	return scanUser(r.db.QueryRowContext(ctx, "SELECT "+postgresUserColumns+" FROM users WHERE id = $1", id))
+}
+
+// GetUserByEmail finds the user with email. Emails are stored normalized, so the lookup
+// normalizes email and matches exactly.
+func (r *postgresUserRepository) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	return scanUser(r.db.QueryRowContext(ctx,
+		"SELECT "+postgresUserColumns+" FROM users WHERE email = $1", NormalizeEmail(email)))
+}
+
+// postgresSortColumns maps sortable fields to their columns.
+var postgresSortColumns = map[string]string{
+	"name":       "name",
+	"email":      "email",
+	"created_at": "created_at",
+	"updated_at": "updated_at",
+}
+
+// GetAllUsers pages through users with keyset pagination: ORDER BY the sort column and id,
+// starting after the (sort value, id) of the cursor, so each page is one indexed range scan.
+// The name filter is part of the WHERE clause, so it applies before LIMIT.
+// Like the in-memory repository's, the cursor encodes the last user of the previous page.
+func (r *postgresUserRepository) GetAllUsers(ctx context.Context, q ListQuery) ([]User, string, error) {
+	column, direction, comparison := postgresSortColumns[q.SortField], "", ">"
+	if q.Descending {
+		direction, comparison = " DESC", "<"
+	}
+
+	var conditions []string
+	var args []any
+	if q.NameContains != "" {
+		// strpos rather than LIKE, so % and _ in the filter match literally
+		args = append(args, q.nameFilter())
+		conditions = append(conditions, fmt.Sprintf("strpos(lower(name), $%d) > 0", len(args)))
+	}
+	if q.Cursor != "" {
+		after, err := q.decodeCursor()
+		if err != nil {
+			return nil, "", err
+		}
+		if column == "" {
+			args = append(args, after.ID)
+			conditions = append(conditions, fmt.Sprintf("id %s $%d", comparison, len(args)))
+		} else {
+			args = append(args, postgresSortValue(q.SortField, after), after.ID)
+			conditions = append(conditions,
+				fmt.Sprintf("(%s, id) %s ($%d, $%d)", column, comparison, len(args)-1, len(args)))
+		}
+	}
+
+	query := "SELECT " + postgresUserColumns + " FROM users"
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY "
+	if column != "" {
+		query += column + direction + ", "
+	}
+	query += "id" + direction
+	limit := q.Limit
+	if limit > 0 {
+		// Read one extra row to learn whether there is a next page
+		args = append(args, limit+1)
+		query += fmt.Sprintf(" LIMIT $%d", len(args))
+	}
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to query users from PostgreSQL: %w", err)
+	}
+	defer rows.Close()
+
+	users := []User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, "", err
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", fmt.Errorf("failed to query users from PostgreSQL: %w", err)
+	}
+
+	if limit <= 0 || len(users) <= limit {
+		return users, "", nil
+	}
+
+	page := users[:limit]
+
+	return page, q.encodeCursor(page[limit-1]), nil
+}
+
+// postgresSortValue returns the value of user's sort field as a query argument.
+func postgresSortValue(field string, user User) any {
+	switch field {
+	case "name":
+		return user.Name
+	case "email":
+		return user.Email
+	case "created_at":
+		return user.CreatedAt
+	default:
+		return user.UpdatedAt
+	}
+}
+
+// querier is implemented by *sql.DB and *sql.Tx.
+type querier interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// updateUser writes every field of user except ID and CreatedAt, provided the stored version
+// is still user.Version, and returns the stored row with its version incremented.
+func updateUser(ctx context.Context, db querier, user User) (User, error) {
+	user.truncateTimestamps()
+
+	metadata, err := metadataColumn(user.Metadata)
+	if err != nil {
+		return User{}, err
+	}
+
+	updated, err := scanUser(db.QueryRowContext(ctx,
+		"UPDATE users SET name = $2, email = $3, updated_at = $4, avatar_url = $5, metadata = $6, version = version + 1 "+
+			"WHERE id = $1 AND version = $7 RETURNING "+postgresUserColumns,
+		user.ID, user.Name, user.Email, user.UpdatedAt, user.AvatarURL, metadata, user.Version))
+	if isUniqueViolation(err) {
+		return User{}, ErrDuplicateEmail
+	}
+	if errors.Is(err, ErrUserNotFound) {
+		// No row matched: either the user is gone or its version has moved on
+		var exists bool
+		row := db.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)", user.ID)
+		if err := row.Scan(&exists); err != nil {
+			return User{}, fmt.Errorf("failed to read user from PostgreSQL: %w", err)
+		}
+		if exists {
+			return User{}, ErrVersionConflict
+		}
+	}
+
+	return updated, err
+}
+
+// UpdateUser replaces a stored user. CreatedAt is never written by an update.
+func (r *postgresUserRepository) UpdateUser(ctx context.Context, user User) (User, error) {
+	return updateUser(ctx, r.db, user)
+}
+
+// UpdateUserFunc replaces a stored user with update(user) in a transaction that holds the
+// row lock from the read to the write.
+func (r *postgresUserRepository) UpdateUserFunc(ctx context.Context, id string, update func(User) User) (User, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return User{}, fmt.Errorf("failed to begin PostgreSQL transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	existing, err := scanUser(tx.QueryRowContext(ctx,
+		"SELECT "+postgresUserColumns+" FROM users WHERE id = $1 FOR UPDATE", id))
+	if err != nil {
+		return User{}, err
+	}
+
+	user := update(existing)
+	if user.Version != existing.Version {
+		return User{}, ErrVersionConflict
+	}
+	user.ID = id
+	updated, err := updateUser(ctx, tx, user)
+	if err != nil {
+		return User{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return User{}, fmt.Errorf("failed to commit PostgreSQL transaction: %w", err)
+	}
+
+	return updated, nil
+}
+
+// DeleteUser deletes a user by ID.
+func (r *postgresUserRepository) DeleteUser(ctx context.Context, id string) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM users WHERE id = $1", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete user from PostgreSQL: %w", err)
+	}
+
+	if deleted, err := result.RowsAffected(); err == nil && deleted == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+}
+
+// DeleteAllUsers deletes every row of the users table in one statement.
+func (r *postgresUserRepository) DeleteAllUsers(ctx context.Context) (int, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM users")
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete users from PostgreSQL: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count deleted users: %w", err)
+	}
+
+	return int(deleted), nil
+}
@@ -0,0 +1,247 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// postgresError stands in for a driver error carrying a SQLSTATE, like *pq.Error.
+type postgresError string
+
+func (e postgresError) Error() string    { return "pq: " + string(e) }
+func (e postgresError) SQLState() string { return string(e) }
+
+func TestPostgresUserRepository(t *testing.T) {
+	created := time.Date(2024, 3, 10, 12, 0, 0, 0, time.UTC)
+	user := User{ID: "user-1", Name: "Ann", Email: "ann@example.com", CreatedAt: created, UpdatedAt: created, Version: 1}
+	row := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "name", "email", "created_at", "updated_at", "avatar_url", "metadata",
+			"version"}).
+			AddRow(user.ID, user.Name, user.Email, created, created, "", []byte(`{"team":"payments"}`), 1)
+	}
+	stored := user
+	stored.Metadata = map[string]string{"team": "payments"}
+
+	const (
+		insert = "INSERT INTO users (" + postgresUserColumns + ") VALUES ($1, $2, $3, $4, $5, $6, $7, $8) " +
+			"ON CONFLICT (id) DO NOTHING"
+		update = "UPDATE users SET name = $2, email = $3, updated_at = $4, avatar_url = $5, metadata = $6, " +
+			"version = version + 1 WHERE id = $1 AND version = $7 RETURNING " + postgresUserColumns
+	)
+
+	tests := []struct {
+		name    string
+		expect  func(mock sqlmock.Sqlmock)
+		call    func(ctx context.Context, repo UserRepository) (any, error)
+		want    any
+		wantErr error
+	}{
+		{
+			name: "create",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(insert).
+					WithArgs(user.ID, user.Name, user.Email, created, created, "", nil, 1).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			call: func(ctx context.Context, repo UserRepository) (any, error) {
+				return repo.CreateUser(ctx, User{ID: user.ID, Name: user.Name, Email: user.Email, CreatedAt: created})
+			},
+			want: user,
+		},
+		{
+			name: "create with a taken ID",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(insert).WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			call: func(ctx context.Context, repo UserRepository) (any, error) {
+				return repo.CreateUser(ctx, user)
+			},
+			wantErr: ErrDuplicateID,
+		},
+		{
+			name: "create with a taken email",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec(insert).WillReturnError(postgresError(postgresUniqueViolation))
+			},
+			call: func(ctx context.Context, repo UserRepository) (any, error) {
+				return repo.CreateUser(ctx, user)
+			},
+			wantErr: ErrDuplicateEmail,
+		},
+		{
+			name: "get by ID",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT " + postgresUserColumns + " FROM users WHERE id = $1").
+					WithArgs(user.ID).
+					WillReturnRows(row())
+			},
+			call: func(ctx context.Context, repo UserRepository) (any, error) {
+				return repo.GetUserByID(ctx, user.ID)
+			},
+			want: stored,
+		},
+		{
+			name: "get a missing user",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT " + postgresUserColumns + " FROM users WHERE id = $1").
+					WithArgs("nobody").
+					WillReturnError(sql.ErrNoRows)
+			},
+			call: func(ctx context.Context, repo UserRepository) (any, error) {
+				return repo.GetUserByID(ctx, "nobody")
+			},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name: "get by normalized email",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT " + postgresUserColumns + " FROM users WHERE email = $1").
+					WithArgs("Ann@example.com").
+					WillReturnRows(row())
+			},
+			call: func(ctx context.Context, repo UserRepository) (any, error) {
+				return repo.GetUserByEmail(ctx, " Ann@EXAMPLE.com ")
+			},
+			want: stored,
+		},
+		{
+			name: "list filtered and sorted",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery("SELECT "+postgresUserColumns+" FROM users WHERE strpos(lower(name), $1) > 0 "+
+					"ORDER BY name DESC, id DESC LIMIT $2").
+					WithArgs("an", 3).
+					WillReturnRows(row())
+			},
+			call: func(ctx context.Context, repo UserRepository) (any, error) {
+				users, _, err := repo.GetAllUsers(ctx, ListQuery{
+					NameContains: "AN", SortField: "name", Descending: true, Limit: 2,
+				})
+
+				return users, err
+			},
+			want: []User{stored},
+		},
+		{
+			name: "update",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(update).
+					WithArgs(user.ID, "Annie", user.Email, created, "", nil, 1).
+					WillReturnRows(row())
+			},
+			call: func(ctx context.Context, repo UserRepository) (any, error) {
+				renamed := user
+				renamed.Name = "Annie"
+
+				return repo.UpdateUser(ctx, renamed)
+			},
+			want: stored,
+		},
+		{
+			name: "stale update",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(update).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery("SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)").
+					WithArgs(user.ID).
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(true))
+			},
+			call: func(ctx context.Context, repo UserRepository) (any, error) {
+				return repo.UpdateUser(ctx, user)
+			},
+			wantErr: ErrVersionConflict,
+		},
+		{
+			name: "update a missing user",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectQuery(update).WillReturnError(sql.ErrNoRows)
+				mock.ExpectQuery("SELECT EXISTS (SELECT 1 FROM users WHERE id = $1)").
+					WithArgs(user.ID).
+					WillReturnRows(sqlmock.NewRows([]string{"exists"}).AddRow(false))
+			},
+			call: func(ctx context.Context, repo UserRepository) (any, error) {
+				return repo.UpdateUser(ctx, user)
+			},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name: "delete",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("DELETE FROM users WHERE id = $1").
+					WithArgs(user.ID).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+			},
+			call: func(ctx context.Context, repo UserRepository) (any, error) {
+				return nil, repo.DeleteUser(ctx, user.ID)
+			},
+		},
+		{
+			name: "delete a missing user",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("DELETE FROM users WHERE id = $1").
+					WithArgs("nobody").
+					WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			call: func(ctx context.Context, repo UserRepository) (any, error) {
+				return nil, repo.DeleteUser(ctx, "nobody")
+			},
+			wantErr: ErrUserNotFound,
+		},
+		{
+			name: "delete all",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectExec("DELETE FROM users").WillReturnResult(sqlmock.NewResult(0, 3))
+			},
+			call: func(ctx context.Context, repo UserRepository) (any, error) {
+				return repo.DeleteAllUsers(ctx)
+			},
+			want: 3,
+		},
+		{
+			name: "batch create rolls back on a taken email",
+			expect: func(mock sqlmock.Sqlmock) {
+				mock.ExpectBegin()
+				mock.ExpectExec(insert).WithArgs("user-1", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+					sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnResult(sqlmock.NewResult(0, 1))
+				mock.ExpectExec(insert).WithArgs("user-2", sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(),
+					sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg(), sqlmock.AnyArg()).
+					WillReturnError(postgresError(postgresUniqueViolation))
+				mock.ExpectRollback()
+			},
+			call: func(ctx context.Context, repo UserRepository) (any, error) {
+				return repo.(BatchCreator).CreateUsers(ctx, []User{
+					{ID: "user-1", Name: "Ann", Email: "ann@example.com"},
+					{ID: "user-2", Name: "Bob", Email: "ann@example.com"},
+				})
+			},
+			wantErr: ErrDuplicateEmail,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherEqual))
+			if err != nil {
+				t.Fatalf("sqlmock.New() error = %v", err)
+			}
+			defer db.Close()
+			tt.expect(mock)
+
+			got, err := tt.call(context.Background(), NewPostgresUserRepository(db))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr == nil && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("result = %+v, want %+v", got, tt.want)
+			}
+			if err := mock.ExpectationsWereMet(); err != nil {
+				t.Errorf("unmet SQL expectations: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,112 @@
+package models
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Projection lists the user fields, by JSON name and sorted, that a read returns. It always
+// includes "id". A nil Projection selects every field.
+type Projection []string
+
+// ProjectedReader is implemented by repositories that can read only some fields of a user,
+// so unselected attributes are never fetched from the store.
+type ProjectedReader interface {
+	GetUserByIDProjected(ctx context.Context, id string, projection Projection) (User, error)
+}
+
+// userJSONFields is the set of JSON field names of User.
+var userJSONFields = func() map[string]bool {
+	fields := make(map[string]bool)
+	t := reflect.TypeOf(User{})
+	for i := 0; i < t.NumField(); i++ {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name != "" && name != "-" {
+			fields[name] = true
+		}
+	}
+
+	return fields
+}()
+
+// With returns the projection with field added, or nil for a nil projection, which already
+// selects every field.
+func (p Projection) With(field string) Projection {
+	if p == nil || slices.Contains(p, field) {
+		return p
+	}
+
+	extended := append(slices.Clone(p), field)
+	sort.Strings(extended)
+
+	return extended
+}
+
+// ParseProjection parses a comma-separated field list such as "name,email". Empty entries
+// are ignored and an unknown field is an error.
+func ParseProjection(value string) (Projection, error) {
+	selected := map[string]bool{"id": true}
+	for _, field := range strings.Split(value, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		if !userJSONFields[field] {
+			return nil, fmt.Errorf("unknown field %q", field)
+		}
+		selected[field] = true
+	}
+
+	projection := make(Projection, 0, len(selected))
+	for field := range selected {
+		projection = append(projection, field)
+	}
+	sort.Strings(projection)
+
+	return projection, nil
+}
+
+// Apply returns user with every field outside the projection zeroed. Repositories that
+// cannot project in the store apply it after reading, so every backend returns the same
+// fields.
+func (p Projection) Apply(user User) User {
+	if p == nil {
+		return user
+	}
+
+	data, err := json.Marshal(user)
+	if err != nil {
+		return user
+	}
+
+	var document map[string]json.RawMessage
+	if err := json.Unmarshal(data, &document); err != nil {
+		return user
+	}
+
+	keep := make(map[string]bool, len(p))
+	for _, field := range p {
+		keep[field] = true
+	}
+	for field := range document {
+		if !keep[field] {
+			delete(document, field)
+		}
+	}
+
+	data, err = json.Marshal(document)
+	if err != nil {
+		return user
+	}
+
+	var projected User
+	if err := json.Unmarshal(data, &projected); err != nil {
+		return user
+	}
+
+	return projected
+}
@@ -0,0 +1,89 @@
+package models
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseProjection(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    Projection
+		wantErr string
+	}{
+		{name: "id is always included", value: "name", want: Projection{"id", "name"}},
+		{name: "sorted", value: "email,name", want: Projection{"email", "id", "name"}},
+		{name: "id given", value: "id,email", want: Projection{"email", "id"}},
+		{name: "spaces and empty entries", value: " name, ,email,", want: Projection{"email", "id", "name"}},
+		{name: "repeated field", value: "name,name", want: Projection{"id", "name"}},
+		{name: "empty", value: "", want: Projection{"id"}},
+		{name: "unknown field", value: "name,password", wantErr: `unknown field "password"`},
+		{name: "Go field name", value: "CreatedAt", wantErr: `unknown field "CreatedAt"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseProjection(tt.value)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("ParseProjection(%q) error = %v, want %q", tt.value, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseProjection(%q) error = %v", tt.value, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseProjection(%q) = %v, want %v", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInMemoryGetUserByIDProjected(t *testing.T) {
+	createdAt := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	user := User{
+		ID: "user-1", Name: "Ann", Email: "ann@example.com", CreatedAt: createdAt,
+		Metadata: map[string]string{"team": "a"},
+	}
+
+	tests := []struct {
+		name       string
+		projection Projection
+		want       User
+	}{
+		{name: "no projection", want: User{
+			ID: "user-1", Name: "Ann", Email: "ann@example.com", CreatedAt: createdAt, UpdatedAt: createdAt, Version: 1,
+			Metadata: map[string]string{"team": "a"},
+		}},
+		{name: "id only", projection: Projection{"id"}, want: User{ID: "user-1"}},
+		{name: "name", projection: Projection{"id", "name"}, want: User{ID: "user-1", Name: "Ann"}},
+		{
+			name:       "metadata and version",
+			projection: Projection{"id", "metadata", "version"},
+			want:       User{ID: "user-1", Version: 1, Metadata: map[string]string{"team": "a"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ClearInMemoryUsers()
+			t.Cleanup(ClearInMemoryUsers)
+			repo := NewInMemoryUserRepository()
+			if _, err := repo.CreateUser(context.Background(), user); err != nil {
+				t.Fatalf("CreateUser() error = %v", err)
+			}
+
+			got, err := repo.(ProjectedReader).GetUserByIDProjected(context.Background(), user.ID, tt.projection)
+			if err != nil {
+				t.Fatalf("GetUserByIDProjected() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetUserByIDProjected() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
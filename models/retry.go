@@ -0,0 +1,93 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultRetryBudget is the number of retries shared by all repository calls in one request
+// when REQUEST_RETRY_BUDGET is unset.
+const DefaultRetryBudget = 3
+
+const (
+	// retryBaseDelay is the upper bound of the jittered delay before the first retry; it
+	// doubles for every further retry.
+	retryBaseDelay = 50 * time.Millisecond
+	// retryMaxDelay caps the delay before any single retry.
+	retryMaxDelay = time.Second
+)
+
+// ErrRetryBudgetExhausted is returned when a request has used up its retry budget.
+var ErrRetryBudgetExhausted = errors.New("retry budget exhausted")
+
+// RetryBudget bounds the total number of retries across every repository call made while
+// serving a single request, so an update (read + write) cannot retry each step independently.
+type RetryBudget struct {
+	remaining atomic.Int32
+}
+
+// NewRetryBudget creates a budget allowing the given number of retries.
+func NewRetryBudget(retries int) *RetryBudget {
+	budget := &RetryBudget{}
+	budget.remaining.Store(int32(retries))
+
+	return budget
+}
+
+// Take consumes one retry, reporting false when the budget is exhausted.
+func (b *RetryBudget) Take() bool {
+	return b.remaining.Add(-1) >= 0
+}
+
+// Remaining returns the number of retries left.
+func (b *RetryBudget) Remaining() int {
+	return max(int(b.remaining.Load()), 0)
+}
+
+type retryBudgetKey struct{}
+
+// ContextWithRetryBudget returns a copy of ctx carrying budget.
+func ContextWithRetryBudget(ctx context.Context, budget *RetryBudget) context.Context {
+	return context.WithValue(ctx, retryBudgetKey{}, budget)
+}
+
+// RetryBudgetFromContext returns the request's retry budget, or nil if there is none.
+func RetryBudgetFromContext(ctx context.Context) *RetryBudget {
+	budget, _ := ctx.Value(retryBudgetKey{}).(*RetryBudget)
+
+	return budget
+}
+
+// Retry runs op, retrying errors for which isRetryable returns true at most maxRetries times
+// and for as long as the request's retry budget allows. Each retry waits a random delay of up
+// to retryBaseDelay, doubling per retry up to retryMaxDelay ("full jitter"), and op is not
+// retried when that delay would run past ctx's deadline. Without a budget in ctx, e.g.
+// outside a request, only maxRetries applies.
+func Retry(ctx context.Context, maxRetries int, isRetryable func(error) bool, op func() error) error {
+	for retry := 0; ; retry++ {
+		err := op()
+		if err == nil || !isRetryable(err) || retry >= maxRetries {
+			return err
+		}
+
+		delay := rand.N(min(retryBaseDelay<<min(retry, 10), retryMaxDelay))
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay {
+			return err
+		}
+		if budget := RetryBudgetFromContext(ctx); budget != nil && !budget.Take() {
+			return fmt.Errorf("%w: %w", ErrRetryBudgetExhausted, err)
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+var errTransient = errors.New("transient")
+
+func TestRetryBudget(t *testing.T) {
+	tests := []struct {
+		name         string
+		budget       int
+		noBudget     bool
+		maxRetries   int
+		failures     int
+		wantAttempts []int
+		wantErrs     []error
+	}{
+		{
+			name:         "shared budget limits total retries",
+			budget:       3,
+			maxRetries:   3,
+			failures:     10,
+			wantAttempts: []int{4, 1},
+			wantErrs:     []error{errTransient, ErrRetryBudgetExhausted},
+		},
+		{
+			name:         "budget split between operations",
+			budget:       3,
+			maxRetries:   3,
+			failures:     2,
+			wantAttempts: []int{3, 2},
+			wantErrs:     []error{nil, ErrRetryBudgetExhausted},
+		},
+		{
+			name:         "budget large enough for both",
+			budget:       10,
+			maxRetries:   3,
+			failures:     2,
+			wantAttempts: []int{3, 3},
+			wantErrs:     []error{nil, nil},
+		},
+		{
+			name:         "each operation limited by its own max without a budget",
+			noBudget:     true,
+			maxRetries:   2,
+			failures:     10,
+			wantAttempts: []int{3, 3},
+			wantErrs:     []error{errTransient, errTransient},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if !tt.noBudget {
+				ctx = ContextWithRetryBudget(ctx, NewRetryBudget(tt.budget))
+			}
+			retryable := func(err error) bool { return errors.Is(err, errTransient) }
+
+			for i := range tt.wantAttempts {
+				attempts := 0
+				err := Retry(ctx, tt.maxRetries, retryable, func() error {
+					attempts++
+					if attempts <= tt.failures {
+						return errTransient
+					}
+					return nil
+				})
+
+				if attempts != tt.wantAttempts[i] {
+					t.Errorf("operation %d made %d attempts, want %d", i, attempts, tt.wantAttempts[i])
+				}
+				if !errors.Is(err, tt.wantErrs[i]) || (err == nil) != (tt.wantErrs[i] == nil) {
+					t.Errorf("operation %d error = %v, want %v", i, err, tt.wantErrs[i])
+				}
+			}
+		})
+	}
+}
+
+func TestRetryDeadline(t *testing.T) {
+	ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+
+	attempts := 0
+	err := Retry(ctx, 3, func(error) bool { return true }, func() error {
+		attempts++
+		return errTransient
+	})
+	if !errors.Is(err, errTransient) {
+		t.Errorf("Retry() error = %v, want %v", err, errTransient)
+	}
+	if attempts != 1 {
+		t.Errorf("made %d attempts past the deadline, want 1", attempts)
+	}
+}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"os"
+	"strings"
+	"time"
+)
+
+// Timestamp precisions, selected with TIMESTAMP_PRECISION.
+const (
+	// TimestampPrecisionSeconds truncates CreatedAt and UpdatedAt to whole seconds.
+	TimestampPrecisionSeconds = "seconds"
+	// TimestampPrecisionMillis truncates CreatedAt and UpdatedAt to milliseconds.
+	TimestampPrecisionMillis = "millis"
+	// TimestampPrecisionNanos keeps the full precision of the clock.
+	TimestampPrecisionNanos = "nanos"
+)
+
+// TruncateTimestamp truncates t to the TIMESTAMP_PRECISION (default "nanos", i.e. unchanged).
+func TruncateTimestamp(t time.Time) time.Time {
+	switch strings.ToLower(os.Getenv("TIMESTAMP_PRECISION")) {
+	case TimestampPrecisionSeconds:
+		return t.Truncate(time.Second)
+	case TimestampPrecisionMillis:
+		return t.Truncate(time.Millisecond)
+	default:
+		return t
+	}
+}
+
+// truncateTimestamps applies TruncateTimestamp to user's timestamps. Repositories call it on
+// every write, so stored and returned timestamps have the same precision.
+func (u *User) truncateTimestamps() {
+	u.CreatedAt = TruncateTimestamp(u.CreatedAt)
+	u.UpdatedAt = TruncateTimestamp(u.UpdatedAt)
+}
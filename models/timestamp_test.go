@@ -0,0 +1,79 @@
+package models
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTimestampPrecision(t *testing.T) {
+	createdAt := time.Date(2024, time.March, 1, 12, 30, 45, 123456789, time.UTC)
+	updatedAt := createdAt.Add(time.Hour + 987654321)
+
+	tests := []struct {
+		name          string
+		precision     string
+		wantCreatedAt time.Time
+		wantUpdatedAt time.Time
+	}{
+		{name: "unchanged by default", wantCreatedAt: createdAt, wantUpdatedAt: updatedAt},
+		{name: "nanos", precision: "nanos", wantCreatedAt: createdAt, wantUpdatedAt: updatedAt},
+		{
+			name:          "millis",
+			precision:     "millis",
+			wantCreatedAt: time.Date(2024, time.March, 1, 12, 30, 45, 123000000, time.UTC),
+			wantUpdatedAt: time.Date(2024, time.March, 1, 13, 30, 46, 111000000, time.UTC),
+		},
+		{
+			name:          "seconds",
+			precision:     "seconds",
+			wantCreatedAt: time.Date(2024, time.March, 1, 12, 30, 45, 0, time.UTC),
+			wantUpdatedAt: time.Date(2024, time.March, 1, 13, 30, 46, 0, time.UTC),
+		},
+		{
+			name:          "case-insensitive",
+			precision:     "SECONDS",
+			wantCreatedAt: time.Date(2024, time.March, 1, 12, 30, 45, 0, time.UTC),
+			wantUpdatedAt: time.Date(2024, time.March, 1, 13, 30, 46, 0, time.UTC),
+		},
+		{name: "unknown precision", precision: "minutes", wantCreatedAt: createdAt, wantUpdatedAt: updatedAt},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TIMESTAMP_PRECISION", tt.precision)
+			ClearInMemoryUsers()
+			t.Cleanup(ClearInMemoryUsers)
+
+			ctx := context.Background()
+			repo := NewInMemoryUserRepository()
+			user := User{ID: "user-1", Name: "Ann", Email: "ann@example.com", CreatedAt: createdAt, UpdatedAt: createdAt}
+			created, err := repo.CreateUser(ctx, user)
+			if err != nil {
+				t.Fatalf("CreateUser() error = %v", err)
+			}
+			if !created.CreatedAt.Equal(tt.wantCreatedAt) {
+				t.Errorf("returned CreatedAt = %s, want %s", created.CreatedAt, tt.wantCreatedAt)
+			}
+
+			created.UpdatedAt = updatedAt
+			updated, err := repo.UpdateUser(ctx, created)
+			if err != nil {
+				t.Fatalf("UpdateUser() error = %v", err)
+			}
+			stored, err := repo.GetUserByID(ctx, user.ID)
+			if err != nil {
+				t.Fatalf("GetUserByID() error = %v", err)
+			}
+
+			for source, got := range map[string]User{"returned": updated, "stored": stored} {
+				if !got.CreatedAt.Equal(tt.wantCreatedAt) {
+					t.Errorf("%s CreatedAt = %s, want %s", source, got.CreatedAt, tt.wantCreatedAt)
+				}
+				if !got.UpdatedAt.Equal(tt.wantUpdatedAt) {
+					t.Errorf("%s UpdatedAt = %s, want %s", source, got.UpdatedAt, tt.wantUpdatedAt)
+				}
+			}
+		})
+	}
+}
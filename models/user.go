@@ -1,26 +1,95 @@
 package models
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"strings"
+	"sync"
 	"time"
 
-	"github.com/aws/aws-sdk-go/aws"
-	"github.com/aws/aws-sdk-go/service/dynamodb"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbattribute"
-	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+	"golang.org/x/text/unicode/norm"
 )
 
+// ErrUserNotFound is returned when no user has the requested ID or email.
+var ErrUserNotFound = errors.New("user not found")
+
+// ErrDuplicateEmail is returned when creating or updating a user whose email is already taken.
+var ErrDuplicateEmail = errors.New("email already exists")
+
+// ErrDuplicateID is returned when creating a user whose ID is already taken.
+var ErrDuplicateID = errors.New("user ID already exists")
+
+// ErrVersionConflict is returned when updating a user whose stored version no longer matches
+// the version the caller read, meaning another update got there first.
+var ErrVersionConflict = errors.New("user was modified concurrently; re-read it and retry")
+
+// ErrInvalidCursor is returned when a pagination cursor cannot be decoded.
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// ErrBatchCreateUnsupported is returned by repository wrappers whose wrapped repository
+// cannot create users atomically.
+var ErrBatchCreateUnsupported = errors.New("atomic batch create is not supported by this repository")
+
+// ErrAtomicUpdateUnsupported is returned by repository wrappers whose wrapped repository
+// cannot update users atomically.
+var ErrAtomicUpdateUnsupported = errors.New("atomic update is not supported by this repository")
+
+// ErrBatchTooLarge is returned by BatchCreator.CreateUsers for more than MaxBatchCreateSize users.
+var ErrBatchTooLarge = fmt.Errorf("cannot create more than %d users at once", MaxBatchCreateSize)
+
+// ErrEmptyBatch is returned by BatchCreator.CreateUsers for a batch without users.
+var ErrEmptyBatch = errors.New("at least one user is required")
+
+// ErrWriteUnprocessed is returned for writes the store left unprocessed, e.g. because a
+// DynamoDB BatchWriteItem call was throttled; they can be sent again.
+var ErrWriteUnprocessed = errors.New("the store did not process the write; retry it")
+
+// MaxBatchCreateSize is the most users BatchCreator.CreateUsers accepts in one call. A
+// DynamoDB transaction writes at most 100 items, two per user with its email sentinel.
+const MaxBatchCreateSize = 50
+
+// listAllPageSize is the page size ListAllUsers reads with.
+const listAllPageSize = 100
+
+// User is a stored user. The dynamodbav tags name the DynamoDB attributes, which the
+// DynamoDB repository also uses in keys and expressions; without them dynamodbattribute
+// would fall back to the json names. The xml tags name the elements of XML responses.
 type User struct {
-	ID        string    `json:"id"`
-	Name      string    `json:"name"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at,omitempty"`
+	ID        string    `json:"id" xml:"id" dynamodbav:"ID"`
+	Name      string    `json:"name" xml:"name" dynamodbav:"Name"`
+	Email     string    `json:"email" xml:"email" dynamodbav:"Email"`
+	CreatedAt time.Time `json:"created_at,omitempty" xml:"created_at" dynamodbav:"CreatedAt"`
+	UpdatedAt time.Time `json:"updated_at,omitempty" xml:"updated_at" dynamodbav:"UpdatedAt"`
+	AvatarURL string    `json:"avatar_url,omitempty" xml:"avatar_url,omitempty" dynamodbav:"AvatarURL,omitempty"`
+	// Version starts at 1 and is incremented by every update. Users stored before versioning
+	// have version 0 until their first update.
+	Version int `json:"version" xml:"version" dynamodbav:"Version"`
+
+	Metadata map[string]string `json:"metadata,omitempty" xml:"metadata,omitempty" dynamodbav:"Metadata,omitempty"`
 }
 
 type UserRequest struct {
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	ID       string            `json:"id,omitempty"` // optional on create; ignored on update
+	Name     string            `json:"name"`
+	Email    string            `json:"email"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Version, when non-zero, is the version the client read; the update fails with
+	// ErrVersionConflict if the stored user has changed since. Ignored on create.
+	Version int `json:"version,omitempty"`
+}
+
+// Normalize canonicalizes request fields before validation and storage. Surrounding
+// whitespace is trimmed from the name, except that a name of only whitespace is kept for
+// Validate to reject rather than read as absent. The name is put in Unicode NFC, so a
+// precomposed "é" and an "e" followed by a combining acute accent are stored the same.
+func (ur *UserRequest) Normalize() {
+	if name := strings.TrimSpace(ur.Name); name != "" {
+		ur.Name = norm.NFC.String(name)
+	}
+	if ur.Email != "" {
+		ur.Email = NormalizeEmail(ur.Email)
+	}
 }
 
 func (ur *UserRequest) Validate(isUpdate bool) error {
@@ -31,24 +100,121 @@ func (ur *UserRequest) Validate(isUpdate bool) error {
 		if ur.Email == "" {
 			return errors.New("email is required")
 		}
-	} else if ur.Name == "" && ur.Email == "" {
+		if ur.ID != "" {
+			if err := validateID(ur.ID); err != nil {
+				return err
+			}
+		}
+	} else if ur.Name == "" && ur.Email == "" && ur.Metadata == nil {
 		return errors.New("no fields to update")
 	}
+	if ur.Version < 0 {
+		return &ValidationError{Field: "version", Message: "version must not be negative"}
+	}
 
-	return nil
+	if ur.Name != "" {
+		if err := validateName(ur.Name); err != nil {
+			return err
+		}
+	}
+	if ur.Email != "" {
+		if err := validateEmail(ur.Email); err != nil {
+			return err
+		}
+	}
+
+	return validateMetadata(ur.Metadata)
 }
 
 // UserRepository defines the interface for user data operations.
 type UserRepository interface {
-	CreateUser(user User) (User, error)
-	GetUserByID(id string) (User, error)
-	GetAllUsers() []User
-	UpdateUser(user User) (User, error)
-	DeleteUser(id string) error
+	CreateUser(ctx context.Context, user User) (User, error)
+	GetUserByID(ctx context.Context, id string) (User, error)
+	GetUserByEmail(ctx context.Context, email string) (User, error)
+	// GetAllUsers returns the page of users query selects, sorted before paging so the order
+	// holds across pages, and the cursor of the next page, which is "" after the last page.
+	GetAllUsers(ctx context.Context, query ListQuery) ([]User, string, error)
+	// UpdateUser replaces the stored user with ID user.ID. user.Version must be the stored
+	// version, otherwise ErrVersionConflict is returned; the stored version is then incremented.
+	// ErrDuplicateEmail is returned when another user has user.Email.
+	UpdateUser(ctx context.Context, user User) (User, error)
+	DeleteUser(ctx context.Context, id string) error
+	// DeleteAllUsers deletes every user and returns how many were deleted. It is meant for
+	// wiping test environments and need not be atomic: on error, some users may be gone.
+	DeleteAllUsers(ctx context.Context) (int, error)
+}
+
+// BulkCreateResult is the outcome of creating one user with BulkCreateUsers.
+type BulkCreateResult struct {
+	User User
+	Err  error
+}
+
+// BulkCreator is implemented by repositories that can create many users in few round trips.
+// Unlike BatchCreator the batch is not atomic: each user is created or fails on its own.
+type BulkCreator interface {
+	BulkCreateUsers(ctx context.Context, users []User) []BulkCreateResult
+}
+
+// BulkCreateUsers creates each of users, returning one result per user in the same order. It
+// uses repo's BulkCreateUsers when it has one and a CreateUser call per user otherwise.
+func BulkCreateUsers(ctx context.Context, repo UserRepository, users []User) []BulkCreateResult {
+	if creator, ok := repo.(BulkCreator); ok {
+		return creator.BulkCreateUsers(ctx, users)
+	}
+
+	results := make([]BulkCreateResult, len(users))
+	for i, user := range users {
+		results[i].User, results[i].Err = repo.CreateUser(ctx, user)
+	}
+
+	return results
+}
+
+// ListAllUsers reads every page of users from repo.
+func ListAllUsers(ctx context.Context, repo UserRepository) ([]User, error) {
+	var users []User
+	cursor := ""
+	for {
+		page, next, err := repo.GetAllUsers(ctx, ListQuery{Limit: listAllPageSize, Cursor: cursor})
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, page...)
+		if next == "" {
+			return users, nil
+		}
+		cursor = next
+	}
+}
+
+// HealthChecker is implemented by repositories that can verify their backing store is reachable.
+type HealthChecker interface {
+	HealthCheck(ctx context.Context) error
+}
+
+// AtomicUpdater is implemented by repositories that can apply a read-modify-write to a
+// single user atomically, so concurrent updates to the same user cannot be lost. If update
+// changes the version of the user it is given, ErrVersionConflict is returned instead.
+type AtomicUpdater interface {
+	UpdateUserFunc(ctx context.Context, id string, update func(User) User) (User, error)
+}
+
+// BatchCreator is implemented by repositories that can create several users atomically:
+// either every user is created or, if any of them conflicts, none is.
+type BatchCreator interface {
+	CreateUsers(ctx context.Context, users []User) ([]User, error)
+}
+
+// SchemaValidator is implemented by repositories that can verify their backing store's
+// schema matches what they expect.
+type SchemaValidator interface {
+	ValidateSchema(ctx context.Context) error
 }
 
 // inMemoryUserRepository implements UserRepository using an in-memory map.
 type inMemoryUserRepository struct {
+	mu    sync.RWMutex
 	users map[string]User
 }
 
@@ -63,177 +229,253 @@ func NewInMemoryUserRepository() UserRepository {
 
 // ClearInMemoryUsers clears the in-memory user store for testing.
 func ClearInMemoryUsers() {
-	globalInMemoryUserRepository.users = make(map[string]User)
+	globalInMemoryUserRepository.ClearUsers()
 }
 
 // ClearUsers clears the in-memory user store for testing.
 func (r *inMemoryUserRepository) ClearUsers() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	r.users = make(map[string]User)
 }
 
-func (r *inMemoryUserRepository) GetUserByID(id string) (User, error) {
+func (r *inMemoryUserRepository) GetUserByID(ctx context.Context, id string) (User, error) {
+	if err := ctx.Err(); err != nil {
+		return User{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
 	user, exists := r.users[id]
 	if !exists {
-		return User{}, errors.New("user not found")
+		return User{}, ErrUserNotFound
 	}
 
 	return user, nil
 }
 
-func (r *inMemoryUserRepository) GetAllUsers() []User {
-	userList := make([]User, 0, len(r.users))
-	for _, user := range r.users {
-		userList = append(userList, user)
+// GetUserByIDProjected reads a user and zeroes the fields outside projection.
+func (r *inMemoryUserRepository) GetUserByIDProjected(
+	ctx context.Context, id string, projection Projection,
+) (User, error) {
+	user, err := r.GetUserByID(ctx, id)
+	if err != nil {
+		return User{}, err
 	}
 
-	return userList
+	return projection.Apply(user), nil
 }
 
-func (r *inMemoryUserRepository) CreateUser(user User) (User, error) {
-	r.users[user.ID] = user
+// GetUserByEmail finds the user with email, compared with SameEmail.
+func (r *inMemoryUserRepository) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	if err := ctx.Err(); err != nil {
+		return User{}, err
+	}
 
-	return user, nil
-}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 
-func (r *inMemoryUserRepository) UpdateUser(user User) (User, error) {
-	_, exists := r.users[user.ID]
-	if !exists {
-		return User{}, errors.New("user not found")
+	for _, user := range r.users {
+		if SameEmail(user.Email, email) {
+			return user, nil
+		}
 	}
-	r.users[user.ID] = user
 
-	return user, nil
+	return User{}, ErrUserNotFound
 }
 
-func (r *inMemoryUserRepository) DeleteUser(id string) error {
-	_, exists := r.users[id]
-	if !exists {
-		return errors.New("user not found")
+// GetAllUsers filters and sorts every user and returns the page after the query's cursor.
+func (r *inMemoryUserRepository) GetAllUsers(ctx context.Context, query ListQuery) ([]User, string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, "", err
 	}
-	delete(r.users, id)
-
-	return nil
-}
 
-// dynamoDBUserRepository implements UserRepository for DynamoDB.
-type dynamoDBUserRepository struct {
-	db        dynamodbiface.DynamoDBAPI
-	tableName string
-}
+	r.mu.RLock()
+	userList := make([]User, 0, len(r.users))
+	for _, user := range r.users {
+		if query.matches(user) {
+			userList = append(userList, user)
+		}
+	}
+	r.mu.RUnlock()
 
-// NewDynamoDBUserRepository creates a new instance of dynamoDBUserRepository.
-func NewDynamoDBUserRepository(db dynamodbiface.DynamoDBAPI, tableName string) UserRepository {
-	return &dynamoDBUserRepository{db: db, tableName: tableName}
+	return pageUsers(userList, query)
 }
 
-// CreateUser inserts a new user into DynamoDB.
-func (r *dynamoDBUserRepository) CreateUser(user User) (User, error) {
-	av, err := dynamodbattribute.MarshalMap(user)
-	if err != nil {
-		return User{}, fmt.Errorf("failed to marshal user: %w", err)
+func (r *inMemoryUserRepository) CreateUser(ctx context.Context, user User) (User, error) {
+	if err := ctx.Err(); err != nil {
+		return User{}, err
 	}
 
-	input := &dynamodb.PutItemInput{
-		Item:      av,
-		TableName: aws.String(r.tableName),
-	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	_, err = r.db.PutItem(input)
-	if err != nil {
-		return User{}, fmt.Errorf("failed to put item to DynamoDB: %w", err)
+	if _, exists := r.users[user.ID]; exists {
+		return User{}, ErrDuplicateID
 	}
+	for _, existing := range r.users {
+		if SameEmail(existing.Email, user.Email) {
+			return User{}, ErrDuplicateEmail
+		}
+	}
+	if user.UpdatedAt.IsZero() {
+		user.UpdatedAt = user.CreatedAt
+	}
+	user.Version = 1
+	user.truncateTimestamps()
+	r.users[user.ID] = user
 
 	return user, nil
 }
 
-// GetUserByID retrieves a user from DynamoDB by ID.
-func (r *dynamoDBUserRepository) GetUserByID(id string) (User, error) {
-	input := &dynamodb.GetItemInput{
-		Key: map[string]*dynamodb.AttributeValue{
-			"ID": {
-				S: aws.String(id),
-			},
-		},
-		TableName: aws.String(r.tableName),
+// CreateUsers creates every user or, when an ID or email is already taken or repeated in
+// users, none of them.
+func (r *inMemoryUserRepository) CreateUsers(ctx context.Context, users []User) ([]User, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
-
-	result, err := r.db.GetItem(input)
-	if err != nil {
-		return User{}, fmt.Errorf("failed to get item from DynamoDB: %w", err)
+	if len(users) == 0 {
+		return nil, ErrEmptyBatch
 	}
-
-	if result.Item == nil {
-		return User{}, errors.New("user not found")
+	if len(users) > MaxBatchCreateSize {
+		return nil, ErrBatchTooLarge
 	}
 
-	var user User
-	err = dynamodbattribute.UnmarshalMap(result.Item, &user)
-	if err != nil {
-		return User{}, fmt.Errorf("failed to unmarshal item: %w", err)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	created := make([]User, len(users))
+	for i, user := range users {
+		if _, exists := r.users[user.ID]; exists {
+			return nil, fmt.Errorf("user %d: %w", i+1, ErrDuplicateID)
+		}
+		for _, existing := range r.users {
+			if SameEmail(existing.Email, user.Email) {
+				return nil, fmt.Errorf("user %d: %w", i+1, ErrDuplicateEmail)
+			}
+		}
+		for _, earlier := range created[:i] {
+			if earlier.ID == user.ID {
+				return nil, fmt.Errorf("user %d: %w", i+1, ErrDuplicateID)
+			}
+			if SameEmail(earlier.Email, user.Email) {
+				return nil, fmt.Errorf("user %d: %w", i+1, ErrDuplicateEmail)
+			}
+		}
+		if user.UpdatedAt.IsZero() {
+			user.UpdatedAt = user.CreatedAt
+		}
+		user.Version = 1
+		user.truncateTimestamps()
+		created[i] = user
+	}
+	for _, user := range created {
+		r.users[user.ID] = user
 	}
 
-	return user, nil
+	return created, nil
 }
 
-// GetAllUsers retrieves all users from DynamoDB.
-func (r *dynamoDBUserRepository) GetAllUsers() []User {
-	input := &dynamodb.ScanInput{
-		TableName: aws.String(r.tableName),
+// emailTaken reports whether a user other than the one with ID id has email. The caller must
+// hold r.mu.
+func (r *inMemoryUserRepository) emailTaken(email, id string) bool {
+	for _, existing := range r.users {
+		if existing.ID != id && SameEmail(existing.Email, email) {
+			return true
+		}
 	}
 
-	result, err := r.db.Scan(input)
-	if err != nil {
-		// Log the error, but return an empty list as per the interface signature
-		fmt.Printf("failed to scan items from DynamoDB: %v\n", err)
-		return []User{}
+	return false
+}
+
+// UpdateUser replaces a stored user, returning ErrDuplicateEmail when another user has its
+// email. CreatedAt is always carried over from the stored record.
+func (r *inMemoryUserRepository) UpdateUser(ctx context.Context, user User) (User, error) {
+	if err := ctx.Err(); err != nil {
+		return User{}, err
 	}
 
-	var users []User
-	err = dynamodbattribute.UnmarshalListOfMaps(result.Items, &users)
-	if err != nil {
-		// Log the error, but return an empty list
-		fmt.Printf("failed to unmarshal scan items: %v\n", err)
-		return []User{}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, exists := r.users[user.ID]
+	if !exists {
+		return User{}, ErrUserNotFound
 	}
+	if user.Version != existing.Version {
+		return User{}, ErrVersionConflict
+	}
+	if r.emailTaken(user.Email, user.ID) {
+		return User{}, ErrDuplicateEmail
+	}
+	user.Version++
+	user.CreatedAt = existing.CreatedAt
+	user.truncateTimestamps()
+	r.users[user.ID] = user
 
-	return users
+	return user, nil
 }
 
-// UpdateUser updates an existing user in DynamoDB.
-func (r *dynamoDBUserRepository) UpdateUser(user User) (User, error) {
-	av, err := dynamodbattribute.MarshalMap(user)
-	if err != nil {
-		return User{}, fmt.Errorf("failed to marshal user: %w", err)
+// UpdateUserFunc replaces a stored user with update(user) under the repository lock, returning
+// ErrDuplicateEmail when another user has the updated email. CreatedAt is always carried over
+// from the stored record.
+func (r *inMemoryUserRepository) UpdateUserFunc(ctx context.Context, id string, update func(User) User) (User, error) {
+	if err := ctx.Err(); err != nil {
+		return User{}, err
 	}
 
-	input := &dynamodb.PutItemInput{
-		Item:      av,
-		TableName: aws.String(r.tableName),
-	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
 
-	_, err = r.db.PutItem(input)
-	if err != nil {
-		return User{}, fmt.Errorf("failed to update item in DynamoDB: %w", err)
+	existing, exists := r.users[id]
+	if !exists {
+		return User{}, ErrUserNotFound
+	}
+	user := update(existing)
+	if user.Version != existing.Version {
+		return User{}, ErrVersionConflict
 	}
+	if r.emailTaken(user.Email, id) {
+		return User{}, ErrDuplicateEmail
+	}
+	user.Version++
+	user.ID = id
+	user.CreatedAt = existing.CreatedAt
+	user.truncateTimestamps()
+	r.users[id] = user
 
 	return user, nil
 }
 
-// DeleteUser deletes a user from DynamoDB by ID.
-func (r *dynamoDBUserRepository) DeleteUser(id string) error {
-	input := &dynamodb.DeleteItemInput{
-		Key: map[string]*dynamodb.AttributeValue{
-			"ID": {
-				S: aws.String(id),
-			},
-		},
-		TableName: aws.String(r.tableName),
+func (r *inMemoryUserRepository) DeleteUser(ctx context.Context, id string) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
 
-	_, err := r.db.DeleteItem(input)
-	if err != nil {
-		return fmt.Errorf("failed to delete item from DynamoDB: %w", err)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, exists := r.users[id]
+	if !exists {
+		return ErrUserNotFound
 	}
+	delete(r.users, id)
 
 	return nil
 }
+
+func (r *inMemoryUserRepository) DeleteAllUsers(ctx context.Context) (int, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	deleted := len(r.users)
+	r.users = make(map[string]User)
+
+	return deleted, nil
+}
@@ -0,0 +1,327 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestInMemoryUpdateUserFuncConcurrent(t *testing.T) {
+	tests := []struct {
+		name    string
+		updates int
+	}{
+		{name: "two updates", updates: 2},
+		{name: "many updates", updates: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ClearInMemoryUsers()
+			t.Cleanup(ClearInMemoryUsers)
+
+			ctx := context.Background()
+			repo := NewInMemoryUserRepository()
+			updater, ok := repo.(AtomicUpdater)
+			if !ok {
+				t.Fatal("the in-memory repository does not implement AtomicUpdater")
+			}
+			created, err := repo.CreateUser(ctx, User{ID: "user-1", Name: "Ann", Email: "ann@example.com"})
+			if err != nil {
+				t.Fatalf("CreateUser() error = %v", err)
+			}
+
+			// Each update adds its own metadata key to whatever the user holds when it runs
+			var wg sync.WaitGroup
+			errs := make(chan error, tt.updates)
+			for i := range tt.updates {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					_, err := updater.UpdateUserFunc(ctx, created.ID, func(user User) User {
+						metadata := maps.Clone(user.Metadata)
+						if metadata == nil {
+							metadata = make(map[string]string)
+						}
+						metadata[fmt.Sprintf("update-%d", i)] = "done"
+						user.Metadata = metadata
+						return user
+					})
+					errs <- err
+				}()
+			}
+			wg.Wait()
+			close(errs)
+			for err := range errs {
+				if err != nil {
+					t.Errorf("UpdateUserFunc() error = %v", err)
+				}
+			}
+
+			stored, err := repo.GetUserByID(ctx, created.ID)
+			if err != nil {
+				t.Fatalf("GetUserByID() error = %v", err)
+			}
+			if len(stored.Metadata) != tt.updates {
+				t.Errorf("stored %d metadata keys, want %d: updates were lost", len(stored.Metadata), tt.updates)
+			}
+			if want := created.Version + tt.updates; stored.Version != want {
+				t.Errorf("version = %d, want %d", stored.Version, want)
+			}
+		})
+	}
+}
+
+func TestInMemoryUserTimestamps(t *testing.T) {
+	createdAt := time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+	updatedAt := createdAt.Add(time.Hour)
+
+	tests := []struct {
+		name          string
+		create        User
+		update        *User
+		wantCreatedAt time.Time
+		wantUpdatedAt time.Time
+	}{
+		{
+			name:          "create defaults UpdatedAt to CreatedAt",
+			create:        User{ID: "user-1", Name: "Ann", Email: "ann@example.com", CreatedAt: createdAt},
+			wantCreatedAt: createdAt,
+			wantUpdatedAt: createdAt,
+		},
+		{
+			name: "create keeps a given UpdatedAt",
+			create: User{
+				ID: "user-1", Name: "Ann", Email: "ann@example.com", CreatedAt: createdAt, UpdatedAt: updatedAt,
+			},
+			wantCreatedAt: createdAt,
+			wantUpdatedAt: updatedAt,
+		},
+		{
+			name:          "update keeps UpdatedAt and the stored CreatedAt",
+			create:        User{ID: "user-1", Name: "Ann", Email: "ann@example.com", CreatedAt: createdAt},
+			update:        &User{ID: "user-1", Name: "Ann Updated", Email: "ann@example.com", Version: 1, UpdatedAt: updatedAt},
+			wantCreatedAt: createdAt,
+			wantUpdatedAt: updatedAt,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ClearInMemoryUsers()
+			t.Cleanup(ClearInMemoryUsers)
+
+			ctx := context.Background()
+			repo := NewInMemoryUserRepository()
+			if _, err := repo.CreateUser(ctx, tt.create); err != nil {
+				t.Fatalf("CreateUser() error = %v", err)
+			}
+			if tt.update != nil {
+				if _, err := repo.UpdateUser(ctx, *tt.update); err != nil {
+					t.Fatalf("UpdateUser() error = %v", err)
+				}
+			}
+
+			stored, err := repo.GetUserByID(ctx, tt.create.ID)
+			if err != nil {
+				t.Fatalf("GetUserByID() error = %v", err)
+			}
+			if !stored.CreatedAt.Equal(tt.wantCreatedAt) {
+				t.Errorf("CreatedAt = %s, want %s", stored.CreatedAt, tt.wantCreatedAt)
+			}
+			if !stored.UpdatedAt.Equal(tt.wantUpdatedAt) {
+				t.Errorf("UpdatedAt = %s, want %s", stored.UpdatedAt, tt.wantUpdatedAt)
+			}
+		})
+	}
+}
+
+func TestUserRequestValidateEmail(t *testing.T) {
+	tests := []struct {
+		name     string
+		request  UserRequest
+		isUpdate bool
+		wantErr  string
+	}{
+		{name: "valid on create", request: UserRequest{Name: "Ann", Email: "ann@example.com"}},
+		{name: "valid with a subdomain and tag", request: UserRequest{Name: "Ann", Email: "ann+news@mail.example.co.uk"}},
+		{name: "missing on create", request: UserRequest{Name: "Ann"}, wantErr: "email is required"},
+		{name: "missing @", request: UserRequest{Name: "Ann", Email: "notanemail"}, wantErr: "invalid email format"},
+		{name: "missing domain", request: UserRequest{Name: "Ann", Email: "ann@"}, wantErr: "invalid email format"},
+		{
+			name:    "display name",
+			request: UserRequest{Name: "Ann", Email: "Ann Lee <ann@example.com>"},
+			wantErr: "invalid email format",
+		},
+		{
+			name:    "surrounding spaces",
+			request: UserRequest{Name: "Ann", Email: " ann@example.com "},
+			wantErr: "invalid email format",
+		},
+		{name: "absent on update", request: UserRequest{Name: "Ann"}, isUpdate: true},
+		{name: "valid on update", request: UserRequest{Email: "ann@example.com"}, isUpdate: true},
+		{
+			name:     "invalid on update",
+			request:  UserRequest{Email: "notanemail"},
+			isUpdate: true,
+			wantErr:  "invalid email format",
+		},
+		{
+			name:     "display name on update",
+			request:  UserRequest{Email: `"Ann" <ann@example.com>`},
+			isUpdate: true,
+			wantErr:  "invalid email format",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.request.Validate(tt.isUpdate)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("Validate() error = %v, want nil", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			message := fmt.Sprint(err)
+			if errors.As(err, &validationErr) {
+				message = validationErr.Message
+			}
+			if message != tt.wantErr {
+				t.Errorf("Validate() error = %q, want %q", message, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUserRequestNormalizeName(t *testing.T) {
+	tests := []struct {
+		name     string
+		composed string
+		other    string
+	}{
+		{name: "acute accent", composed: "Jos\u00e9", other: "Jose\u0301"},
+		{name: "several marks", composed: "\u1ec7", other: "e\u0323\u0302"},
+		{name: "marks in either order", composed: "\u1ec7", other: "e\u0302\u0323"},
+		{name: "Hangul", composed: "\ud55c", other: "\u1112\u1161\u11ab"},
+		{name: "trimmed too", composed: "Zo\u00eb", other: "  Zoe\u0308\t"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			composed, other := UserRequest{Name: tt.composed}, UserRequest{Name: tt.other}
+			composed.Normalize()
+			other.Normalize()
+
+			if composed.Name != other.Name {
+				t.Errorf("Normalize() gave %+q and %+q, want the same bytes", composed.Name, other.Name)
+			}
+			if composed.Name != tt.composed {
+				t.Errorf("Normalize() = %+q, want the composed form %+q", composed.Name, tt.composed)
+			}
+		})
+	}
+}
+
+func TestInMemoryCreateUserDuplicateEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		second  User
+		wantErr error
+	}{
+		{name: "same email", second: User{ID: "user-2", Name: "Other", Email: "ann@example.com"}, wantErr: ErrDuplicateEmail},
+		{
+			name:    "domain in another case",
+			second:  User{ID: "user-2", Name: "Other", Email: "ann@EXAMPLE.com"},
+			wantErr: ErrDuplicateEmail,
+		},
+		{name: "same ID", second: User{ID: "user-1", Name: "Other", Email: "other@example.com"}, wantErr: ErrDuplicateID},
+		{name: "another email", second: User{ID: "user-2", Name: "Other", Email: "other@example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ClearInMemoryUsers()
+			t.Cleanup(ClearInMemoryUsers)
+
+			ctx := context.Background()
+			repo := NewInMemoryUserRepository()
+			if _, err := repo.CreateUser(ctx, User{ID: "user-1", Name: "Ann", Email: "ann@example.com"}); err != nil {
+				t.Fatalf("first CreateUser() error = %v", err)
+			}
+			if _, err := repo.CreateUser(ctx, tt.second); !errors.Is(err, tt.wantErr) {
+				t.Errorf("second CreateUser() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInMemoryUpdateUserDuplicateEmail(t *testing.T) {
+	tests := []struct {
+		name    string
+		email   string
+		wantErr error
+	}{
+		{name: "another user's email", email: "bob@example.com", wantErr: ErrDuplicateEmail},
+		{name: "another user's email in another case", email: "bob@EXAMPLE.com", wantErr: ErrDuplicateEmail},
+		{name: "own email", email: "ann@example.com"},
+		{name: "unused email", email: "other@example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ClearInMemoryUsers()
+			t.Cleanup(ClearInMemoryUsers)
+
+			ctx := context.Background()
+			repo := NewInMemoryUserRepository()
+			ann, err := repo.CreateUser(ctx, User{ID: "user-1", Name: "Ann", Email: "ann@example.com"})
+			if err != nil {
+				t.Fatalf("CreateUser() error = %v", err)
+			}
+			if _, err := repo.CreateUser(ctx, User{ID: "user-2", Name: "Bob", Email: "bob@example.com"}); err != nil {
+				t.Fatalf("CreateUser() error = %v", err)
+			}
+
+			ann.Email = tt.email
+			if _, err := repo.UpdateUser(ctx, ann); !errors.Is(err, tt.wantErr) {
+				t.Errorf("UpdateUser() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestInMemoryCancelledContext(t *testing.T) {
+	ClearInMemoryUsers()
+	t.Cleanup(ClearInMemoryUsers)
+	repo := NewInMemoryUserRepository()
+	user := User{ID: "user-1", Name: "Ann", Email: "ann@example.com"}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name string
+		call func() error
+	}{
+		{name: "CreateUser", call: func() error { _, err := repo.CreateUser(ctx, user); return err }},
+		{name: "GetUserByID", call: func() error { _, err := repo.GetUserByID(ctx, user.ID); return err }},
+		{name: "GetAllUsers", call: func() error { _, _, err := repo.GetAllUsers(ctx, ListQuery{}); return err }},
+		{name: "UpdateUser", call: func() error { _, err := repo.UpdateUser(ctx, user); return err }},
+		{name: "DeleteUser", call: func() error { return repo.DeleteUser(ctx, user.ID) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.call(); !errors.Is(err, context.Canceled) {
+				t.Errorf("%s() error = %v, want %v", tt.name, err, context.Canceled)
+			}
+		})
+	}
+}
@@ -0,0 +1,111 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"go-lambda-api/utils"
+)
+
+const (
+	// DefaultNameMaxLength is the maximum name length, in characters, when USER_NAME_MAX_LENGTH is unset.
+	DefaultNameMaxLength = 255
+
+	// DefaultMetadataMaxEntries is the maximum number of metadata entries when METADATA_MAX_ENTRIES is unset.
+	DefaultMetadataMaxEntries = 20
+	// DefaultMetadataMaxKeyLength is the maximum metadata key length when METADATA_MAX_KEY_LENGTH is unset.
+	DefaultMetadataMaxKeyLength = 64
+	// DefaultMetadataMaxValueLength is the maximum metadata value length when METADATA_MAX_VALUE_LENGTH is unset.
+	DefaultMetadataMaxValueLength = 256
+)
+
+// ValidationError reports a request field that is well-formed JSON but violates a constraint.
+// Handlers map it to 422 Unprocessable Entity.
+type ValidationError struct {
+	Field   string
+	Message string
+	// Suggestion, when set, is a corrected value the client probably meant.
+	Suggestion string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Message
+}
+
+// FieldName returns the name of the invalid field.
+func (e *ValidationError) FieldName() string {
+	return e.Field
+}
+
+// SuggestedValue returns the corrected value, or "" when there is none.
+func (e *ValidationError) SuggestedValue() string {
+	return e.Suggestion
+}
+
+// idPattern restricts client-supplied user IDs to URL-safe characters.
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+func validateID(id string) error {
+	if !idPattern.MatchString(id) {
+		return &ValidationError{Field: "id", Message: "id must be 1 to 64 letters, digits, '-' or '_'"}
+	}
+
+	return nil
+}
+
+func validateName(name string) error {
+	if strings.TrimSpace(name) == "" {
+		return &ValidationError{Field: "name", Message: "name must not be blank"}
+	}
+
+	maxLength := utils.GetEnvInt("USER_NAME_MAX_LENGTH", DefaultNameMaxLength)
+	if utf8.RuneCountInString(name) > maxLength {
+		return &ValidationError{Field: "name", Message: fmt.Sprintf("name must be at most %d characters", maxLength)}
+	}
+
+	for _, r := range name {
+		if unicode.IsControl(r) {
+			return &ValidationError{Field: "name", Message: "name must not contain control characters"}
+		}
+	}
+
+	first, _ := utf8.DecodeRuneInString(name)
+	last, _ := utf8.DecodeLastRuneInString(name)
+	if unicode.IsPunct(first) || unicode.IsPunct(last) {
+		return &ValidationError{Field: "name", Message: "name must not start or end with punctuation"}
+	}
+
+	return nil
+}
+
+func validateMetadata(metadata map[string]string) error {
+	maxEntries := utils.GetEnvInt("METADATA_MAX_ENTRIES", DefaultMetadataMaxEntries)
+	if len(metadata) > maxEntries {
+		return &ValidationError{Field: "metadata", Message: fmt.Sprintf("metadata must have at most %d entries", maxEntries)}
+	}
+
+	maxKeyLength := utils.GetEnvInt("METADATA_MAX_KEY_LENGTH", DefaultMetadataMaxKeyLength)
+	maxValueLength := utils.GetEnvInt("METADATA_MAX_VALUE_LENGTH", DefaultMetadataMaxValueLength)
+	for key, value := range metadata {
+		if key == "" {
+			return &ValidationError{Field: "metadata", Message: "metadata keys must not be empty"}
+		}
+		if utf8.RuneCountInString(key) > maxKeyLength {
+			return &ValidationError{
+				Field:   "metadata",
+				Message: fmt.Sprintf("metadata key %q must be at most %d characters", key, maxKeyLength),
+			}
+		}
+		if utf8.RuneCountInString(value) > maxValueLength {
+			return &ValidationError{
+				Field:   "metadata",
+				Message: fmt.Sprintf("metadata value for %q must be at most %d characters", key, maxValueLength),
+			}
+		}
+	}
+
+	return nil
+}
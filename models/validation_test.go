@@ -0,0 +1,116 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestValidateName(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		maxLength string
+		wantErr   string
+	}{
+		{name: "plain", input: "Ann Lee"},
+		{name: "unicode letters", input: "Zoë Łukasz 山田"},
+		{name: "inner punctuation", input: "Mary-Jane O'Neil"},
+		{name: "at default limit", input: strings.Repeat("a", DefaultNameMaxLength)},
+		{
+			name:    "over default limit",
+			input:   strings.Repeat("a", DefaultNameMaxLength+1),
+			wantErr: "name must be at most 255 characters",
+		},
+		{name: "limit counts characters", input: strings.Repeat("é", 10), maxLength: "10"},
+		{name: "over configured limit", input: "Annabelle", maxLength: "5", wantErr: "name must be at most 5 characters"},
+		{name: "blank", input: "   ", wantErr: "name must not be blank"},
+		{name: "newline", input: "Ann\nLee", wantErr: "name must not contain control characters"},
+		{name: "NUL", input: "Ann\x00", wantErr: "name must not contain control characters"},
+		{name: "leading punctuation", input: "-Ann", wantErr: "name must not start or end with punctuation"},
+		{name: "trailing punctuation", input: "Ann!", wantErr: "name must not start or end with punctuation"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("USER_NAME_MAX_LENGTH", tt.maxLength)
+
+			err := validateName(tt.input)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateName(%q) error = %v, want nil", tt.input, err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("validateName(%q) error = %v, want a *ValidationError", tt.input, err)
+			}
+			if validationErr.Field != "name" || validationErr.Message != tt.wantErr {
+				t.Errorf("error = %s: %q, want name: %q", validationErr.Field, validationErr.Message, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateMetadata(t *testing.T) {
+	tooMany := make(map[string]string, DefaultMetadataMaxEntries+1)
+	for i := 0; i <= DefaultMetadataMaxEntries; i++ {
+		tooMany[strings.Repeat("k", i+1)] = "v"
+	}
+
+	tests := []struct {
+		name       string
+		metadata   map[string]string
+		maxEntries string
+		wantErr    string
+	}{
+		{name: "nil", metadata: nil},
+		{name: "entries", metadata: map[string]string{"team": "payments", "tier": ""}},
+		{name: "too many entries", metadata: tooMany, wantErr: "metadata must have at most 20 entries"},
+		{
+			name:       "over configured entries",
+			metadata:   map[string]string{"a": "1", "b": "2"},
+			maxEntries: "1",
+			wantErr:    "metadata must have at most 1 entries",
+		},
+		{name: "empty key", metadata: map[string]string{"": "v"}, wantErr: "metadata keys must not be empty"},
+		{
+			name:     "key at limit counts characters",
+			metadata: map[string]string{strings.Repeat("é", DefaultMetadataMaxKeyLength): "v"},
+		},
+		{
+			name:     "key too long",
+			metadata: map[string]string{strings.Repeat("k", DefaultMetadataMaxKeyLength+1): "v"},
+			wantErr:  `metadata key "` + strings.Repeat("k", DefaultMetadataMaxKeyLength+1) + `" must be at most 64 characters`,
+		},
+		{
+			name:     "value too long",
+			metadata: map[string]string{"team": strings.Repeat("v", DefaultMetadataMaxValueLength+1)},
+			wantErr:  `metadata value for "team" must be at most 256 characters`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("METADATA_MAX_ENTRIES", tt.maxEntries)
+
+			err := validateMetadata(tt.metadata)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("validateMetadata() error = %v, want nil", err)
+				}
+				return
+			}
+
+			var validationErr *ValidationError
+			if !errors.As(err, &validationErr) {
+				t.Fatalf("validateMetadata() error = %v, want a *ValidationError", err)
+			}
+			if validationErr.Field != "metadata" || validationErr.Message != tt.wantErr {
+				t.Errorf("error = %s: %q, want metadata: %q", validationErr.Field, validationErr.Message, tt.wantErr)
+			}
+		})
+	}
+}
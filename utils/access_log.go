@@ -0,0 +1,87 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AccessLogCLF selects Combined Log Format access logs with ACCESS_LOG_FORMAT.
+const AccessLogCLF = "clf"
+
+// clfTimeLayout is the timestamp layout of Common Log Format, e.g. 10/Oct/2000:13:55:36 -0700.
+const clfTimeLayout = "02/Jan/2006:15:04:05 -0700"
+
+// accessLogOutput is where access log lines are written, one per line, without the
+// standard logger's prefix so log pipelines can parse them as-is.
+var accessLogOutput io.Writer = os.Stdout
+
+// AccessLogEntry describes a served request for the access log.
+type AccessLogEntry struct {
+	RemoteAddr string
+	Time       time.Time
+	Method     string
+	URI        string
+	Protocol   string
+	Status     int
+	Bytes      int
+	Referer    string
+	UserAgent  string
+	Duration   time.Duration
+}
+
+// AccessLogEnabled reports whether ACCESS_LOG_FORMAT selects CLF access logs.
+func AccessLogEnabled() bool {
+	return strings.EqualFold(os.Getenv("ACCESS_LOG_FORMAT"), AccessLogCLF)
+}
+
+// LogAccess writes entry in Combined Log Format when access logs are enabled.
+func LogAccess(entry AccessLogEntry) {
+	if !AccessLogEnabled() {
+		return
+	}
+
+	fmt.Fprintln(accessLogOutput, FormatAccessLog(entry))
+}
+
+// FormatAccessLog returns entry as a Combined Log Format line followed by the response time
+// in milliseconds, like nginx's "combined" format with $request_time appended:
+//
+//	203.0.113.7 - - [15/Oct/2026:12:00:00 +0000] "GET /users HTTP/1.1" 200 512 "-" "curl/8.5.0" 3
+//
+// Unknown values are written as "-", and a response without a body as 0 bytes.
+func FormatAccessLog(entry AccessLogEntry) string {
+	return fmt.Sprintf("%s - - [%s] \"%s %s %s\" %d %d \"%s\" \"%s\" %d",
+		clfField(entry.RemoteAddr),
+		entry.Time.Format(clfTimeLayout),
+		clfQuoted(entry.Method), clfQuoted(entry.URI), clfQuoted(entry.Protocol),
+		entry.Status,
+		entry.Bytes,
+		clfQuoted(entry.Referer),
+		clfQuoted(entry.UserAgent),
+		entry.Duration.Milliseconds(),
+	)
+}
+
+// clfField returns value, or "-" when it is empty.
+func clfField(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	return value
+}
+
+// clfQuoted returns value escaped for a quoted CLF field, or "-" when it is empty.
+func clfQuoted(value string) string {
+	if value == "" {
+		return "-"
+	}
+
+	quoted := strconv.Quote(value)
+
+	return quoted[1 : len(quoted)-1]
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestFormatAccessLog(t *testing.T) {
+	at := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		entry AccessLogEntry
+		want  string
+	}{
+		{
+			name: "every field",
+			entry: AccessLogEntry{
+				RemoteAddr: "203.0.113.7",
+				Time:       at,
+				Method:     "GET",
+				URI:        "/users?limit=10",
+				Protocol:   "HTTP/1.1",
+				Status:     200,
+				Bytes:      512,
+				Referer:    "https://example.com/",
+				UserAgent:  "curl/8.5.0",
+				Duration:   3 * time.Millisecond,
+			},
+			want: `203.0.113.7 - - [15/Oct/2026:12:00:00 +0000] "GET /users?limit=10 HTTP/1.1" 200 512 ` +
+				`"https://example.com/" "curl/8.5.0" 3`,
+		},
+		{
+			name:  "unknown values",
+			entry: AccessLogEntry{Time: at, Method: "DELETE", URI: "/users/1", Status: 204},
+			want:  `- - - [15/Oct/2026:12:00:00 +0000] "DELETE /users/1 -" 204 0 "-" "-" 0`,
+		},
+		{
+			name: "quotes escaped",
+			entry: AccessLogEntry{
+				Time: at, Method: "GET", URI: "/", Protocol: "HTTP/1.1", Status: 200, UserAgent: `evil" agent`,
+			},
+			want: `- - - [15/Oct/2026:12:00:00 +0000] "GET / HTTP/1.1" 200 0 "-" "evil\" agent" 0`,
+		},
+		{
+			name: "time zone kept",
+			entry: AccessLogEntry{
+				Time: at.In(time.FixedZone("", -7*60*60)), Method: "GET", URI: "/", Status: 200,
+			},
+			want: `- - - [15/Oct/2026:05:00:00 -0700] "GET / -" 200 0 "-" "-" 0`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := FormatAccessLog(tt.entry); got != tt.want {
+				t.Errorf("FormatAccessLog() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLogAccess(t *testing.T) {
+	output := accessLogOutput
+	t.Cleanup(func() { accessLogOutput = output })
+
+	entry := AccessLogEntry{Time: time.Unix(0, 0).UTC(), Method: "GET", URI: "/health", Status: 200}
+
+	tests := []struct {
+		name   string
+		format string
+		want   string
+	}{
+		{name: "clf", format: "clf", want: FormatAccessLog(entry) + "\n"},
+		{name: "case-insensitive", format: "CLF", want: FormatAccessLog(entry) + "\n"},
+		{name: "unset"},
+		{name: "other format", format: "json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ACCESS_LOG_FORMAT", tt.format)
+			var buf bytes.Buffer
+			accessLogOutput = &buf
+
+			LogAccess(entry)
+			if buf.String() != tt.want {
+				t.Errorf("logged %q, want %q", buf.String(), tt.want)
+			}
+		})
+	}
+}
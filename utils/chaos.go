@@ -0,0 +1,96 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// DefaultChaosLatency is the latency injected when CHAOS_LATENCY_MS is unset.
+const DefaultChaosLatency = time.Second
+
+// ErrChaos is the error of the synthetic failures returned by chaos mode.
+var ErrChaos = errors.New("synthetic failure injected by chaos mode")
+
+// chaosStages are the STAGE values chaos mode may run in. It is an allow-list, so a
+// production stage with an unexpected name such as "prd" or "live" stays protected.
+var chaosStages = map[string]bool{"local": true, "dev": true, "development": true, "test": true, "staging": true}
+
+var chaosModeIgnored sync.Once
+
+// chaosIntN and chaosSleep are the randomness and the wait of InjectChaos, which tests replace
+// with a seeded source and a recorder.
+var (
+	chaosIntN  = rand.IntN
+	chaosSleep = sleepContext
+)
+
+// ChaosMode reports whether CHAOS_MODE=true enables fault injection. It is only on when
+// STAGE is one of chaosStages, or unset outside Lambda, so a stray CHAOS_MODE cannot reach
+// production traffic.
+func ChaosMode() bool {
+	if !GetEnvBool("CHAOS_MODE", false) {
+		return false
+	}
+
+	stage := strings.ToLower(strings.TrimSpace(os.Getenv("STAGE")))
+	if !chaosStages[stage] && (stage != "" || os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != "") {
+		chaosModeIgnored.Do(func() { log.Printf("Ignoring CHAOS_MODE in stage %q", stage) })
+		return false
+	}
+
+	return true
+}
+
+// InjectChaos applies chaos mode to request. For CHAOS_LATENCY_PERCENT percent of requests it
+// waits CHAOS_LATENCY_MS first, or until ctx is done. For CHAOS_ERROR_PERCENT percent it
+// returns a synthetic 500 or 503, chosen at random, and true, and the request must not be
+// served. Health checks are exempt, so probes keep working.
+func InjectChaos(ctx context.Context, request events.APIGatewayProxyRequest) (events.APIGatewayProxyResponse, bool) {
+	if !ChaosMode() || isHealthPath(request.Path) {
+		return events.APIGatewayProxyResponse{}, false
+	}
+
+	if chaosRoll(GetEnvInt("CHAOS_LATENCY_PERCENT", 0)) {
+		latency := time.Duration(GetEnvInt("CHAOS_LATENCY_MS", int(DefaultChaosLatency/time.Millisecond))) * time.Millisecond
+		chaosSleep(ctx, latency)
+	}
+
+	if chaosRoll(GetEnvInt("CHAOS_ERROR_PERCENT", 0)) {
+		status := http.StatusInternalServerError
+		if chaosIntN(2) == 0 {
+			status = http.StatusServiceUnavailable
+		}
+		response, _ := ErrorResponse(status, ErrChaos)
+		EnsureHeaders(&response)
+		response.Headers["X-Chaos-Injected"] = "true"
+
+		return response, true
+	}
+
+	return events.APIGatewayProxyResponse{}, false
+}
+
+// chaosRoll reports true for percent percent of calls.
+func chaosRoll(percent int) bool {
+	return percent > 0 && chaosIntN(100) < percent
+}
+
+// sleepContext waits for d, or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
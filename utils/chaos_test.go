@@ -0,0 +1,139 @@
+package utils
+
+import (
+	"context"
+	"math/rand/v2"
+	"net/http"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestChaosMode(t *testing.T) {
+	tests := []struct {
+		name     string
+		chaos    string
+		stage    string
+		function string
+		want     bool
+	}{
+		{name: "off by default", stage: "dev", want: false},
+		{name: "dev", chaos: "true", stage: "dev", function: "users-api", want: true},
+		{name: "staging in any case", chaos: "true", stage: " Staging ", function: "users-api", want: true},
+		{name: "local server without a stage", chaos: "true", want: true},
+		{name: "prod", chaos: "true", stage: "prod", function: "users-api", want: false},
+		{name: "unknown stage", chaos: "true", stage: "live", function: "users-api", want: false},
+		{name: "Lambda without a stage", chaos: "true", function: "users-api", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CHAOS_MODE", tt.chaos)
+			t.Setenv("STAGE", tt.stage)
+			t.Setenv("AWS_LAMBDA_FUNCTION_NAME", tt.function)
+
+			if got := ChaosMode(); got != tt.want {
+				t.Errorf("ChaosMode() = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+// stubChaos replaces the randomness of InjectChaos with a seeded source and its wait with a
+// recorder, returning the latencies waited.
+func stubChaos(t *testing.T) *[]time.Duration {
+	t.Helper()
+
+	var waited []time.Duration
+	intN, sleep := chaosIntN, chaosSleep
+	chaosIntN = rand.New(rand.NewPCG(1, 2)).IntN
+	chaosSleep = func(_ context.Context, d time.Duration) { waited = append(waited, d) }
+	t.Cleanup(func() { chaosIntN, chaosSleep = intN, sleep })
+
+	return &waited
+}
+
+func TestInjectChaosRates(t *testing.T) {
+	const requests = 400
+	const latency = 250 * time.Millisecond
+
+	// The counts are exactly those of stubChaos's seeded source, near the configured rates
+	tests := []struct {
+		name           string
+		stage          string
+		path           string
+		latencyPercent string
+		errorPercent   string
+		wantDelayed    int
+		wantStatuses   map[int]int
+	}{
+		{name: "latency only", stage: "dev", latencyPercent: "50", wantDelayed: 192},
+		{name: "errors only", stage: "dev", errorPercent: "25",
+			wantStatuses: map[int]int{http.StatusInternalServerError: 49, http.StatusServiceUnavailable: 47}},
+		{name: "every request", stage: "dev", latencyPercent: "100", errorPercent: "100", wantDelayed: requests,
+			wantStatuses: map[int]int{http.StatusInternalServerError: 211, http.StatusServiceUnavailable: 189}},
+		{name: "never in prod", stage: "prod", latencyPercent: "100", errorPercent: "100"},
+		{name: "health checks exempt", stage: "dev", path: "/health", latencyPercent: "100", errorPercent: "100"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("CHAOS_MODE", "true")
+			t.Setenv("STAGE", tt.stage)
+			t.Setenv("CHAOS_LATENCY_PERCENT", tt.latencyPercent)
+			t.Setenv("CHAOS_LATENCY_MS", strconv.Itoa(int(latency/time.Millisecond)))
+			t.Setenv("CHAOS_ERROR_PERCENT", tt.errorPercent)
+			waited := stubChaos(t)
+
+			path := tt.path
+			if path == "" {
+				path = "/users"
+			}
+			request := events.APIGatewayProxyRequest{Path: path}
+
+			statuses := map[int]int{}
+			for range requests {
+				response, injected := InjectChaos(context.Background(), request)
+				if injected {
+					statuses[response.StatusCode]++
+					if response.Headers["X-Chaos-Injected"] != "true" {
+						t.Fatalf("injected response headers = %v, want X-Chaos-Injected", response.Headers)
+					}
+				}
+			}
+
+			if len(*waited) != tt.wantDelayed {
+				t.Errorf("%d of %d requests delayed, want %d", len(*waited), requests, tt.wantDelayed)
+			}
+			for _, d := range *waited {
+				if d != latency {
+					t.Fatalf("waited %v, want %v", d, latency)
+				}
+			}
+			if len(statuses)+len(tt.wantStatuses) > 0 && !reflect.DeepEqual(statuses, tt.wantStatuses) {
+				t.Errorf("injected statuses = %v, want %v", statuses, tt.wantStatuses)
+			}
+		})
+	}
+}
+
+func TestInjectChaosLatencyRespectsDeadline(t *testing.T) {
+	t.Setenv("CHAOS_MODE", "true")
+	t.Setenv("STAGE", "dev")
+	t.Setenv("CHAOS_LATENCY_PERCENT", "100")
+	t.Setenv("CHAOS_LATENCY_MS", "60000")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, injected := InjectChaos(ctx, events.APIGatewayProxyRequest{Path: "/users"}); injected {
+		t.Error("InjectChaos() injected an error, want latency only")
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("InjectChaos() waited %v past the deadline", elapsed)
+	}
+}
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// DefaultGzipMinBytes is the smallest body compressed when GZIP_MIN_BYTES is not set.
+// Smaller bodies gain little and cost CPU.
+const DefaultGzipMinBytes = 1024
+
+// CompressResponse gzips the body of response when the client accepts gzip and the body is
+// at least GZIP_MIN_BYTES long. The compressed body is base64 encoded, as API Gateway
+// requires for binary bodies, and Content-Encoding is set. It must run after anything
+// that reads or rewrites the body.
+func CompressResponse(request events.APIGatewayProxyRequest, response *events.APIGatewayProxyResponse) {
+	if response.IsBase64Encoded || len(response.Body) < GetEnvInt("GZIP_MIN_BYTES", DefaultGzipMinBytes) {
+		return
+	}
+	if response.Headers["Content-Encoding"] != "" || !acceptsGzip(GetHeader(request, "Accept-Encoding")) {
+		return
+	}
+
+	var compressed bytes.Buffer
+	writer := gzip.NewWriter(&compressed)
+	if _, err := writer.Write([]byte(response.Body)); err != nil {
+		return
+	}
+	if err := writer.Close(); err != nil {
+		return
+	}
+
+	EnsureHeaders(response)
+	response.Headers["Content-Encoding"] = "gzip"
+	AddVary(response, "Accept-Encoding")
+	response.Body = base64.StdEncoding.EncodeToString(compressed.Bytes())
+	response.IsBase64Encoded = true
+}
+
+// acceptsGzip reports whether an Accept-Encoding value allows gzip, either by name or
+// through "*", without a zero quality.
+func acceptsGzip(acceptEncoding string) bool {
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding != "gzip" && coding != "*" {
+			continue
+		}
+
+		quality := 1.0
+		if q, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(q, 64); err == nil {
+				quality = parsed
+			}
+		}
+		if quality > 0 {
+			return true
+		}
+	}
+
+	return false
+}
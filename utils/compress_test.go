@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestCompressResponse(t *testing.T) {
+	large := `{"users":[` + strings.TrimSuffix(strings.Repeat(`{"name":"Ann","email":"ann@example.com"},`, 40), ",") + `]}`
+	small := `{"status":"ok"}`
+
+	tests := []struct {
+		name           string
+		acceptEncoding string
+		minBytes       string
+		body           string
+		wantGzip       bool
+	}{
+		{name: "large body accepting gzip", acceptEncoding: "gzip, deflate, br", body: large, wantGzip: true},
+		{name: "wildcard encoding", acceptEncoding: "*", body: large, wantGzip: true},
+		{name: "small body", acceptEncoding: "gzip", body: small},
+		{name: "configured threshold", acceptEncoding: "gzip", minBytes: "10", body: small, wantGzip: true},
+		{name: "gzip not accepted", acceptEncoding: "deflate", body: large},
+		{name: "gzip refused with q=0", acceptEncoding: "gzip;q=0, deflate", body: large},
+		{name: "no Accept-Encoding", body: large},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("GZIP_MIN_BYTES", tt.minBytes)
+
+			request := events.APIGatewayProxyRequest{Headers: map[string]string{"Accept-Encoding": tt.acceptEncoding}}
+			response := events.APIGatewayProxyResponse{
+				StatusCode: http.StatusOK,
+				Headers:    map[string]string{"Content-Type": "application/json"},
+				Body:       tt.body,
+			}
+			CompressResponse(request, &response)
+
+			if !tt.wantGzip {
+				if response.IsBase64Encoded || response.Body != tt.body || response.Headers["Content-Encoding"] != "" {
+					t.Errorf("response = %+v, want it unchanged", response)
+				}
+				return
+			}
+
+			if !response.IsBase64Encoded {
+				t.Error("IsBase64Encoded = false, want true")
+			}
+			if got := response.Headers["Content-Encoding"]; got != "gzip" {
+				t.Errorf("Content-Encoding = %q, want gzip", got)
+			}
+			if got := response.Headers["Vary"]; got != "Accept-Encoding" {
+				t.Errorf("Vary = %q, want Accept-Encoding", got)
+			}
+
+			compressed, err := base64.StdEncoding.DecodeString(response.Body)
+			if err != nil {
+				t.Fatalf("decoding base64 body: %v", err)
+			}
+			reader, err := gzip.NewReader(bytes.NewReader(compressed))
+			if err != nil {
+				t.Fatalf("opening gzip body: %v", err)
+			}
+			decoded, err := io.ReadAll(reader)
+			if err != nil {
+				t.Fatalf("reading gzip body: %v", err)
+			}
+			if string(decoded) != tt.body {
+				t.Errorf("decompressed body = %s, want %s", decoded, tt.body)
+			}
+		})
+	}
+}
@@ -0,0 +1,44 @@
+package utils
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ErrContentLengthMismatch is returned by CheckContentLength; it maps to 400 Bad Request.
+var ErrContentLengthMismatch = errors.New("content length mismatch")
+
+// BodyLength returns the length in bytes of a request or response body, decoding the length
+// of base64-encoded bodies without decoding them.
+func BodyLength(body string, isBase64Encoded bool) int {
+	if !isBase64Encoded {
+		return len(body)
+	}
+
+	return base64.StdEncoding.DecodedLen(len(body)) - strings.Count(body[max(0, len(body)-2):], "=")
+}
+
+// CheckContentLength rejects requests whose Content-Length header is not a valid length or
+// differs from the size of the body, which for base64-encoded bodies is the decoded size.
+// Requests without the header are accepted.
+func CheckContentLength(request events.APIGatewayProxyRequest) error {
+	header := GetHeader(request, "Content-Length")
+	if header == "" {
+		return nil
+	}
+
+	declared, err := strconv.Atoi(strings.TrimSpace(header))
+	if err != nil || declared < 0 {
+		return fmt.Errorf("%w: invalid Content-Length %q", ErrContentLengthMismatch, header)
+	}
+	if actual := BodyLength(request.Body, request.IsBase64Encoded); declared != actual {
+		return fmt.Errorf("%w: Content-Length is %d but the body is %d bytes", ErrContentLengthMismatch, declared, actual)
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestBodyLength(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		base64 bool
+		want   int
+	}{
+		{name: "empty", want: 0},
+		{name: "plain", body: "héllo", want: 6},
+		{name: "base64 without padding", body: "YWJj", base64: true, want: 3},
+		{name: "base64 with one pad", body: "YWI=", base64: true, want: 2},
+		{name: "base64 with two pads", body: "YQ==", base64: true, want: 1},
+		{name: "empty base64", base64: true, want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := BodyLength(tt.body, tt.base64); got != tt.want {
+				t.Errorf("BodyLength(%q, %v) = %d, want %d", tt.body, tt.base64, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCheckContentLength(t *testing.T) {
+	tests := []struct {
+		name          string
+		contentLength string
+		body          string
+		base64        bool
+		wantErr       bool
+	}{
+		{name: "matching", contentLength: "13", body: `{"name":"A"}` + "\n"},
+		{name: "matching with whitespace", contentLength: " 3 ", body: "abc"},
+		{name: "mismatched", contentLength: "20", body: "abc", wantErr: true},
+		{name: "absent", body: "abc"},
+		{name: "zero for an empty body", contentLength: "0"},
+		{name: "not a number", contentLength: "three", body: "abc", wantErr: true},
+		{name: "negative", contentLength: "-1", wantErr: true},
+		{name: "base64 matching the decoded size", contentLength: "3", body: "YWJj", base64: true},
+		{name: "base64 matching the encoded size", contentLength: "4", body: "YWJj", base64: true, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := events.APIGatewayProxyRequest{Body: tt.body, IsBase64Encoded: tt.base64}
+			if tt.contentLength != "" {
+				request.Headers = map[string]string{"content-length": tt.contentLength}
+			}
+
+			err := CheckContentLength(request)
+			if tt.wantErr != errors.Is(err, ErrContentLengthMismatch) {
+				t.Errorf("CheckContentLength() error = %v, want mismatch %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("CheckContentLength() error = %v, want nil", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// UnknownFieldError reports a JSON object key that the target type has no field for.
+type UnknownFieldError struct {
+	Field string
+}
+
+func (e *UnknownFieldError) Error() string {
+	return fmt.Sprintf("unknown field %q", e.Field)
+}
+
+// FieldName returns the unknown key.
+func (e *UnknownFieldError) FieldName() string {
+	return e.Field
+}
+
+// DecodeStrict unmarshals the JSON value in body into v like json.Unmarshal, but rejects
+// keys v has no field for with an *UnknownFieldError, so typos such as "emial" are not
+// silently dropped, and rejects anything after the value.
+func DecodeStrict(body []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		// encoding/json has no typed error for unknown fields
+		if field, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return &UnknownFieldError{Field: strings.Trim(field, `"`)}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return errors.New("invalid JSON: unexpected end of input")
+		}
+
+		var syntaxErr *json.SyntaxError
+		if errors.As(err, &syntaxErr) {
+			return fmt.Errorf("invalid JSON at offset %d: %w", syntaxErr.Offset, err)
+		}
+
+		return err
+	}
+	if _, err := decoder.Token(); !errors.Is(err, io.EOF) {
+		return errors.New("invalid JSON: unexpected data after the top-level value")
+	}
+
+	return nil
+}
@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestDecodeStrict(t *testing.T) {
+	type user struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	}
+
+	tests := []struct {
+		name      string
+		body      string
+		want      user
+		wantField string
+		wantErr   string
+	}{
+		{
+			name: "valid body",
+			body: `{"name":"Ann","email":"ann@example.com"}`,
+			want: user{Name: "Ann", Email: "ann@example.com"},
+		},
+		{name: "unknown field", body: `{"name":"Ann","emial":"ann@example.com"}`, wantField: "emial"},
+		{name: "malformed JSON", body: `{"name":"Ann",}`, wantErr: "invalid JSON at offset 15: " +
+			"invalid character '}' looking for beginning of object key string"},
+		{name: "truncated JSON", body: `{"name":`, wantErr: "invalid JSON: unexpected end of input"},
+		{name: "empty body", body: ``, wantErr: "invalid JSON: unexpected end of input"},
+		{
+			name:    "trailing data",
+			body:    `{"name":"Ann"} {"name":"Bob"}`,
+			wantErr: "invalid JSON: unexpected data after the top-level value",
+		},
+		{name: "trailing whitespace", body: "{\"name\":\"Ann\"}\n", want: user{Name: "Ann"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got user
+			err := DecodeStrict([]byte(tt.body), &got)
+
+			var unknownErr *UnknownFieldError
+			switch {
+			case tt.wantField != "":
+				if !errors.As(err, &unknownErr) || unknownErr.FieldName() != tt.wantField {
+					t.Errorf("DecodeStrict() error = %v, want unknown field %q", err, tt.wantField)
+				}
+			case tt.wantErr != "":
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("DecodeStrict() error = %v, want %q", err, tt.wantErr)
+				}
+			case err != nil:
+				t.Errorf("DecodeStrict() error = %v, want nil", err)
+			case got != tt.want:
+				t.Errorf("DecodeStrict() decoded %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"log"
+	"os"
+	"strconv"
+)
+
+// GetEnvInt returns the integer value of the environment variable key,
+// or fallback when it is unset or not a valid integer.
+func GetEnvInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("Invalid integer for %s=%q, using default %d", key, value, fallback)
+		return fallback
+	}
+
+	return n
+}
+
+// GetEnvBool returns the boolean value of the environment variable key,
+// or fallback when it is unset or not a valid boolean.
+func GetEnvBool(key string, fallback bool) bool {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		log.Printf("Invalid boolean for %s=%q, using default %t", key, value, fallback)
+		return fallback
+	}
+
+	return b
+}
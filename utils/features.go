@@ -0,0 +1,156 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// FeatureOverridesHeader carries per-request feature flag overrides,
+// e.g. "strict_json=true,pretty_json=true". Only honored for trusted callers.
+const FeatureOverridesHeader = "X-Feature-Overrides"
+
+// Feature flags. Each defaults to the FEATURE_<NAME> environment variable.
+const (
+	// FeatureStrictJSON rejects unknown fields in request bodies. It is on by default.
+	FeatureStrictJSON = "strict_json"
+	// FeaturePrettyJSON indents JSON response bodies.
+	FeaturePrettyJSON = "pretty_json"
+)
+
+// overridableFeatures are the flags that are safe to toggle per request.
+var overridableFeatures = map[string]bool{
+	FeatureStrictJSON: true,
+	FeaturePrettyJSON: true,
+}
+
+// featureDefaults are the values of flags whose FEATURE_<NAME> variable is unset, when not false.
+var featureDefaults = map[string]bool{
+	FeatureStrictJSON: true,
+}
+
+// Features is a set of feature flag values.
+type Features map[string]bool
+
+type featuresKey struct{}
+
+// DefaultFeatures returns the flag values configured through the environment.
+func DefaultFeatures() Features {
+	features := make(Features, len(overridableFeatures))
+	for name := range overridableFeatures {
+		features[name] = GetEnvBool("FEATURE_"+strings.ToUpper(name), featureDefaults[name])
+	}
+
+	return features
+}
+
+// RequestFeatures returns the flag values for request: the environment defaults, with each
+// flag that is off turned on for the FEATURE_<NAME>_ROLLOUT percent of requests selected by
+// InRollout.
+func RequestFeatures(request events.APIGatewayProxyRequest) Features {
+	features := DefaultFeatures()
+	key := RolloutKey(request)
+	for name, enabled := range features {
+		if !enabled {
+			features[name] = InRollout(name, key, GetEnvInt("FEATURE_"+strings.ToUpper(name)+"_ROLLOUT", 0))
+		}
+	}
+
+	return features
+}
+
+// RolloutKey returns the value a request is bucketed by for percentage rollouts: the {id}
+// path parameter when there is one, so every request for a user takes the same path, and
+// otherwise the caller's source IP.
+func RolloutKey(request events.APIGatewayProxyRequest) string {
+	if id := request.PathParameters["id"]; id != "" {
+		return id
+	}
+
+	return request.RequestContext.Identity.SourceIP
+}
+
+// InRollout reports whether key falls in the first percent of 100 buckets for the named
+// flag. The bucket is a hash of the flag name and key, so the same key always gets the same
+// answer, raising percent only adds keys, and each flag selects a different subset. An
+// empty key is never in a rollout.
+func InRollout(name, key string, percent int) bool {
+	if key == "" || percent <= 0 {
+		return false
+	}
+	if percent >= 100 {
+		return true
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(name + ":" + key))
+
+	return hash.Sum32()%100 < uint32(percent)
+}
+
+// ApplyFeatureOverrides parses a comma-separated list of name=bool pairs and returns a copy
+// of features with them applied. Unknown or non-overridable flags are rejected.
+func ApplyFeatureOverrides(features Features, header string) (Features, error) {
+	overridden := make(Features, len(features))
+	for name, enabled := range features {
+		overridden[name] = enabled
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		name, value, _ := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !overridableFeatures[name] {
+			return features, fmt.Errorf("feature %q cannot be overridden", name)
+		}
+
+		enabled, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return features, fmt.Errorf("invalid value for feature %q", name)
+		}
+		overridden[name] = enabled
+	}
+
+	return overridden, nil
+}
+
+// ContextWithFeatures returns a copy of ctx carrying the request's feature flags.
+func ContextWithFeatures(ctx context.Context, features Features) context.Context {
+	return context.WithValue(ctx, featuresKey{}, features)
+}
+
+// FeatureEnabled reports whether the named flag is on for the request in ctx,
+// falling back to the environment default when ctx carries no flags.
+func FeatureEnabled(ctx context.Context, name string) bool {
+	if features, ok := ctx.Value(featuresKey{}).(Features); ok {
+		return features[name]
+	}
+
+	return DefaultFeatures()[name]
+}
+
+// FormatResponse applies response formatting flags, such as pretty_json, to response.
+func FormatResponse(ctx context.Context, response *events.APIGatewayProxyResponse) {
+	if !FeatureEnabled(ctx, FeaturePrettyJSON) || response.IsBase64Encoded || response.Body == "" {
+		return
+	}
+	if contentType := response.Headers["Content-Type"]; !strings.HasPrefix(contentType, "application/json") &&
+		contentType != ProblemContentType {
+		return
+	}
+
+	var indented bytes.Buffer
+	if err := json.Indent(&indented, []byte(response.Body), "", "  "); err == nil {
+		response.Body = indented.String()
+	}
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestInRollout(t *testing.T) {
+	keys := make([]string, 1000)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("user-%d", i)
+	}
+
+	tests := []struct {
+		name    string
+		percent int
+		wantMin int
+		wantMax int
+	}{
+		{name: "off", percent: 0, wantMin: 0, wantMax: 0},
+		{name: "ten percent", percent: 10, wantMin: 70, wantMax: 130},
+		{name: "half", percent: 50, wantMin: 450, wantMax: 550},
+		{name: "everyone", percent: 100, wantMin: 1000, wantMax: 1000},
+		{name: "over 100", percent: 150, wantMin: 1000, wantMax: 1000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selected := 0
+			for _, key := range keys {
+				in := InRollout(FeaturePrettyJSON, key, tt.percent)
+				if again := InRollout(FeaturePrettyJSON, key, tt.percent); again != in {
+					t.Fatalf("InRollout(%q) changed from %v to %v", key, in, again)
+				}
+				if in && !InRollout(FeaturePrettyJSON, key, tt.percent+10) {
+					t.Errorf("%q is in at %d%% but not at %d%%", key, tt.percent, tt.percent+10)
+				}
+				if in {
+					selected++
+				}
+			}
+			if selected < tt.wantMin || selected > tt.wantMax {
+				t.Errorf("%d of %d keys selected, want %d to %d", selected, len(keys), tt.wantMin, tt.wantMax)
+			}
+			if InRollout(FeaturePrettyJSON, "", tt.percent) {
+				t.Errorf("empty key selected at %d%%", tt.percent)
+			}
+		})
+	}
+}
+
+func TestInRolloutPerFlag(t *testing.T) {
+	same := 0
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("user-%d", i)
+		if InRollout(FeaturePrettyJSON, key, 50) == InRollout(FeatureStrictJSON, key, 50) {
+			same++
+		}
+	}
+	if same == 1000 {
+		t.Error("two flags selected the same subset of keys")
+	}
+}
+
+func TestRequestFeatures(t *testing.T) {
+	byID := events.APIGatewayProxyRequest{PathParameters: map[string]string{"id": "user-1"}}
+	byIP := events.APIGatewayProxyRequest{}
+	byIP.RequestContext.Identity.SourceIP = "203.0.113.7"
+
+	tests := []struct {
+		name        string
+		request     events.APIGatewayProxyRequest
+		pretty      string
+		rollout     string
+		wantKey     string
+		wantEnabled bool
+	}{
+		{name: "keyed by user ID", request: byID, rollout: "100", wantKey: "user-1", wantEnabled: true},
+		{name: "keyed by source IP", request: byIP, rollout: "100", wantKey: "203.0.113.7", wantEnabled: true},
+		{name: "no rollout", request: byID, wantKey: "user-1"},
+		{name: "no key", request: events.APIGatewayProxyRequest{}, rollout: "100"},
+		{name: "enabled regardless of rollout", request: byID, pretty: "true", wantKey: "user-1", wantEnabled: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("FEATURE_PRETTY_JSON", tt.pretty)
+			t.Setenv("FEATURE_PRETTY_JSON_ROLLOUT", tt.rollout)
+
+			if key := RolloutKey(tt.request); key != tt.wantKey {
+				t.Errorf("RolloutKey() = %q, want %q", key, tt.wantKey)
+			}
+			if enabled := RequestFeatures(tt.request)[FeaturePrettyJSON]; enabled != tt.wantEnabled {
+				t.Errorf("%s = %v, want %v", FeaturePrettyJSON, enabled, tt.wantEnabled)
+			}
+		})
+	}
+}
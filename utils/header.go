@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// GetHeader returns the value of the request header name, matched case-insensitively.
+// API Gateway keeps the casing the client sent, so exact map lookups are unreliable.
+func GetHeader(request events.APIGatewayProxyRequest, name string) string {
+	if value, ok := request.Headers[name]; ok {
+		return value
+	}
+
+	for key, value := range request.Headers {
+		if strings.EqualFold(key, name) {
+			return value
+		}
+	}
+
+	return ""
+}
+
+// AddVary adds field to response's Vary header unless it is already listed, so the
+// middlewares that each make a response depend on a request header can all say so.
+func AddVary(response *events.APIGatewayProxyResponse, field string) {
+	EnsureHeaders(response)
+
+	vary := response.Headers["Vary"]
+	for _, listed := range strings.Split(vary, ",") {
+		if strings.EqualFold(strings.TrimSpace(listed), field) {
+			return
+		}
+	}
+
+	if vary == "" {
+		response.Headers["Vary"] = field
+	} else {
+		response.Headers["Vary"] = vary + ", " + field
+	}
+}
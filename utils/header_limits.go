@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+const (
+	// DefaultMaxHeaderCount is the maximum number of request headers when MAX_HEADER_COUNT is unset.
+	DefaultMaxHeaderCount = 100
+	// DefaultMaxHeaderBytes is the maximum total size of request header names and values
+	// when MAX_HEADER_BYTES is unset.
+	DefaultMaxHeaderBytes = 16 << 10
+)
+
+// ErrHeadersTooLarge is returned by CheckHeaderLimits; it maps to 431 Request Header Fields Too Large.
+var ErrHeadersTooLarge = errors.New("request header fields too large")
+
+// CheckHeaderLimits rejects requests with more than MAX_HEADER_COUNT header values or more than
+// MAX_HEADER_BYTES of header names and values. Repeated headers count once per value, using
+// MultiValueHeaders when API Gateway provides it.
+func CheckHeaderLimits(request events.APIGatewayProxyRequest) error {
+	count, size := 0, 0
+	if len(request.MultiValueHeaders) > 0 {
+		for name, values := range request.MultiValueHeaders {
+			for _, value := range values {
+				count++
+				size += len(name) + len(value)
+			}
+		}
+	} else {
+		for name, value := range request.Headers {
+			count++
+			size += len(name) + len(value)
+		}
+	}
+
+	if maxCount := GetEnvInt("MAX_HEADER_COUNT", DefaultMaxHeaderCount); count > maxCount {
+		return fmt.Errorf("%w: %d headers, at most %d allowed", ErrHeadersTooLarge, count, maxCount)
+	}
+	if maxBytes := GetEnvInt("MAX_HEADER_BYTES", DefaultMaxHeaderBytes); size > maxBytes {
+		return fmt.Errorf("%w: %d bytes, at most %d allowed", ErrHeadersTooLarge, size, maxBytes)
+	}
+
+	return nil
+}
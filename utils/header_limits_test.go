@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// headers returns n headers named h0, h1, ... with one-character values.
+func headers(n int) map[string]string {
+	headers := make(map[string]string, n)
+	for i := 0; i < n; i++ {
+		headers[fmt.Sprintf("h%d", i)] = "v"
+	}
+
+	return headers
+}
+
+func TestCheckHeaderLimits(t *testing.T) {
+	tests := []struct {
+		name     string
+		maxCount string
+		maxBytes string
+		request  events.APIGatewayProxyRequest
+		wantErr  bool
+	}{
+		{name: "at the default count", request: events.APIGatewayProxyRequest{Headers: headers(DefaultMaxHeaderCount)}},
+		{
+			name:    "above the default count",
+			request: events.APIGatewayProxyRequest{Headers: headers(DefaultMaxHeaderCount + 1)},
+			wantErr: true,
+		},
+		{name: "at the configured count", maxCount: "3", request: events.APIGatewayProxyRequest{Headers: headers(3)}},
+		{
+			name:     "above the configured count",
+			maxCount: "3",
+			request:  events.APIGatewayProxyRequest{Headers: headers(4)},
+			wantErr:  true,
+		},
+		{
+			name:     "repeated values count once each",
+			maxCount: "3",
+			request: events.APIGatewayProxyRequest{
+				Headers:           map[string]string{"a": "1", "b": "2"},
+				MultiValueHeaders: map[string][]string{"a": {"1", "1", "1"}, "b": {"2"}},
+			},
+			wantErr: true,
+		},
+		{
+			name:     "at the configured size",
+			maxBytes: "10",
+			request:  events.APIGatewayProxyRequest{Headers: map[string]string{"name": "value1"}},
+		},
+		{
+			name:     "above the configured size",
+			maxBytes: "10",
+			request:  events.APIGatewayProxyRequest{Headers: map[string]string{"name": "value12"}},
+			wantErr:  true,
+		},
+		{
+			name: "above the default size",
+			request: events.APIGatewayProxyRequest{
+				Headers: map[string]string{"Cookie": strings.Repeat("x", DefaultMaxHeaderBytes)},
+			},
+			wantErr: true,
+		},
+		{
+			name:     "multi-value size",
+			maxBytes: "10",
+			request:  events.APIGatewayProxyRequest{MultiValueHeaders: map[string][]string{"a": {"1234", "1234"}}},
+		},
+		{
+			name:     "multi-value size above the limit",
+			maxBytes: "10",
+			request:  events.APIGatewayProxyRequest{MultiValueHeaders: map[string][]string{"a": {"1234", "12345"}}},
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MAX_HEADER_COUNT", tt.maxCount)
+			t.Setenv("MAX_HEADER_BYTES", tt.maxBytes)
+
+			err := CheckHeaderLimits(tt.request)
+			if tt.wantErr != errors.Is(err, ErrHeadersTooLarge) {
+				t.Errorf("CheckHeaderLimits() error = %v, want ErrHeadersTooLarge: %t", err, tt.wantErr)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("CheckHeaderLimits() error = %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestGetHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers map[string]string
+		lookup  string
+		want    string
+	}{
+		{
+			name:    "exact case",
+			headers: map[string]string{"Authorization": "Bearer a"},
+			lookup:  "Authorization",
+			want:    "Bearer a",
+		},
+		{
+			name:    "lowercase key",
+			headers: map[string]string{"authorization": "Bearer a"},
+			lookup:  "Authorization",
+			want:    "Bearer a",
+		},
+		{
+			name:    "uppercase key",
+			headers: map[string]string{"CONTENT-TYPE": "text/csv"},
+			lookup:  "Content-Type",
+			want:    "text/csv",
+		},
+		{
+			name:    "mixed case lookup",
+			headers: map[string]string{"Idempotency-Key": "k1"},
+			lookup:  "idempotency-KEY",
+			want:    "k1",
+		},
+		{name: "missing", headers: map[string]string{"Accept": "*/*"}, lookup: "Authorization"},
+		{name: "nil headers", lookup: "Authorization"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := events.APIGatewayProxyRequest{Headers: tt.headers}
+			if got := GetHeader(request, tt.lookup); got != tt.want {
+				t.Errorf("GetHeader(%q) = %q, want %q", tt.lookup, got, tt.want)
+			}
+		})
+	}
+}
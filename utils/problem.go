@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ProblemContentType is the RFC 7807 Problem Details media type.
+const ProblemContentType = "application/problem+json"
+
+// FieldError is implemented by errors that relate to a single request field,
+// such as validation errors. ErrorResponse reports the field alongside the message.
+type FieldError interface {
+	error
+	FieldName() string
+}
+
+// SuggestionError is implemented by errors that can propose a corrected value for their
+// field, such as a malformed email. ErrorResponse reports it as "suggestion".
+type SuggestionError interface {
+	error
+	SuggestedValue() string
+}
+
+// Problem is an RFC 7807 Problem Details object.
+type Problem struct {
+	Type   string         `json:"type"`
+	Title  string         `json:"title"`
+	Status int            `json:"status"`
+	Detail string         `json:"detail,omitempty"`
+	Errors []ProblemError `json:"errors,omitempty"`
+}
+
+// ProblemError describes one invalid field in a Problem.
+type ProblemError struct {
+	Field  string `json:"field"`
+	Detail string `json:"detail"`
+}
+
+// problemErrorsEnabled reports whether ERROR_FORMAT=problem selects problem+json for every error.
+func problemErrorsEnabled() bool {
+	return strings.EqualFold(os.Getenv("ERROR_FORMAT"), "problem")
+}
+
+// NegotiateErrorFormat converts an error response to application/problem+json when
+// ERROR_FORMAT=problem is set or the request's Accept header asks for it.
+// Other responses, and error responses already in problem format, are left unchanged.
+func NegotiateErrorFormat(request events.APIGatewayProxyRequest, response *events.APIGatewayProxyResponse) {
+	if response.StatusCode < http.StatusBadRequest || response.Headers["Content-Type"] == ProblemContentType {
+		return
+	}
+	if !problemErrorsEnabled() {
+		AddVary(response, "Accept")
+		if !strings.Contains(GetHeader(request, "Accept"), ProblemContentType) {
+			return
+		}
+	}
+
+	var body map[string]interface{}
+	if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+		return
+	}
+	message, ok := body["error"].(string)
+	if !ok {
+		return
+	}
+
+	problem := Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(response.StatusCode),
+		Status: response.StatusCode,
+		Detail: message,
+	}
+	if field, ok := body["field"].(string); ok {
+		problem.Errors = []ProblemError{{Field: field, Detail: message}}
+	}
+
+	// Keep any extension members, such as existing_id on conflicts
+	document := map[string]interface{}{}
+	for key, value := range body {
+		if key != "error" && key != "field" {
+			document[key] = value
+		}
+	}
+	encoded, err := jsonMarshal(problem)
+	if err != nil || json.Unmarshal(encoded, &document) != nil {
+		return
+	}
+
+	problemBody, err := jsonMarshal(document)
+	if err != nil {
+		return
+	}
+
+	response.Body = string(problemBody)
+	response.Headers["Content-Type"] = ProblemContentType
+}
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// testFieldError is a FieldError for the "email" field.
+type testFieldError struct{}
+
+func (testFieldError) Error() string     { return "email is invalid" }
+func (testFieldError) FieldName() string { return "email" }
+
+func TestNegotiateErrorFormat(t *testing.T) {
+	errorResponse := func(status int, err error) events.APIGatewayProxyResponse {
+		response, _ := ErrorResponse(status, err)
+		return response
+	}
+	conflict, _ := APIResponse(http.StatusConflict, map[string]string{"error": "duplicate email", "existing_id": "u1"})
+	success, _ := APIResponse(http.StatusOK, map[string]string{"id": "u1"})
+
+	tests := []struct {
+		name            string
+		errorFormat     string
+		accept          string
+		response        events.APIGatewayProxyResponse
+		wantContentType string
+		wantBody        map[string]any
+	}{
+		{
+			name:            "simple shape by default",
+			response:        errorResponse(http.StatusNotFound, errors.New("user not found")),
+			wantContentType: "application/json",
+			wantBody:        map[string]any{"error": "user not found"},
+		},
+		{
+			name:            "problem via Accept",
+			accept:          "application/problem+json, application/json;q=0.5",
+			response:        errorResponse(http.StatusNotFound, errors.New("user not found")),
+			wantContentType: ProblemContentType,
+			wantBody: map[string]any{
+				"type": "about:blank", "title": "Not Found", "status": float64(404), "detail": "user not found",
+			},
+		},
+		{
+			name:            "problem via ERROR_FORMAT with field errors",
+			errorFormat:     "problem",
+			response:        errorResponse(http.StatusUnprocessableEntity, testFieldError{}),
+			wantContentType: ProblemContentType,
+			wantBody: map[string]any{
+				"type": "about:blank", "title": "Unprocessable Entity", "status": float64(422),
+				"detail": "email is invalid",
+				"errors": []any{map[string]any{"field": "email", "detail": "email is invalid"}},
+			},
+		},
+		{
+			name:            "extension members are kept",
+			errorFormat:     "problem",
+			response:        conflict,
+			wantContentType: ProblemContentType,
+			wantBody: map[string]any{
+				"type": "about:blank", "title": "Conflict", "status": float64(409), "detail": "duplicate email",
+				"existing_id": "u1",
+			},
+		},
+		{
+			name:            "success responses are unchanged",
+			errorFormat:     "problem",
+			response:        success,
+			wantContentType: "application/json",
+			wantBody:        map[string]any{"id": "u1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("ERROR_FORMAT", tt.errorFormat)
+
+			response := tt.response
+			NegotiateErrorFormat(events.APIGatewayProxyRequest{Headers: map[string]string{"Accept": tt.accept}}, &response)
+
+			if got := response.Headers["Content-Type"]; got != tt.wantContentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantContentType)
+			}
+			var body map[string]any
+			if err := json.Unmarshal([]byte(response.Body), &body); err != nil {
+				t.Fatalf("decoding body %q: %v", response.Body, err)
+			}
+			if !reflect.DeepEqual(body, tt.wantBody) {
+				t.Errorf("body = %v, want %v", body, tt.wantBody)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ResolveQueryParams makes repeated query parameters deterministic. API Gateway keeps an
+// arbitrary value of a repeated parameter in QueryStringParameters; this sets it to the
+// first value from MultiValueQueryStringParameters instead, or, with
+// STRICT_QUERY_PARAMS=true, returns an error naming the repeated parameter.
+func ResolveQueryParams(request *events.APIGatewayProxyRequest) error {
+	if len(request.MultiValueQueryStringParameters) == 0 {
+		return nil
+	}
+
+	strict := GetEnvBool("STRICT_QUERY_PARAMS", false)
+
+	names := make([]string, 0, len(request.MultiValueQueryStringParameters))
+	for name := range request.MultiValueQueryStringParameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	if request.QueryStringParameters == nil {
+		request.QueryStringParameters = make(map[string]string, len(names))
+	}
+	for _, name := range names {
+		values := request.MultiValueQueryStringParameters[name]
+		if len(values) == 0 {
+			continue
+		}
+		if len(values) > 1 && strict {
+			return fmt.Errorf("duplicate query parameter %q", name)
+		}
+		request.QueryStringParameters[name] = values[0]
+	}
+
+	return nil
+}
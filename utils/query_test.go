@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestResolveQueryParams(t *testing.T) {
+	tests := []struct {
+		name    string
+		strict  string
+		single  map[string]string
+		multi   map[string][]string
+		want    map[string]string
+		wantErr string
+	}{
+		{name: "no multi-value parameters", single: map[string]string{"limit": "20"}, want: map[string]string{"limit": "20"}},
+		{
+			name:   "single values",
+			single: map[string]string{"limit": "10", "sort": "name"},
+			multi:  map[string][]string{"limit": {"10"}, "sort": {"name"}},
+			want:   map[string]string{"limit": "10", "sort": "name"},
+		},
+		{
+			name:   "lenient keeps the first of a duplicate",
+			single: map[string]string{"limit": "20"},
+			multi:  map[string][]string{"limit": {"10", "20"}},
+			want:   map[string]string{"limit": "10"},
+		},
+		{
+			name:  "lenient without a single-value map",
+			multi: map[string][]string{"limit": {"10", "20"}, "sort": {"name"}},
+			want:  map[string]string{"limit": "10", "sort": "name"},
+		},
+		{
+			name:   "strict allows single values",
+			strict: "true",
+			multi:  map[string][]string{"limit": {"10"}},
+			want:   map[string]string{"limit": "10"},
+		},
+		{
+			name:    "strict rejects a duplicate",
+			strict:  "true",
+			single:  map[string]string{"limit": "20"},
+			multi:   map[string][]string{"limit": {"10", "20"}},
+			wantErr: `duplicate query parameter "limit"`,
+		},
+		{
+			name:    "strict names the first duplicate by name",
+			strict:  "true",
+			multi:   map[string][]string{"sort": {"name", "email"}, "limit": {"10", "20"}},
+			wantErr: `duplicate query parameter "limit"`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("STRICT_QUERY_PARAMS", tt.strict)
+
+			request := events.APIGatewayProxyRequest{
+				QueryStringParameters:           tt.single,
+				MultiValueQueryStringParameters: tt.multi,
+			}
+			err := ResolveQueryParams(&request)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("ResolveQueryParams() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveQueryParams() error = %v", err)
+			}
+			if !reflect.DeepEqual(request.QueryStringParameters, tt.want) {
+				t.Errorf("QueryStringParameters = %v, want %v", request.QueryStringParameters, tt.want)
+			}
+		})
+	}
+}
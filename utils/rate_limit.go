@@ -0,0 +1,153 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// DefaultTenant is the tenant of requests whose authorizer names none.
+const DefaultTenant = "default"
+
+// ErrRateLimited is returned by CheckRateLimit; it maps to 429 Too Many Requests.
+var ErrRateLimited = errors.New("rate limit exceeded")
+
+// TenantID returns the request's tenant: "tenant_id" from the API Gateway authorizer context
+// or its JWT claims, and otherwise DefaultTenant. Only the authorizer is trusted, so clients
+// cannot pick a tenant with a higher limit or spread requests over made-up tenants.
+func TenantID(request events.APIGatewayProxyRequest) string {
+	authorizer := request.RequestContext.Authorizer
+	if tenant, ok := authorizer["tenant_id"].(string); ok && tenant != "" {
+		return tenant
+	}
+	if claims, ok := authorizer["claims"].(map[string]interface{}); ok {
+		if tenant, ok := claims["tenant_id"].(string); ok && tenant != "" {
+			return tenant
+		}
+	}
+
+	return DefaultTenant
+}
+
+// TenantRateLimit returns tenant's limit in requests per minute, looked up on every call in
+// TENANT_RATE_LIMITS, e.g. "acme=600,globex=60", and otherwise RATE_LIMIT_PER_MINUTE. Zero
+// or less means unlimited, which is the default. Malformed entries are ignored.
+func TenantRateLimit(tenant string) int {
+	for _, entry := range strings.Split(os.Getenv("TENANT_RATE_LIMITS"), ",") {
+		name, value, ok := strings.Cut(entry, "=")
+		if !ok || strings.TrimSpace(name) != tenant {
+			continue
+		}
+		if limit, err := strconv.Atoi(strings.TrimSpace(value)); err == nil {
+			return limit
+		}
+	}
+
+	return GetEnvInt("RATE_LIMIT_PER_MINUTE", 0)
+}
+
+// tokenBucket holds the requests a tenant may still make, refilled continuously.
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// RateLimiter throttles each tenant independently with a token bucket that holds a minute's
+// worth of requests at the tenant's limit and refills at that limit. Buckets live in memory,
+// so each Lambda container or local server process enforces the limit on its own.
+type RateLimiter struct {
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+	swept   time.Time
+}
+
+// NewRateLimiter creates a RateLimiter with every tenant's bucket full.
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{buckets: make(map[string]*tokenBucket)}
+}
+
+// Allow takes one request from tenant's bucket for a limit of limit requests per minute.
+// When the bucket is empty it returns false and how long until a request is available. A
+// limit of zero or less always allows.
+func (l *RateLimiter) Allow(tenant string, limit int, now time.Time) (bool, time.Duration) {
+	if limit <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.evictIdle(now)
+
+	capacity := float64(limit)
+	perToken := max(time.Minute/time.Duration(limit), 1)
+
+	bucket, ok := l.buckets[tenant]
+	if !ok {
+		bucket = &tokenBucket{tokens: capacity, updated: now}
+		l.buckets[tenant] = bucket
+	}
+	if elapsed := now.Sub(bucket.updated); elapsed > 0 {
+		bucket.tokens += float64(elapsed) / float64(perToken)
+		bucket.updated = now
+	}
+	// The limit is looked up per request, so a lowered limit shrinks a full bucket
+	bucket.tokens = min(bucket.tokens, capacity)
+
+	if bucket.tokens < 1 {
+		return false, time.Duration(math.Ceil((1 - bucket.tokens) * float64(perToken)))
+	}
+	bucket.tokens--
+
+	return true, 0
+}
+
+// evictIdle forgets, at most once a minute, the buckets of tenants idle for a minute. Every
+// bucket refills completely within a minute, so a forgotten bucket is recreated as it was.
+func (l *RateLimiter) evictIdle(now time.Time) {
+	if now.Sub(l.swept) < time.Minute {
+		return
+	}
+	l.swept = now
+
+	for tenant, bucket := range l.buckets {
+		if now.Sub(bucket.updated) >= time.Minute {
+			delete(l.buckets, tenant)
+		}
+	}
+}
+
+var sharedRateLimiter = NewRateLimiter()
+
+// CheckRateLimit counts the request against its tenant's limit, returning ErrRateLimited
+// and how long the caller should wait once the limit is reached. Health checks are exempt.
+func CheckRateLimit(request events.APIGatewayProxyRequest) (time.Duration, error) {
+	if isHealthPath(request.Path) {
+		return 0, nil
+	}
+
+	tenant := TenantID(request)
+	if ok, retryAfter := sharedRateLimiter.Allow(tenant, TenantRateLimit(tenant), Now()); !ok {
+		return retryAfter, fmt.Errorf("%w for tenant %q", ErrRateLimited, tenant)
+	}
+
+	return 0, nil
+}
+
+// RateLimitedResponse returns the 429 for err, with Retry-After in whole seconds.
+func RateLimitedResponse(err error, retryAfter time.Duration) events.APIGatewayProxyResponse {
+	response, _ := ErrorResponse(http.StatusTooManyRequests, err)
+	EnsureHeaders(&response)
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	response.Headers["Retry-After"] = strconv.Itoa(max(seconds, 1))
+
+	return response
+}
@@ -0,0 +1,167 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestTenantID(t *testing.T) {
+	tests := []struct {
+		name       string
+		authorizer map[string]interface{}
+		headers    map[string]string
+		want       string
+	}{
+		{name: "authorizer context", authorizer: map[string]interface{}{"tenant_id": "acme"}, want: "acme"},
+		{
+			name:       "JWT claims",
+			authorizer: map[string]interface{}{"claims": map[string]interface{}{"tenant_id": "globex"}},
+			want:       "globex",
+		},
+		{name: "no tenant", want: DefaultTenant},
+		{name: "client header is not trusted", headers: map[string]string{"X-Tenant-ID": "acme"}, want: DefaultTenant},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := events.APIGatewayProxyRequest{Headers: tt.headers}
+			request.RequestContext.Authorizer = tt.authorizer
+
+			if got := TenantID(request); got != tt.want {
+				t.Errorf("TenantID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTenantRateLimit(t *testing.T) {
+	tests := []struct {
+		name         string
+		tenants      string
+		defaultLimit string
+		tenant       string
+		want         int
+	}{
+		{name: "configured tenant", tenants: "acme=600,globex=60", tenant: "globex", want: 60},
+		{name: "spaces around entries", tenants: " acme = 600 , globex=60", tenant: "acme", want: 600},
+		{name: "unconfigured tenant gets the default", tenants: "acme=600", defaultLimit: "30", tenant: "initech", want: 30},
+		{name: "malformed entry falls back", tenants: "acme=lots", defaultLimit: "30", tenant: "acme", want: 30},
+		{name: "unlimited by default", tenant: "acme", want: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TENANT_RATE_LIMITS", tt.tenants)
+			t.Setenv("RATE_LIMIT_PER_MINUTE", tt.defaultLimit)
+
+			if got := TenantRateLimit(tt.tenant); got != tt.want {
+				t.Errorf("TenantRateLimit(%q) = %d, want %d", tt.tenant, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	now := time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name           string
+		limit          int
+		requests       int
+		later          time.Duration
+		laterRequests  int
+		wantAllowed    int
+		wantRetryAfter time.Duration
+	}{
+		{name: "up to the limit", limit: 3, requests: 3, wantAllowed: 3},
+		{name: "over the limit", limit: 3, requests: 5, wantAllowed: 3, wantRetryAfter: 20 * time.Second},
+		{
+			name:           "refilled over time",
+			limit:          60,
+			requests:       60,
+			later:          time.Second,
+			laterRequests:  2,
+			wantAllowed:    61,
+			wantRetryAfter: time.Second,
+		},
+		{name: "unlimited", limit: 0, requests: 100, wantAllowed: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewRateLimiter()
+			allowed := 0
+			var retryAfter time.Duration
+			allow := func(at time.Time) {
+				if ok, wait := limiter.Allow("acme", tt.limit, at); ok {
+					allowed++
+				} else {
+					retryAfter = wait
+				}
+			}
+			for range tt.requests {
+				allow(now)
+			}
+			for range tt.laterRequests {
+				allow(now.Add(tt.later))
+			}
+
+			if allowed != tt.wantAllowed {
+				t.Errorf("allowed %d requests, want %d", allowed, tt.wantAllowed)
+			}
+			if retryAfter != tt.wantRetryAfter {
+				t.Errorf("retry after %v, want %v", retryAfter, tt.wantRetryAfter)
+			}
+		})
+	}
+}
+
+func TestCheckRateLimitPerTenant(t *testing.T) {
+	t.Setenv("TENANT_RATE_LIMITS", "acme=2,globex=4")
+	t.Setenv("RATE_LIMIT_PER_MINUTE", "")
+	saved := sharedRateLimiter
+	sharedRateLimiter = NewRateLimiter()
+	t.Cleanup(func() { sharedRateLimiter = saved })
+
+	request := func(tenant string) events.APIGatewayProxyRequest {
+		request := events.APIGatewayProxyRequest{Path: "/users"}
+		request.RequestContext.Authorizer = map[string]interface{}{"tenant_id": tenant}
+		return request
+	}
+
+	// Interleaved, so one tenant's requests cannot use up the other's limit
+	allowed := map[string]int{}
+	for range 6 {
+		for _, tenant := range []string{"acme", "globex"} {
+			_, err := CheckRateLimit(request(tenant))
+			switch {
+			case err == nil:
+				allowed[tenant]++
+			case !errors.Is(err, ErrRateLimited):
+				t.Fatalf("CheckRateLimit(%s) error = %v, want %v", tenant, err, ErrRateLimited)
+			}
+		}
+	}
+
+	tests := []struct {
+		tenant string
+		want   int
+	}{
+		{tenant: "acme", want: 2},
+		{tenant: "globex", want: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.tenant, func(t *testing.T) {
+			if allowed[tt.tenant] != tt.want {
+				t.Errorf("%s allowed %d of 6 requests, want %d", tt.tenant, allowed[tt.tenant], tt.want)
+			}
+		})
+	}
+
+	if _, err := CheckRateLimit(events.APIGatewayProxyRequest{Path: "/health"}); err != nil {
+		t.Errorf("health check error = %v, want none", err)
+	}
+}
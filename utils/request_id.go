@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"context"
+
+	"github.com/aws/aws-lambda-go/events"
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader returns the request's correlation ID to the client.
+const RequestIDHeader = "X-Request-Id"
+
+type requestIDKey struct{}
+
+// RequestID returns the API Gateway request ID of request, or a new UUID when there is none,
+// as with the local server.
+func RequestID(request events.APIGatewayProxyRequest) string {
+	if id := request.RequestContext.RequestID; id != "" {
+		return id
+	}
+
+	return uuid.NewString()
+}
+
+// ContextWithRequestID returns a copy of ctx carrying the request ID.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID in ctx, or "" if there is none.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+
+	return id
+}
+
+// AddRequestIDHeader sets X-Request-Id on response to the request ID in ctx.
+func AddRequestIDHeader(ctx context.Context, response *events.APIGatewayProxyResponse) {
+	id := RequestIDFromContext(ctx)
+	if id == "" {
+		return
+	}
+	if response.Headers == nil {
+		response.Headers = make(map[string]string)
+	}
+	response.Headers[RequestIDHeader] = id
+}
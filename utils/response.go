@@ -2,6 +2,7 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -52,7 +53,18 @@ func ErrorResponse(statusCode int, err error) (events.APIGatewayProxyResponse, e
 		errMessage = err.Error()
 	}
 
-	respBody, jsonErr := jsonMarshal(map[string]string{"error": errMessage})
+	body := map[string]string{"error": errMessage}
+
+	var fieldErr FieldError
+	if errors.As(err, &fieldErr) && fieldErr.FieldName() != "" {
+		body["field"] = fieldErr.FieldName()
+	}
+	var suggestionErr SuggestionError
+	if errors.As(err, &suggestionErr) && suggestionErr.SuggestedValue() != "" {
+		body["suggestion"] = suggestionErr.SuggestedValue()
+	}
+
+	respBody, jsonErr := jsonMarshal(body)
 	if jsonErr != nil {
 		// Fallback if marshaling error also fails
 		return events.APIGatewayProxyResponse{StatusCode: http.StatusInternalServerError, Body: "{\"error\": \"failed to marshal error response\"}"}, nil
@@ -65,6 +77,9 @@ func ErrorResponse(statusCode int, err error) (events.APIGatewayProxyResponse, e
 	}
 
 	EnsureHeaders(&response)
+	if problemErrorsEnabled() {
+		NegotiateErrorFormat(events.APIGatewayProxyRequest{}, &response)
+	}
 
 	return response, nil
 }
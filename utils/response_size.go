@@ -0,0 +1,42 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// DefaultMaxResponseBytes is the largest response body when MAX_RESPONSE_BYTES is unset,
+// below the 6 MB payload limit of Lambda behind API Gateway.
+const DefaultMaxResponseBytes = 5 << 20
+
+// ErrResponseTooLarge is returned by CheckResponseSize; it maps to 500 Internal Server Error.
+var ErrResponseTooLarge = errors.New("response too large")
+
+// CheckResponseSize rejects responses whose body is over MAX_RESPONSE_BYTES as sent, i.e.
+// measured after compression and base64 encoding.
+func CheckResponseSize(response events.APIGatewayProxyResponse) error {
+	maxBytes := GetEnvInt("MAX_RESPONSE_BYTES", DefaultMaxResponseBytes)
+	if size := len(response.Body); size > maxBytes {
+		return fmt.Errorf("%w: %d bytes, at most %d allowed; request less data, e.g. with ?limit=",
+			ErrResponseTooLarge, size, maxBytes)
+	}
+
+	return nil
+}
+
+// ResponseTooLargeResponse returns the 500 sent in place of response, keeping its CORS
+// headers so browsers can read the error.
+func ResponseTooLargeResponse(response events.APIGatewayProxyResponse, err error) events.APIGatewayProxyResponse {
+	replacement, _ := ErrorResponse(http.StatusInternalServerError, err)
+	for name, value := range response.Headers {
+		if strings.HasPrefix(strings.ToLower(name), "access-control-") {
+			replacement.Headers[name] = value
+		}
+	}
+
+	return replacement
+}
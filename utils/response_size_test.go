@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestCheckResponseSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		body     string
+		maxBytes string
+		wantErr  error
+	}{
+		{name: "empty"},
+		{name: "at the default limit", body: strings.Repeat("x", DefaultMaxResponseBytes)},
+		{name: "over the default limit", body: strings.Repeat("x", DefaultMaxResponseBytes+1), wantErr: ErrResponseTooLarge},
+		{name: "at a configured limit", body: "0123456789", maxBytes: "10"},
+		{name: "over a configured limit", body: "0123456789a", maxBytes: "10", wantErr: ErrResponseTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MAX_RESPONSE_BYTES", tt.maxBytes)
+
+			err := CheckResponseSize(events.APIGatewayProxyResponse{StatusCode: http.StatusOK, Body: tt.body})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckResponseSize() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResponseTooLargeResponse(t *testing.T) {
+	response := events.APIGatewayProxyResponse{
+		StatusCode: http.StatusOK,
+		Headers: map[string]string{
+			"Access-Control-Allow-Origin": "https://app.example.com",
+			"ETag":                        `"1-abc"`,
+		},
+		Body: "too large",
+	}
+
+	replacement := ResponseTooLargeResponse(response, ErrResponseTooLarge)
+	if replacement.StatusCode != http.StatusInternalServerError {
+		t.Errorf("status = %d, want %d", replacement.StatusCode, http.StatusInternalServerError)
+	}
+	if got := replacement.Headers["Access-Control-Allow-Origin"]; got != "https://app.example.com" {
+		t.Errorf("Access-Control-Allow-Origin = %q, want it kept", got)
+	}
+	if got, ok := replacement.Headers["ETag"]; ok {
+		t.Errorf("ETag = %q, want it dropped", got)
+	}
+	if !strings.Contains(replacement.Body, "response too large") {
+		t.Errorf("body = %s, want the error", replacement.Body)
+	}
+}
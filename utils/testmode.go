@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// TestModeEpoch is the fixed time returned by Now in test mode.
+var TestModeEpoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+var (
+	testModeSequence atomic.Uint64
+	testModeIgnored  sync.Once
+)
+
+// TestMode reports whether TEST_MODE=true enables deterministic IDs and timestamps.
+// It is always off inside a real Lambda environment, so a stray TEST_MODE in a
+// deployed function's configuration cannot affect production data.
+func TestMode() bool {
+	if !GetEnvBool("TEST_MODE", false) {
+		return false
+	}
+	if os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != "" {
+		testModeIgnored.Do(func() { log.Printf("Ignoring TEST_MODE inside Lambda") })
+		return false
+	}
+
+	return true
+}
+
+// NewID returns a new user ID: a random UUID, or in test mode a sequential one
+// (00000000-0000-0000-0000-000000000001, ...).
+func NewID() string {
+	if TestMode() {
+		return fmt.Sprintf("00000000-0000-0000-0000-%012d", testModeSequence.Add(1))
+	}
+
+	return uuid.New().String()
+}
+
+// Now returns the current time, or TestModeEpoch in test mode.
+func Now() time.Time {
+	if TestMode() {
+		return TestModeEpoch
+	}
+
+	return time.Now()
+}
+
+// ResetTestMode restarts the test mode ID sequence, e.g. between golden-file runs.
+func ResetTestMode() {
+	testModeSequence.Store(0)
+}
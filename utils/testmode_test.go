@@ -0,0 +1,50 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTestMode(t *testing.T) {
+	tests := []struct {
+		name         string
+		testMode     string
+		lambda       string
+		wantTestMode bool
+	}{
+		{name: "off by default"},
+		{name: "on", testMode: "true", wantTestMode: true},
+		{name: "ignored inside Lambda", testMode: "true", lambda: "users-api"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("TEST_MODE", tt.testMode)
+			t.Setenv("AWS_LAMBDA_FUNCTION_NAME", tt.lambda)
+			ResetTestMode()
+			t.Cleanup(ResetTestMode)
+
+			if got := TestMode(); got != tt.wantTestMode {
+				t.Fatalf("TestMode() = %v, want %v", got, tt.wantTestMode)
+			}
+
+			first, second := NewID(), NewID()
+			if tt.wantTestMode {
+				if first != "00000000-0000-0000-0000-000000000001" || second != "00000000-0000-0000-0000-000000000002" {
+					t.Errorf("NewID() = %q, %q; want sequential IDs", first, second)
+				}
+				if now := Now(); !now.Equal(TestModeEpoch) {
+					t.Errorf("Now() = %s, want %s", now, TestModeEpoch)
+				}
+				return
+			}
+
+			if first == second {
+				t.Errorf("NewID() returned %q twice", first)
+			}
+			if now := Now(); time.Since(now) > time.Minute {
+				t.Errorf("Now() = %s, want the current time", now)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package utils
+
+import (
+	"strings"
+	"time"
+)
+
+// DefaultRequestTimeout is used when neither a route, method nor REQUEST_TIMEOUT_MS timeout is set.
+// It matches the function timeout in serverless.yml.
+const DefaultRequestTimeout = 30 * time.Second
+
+// RouteTimeouts overrides the request timeout for individual routes, keyed by
+// "METHOD /path/pattern", e.g. "POST /admin/import": 2 * time.Minute.
+var RouteTimeouts = map[string]time.Duration{}
+
+// RequestTimeout returns the timeout for a request to the route pattern path. In order of
+// precedence it uses RouteTimeouts, <METHOD>_TIMEOUT_MS (e.g. GET_TIMEOUT_MS),
+// REQUEST_TIMEOUT_MS, then DefaultRequestTimeout.
+func RequestTimeout(method, path string) time.Duration {
+	if timeout, ok := RouteTimeouts[method+" "+path]; ok {
+		return timeout
+	}
+
+	fallback := GetEnvInt("REQUEST_TIMEOUT_MS", int(DefaultRequestTimeout/time.Millisecond))
+	ms := GetEnvInt(strings.ToUpper(method)+"_TIMEOUT_MS", fallback)
+
+	return time.Duration(ms) * time.Millisecond
+}
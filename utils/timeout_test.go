@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRequestTimeout(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		path          string
+		env           map[string]string
+		routeTimeouts map[string]time.Duration
+		want          time.Duration
+	}{
+		{name: "default", method: http.MethodGet, path: "/users", want: DefaultRequestTimeout},
+		{
+			name:   "global timeout",
+			method: http.MethodGet,
+			path:   "/users",
+			env:    map[string]string{"REQUEST_TIMEOUT_MS": "5000"},
+			want:   5 * time.Second,
+		},
+		{
+			name:   "GET timeout",
+			method: http.MethodGet,
+			path:   "/users",
+			env:    map[string]string{"REQUEST_TIMEOUT_MS": "5000", "GET_TIMEOUT_MS": "1000", "POST_TIMEOUT_MS": "20000"},
+			want:   time.Second,
+		},
+		{
+			name:   "POST timeout",
+			method: http.MethodPost,
+			path:   "/users",
+			env:    map[string]string{"REQUEST_TIMEOUT_MS": "5000", "GET_TIMEOUT_MS": "1000", "POST_TIMEOUT_MS": "20000"},
+			want:   20 * time.Second,
+		},
+		{
+			name:   "method without its own timeout",
+			method: http.MethodDelete,
+			path:   "/users/{id}",
+			env:    map[string]string{"REQUEST_TIMEOUT_MS": "5000", "GET_TIMEOUT_MS": "1000"},
+			want:   5 * time.Second,
+		},
+		{
+			name:          "route timeout overrides the method",
+			method:        http.MethodPost,
+			path:          "/users/batch",
+			env:           map[string]string{"POST_TIMEOUT_MS": "20000"},
+			routeTimeouts: map[string]time.Duration{"POST /users/batch": time.Minute},
+			want:          time.Minute,
+		},
+		{
+			name:          "route timeout is per method",
+			method:        http.MethodGet,
+			path:          "/users/batch",
+			env:           map[string]string{"GET_TIMEOUT_MS": "1000"},
+			routeTimeouts: map[string]time.Duration{"POST /users/batch": time.Minute},
+			want:          time.Second,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"REQUEST_TIMEOUT_MS", "GET_TIMEOUT_MS", "POST_TIMEOUT_MS", "DELETE_TIMEOUT_MS"} {
+				t.Setenv(name, tt.env[name])
+			}
+			saved := RouteTimeouts
+			RouteTimeouts = tt.routeTimeouts
+			t.Cleanup(func() { RouteTimeouts = saved })
+
+			if got := RequestTimeout(tt.method, tt.path); got != tt.want {
+				t.Errorf("RequestTimeout(%s, %s) = %s, want %s", tt.method, tt.path, got, tt.want)
+			}
+		})
+	}
+}
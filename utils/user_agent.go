@@ -0,0 +1,30 @@
+package utils
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// ErrUserAgentRequired is returned by CheckUserAgent; it maps to 400 Bad Request.
+var ErrUserAgentRequired = errors.New("a User-Agent header is required")
+
+// CheckUserAgent rejects requests without a non-empty User-Agent header when
+// REQUIRE_USER_AGENT is true. Health checks are exempt, since load balancer and
+// orchestrator probes often send no User-Agent.
+func CheckUserAgent(request events.APIGatewayProxyRequest) error {
+	if !GetEnvBool("REQUIRE_USER_AGENT", false) || isHealthPath(request.Path) {
+		return nil
+	}
+	if strings.TrimSpace(GetHeader(request, "User-Agent")) == "" {
+		return ErrUserAgentRequired
+	}
+
+	return nil
+}
+
+// isHealthPath reports whether path is /health or one of its sub-paths.
+func isHealthPath(path string) bool {
+	return path == "/health" || strings.HasPrefix(path, "/health/")
+}
@@ -0,0 +1,40 @@
+package utils
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestCheckUserAgent(t *testing.T) {
+	tests := []struct {
+		name      string
+		require   string
+		path      string
+		userAgent string
+		wantErr   error
+	}{
+		{name: "present", require: "true", path: "/users", userAgent: "curl/8.5.0"},
+		{name: "missing", require: "true", path: "/users", wantErr: ErrUserAgentRequired},
+		{name: "blank", require: "true", path: "/users", userAgent: "  ", wantErr: ErrUserAgentRequired},
+		{name: "health exempt", require: "true", path: "/health"},
+		{name: "health sub-path exempt", require: "true", path: "/health/ready"},
+		{name: "look-alike path not exempt", require: "true", path: "/healthz", wantErr: ErrUserAgentRequired},
+		{name: "missing when not required", path: "/users"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("REQUIRE_USER_AGENT", tt.require)
+
+			request := events.APIGatewayProxyRequest{Path: tt.path}
+			if tt.userAgent != "" {
+				request.Headers = map[string]string{"user-agent": tt.userAgent}
+			}
+			if err := CheckUserAgent(request); !errors.Is(err, tt.wantErr) {
+				t.Errorf("CheckUserAgent() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
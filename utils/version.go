@@ -0,0 +1,18 @@
+package utils
+
+import "github.com/aws/aws-lambda-go/events"
+
+// APIVersionHeader reports the version of the API that served a request.
+const APIVersionHeader = "X-API-Version"
+
+// Version is the build version, injected at build time with
+// -ldflags "-X go-lambda-api/utils.Version=1.2.3".
+var Version = "dev"
+
+// AddVersionHeader sets X-API-Version on response.
+func AddVersionHeader(response *events.APIGatewayProxyResponse) {
+	if response.Headers == nil {
+		response.Headers = make(map[string]string)
+	}
+	response.Headers[APIVersionHeader] = Version
+}
@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"encoding"
+	"encoding/xml"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+// XMLContentType is the media type of XML responses.
+const XMLContentType = "application/xml"
+
+// xmlRootElement names the root element of every XML response.
+const xmlRootElement = "response"
+
+// PrefersXML reports whether request's Accept header ranks application/xml or text/xml above
+// application/json and the wildcards that match it. Between equal quality values the type
+// listed first wins, so "Accept: application/xml, */*" selects XML.
+func PrefersXML(request events.APIGatewayProxyRequest) bool {
+	bestXML, bestJSON := 0.0, 0.0
+	firstXML, firstJSON := -1, -1
+	for i, accepted := range strings.Split(GetHeader(request, "Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(accepted))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil {
+			quality = q
+		}
+
+		switch mediaType {
+		case XMLContentType, "text/xml":
+			if quality > bestXML {
+				bestXML, firstXML = quality, i
+			}
+		case "application/json", "application/*", "*/*":
+			if quality > bestJSON {
+				bestJSON, firstJSON = quality, i
+			}
+		}
+	}
+
+	if bestXML == 0 {
+		return false
+	}
+
+	return bestXML > bestJSON || (bestXML == bestJSON && firstXML < firstJSON)
+}
+
+// APIResponseNegotiated is APIResponse for handlers that see the request: when the request
+// prefers XML, body is encoded with MarshalXML and served as application/xml instead.
+// Either way the response carries "Vary: Accept", so caches keep the formats apart.
+func APIResponseNegotiated(
+	request events.APIGatewayProxyRequest, statusCode int, body interface{},
+) (events.APIGatewayProxyResponse, error) {
+	if body == nil {
+		return APIResponse(statusCode, body)
+	}
+	if !PrefersXML(request) {
+		response, err := APIResponse(statusCode, body)
+		AddVary(&response, "Accept")
+
+		return response, err
+	}
+
+	respBody, err := MarshalXML(body)
+	if err != nil {
+		return ErrorResponse(http.StatusInternalServerError, fmt.Errorf("failed to marshal response body: %w", err))
+	}
+
+	response := events.APIGatewayProxyResponse{
+		StatusCode:        statusCode,
+		Headers:           map[string]string{"Content-Type": XMLContentType},
+		Body:              xml.Header + string(respBody),
+		MultiValueHeaders: map[string][]string{},
+	}
+	AddVary(&response, "Accept")
+
+	return response, nil
+}
+
+// MarshalXML encodes body as an XML document with a <response> root element. Struct fields
+// are named by their xml tags, falling back to their json tags, and honor omitempty from
+// either. Map entries become elements named by their keys, in key order, or <entry key="...">
+// when a key is not a valid element name. Slice elements become <item> elements.
+func MarshalXML(body interface{}) ([]byte, error) {
+	return xml.Marshal(xmlValue{value: body})
+}
+
+// xmlValue encodes any JSON-shaped value through encoding/xml, which cannot encode maps.
+type xmlValue struct {
+	value interface{}
+}
+
+// MarshalXML implements xml.Marshaler.
+func (v xmlValue) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start.Name = xml.Name{Local: xmlRootElement}
+
+	return encodeXMLValue(e, start, reflect.ValueOf(v.value))
+}
+
+var textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+
+// encodeXMLValue writes value as the element start, leaving it empty for nil.
+func encodeXMLValue(e *xml.Encoder, start xml.StartElement, value reflect.Value) error {
+	for value.Kind() == reflect.Interface || value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			return e.EncodeElement("", start)
+		}
+		value = value.Elem()
+	}
+	if !value.IsValid() {
+		return e.EncodeElement("", start)
+	}
+	// Timestamps encode as RFC 3339, as in JSON
+	if value.Type().Implements(textMarshalerType) {
+		return e.EncodeElement(value.Interface(), start)
+	}
+
+	switch value.Kind() {
+	case reflect.Struct:
+		return encodeXMLElement(e, start, func() error {
+			return encodeXMLFields(e, value)
+		})
+	case reflect.Map:
+		return encodeXMLElement(e, start, func() error {
+			return encodeXMLEntries(e, value)
+		})
+	case reflect.Slice, reflect.Array:
+		return encodeXMLElement(e, start, func() error {
+			for i := 0; i < value.Len(); i++ {
+				item := xml.StartElement{Name: xml.Name{Local: "item"}}
+				if err := encodeXMLValue(e, item, value.Index(i)); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+	case reflect.Float32, reflect.Float64:
+		// Decoded JSON numbers are float64; keep whole numbers out of exponent notation
+		return e.EncodeElement(strconv.FormatFloat(value.Float(), 'f', -1, 64), start)
+	default:
+		return e.EncodeElement(value.Interface(), start)
+	}
+}
+
+// encodeXMLElement writes start, the children written by encodeChildren, and the end tag.
+func encodeXMLElement(e *xml.Encoder, start xml.StartElement, encodeChildren func() error) error {
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := encodeChildren(); err != nil {
+		return err
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+// encodeXMLFields writes the exported fields of the struct value.
+func encodeXMLFields(e *xml.Encoder, value reflect.Value) error {
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, options, _ := strings.Cut(field.Tag.Get("xml"), ",")
+		if name == "" {
+			name, options, _ = strings.Cut(field.Tag.Get("json"), ",")
+		}
+		if name == "-" || (strings.Contains(options, "omitempty") && value.Field(i).IsZero()) {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		if err := encodeXMLValue(e, xml.StartElement{Name: xml.Name{Local: name}}, value.Field(i)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encodeXMLEntries writes the entries of the map value in key order.
+func encodeXMLEntries(e *xml.Encoder, value reflect.Value) error {
+	keys := make([]string, 0, value.Len())
+	values := make(map[string]reflect.Value, value.Len())
+	for _, key := range value.MapKeys() {
+		name := fmt.Sprint(key.Interface())
+		keys = append(keys, name)
+		values[name] = value.MapIndex(key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		start := xml.StartElement{Name: xml.Name{Local: key}}
+		if !isXMLName(key) {
+			start = xml.StartElement{
+				Name: xml.Name{Local: "entry"},
+				Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: key}},
+			}
+		}
+		if err := encodeXMLValue(e, start, values[key]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isXMLName reports whether name can be used as an element name as is: a letter or
+// underscore followed by letters, digits, '_', '-' or '.'.
+func isXMLName(name string) bool {
+	for i, r := range name {
+		if unicode.IsLetter(r) || r == '_' {
+			continue
+		}
+		if i == 0 || (!unicode.IsDigit(r) && r != '-' && r != '.') {
+			return false
+		}
+	}
+
+	return name != ""
+}
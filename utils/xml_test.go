@@ -0,0 +1,123 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-lambda-go/events"
+)
+
+func TestPrefersXML(t *testing.T) {
+	tests := []struct {
+		name   string
+		accept string
+		want   bool
+	}{
+		{name: "no Accept", want: false},
+		{name: "JSON", accept: "application/json", want: false},
+		{name: "any", accept: "*/*", want: false},
+		{name: "XML", accept: "application/xml", want: true},
+		{name: "text/xml", accept: "text/xml", want: true},
+		{name: "XML listed first", accept: "application/xml, */*", want: true},
+		{name: "JSON listed first", accept: "application/json, application/xml", want: false},
+		{name: "XML ranked higher", accept: "application/json;q=0.5, application/xml", want: true},
+		{name: "XML ranked lower", accept: "application/xml;q=0.5, */*", want: false},
+		{name: "XML refused", accept: "application/xml;q=0", want: false},
+		{name: "malformed entries skipped", accept: ";;, application/xml", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := events.APIGatewayProxyRequest{Headers: map[string]string{"Accept": tt.accept}}
+			if got := PrefersXML(request); got != tt.want {
+				t.Errorf("PrefersXML(%q) = %t, want %t", tt.accept, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIResponseNegotiated(t *testing.T) {
+	type user struct {
+		ID        string            `json:"id"`
+		Name      string            `json:"name" xml:"full_name"`
+		Nickname  string            `json:"nickname,omitempty"`
+		Secret    string            `json:"-"`
+		Metadata  map[string]string `json:"metadata,omitempty"`
+		CreatedAt time.Time         `json:"created_at"`
+	}
+	body := user{
+		ID:        "user-1",
+		Name:      "Ann & Bob",
+		Secret:    "hidden",
+		Metadata:  map[string]string{"team": "a", "2fa": "on"},
+		CreatedAt: time.Date(2026, 10, 15, 12, 0, 0, 0, time.UTC),
+	}
+
+	tests := []struct {
+		name            string
+		accept          string
+		body            interface{}
+		wantContentType string
+		wantBody        string
+	}{
+		{
+			name:            "JSON by default",
+			body:            map[string]int{"count": 2},
+			wantContentType: "application/json",
+			wantBody:        `{"count":2}`,
+		},
+		{
+			name:            "JSON when preferred",
+			accept:          "application/json, application/xml",
+			body:            map[string]int{"count": 2},
+			wantContentType: "application/json",
+			wantBody:        `{"count":2}`,
+		},
+		{
+			name:            "XML struct",
+			accept:          "application/xml",
+			body:            body,
+			wantContentType: XMLContentType,
+			wantBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" + `<response><id>user-1</id>` +
+				`<full_name>Ann &amp; Bob</full_name><metadata><entry key="2fa">on</entry><team>a</team></metadata>` +
+				`<created_at>2026-10-15T12:00:00Z</created_at></response>`,
+		},
+		{
+			name:            "XML list",
+			accept:          "application/xml",
+			body:            []interface{}{1.0, 2.5e7, nil},
+			wantContentType: XMLContentType,
+			wantBody: `<?xml version="1.0" encoding="UTF-8"?>` + "\n" +
+				`<response><item>1</item><item>25000000</item><item></item></response>`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := events.APIGatewayProxyRequest{Headers: map[string]string{"Accept": tt.accept}}
+			response, err := APIResponseNegotiated(request, http.StatusOK, tt.body)
+			if err != nil {
+				t.Fatalf("APIResponseNegotiated() error = %v", err)
+			}
+
+			if got := response.Headers["Content-Type"]; !strings.HasPrefix(got, tt.wantContentType) {
+				t.Errorf("Content-Type = %q, want %q", got, tt.wantContentType)
+			}
+			if got := response.Headers["Vary"]; !strings.Contains(got, "Accept") {
+				t.Errorf("Vary = %q, want Accept", got)
+			}
+			if tt.wantContentType == "application/json" {
+				if !json.Valid([]byte(response.Body)) || strings.TrimSpace(response.Body) != tt.wantBody {
+					t.Errorf("body = %s, want %s", response.Body, tt.wantBody)
+				}
+				return
+			}
+			if response.Body != tt.wantBody {
+				t.Errorf("body = %s\nwant %s", response.Body, tt.wantBody)
+			}
+		})
+	}
+}